@@ -3,17 +3,18 @@ module dbtool
 go 1.21
 
 require (
+	github.com/alexbrainman/odbc v0.0.0-20240810052813-bcbcb6842ce9
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/microsoft/go-mssqldb v1.7.2
 	github.com/sijms/go-ora/v2 v2.8.10
+	golang.org/x/crypto v0.18.0
+	golang.org/x/text v0.14.0
 )
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/golang-sql/civil v0.0.0-20220223132316-b832511892a9 // indirect
 	github.com/golang-sql/sqlexp v0.1.0 // indirect
-	golang.org/x/crypto v0.18.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 )
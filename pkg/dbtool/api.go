@@ -0,0 +1,245 @@
+// Package dbtool 跨数据库复制表数据，是命令行 dbtool 的全部实现，也可被其它程序直接引用。
+//
+// 嵌入使用时用 Config 描述源库、目标库与表清单（与 -config 的 JSON 相同），Open 连接两端并解析表清单，
+// 之后用 Run 同步整个表清单，或用 CopyTable 只同步其中一张表：
+//
+//	cfg, err := dbtool.LoadConfig("config.json")
+//	if err != nil {
+//		return err
+//	}
+//	s, err := dbtool.Open(ctx, cfg, dbtool.Options{StatePath: "state.json"})
+//	if err != nil {
+//		return err
+//	}
+//	defer s.Close()
+//	res, err := s.Run(ctx)
+//
+// 导出的函数只返回错误，不会结束进程；Main 是命令行入口，出错时以非 0 退出码结束进程。
+package dbtool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config 同步配置，结构与 -config 的 JSON 配置文件相同
+type Config = toolConfig
+
+// DBConfig 一个数据源的连接配置（driver、dsn 及会话选项）
+type DBConfig = dbConfig
+
+// SyncConfig sync：使用 Config.Sources 中的哪两个数据源
+type SyncConfig = syncConfig
+
+// TableListConfig table_list：表清单与表级配置的默认值
+type TableListConfig = tableListConfig
+
+// TableSpec 单张表的同步配置
+type TableSpec = configTable
+
+// ColumnMapping 列映射（源列 -> 目标列，类型覆盖等）
+type ColumnMapping = columnMapping
+
+// LoadConfig 读取并解析 JSON 配置文件
+func LoadConfig(path string) (*Config, error) {
+	return loadConfig(path)
+}
+
+// Logger 日志接收者，*log.Logger 满足该接口
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// SetLogLevel 设置日志级别（进程级，所有 Syncer 共用）：debug、info（默认）、warn、error
+func SetLogLevel(level string) error {
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	setLogLevel(l)
+	return nil
+}
+
+// Options 运行参数，与命令行的同名参数含义相同
+type Options struct {
+	DryRun            bool   // -dry-run：只读取源表，不写入目标库
+	DryRunSamples     int    // -dry-run-samples：Dry-Run 时每张表打印的示例行数
+	Limit             int64  // -limit：每张表最多复制的行数（表级 limit 优先），0 表示不限制
+	StatePath         string // -state：增量水位状态文件，为空表示不记录（state_backend=target 时忽略）
+	ResetState        bool   // -reset-state：忽略已记录的水位
+	AllowSameDatabase bool   // -allow-same-database：不检查源表与目标表是否为同一张表
+	LockName          string // -lock-name：目标库锁名，覆盖配置中的 lock_name；Open 时获取，Close 时释放
+
+	// Logger 该 Syncer 的日志（按日志级别过滤，连接密码已隐藏，末尾不带换行）交给它，不写标准错误与 -log-file；
+	// 为 nil 时与命令行一样输出。同一进程中的多个 Syncer 可分别设置
+	Logger Logger
+
+	Callbacks Callbacks // 进度回调，见 Callbacks 的说明
+}
+
+// TableResult 一张表的同步结果
+type TableResult struct {
+	Table       string        // 源表名（分区表为父表名）
+	TargetTable string        // 目标表名
+	PartitionOf string        // 非空表示这是该分区表的一个分区单元（expand_partitions），核对结果在父表汇总
+	RowsCopied  int64         // 已写入（dry-run 时为已读取）的行数；失败时为失败前已提交的行数
+	SourceCount int64         // 源表（窗口）记录数，-1 表示未统计
+	TargetCount int64         // 目标表（窗口）记录数，-1 表示未统计
+	HasDiff     bool          // 源表与目标表记录数不一致
+	Duration    time.Duration // 该表的耗时
+	Err         error         // 非空表示该表失败
+}
+
+// RunResult 一轮同步的结果
+type RunResult struct {
+	RunID      string        // 本轮的标识，与 history_table 的 run_id 一致
+	Tables     []TableResult // 按完成顺序；某张表失败时本轮停止，失败的表为最后一项
+	RowsCopied int64         // 各表 RowsCopied 之和
+	Duration   time.Duration // 本轮耗时
+}
+
+// Failed 返回失败的表
+func (r *RunResult) Failed() []TableResult {
+	var out []TableResult
+	for _, t := range r.Tables {
+		if t.Err != nil {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Syncer 持有源库、目标库连接与解析后的表清单，可多次调用 Run、CopyTable（增量水位按 Options.StatePath
+// 或 state_backend 延续）。同一个 Syncer 上的调用依次执行，并发调用时排队
+type Syncer struct {
+	mu     sync.Mutex
+	runner *syncRunner
+	lock   *dbLock
+}
+
+// Open 连接源库与目标库并解析表清单（from_source 时从源库拉取）。配置中的 history_table、notifications、
+// lock_name 与命令行模式一样生效；schedule 被忽略，由调用方决定何时调用 Run
+func Open(ctx context.Context, cfg *Config, opts Options) (*Syncer, error) {
+	if cfg == nil {
+		return nil, errors.New("配置不能为空")
+	}
+	registerConfigSecrets(cfg)
+	r, err := openSyncRunner(ctx, cfg, runOptions{
+		LockName:   opts.LockName,
+		AllowSame:  opts.AllowSameDatabase,
+		DryRun:     opts.DryRun,
+		Samples:    opts.DryRunSamples,
+		Limit:      opts.Limit,
+		StatePath:  opts.StatePath,
+		ResetState: opts.ResetState,
+		RunOnce:    true,
+		Log:        newOutputLogger(opts.Logger),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &Syncer{runner: r}
+	if name := firstNonEmpty(strings.TrimSpace(opts.LockName), strings.TrimSpace(cfg.LockName)); name != "" {
+		if s.lock, err = acquireTargetLock(r.targetCfg, name); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	if n, err := newNotifier(cfg.Notifications); err != nil {
+		s.Close()
+		return nil, err
+	} else if n != nil {
+		n.log = r.log
+		r.observers = append(r.observers, n)
+	}
+	r.history = newHistoryRecorder(r)
+	if !opts.Callbacks.empty() {
+		r.listeners = append(r.listeners, opts.Callbacks)
+	}
+	return s, nil
+}
+
+// Close 释放目标库锁并关闭连接
+func (s *Syncer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lock.release()
+	s.lock = nil
+	s.runner.Close()
+	return nil
+}
+
+// Tables 返回解析后的表清单（from_source 拉取、依赖排序、分区拆分之后）
+func (s *Syncer) Tables() []TableSpec {
+	return append([]TableSpec(nil), s.runner.tables...)
+}
+
+// Run 按表清单同步一轮。某张表失败时本轮停止，返回已完成的表与该表的结果以及错误；
+// ctx 取消时正在执行的数据库操作随之中止
+func (s *Syncer) Run(ctx context.Context) (*RunResult, error) {
+	return s.run(ctx, nil)
+}
+
+// CopyTable 只同步表清单中的一张表（按源表、目标表或分区父表名匹配，不区分大小写）。
+// 同一源表配置了多个目标表时依次复制，返回最后一个的结果
+func (s *Syncer) CopyTable(ctx context.Context, table string) (*TableResult, error) {
+	filter, err := s.runner.tableFilter([]string{table})
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.run(ctx, filter)
+	if len(res.Tables) == 0 {
+		if err == nil {
+			err = fmt.Errorf("表 %s 没有同步结果", table)
+		}
+		return nil, err
+	}
+	t := res.Tables[len(res.Tables)-1]
+	return &t, err
+}
+
+// run 检查连接后按表过滤同步一轮，收集各表结果
+func (s *Syncer) run(ctx context.Context, filter map[string]bool) (*RunResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.runner
+	res := &RunResult{RunID: newRunID()}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	release, err := r.ensureConnected(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer release()
+	c := &resultCollector{res: res}
+	_, err = r.runPass(passOptions{ctx: ctx, stop: ctx, tables: filter, listeners: []copyListener{c}, runID: res.RunID})
+	if errors.Is(err, errSyncStopped) && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return res, err
+}
+
+// resultCollector 把一轮同步的表级事件汇总为 RunResult
+type resultCollector struct {
+	res *RunResult
+}
+
+func (c *resultCollector) tableStart(table string, estimatedRows int64) {}
+func (c *resultCollector) batchCommitted(table string, rowsSoFar int64) {}
+func (c *resultCollector) rowError(table string, row RowRef, err error) {}
+
+// tableDone 实现 copyListener：成功的分区单元不单独记录，由父表的汇总结果记录
+func (c *resultCollector) tableDone(result TableResult, detail *tableVerificationResult) {
+	if result.PartitionOf != "" && result.Err == nil {
+		return
+	}
+	c.res.Tables = append(c.res.Tables, result)
+	c.res.RowsCopied += result.RowsCopied
+}
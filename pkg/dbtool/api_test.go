@@ -0,0 +1,120 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordLogger 记录收到的日志
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+// contains 是否有包含 s 的日志
+func (l *recordLogger) contains(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.Contains(line, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSyncerLoggersAreSeparate(t *testing.T) {
+	ctx := context.Background()
+	tables := []string{"orders_a", "orders_b"}
+	loggers := make([]*recordLogger, len(tables))
+	syncers := make([]*Syncer, len(tables))
+	for i, table := range tables {
+		dir := t.TempDir()
+		srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+		ddl := "CREATE TABLE " + table + " (id INTEGER PRIMARY KEY, name TEXT)"
+		openTestSQLite(t, srcPath, ddl, "INSERT INTO "+table+" (id, name) VALUES (1, 'x'), (2, 'y')")
+		openTestSQLite(t, dstPath, ddl)
+		cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "`+table+`"}]`)
+		loggers[i] = &recordLogger{}
+		s, err := Open(ctx, cfg, Options{Logger: loggers[i]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		syncers[i] = s
+	}
+
+	// 两个 Syncer 同时运行，日志分别交给各自的 Logger
+	var wg sync.WaitGroup
+	errs := make([]error, len(syncers))
+	for i, s := range syncers {
+		wg.Add(1)
+		go func(i int, s *Syncer) {
+			defer wg.Done()
+			_, errs[i] = s.Run(ctx)
+		}(i, s)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("%s: 同步失败: %v", tables[i], err)
+		}
+	}
+	for i, table := range tables {
+		other := tables[1-i]
+		if !loggers[i].contains("连接源数据库") || !loggers[i].contains("["+table+"]") {
+			t.Errorf("%s 的 Logger 缺少连接或表级日志: %q", table, loggers[i].lines)
+		}
+		if loggers[i].contains(other) {
+			t.Errorf("%s 的 Logger 收到了 %s 的日志", table, other)
+		}
+		for _, line := range loggers[i].lines {
+			if strings.HasSuffix(line, "\n") {
+				t.Errorf("日志末尾带换行: %q", line)
+			}
+		}
+	}
+}
+
+func TestOnRowErrorReportsKey(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	openTestSQLite(t, srcPath, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)", "INSERT INTO t VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+	openTestSQLite(t, dstPath, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT UNIQUE)", "INSERT INTO t VALUES (10, 'b')")
+	cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "t", "atomic": true, "skip_bad_rows": true}]`)
+	var rows []RowRef
+	var done []TableResult
+	s, err := Open(ctx, cfg, Options{Logger: &recordLogger{}, Callbacks: Callbacks{
+		OnRowError:  func(table string, row RowRef, err error) { rows = append(rows, row) },
+		OnTableDone: func(r TableResult) { done = append(done, r) },
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 违反唯一约束的第 2 行被跳过，以序号与主键值报告，表继续复制并成功结束
+	if len(rows) != 1 || rows[0].Ordinal != 2 || len(rows[0].Key) != 1 || rows[0].Key[0].Column != "id" || fmt.Sprint(rows[0].Key[0].Value) != "2" {
+		t.Fatalf("OnRowError: %+v", rows)
+	}
+	if rows[0].String() != "第 2 行（id=2）" {
+		t.Errorf("行标识 %q", rows[0].String())
+	}
+	if len(done) != 1 || done[0].Err != nil || done[0].RowsCopied != 2 {
+		t.Fatalf("OnTableDone: %+v", done)
+	}
+}
@@ -0,0 +1,47 @@
+package dbtool
+
+import "fmt"
+
+// atomic（表级）：整表在一个目标库事务中写入，中途不提交，全部写完后提交一次；任何错误都回滚整个事务，
+// 目标表保持复制前的状态（自动建表创建的空表保留）。COPY / LOAD DATA 本来就是整表一个事务，
+// INSERT 方式不再按 batch_size / batch_bytes / max_memory 分批提交，也不缓存本批用于重放：
+// 连接断开、序列化冲突、批次过大等错误直接失败（重放只能恢复本批，无法恢复整个事务）。
+// 与按区间断点续跑（chunk_by）、按批限时（batch_timeout）互斥；chunk_by 的每个区间按同样的方式写入（ChunkLabel 非空）
+
+// atomicWarnRows atomic 表的预计行数超过该值时提醒事务过大
+const atomicWarnRows = 1000000
+
+// validateAtomic 检查 atomic 与其它表配置的组合
+func validateAtomic(opts copyTableOptions) error {
+	if !opts.Atomic {
+		if opts.SkipBadRows {
+			return fmt.Errorf("skip_bad_rows 通过整表事务中的保存点跳过失败的行，目前只支持与 atomic 一起配置")
+		}
+		return nil
+	}
+	if opts.ChunkBy != nil {
+		return fmt.Errorf("atomic 要求整表一个事务，不能与按区间提交、失败后从中断区间继续的 chunk_by 同时配置")
+	}
+	if opts.BatchTimeout != "" {
+		return fmt.Errorf("atomic 不分批提交，不能配置 batch_timeout")
+	}
+	return nil
+}
+
+// logAtomic 说明 atomic 写入方式；预计行数超过 atomicWarnRows 时提醒目标库 undo/redo（WAL）增长
+func logAtomic(opts copyTableOptions, plannedRows int64) {
+	if !opts.Atomic || opts.DryRun {
+		return
+	}
+	advice := "改为分批提交"
+	if opts.ChunkLabel != "" {
+		opts.Log.infof("区间 %s 在一个事务中写入，全部写完后提交，出错时回滚，重跑时从该区间继续\n", opts.ChunkLabel)
+		advice = "减小 chunk_by.step"
+	} else {
+		opts.Log.infof("atomic：整表在一个事务中写入，全部写完后提交，出错时回滚，目标表不留部分数据\n")
+	}
+	if plannedRows > atomicWarnRows {
+		opts.Log.warnf("警告：表 %s 预计写入 %d 行（超过 %d 行）且只在最后提交一次，目标库的 undo/redo（WAL）与锁会随事务持续增长，请确认目标库容量或%s\n",
+			opts.Table, plannedRows, atomicWarnRows, advice)
+	}
+}
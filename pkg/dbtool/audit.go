@@ -0,0 +1,117 @@
+package dbtool
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// audit_column 的取值方式
+const (
+	auditValueRunStart = "run_start" // 本轮同步的开始时间（默认），同一轮写入的行相同
+	auditValueNow      = "now"       // 每行写入时的当前时间
+)
+
+// auditColumnConfig audit_column：每行追加一个写入时间列，供下游判断行何时落地
+type auditColumnConfig struct {
+	Name  string `json:"name"`            // 目标列名，如 synced_at
+	Value string `json:"value,omitempty"` // run_start（默认）/ now
+}
+
+// auditColumn 返回表使用的审计列：表级 audit_column 优先（name 为空表示关闭），否则取 table_list 级默认值
+func auditColumn(cfg *toolConfig, t configTable) *auditColumnConfig {
+	if t.AuditColumn != nil {
+		if strings.TrimSpace(t.AuditColumn.Name) == "" {
+			return nil
+		}
+		return t.AuditColumn
+	}
+	if cfg.TableList != nil && cfg.TableList.AuditColumn != nil && strings.TrimSpace(cfg.TableList.AuditColumn.Name) != "" {
+		return cfg.TableList.AuditColumn
+	}
+	return nil
+}
+
+// appendedColumns 追加在插入列最后的附加列（不来自源表）：audit_column、provenance 的来源列
+func appendedColumns(opts copyTableOptions) []string {
+	var out []string
+	if opts.AuditColumn != "" {
+		out = append(out, opts.AuditColumn)
+	}
+	for _, kind := range opts.Provenance {
+		out = append(out, provenanceColumnName(kind))
+	}
+	return out
+}
+
+// appendedValue 返回附加列在本行的值；col 不是附加列时 ok 为 false
+func appendedValue(opts copyTableOptions, col string) (v interface{}, ok bool) {
+	if opts.AuditColumn != "" && col == opts.AuditColumn {
+		return auditValue(opts), true
+	}
+	for _, kind := range opts.Provenance {
+		if col == provenanceColumnName(kind) {
+			return provenanceValue(opts, kind), true
+		}
+	}
+	return nil, false
+}
+
+// validateAppendedColumns 检查附加列的配置，以及列名不与写入目标库的列（映射后的列名）、其它附加列同名
+func validateAppendedColumns(opts copyTableOptions, sourceCols []string) error {
+	if opts.AuditColumn != "" {
+		switch opts.AuditValue {
+		case auditValueRunStart, auditValueNow:
+		default:
+			return fmt.Errorf("audit_column.value 无效: %q（可选 run_start、now）", opts.AuditValue)
+		}
+	}
+	if err := validateProvenance(opts); err != nil {
+		return err
+	}
+	seen := make(map[string]string)
+	for _, c := range mappedInsertColumns(sourceCols, opts) {
+		seen[strings.ToLower(c)] = "源表写入目标库的列 " + c
+	}
+	for _, c := range appendedColumns(opts) {
+		if what, ok := seen[strings.ToLower(c)]; ok {
+			return fmt.Errorf("附加列 %s 与%s 同名，请换一个列名，或在 columns 中把该列改名或 skip", c, what)
+		}
+		seen[strings.ToLower(c)] = "附加列 " + c
+	}
+	return nil
+}
+
+// appendedColumnsDDL 自动建表时附加列的定义
+func appendedColumnsDDL(opts copyTableOptions, driver string) []string {
+	var out []string
+	if opts.AuditColumn != "" {
+		out = append(out, quoteIdent(opts.AuditColumn, driver)+" "+auditColumnType(driver))
+	}
+	for _, kind := range opts.Provenance {
+		out = append(out, quoteIdent(provenanceColumnName(kind), driver)+" "+provenanceColumnType(driver))
+	}
+	return out
+}
+
+// auditValue 返回本行审计列的值
+func auditValue(opts copyTableOptions) interface{} {
+	if opts.AuditValue == auditValueNow || opts.AuditRunStart.IsZero() {
+		return time.Now()
+	}
+	return opts.AuditRunStart
+}
+
+// auditColumnType 自动建表时审计列的类型
+func auditColumnType(driver string) string {
+	switch normalizeDriver(driver) {
+	case "mysql":
+		return "DATETIME(6)"
+	case "sqlserver":
+		return "DATETIME2"
+	case "sqlite3":
+		return "DATETIME"
+	default:
+		return "TIMESTAMP"
+	}
+}
@@ -0,0 +1,618 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// 逐批 INSERT 写入的批次控制：缓存本批已写入的行直到提交成功，失败后在新事务中重放：
+// - 连接断开（目标库故障切换等）：重新连接后只重放未提交的本批，继续复制；提交时断开则无法确认该批是否已提交，
+//   不重放，记录为核对提示（见 copyHints）
+// - batch_timeout：每批从开启事务到提交须在该时长内完成，超时即回滚（事务使用带超时的 context），
+//   记录目标表上持有锁的其它会话，然后重试本批；同时设置服务端的语句超时（Postgres statement_timeout、
+//   MySQL innodb_lock_wait_timeout、SQL Server LOCK_TIMEOUT），数据库一侧也会释放资源
+// - CockroachDB 的事务序列化冲突（40001），见 cockroach.go
+// - 批次过大（MySQL max_allowed_packet、参数个数上限等）：本批对半拆开分别提交，仍失败时继续拆分直到单行，
+//   并记住缩小后的每批行数，该表之后的批次都按此提交
+
+const (
+	// batchTimeoutRetries 一批超时后的最多重试次数
+	batchTimeoutRetries = 2
+	// batchReconnectRetries 一批因连接断开重放的最多次数
+	batchReconnectRetries = 3
+	// batchReconnectAttempts 每次重新连接的最多尝试次数（间隔从 1s 起逐次翻倍，最多 30s）
+	batchReconnectAttempts = 6
+)
+
+// errBatchTimeout 一批写入超过 batch_timeout
+type errBatchTimeout struct {
+	batch   int
+	timeout time.Duration
+	err     error
+}
+
+func (e *errBatchTimeout) Error() string {
+	return fmt.Sprintf("第 %d 批写入超过 batch_timeout=%s: %v", e.batch, e.timeout, e.err)
+}
+
+func (e *errBatchTimeout) Unwrap() error { return e.err }
+
+// parseBatchTimeout 解析 batch_timeout，为空时返回 0（不限时）
+func parseBatchTimeout(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("batch_timeout 无效: %q（如 30s、5m）", s)
+	}
+	return d, nil
+}
+
+// batchReplay 逐批写入的当前批次：事务的 context 与已写入、未提交的行；nil 表示不缓存也不重试（Dry-Run、atomic）
+type batchReplay struct {
+	rows    [][]interface{}
+	log     *tableLogger
+	dst     *simpleDB
+	session *writeSession
+	table   string
+	timeout time.Duration // batch_timeout，0 表示不限时
+	hints   *copyHints
+	sizing  *batchSizing
+	memory  *memoryBudget
+	lobs    *lobWriter
+
+	batch      int   // 当前批次序号（从 1 开始）
+	committed  int64 // 已提交的行数
+	autocommit bool  // write_mode_tx=none：不使用事务，本批的行执行成功即已写入
+	maxBytes   int64 // batch_bytes，0 表示只按行数
+	bytes      int64 // 本批缓存的行的估算字节数
+	batches    int   // 已提交的批次数
+	written    int64 // 已提交的行的估算字节数
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+// newBatchReplay 实际写入时返回 batchReplay，Dry-Run 与 atomic（整表一个事务，无法只重放本批）时返回 nil
+func newBatchReplay(dst *simpleDB, session *writeSession, table string, opts copyTableOptions, timeout time.Duration) *batchReplay {
+	if opts.DryRun || opts.Atomic {
+		return nil
+	}
+	sizing := opts.BatchSizing
+	if sizing == nil {
+		sizing = &batchSizing{}
+	}
+	return &batchReplay{log: opts.Log, dst: dst, session: session, table: table, timeout: timeout, hints: opts.Hints, sizing: sizing, maxBytes: opts.BatchBytes, memory: opts.Memory,
+		lobs: opts.Lobs, autocommit: autocommitWrites(opts)}
+}
+
+// begin 开启下一批的事务
+func (b *batchReplay) begin(ctx context.Context, w dbExecutor) (batchTx, error) {
+	if b == nil {
+		tx, err := w.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	b.batch++
+	return b.beginTx(ctx)
+}
+
+// beginTx 为当前批次开启事务：配置了 batch_timeout 时事务使用带超时的 context（超时后 database/sql 自动回滚），
+// 并设置服务端语句超时；write_mode_tx=none 时不开启事务（batch_timeout 只通过 context 限时）
+func (b *batchReplay) beginTx(ctx context.Context) (batchTx, error) {
+	b.close()
+	b.ctx, b.cancel = ctx, nil
+	if b.timeout > 0 {
+		b.ctx, b.cancel = context.WithTimeout(ctx, b.timeout)
+	}
+	if b.autocommit {
+		return autocommitTx{w: b.session.executor()}, nil
+	}
+	tx, err := b.session.executor().BeginTx(b.ctx, nil)
+	if err != nil {
+		return nil, b.wrap(err)
+	}
+	if err := setStatementTimeout(b.ctx, tx, b.dst, b.timeout); err != nil {
+		_ = tx.Rollback()
+		return nil, b.wrap(fmt.Errorf("设置服务端语句超时失败: %w", err))
+	}
+	return tx, nil
+}
+
+// execContext 本批语句使用的 context
+func (b *batchReplay) execContext(ctx context.Context) context.Context {
+	if b == nil || b.ctx == nil {
+		return ctx
+	}
+	return b.ctx
+}
+
+// wrap 本批超时导致的错误包装为 errBatchTimeout（外层 ctx 取消时原样返回）
+func (b *batchReplay) wrap(err error) error {
+	if err == nil || b == nil || b.timeout <= 0 || b.ctx == nil {
+		return err
+	}
+	if errors.Is(b.ctx.Err(), context.DeadlineExceeded) {
+		return &errBatchTimeout{batch: b.batch, timeout: b.timeout, err: err}
+	}
+	return err
+}
+
+// close 释放本批的 context
+func (b *batchReplay) close() {
+	if b != nil && b.cancel != nil {
+		b.cancel()
+		b.cancel = nil
+	}
+}
+
+// finish 表复制结束（成功或失败）时释放本批的 context 与内存预算中的占用
+func (b *batchReplay) finish() {
+	if b != nil {
+		b.close()
+		b.memory.release(b.bytes)
+		b.bytes = 0
+	}
+}
+
+// add 记录本批写入的一行；配置了 max_memory 且其它表占满预算时等待释放
+func (b *batchReplay) add(ctx context.Context, args []interface{}) error {
+	if b == nil {
+		return nil
+	}
+	n := rowSize(args)
+	if err := b.memory.acquire(ctx, n, b.bytes); err != nil {
+		return err
+	}
+	b.rows = append(b.rows, append([]interface{}(nil), args...))
+	b.bytes += n
+	return nil
+}
+
+// reset 本批已提交
+func (b *batchReplay) reset() {
+	if b != nil && len(b.rows) > 0 {
+		b.batches++
+		b.committed += int64(len(b.rows))
+		b.written += b.bytes
+		b.memory.release(b.bytes)
+		b.rows, b.bytes = b.rows[:0], 0
+	}
+}
+
+// retry cause 为连接断开、本批超时或序列化冲突时回滚 tx（连接断开时先重新连接），在新事务中重放本批的行；
+// commit 为 true 表示 cause 发生在提交时，重放后随后提交（final 非空时提交前执行）。
+// 返回之后使用的事务（未提交时继续写入）；cause 不可重试或重试次数用尽时返回最后的错误
+func (b *batchReplay) retry(ctx context.Context, tx batchTx, insertSQL string, cause error, commit bool, final func(batchTx) error) (batchTx, error) {
+	if b == nil {
+		return tx, cause
+	}
+	err := b.wrap(cause)
+	atCommit := commit
+	for attempt := 1; ; attempt++ {
+		var timeout *errBatchTimeout
+		switch {
+		case errors.As(err, &timeout) && attempt <= batchTimeoutRetries:
+			b.log.warnf("警告：%v%s；回滚并第 %d 次重试本批 %d 行\n", err, describeBlockers(b.dst, b.table), attempt, len(b.rows))
+		case b.dst.cockroach && isSerializationFailure(err) && attempt <= cockroachMaxRetries:
+			b.log.warnf("警告：事务序列化冲突（40001），第 %d 次重试本批 %d 行: %v\n", attempt, len(b.rows), err)
+		case isConnectionError(err) && atCommit:
+			return b.commitUnknown(ctx, tx, err)
+		case isConnectionError(err) && attempt <= batchReconnectRetries:
+			b.log.warnf("警告：写入第 %d 批时目标库连接断开（%v），重新连接后重放本批 %d 行\n", b.batch, err, len(b.rows))
+		case isBatchSizeError(err) && len(b.rows) > 1 && !b.autocommit:
+			return b.shrink(ctx, tx, insertSQL, err, commit, final)
+		default:
+			return tx, err
+		}
+		_ = tx.Rollback()
+		if isConnectionError(err) {
+			if errConn := b.reconnect(ctx); errConn != nil {
+				return tx, fmt.Errorf("%w（重新连接失败: %v）", err, errConn)
+			}
+		} else {
+			select {
+			case <-ctx.Done():
+				return tx, ctx.Err()
+			case <-time.After(time.Duration(attempt*attempt) * 100 * time.Millisecond):
+			}
+		}
+		next, errBegin := b.beginTx(ctx)
+		if errBegin != nil {
+			if errors.As(errBegin, &timeout) || isConnectionError(errBegin) {
+				err, atCommit = errBegin, false
+				continue
+			}
+			return tx, fmt.Errorf("开启新事务失败: %w", errBegin)
+		}
+		tx, atCommit = next, false
+		if err = b.wrap(b.exec(tx, insertSQL)); err != nil {
+			continue
+		}
+		if final != nil {
+			if err = b.wrap(final(tx)); err != nil {
+				continue
+			}
+		}
+		if commit {
+			atCommit = true
+			err = b.wrap(tx.Commit())
+		}
+		if err == nil {
+			return tx, nil
+		}
+	}
+}
+
+// shrink cause 为批次过大：回滚后按缩小的行数拆开提交本批（见 writeSplit）。
+// commit 为 true 时本批的行保留到 reset 时计入已提交，否则开启新事务继续写入
+func (b *batchReplay) shrink(ctx context.Context, tx batchTx, insertSQL string, cause error, commit bool, final func(batchTx) error) (batchTx, error) {
+	_ = tx.Rollback()
+	rows := b.rows
+	b.reduce(len(rows), cause)
+	if err := b.writeSplit(ctx, insertSQL, rows, final); err != nil {
+		return tx, err
+	}
+	if commit {
+		return tx, nil
+	}
+	b.reset()
+	next, err := b.beginTx(ctx)
+	if err != nil {
+		return tx, fmt.Errorf("开启新事务失败: %w", err)
+	}
+	return next, nil
+}
+
+// reduce 把每批行数降为 n 行的一半（至少 1 行）；该表第一次缩小时打印警告
+func (b *batchReplay) reduce(n int, cause error) {
+	size := max(n/2, 1)
+	if b.sizing.size == 0 {
+		b.log.warnf("警告：第 %d 批 %d 行超出目标库限制（%v），拆分重试，该表之后每批 %d 行\n", b.batch, n, cause, size)
+	} else {
+		b.log.debugf("第 %d 批拆分后仍超出目标库限制，每批行数降为 %d\n", b.batch, size)
+	}
+	if b.sizing.size == 0 || size < b.sizing.size {
+		b.sizing.size = size
+	}
+}
+
+// writeSplit 按当前的每批行数把 rows 分成多个事务提交；某一部分仍因批次过大失败时对半拆分递归重试，
+// 单行仍失败时返回错误。final 在最后一个事务提交前执行
+func (b *batchReplay) writeSplit(ctx context.Context, insertSQL string, rows [][]interface{}, final func(batchTx) error) error {
+	for start := 0; start < len(rows); {
+		end := min(start+b.sizing.size, len(rows))
+		part := rows[start:end]
+		var fin func(batchTx) error
+		if end == len(rows) {
+			fin = final
+		}
+		if err := b.writePart(ctx, insertSQL, part, fin); err != nil {
+			if !isBatchSizeError(err) || len(part) == 1 {
+				return err
+			}
+			b.reduce(len(part), err)
+			if err := b.writeSplit(ctx, insertSQL, part, fin); err != nil {
+				return err
+			}
+		}
+		start = end
+	}
+	return nil
+}
+
+// writePart 在一个事务中写入并提交 rows
+func (b *batchReplay) writePart(ctx context.Context, insertSQL string, rows [][]interface{}, final func(batchTx) error) error {
+	tx, err := b.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	err = b.execRows(tx, insertSQL, rows)
+	if err == nil && final != nil {
+		err = final(tx)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return b.wrap(err)
+	}
+	return nil
+}
+
+// limit 每批行数：自动缩小后为缩小的行数，否则为 batchSize
+func (b *batchReplay) limit(batchSize int) int {
+	if b == nil || b.sizing.size == 0 {
+		return batchSize
+	}
+	return b.sizing.size
+}
+
+// due 本批是否应提交：行数达到每批行数，或配置了 batch_bytes 且缓存的行达到该字节数，
+// 或缓存中的行达到 max_memory（nil 时只按 batchCount）
+func (b *batchReplay) due(batchCount, batchSize int) bool {
+	if batchCount >= b.limit(batchSize) {
+		return true
+	}
+	return b != nil && (b.maxBytes > 0 && b.bytes >= b.maxBytes || b.memory.exceeded())
+}
+
+// pending 本批尚未提交的行数（nil 时为 batchCount）
+func (b *batchReplay) pending(batchCount int) int {
+	if b == nil {
+		return batchCount
+	}
+	return len(b.rows)
+}
+
+// logBatchSize 在表的汇总中打印批次大小：配置了 batch_bytes 时打印平均每批的行数与字节数，
+// 每批行数自动缩小过时打印实际使用的行数
+func (b *batchReplay) logBatchSize(opts copyTableOptions) {
+	if b == nil {
+		return
+	}
+	if b.maxBytes > 0 && b.batches > 0 {
+		opts.Log.infof("平均每批: %d 行，约 %d 字节（batch_size=%d，batch_bytes=%d）\n",
+			b.committed/int64(b.batches), b.written/int64(b.batches), opts.BatchSize, b.maxBytes)
+	}
+	if b.sizing.size == 0 {
+		return
+	}
+	opts.Log.infof("实际每批行数: %d（batch_size=%d 超出目标库限制，已自动缩小，建议调整配置）\n", b.sizing.size, opts.BatchSize)
+}
+
+// commitUnknown 提交时连接断开：无法确认该批是否已提交，重放可能造成重复、不重放可能缺失，
+// 因此不重放，记录为核对提示后重新连接，继续下一批
+func (b *batchReplay) commitUnknown(ctx context.Context, tx batchTx, err error) (batchTx, error) {
+	hint := fmt.Sprintf("第 %d 批（第 %d–%d 行）提交时目标库连接断开，无法确认是否已提交，请核对该批数据是否缺失",
+		b.batch, b.committed+1, b.committed+int64(len(b.rows)))
+	b.log.warnf("警告：%s: %v\n", hint, err)
+	b.hints.add(hint)
+	_ = tx.Rollback()
+	if errConn := b.reconnect(ctx); errConn != nil {
+		return tx, fmt.Errorf("%w（重新连接失败: %v）", err, errConn)
+	}
+	return tx, nil
+}
+
+// reconnect 等待目标库恢复：连接池中的坏连接由 database/sql 丢弃，这里按退避 ping 直到成功；
+// 写入固定在专用连接上时换一个连接，在该连接上 ping 并重新设置会话参数
+func (b *batchReplay) reconnect(ctx context.Context) error {
+	wait := time.Second
+	var err error
+	for attempt := 1; attempt <= batchReconnectAttempts; attempt++ {
+		if b.session.pinned() {
+			// 专用连接占用了连接池中的连接（连接池可能只有一个连接），通过新的专用连接 ping，不从连接池另取
+			err = b.session.reconnect(ctx, b.dst.pingTimeout)
+		} else {
+			pingCtx, cancel := context.WithTimeout(ctx, b.dst.pingTimeout)
+			err = b.dst.db.PingContext(pingCtx)
+			cancel()
+		}
+		if err == nil {
+			b.log.infof("目标库已重新连接（第 %d 次尝试）\n", attempt)
+			return nil
+		}
+		if attempt == batchReconnectAttempts {
+			break
+		}
+		b.log.warnf("警告：目标库重新连接失败（第 %d 次），%s 后重试: %v\n", attempt, wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxConnectRetryInterval)
+	}
+	return err
+}
+
+// isBatchSizeError 判断错误是否因批次过大（缩小批次后可能成功）：MySQL 数据包超过 max_allowed_packet、
+// 事务超过 max_binlog_cache_size，参数个数超过上限（Postgres 65535、SQL Server 2100），Oracle 绑定变量过多、undo 空间不足
+func isBatchSizeError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, mysql.ErrPktTooLarge) {
+		return true
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		// 1153 ER_NET_PACKET_TOO_LARGE、1197 ER_TRANS_CACHE_FULL、1301 ER_WARN_ALLOWED_PACKET_OVERFLOWED
+		return myErr.Number == 1153 || myErr.Number == 1197 || myErr.Number == 1301
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"max_allowed_packet", "packet for query is too large", "max_binlog_cache_size",
+		"65535 parameters", "too many parameters", "too many bind", "ora-01745", "ora-30036"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// brokenSide 连接断开导致的错误说明是哪一端中断（source 为 true 时为读取端，否则为写入端），用于错误信息；其它错误返回空串
+func brokenSide(err error, source bool) string {
+	if !isConnectionError(err) {
+		return ""
+	}
+	if source {
+		return "（读取端：源库连接断开）"
+	}
+	return "（写入端：目标库连接断开）"
+}
+
+// isConnectionError 判断错误是否为连接断开（连接被重置、对端关闭、数据库关闭或故障切换中）
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	// io.EOF 不单独判断：读取源表、文件等正常结束也返回它；驱动在连接被对端关闭时已转换为 driver.ErrBadConn
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// 08 connection_exception；57P01 admin_shutdown、57P02 crash_shutdown、57P03 cannot_connect_now
+		return pqErr.Code.Class() == "08" || pqErr.Code == "57P01" || pqErr.Code == "57P02" || pqErr.Code == "57P03"
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"bad connection", "broken pipe", "connection reset", "server closed the connection", "connection refused"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// exec 在 tx 中重放本批的行；write_mode_tx=none 时之前的行已经写入，只重放失败的最后一行
+func (b *batchReplay) exec(tx batchTx, insertSQL string) error {
+	if b.autocommit && len(b.rows) > 0 {
+		return b.execRows(tx, insertSQL, b.rows[len(b.rows)-1:])
+	}
+	return b.execRows(tx, insertSQL, b.rows)
+}
+
+// execRows 在 tx 中写入 rows
+func (b *batchReplay) execRows(tx batchTx, insertSQL string, rows [][]interface{}) error {
+	for _, args := range rows {
+		if err := b.lobs.exec(b.ctx, tx, insertSQL, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setStatementTimeout 在本批的事务中设置服务端语句超时（d 为 0 或方言不支持时不设置）；
+// 只有 Postgres 的 SET LOCAL 随事务结束，MySQL、SQL Server 的会话级超时见 lockTimeoutSetting
+func setStatementTimeout(ctx context.Context, tx batchTx, dst *simpleDB, d time.Duration) error {
+	if d <= 0 || !isPostgresDriver(normalizeDriver(dst.cfg.Driver)) {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", d.Milliseconds()))
+	return err
+}
+
+// lockTimeoutSetting batch_timeout 对应的会话级锁等待超时（MySQL innodb_lock_wait_timeout，SQL Server LOCK_TIMEOUT）。
+// 会话级参数会留在归还连接池的连接上，因此在写入会话的专用连接上设置一次，表结束时恢复为原值；其它方言返回 nil
+func lockTimeoutSetting(driver string, d time.Duration) *sessionSetting {
+	switch normalizeDriver(driver) {
+	case "mysql":
+		// MAX_EXECUTION_TIME 只对 SELECT 生效，写入时等待行锁由 innodb_lock_wait_timeout 控制（单位秒）
+		return &sessionSetting{
+			set:     fmt.Sprintf("SET SESSION innodb_lock_wait_timeout = %d", int64(math.Max(1, math.Ceil(d.Seconds())))),
+			current: "SELECT @@SESSION.innodb_lock_wait_timeout",
+			restore: "SET SESSION innodb_lock_wait_timeout = %d",
+		}
+	case "sqlserver":
+		return &sessionSetting{
+			set:     fmt.Sprintf("SET LOCK_TIMEOUT %d", d.Milliseconds()),
+			current: "SELECT @@LOCK_TIMEOUT",
+			restore: "SET LOCK_TIMEOUT %d",
+		}
+	}
+	return nil
+}
+
+// describeBlockers 超时后查询目标表上持有锁（或有未结束事务）的其它会话，返回用于日志的说明；查不到时返回空串
+func describeBlockers(dst *simpleDB, table string) string {
+	driver := normalizeDriver(dst.cfg.Driver)
+	var query string
+	var labels []string
+	var args []interface{}
+	switch {
+	case isPostgresDriver(driver) && !dst.cockroach:
+		query = `SELECT DISTINCT a.pid, a.state, a.wait_event_type, left(a.query, 200)
+FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE l.relation = to_regclass($1) AND l.granted AND a.pid <> pg_backend_pid()`
+		labels = []string{"pid", "state", "wait", "query"}
+		args = []interface{}{table}
+	case driver == "mysql":
+		query = `SELECT trx_mysql_thread_id, trx_state, trx_started, LEFT(trx_query, 200)
+FROM information_schema.innodb_trx WHERE trx_mysql_thread_id <> CONNECTION_ID()`
+		labels = []string{"thread", "state", "started", "query"}
+	case driver == "sqlserver":
+		query = `SELECT DISTINCT l.request_session_id, l.request_mode, l.request_status, s.status
+FROM sys.dm_tran_locks l JOIN sys.dm_exec_sessions s ON s.session_id = l.request_session_id
+WHERE l.resource_associated_entity_id = OBJECT_ID(@p1) AND l.request_session_id <> @@SPID`
+		labels = []string{"session", "mode", "request", "status"}
+		args = []interface{}{table}
+	default:
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rows, err := dst.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		dst.cfg.log.debugf("查询目标表上的锁失败: %v\n", err)
+		return ""
+	}
+	defer rows.Close()
+	var sessions []string
+	for rows.Next() && len(sessions) < 5 {
+		vals := make([]sql.NullString, len(labels))
+		ptrs := make([]interface{}, len(labels))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if rows.Scan(ptrs...) != nil {
+			return ""
+		}
+		var parts []string
+		for i, v := range vals {
+			if v.Valid && v.String != "" {
+				parts = append(parts, labels[i]+"="+strings.Join(strings.Fields(v.String), " "))
+			}
+		}
+		sessions = append(sessions, strings.Join(parts, " "))
+	}
+	if len(sessions) == 0 {
+		return ""
+	}
+	return "；目标表上的其它会话: " + strings.Join(sessions, "；")
+}
+
+// copyHints 复制过程中需要在核对结果中提示的情况（如提交结果未知的批次），nil 时忽略
+type copyHints struct {
+	list []string
+}
+
+func (h *copyHints) add(hint string) {
+	if h != nil {
+		h.list = append(h.list, hint)
+	}
+}
+
+// items 全部提示（nil 时为空）
+func (h *copyHints) items() []string {
+	if h == nil {
+		return nil
+	}
+	return h.list
+}
+
+// batchSizing 一张表自动缩小后的每批行数（按区间复制时各区间共用）
+type batchSizing struct {
+	size int // 0 表示未缩小，按 batch_size
+}
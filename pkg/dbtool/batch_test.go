@@ -0,0 +1,186 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestIsConnectionError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{io.EOF, false},
+		{fmt.Errorf("读取源表失败: %w", io.EOF), false},
+		{errors.New("some other error"), false},
+		{driver.ErrBadConn, true},
+		{fmt.Errorf("插入目标库失败: %w", driver.ErrBadConn), true},
+		{mysql.ErrInvalidConn, true},
+		{io.ErrUnexpectedEOF, true},
+		{errors.New("write: broken pipe"), true},
+	}
+	for _, c := range cases {
+		if got := isConnectionError(c.err); got != c.want {
+			t.Errorf("%v: %v，期望 %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestLockTimeoutSetting(t *testing.T) {
+	cases := []struct {
+		driver string
+		set    string
+	}{
+		{"mysql", "SET SESSION innodb_lock_wait_timeout = 2"},
+		{"sqlserver", "SET LOCK_TIMEOUT 1500"},
+		{"postgres", ""}, // SET LOCAL 随事务结束，每批设置
+		{"sqlite3", ""},
+	}
+	for _, c := range cases {
+		st := lockTimeoutSetting(c.driver, 1500*time.Millisecond)
+		if c.set == "" {
+			if st != nil {
+				t.Errorf("%s: 不应设置会话级超时: %s", c.driver, st.set)
+			}
+			continue
+		}
+		if st == nil || st.set != c.set || st.current == "" {
+			t.Errorf("%s: %+v，期望 %s 并记录原值", c.driver, st, c.set)
+		}
+	}
+}
+
+func TestWriteSessionRestoresOriginalValue(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "dst.db"))
+	// 只有一个连接，归还后再取到的是同一个连接
+	db.SetMaxOpenConns(1)
+	busyTimeout := func() int64 {
+		var v int64
+		if err := db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	orig := busyTimeout()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := sessionSetting{set: "PRAGMA busy_timeout = 1234", current: "PRAGMA busy_timeout", restore: "PRAGMA busy_timeout = %d"}
+	restore, err := st.apply(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("PRAGMA busy_timeout = %d", orig); restore != want {
+		t.Fatalf("恢复语句 %q，期望 %q", restore, want)
+	}
+	s := &writeSession{db: db, conn: conn, settings: []sessionSetting{st}, restore: []string{restore}}
+	s.Close()
+	if got := busyTimeout(); got != orig {
+		t.Fatalf("表结束后连接上的值为 %d，期望恢复为 %d", got, orig)
+	}
+}
+
+func TestWriteSessionDiscardsUnrestoredConn(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "dst.db"))
+	db.SetMaxOpenConns(1)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA busy_timeout = 1234"); err != nil {
+		t.Fatal(err)
+	}
+	// 恢复语句执行失败，连接上仍是修改后的值
+	s := &writeSession{db: db, conn: conn, restore: []string{"PRAGMA no_such_pragma = ("}}
+	s.Close()
+	if n := db.Stats().OpenConnections; n != 0 {
+		t.Fatalf("未恢复的连接应被丢弃，连接池中仍有 %d 个连接", n)
+	}
+	var v int64
+	if err := db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v == 1234 {
+		t.Fatal("之后的查询借到了未恢复参数的连接")
+	}
+}
+
+func TestMySQLCheckSettingsRestoreOriginalValue(t *testing.T) {
+	settings, err := sessionSettings(dbConfig{Driver: "mysql", DisableFKChecks: true, DisableUniqueChecks: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 2 {
+		t.Fatalf("%+v", settings)
+	}
+	for _, st := range settings {
+		// 按会话原值恢复，而不是固定恢复为 1
+		if st.current == "" || !strings.Contains(st.restore, "%d") {
+			t.Errorf("%s: 恢复语句 %q 未使用原值", st.set, st.restore)
+		}
+	}
+}
+
+func TestSessionReplicationRoleExclusive(t *testing.T) {
+	cases := []struct {
+		cfg dbConfig
+		ok  bool
+	}{
+		{dbConfig{Driver: "postgres", SessionReplicationRole: "replica"}, true},
+		{dbConfig{Driver: "postgres", SessionReplicationRole: "origin"}, false},
+		{dbConfig{Driver: "postgres", SessionReplicationRole: "replica", DisableFKChecks: true}, false},
+		{dbConfig{Driver: "postgres", SessionReplicationRole: "replica", SQLiteFastLoad: true}, false},
+		{dbConfig{Driver: "mysql", DisableFKChecks: true, DisableUniqueChecks: true}, true},
+	}
+	for _, c := range cases {
+		if err := checkSessionOptions(c.cfg); (err == nil) != c.ok {
+			t.Errorf("%+v: %v", c.cfg, err)
+		}
+	}
+}
+
+func TestReconnectPingsThroughPinnedConn(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "dst.db"))
+	// sqlite_fast_load 时连接池只有一个连接，被专用连接占用
+	db.SetMaxOpenConns(1)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := sessionSetting{set: "PRAGMA busy_timeout = 1234", current: "PRAGMA busy_timeout", restore: "PRAGMA busy_timeout = %d"}
+	restore, err := st.apply(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &writeSession{db: db, conn: conn, settings: []sessionSetting{st}, restore: []string{restore}}
+	defer s.Close()
+	b := &batchReplay{dst: &simpleDB{db: db, pingTimeout: 200 * time.Millisecond}, session: s, log: newTableLogger("t")}
+	if err := b.reconnect(ctx); err != nil {
+		t.Fatalf("重新连接失败: %v", err)
+	}
+	if s.conn == conn {
+		t.Fatal("应换用新的专用连接")
+	}
+	var v int64
+	if err := s.conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v != 1234 {
+		t.Fatalf("新连接上的 busy_timeout 为 %d，期望重新设置为 1234", v)
+	}
+}
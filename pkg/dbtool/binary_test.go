@@ -0,0 +1,178 @@
+package dbtool
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestConfig 写出 sqlite3 源库到 sqlite3 目标库的配置文件并加载
+func writeTestConfig(t *testing.T, srcPath, dstPath, tables string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	text := `{"source": {"driver": "sqlite3", "dsn": "` + srcPath + `"},
+ "target": {"driver": "sqlite3", "dsn": "` + dstPath + `"},
+ "tables": ` + tables + `}`
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	return cfg
+}
+
+// openTestSQLite 打开（创建）sqlite 数据库并执行 stmts
+func openTestSQLite(t *testing.T, path string, stmts ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	return db
+}
+
+// randomBytes 生成含 NUL 字节的随机二进制值
+func randomBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	r.Read(b)
+	for i := 0; i < n; i += 97 {
+		b[i] = 0
+	}
+	return b
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(834))
+	values := map[int64][]byte{
+		1: randomBytes(r, 1024),
+		2: {0, 0, 0, 0},
+		3: {0, 'a', 0, 'b', 0xff, 0xfe, 0},
+		4: {},
+		5: nil,
+		6: randomBytes(r, 1<<20+12345), // 超过 1 MB
+		7: []byte("\x00text-like\x00"),
+	}
+
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	src := openTestSQLite(t, srcPath, "CREATE TABLE blobs (id INTEGER PRIMARY KEY, data BLOB)")
+	for id, v := range values {
+		var arg interface{}
+		if v != nil {
+			arg = v
+		}
+		if _, err := src.Exec("INSERT INTO blobs (id, data) VALUES (?, ?)", id, arg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "blobs", "auto_create": true, "batch_size": 3}]`)
+	ctx := context.Background()
+	s, err := Open(ctx, cfg, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	res, err := s.Run(ctx)
+	if err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	if res.RowsCopied != int64(len(values)) {
+		t.Fatalf("复制行数 %d，期望 %d", res.RowsCopied, len(values))
+	}
+
+	dst := openTestSQLite(t, dstPath)
+	rows, err := dst.Query("SELECT id, data, typeof(data) FROM blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	seen := 0
+	for rows.Next() {
+		var id int64
+		var got []byte
+		var typ string
+		if err := rows.Scan(&id, &got, &typ); err != nil {
+			t.Fatal(err)
+		}
+		seen++
+		want := values[id]
+		switch {
+		case want == nil:
+			if typ != "null" {
+				t.Errorf("id=%d 应为 NULL，实际类型 %s", id, typ)
+			}
+		case typ != "blob":
+			t.Errorf("id=%d 以 %s 写入，期望 blob", id, typ)
+		case !bytes.Equal(got, want):
+			t.Errorf("id=%d 的值不一致: 长度 %d，期望 %d", id, len(got), len(want))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if seen != len(values) {
+		t.Fatalf("目标表 %d 行，期望 %d", seen, len(values))
+	}
+}
+
+func TestConvertRowKeepsBinaryBytes(t *testing.T) {
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "src.db"),
+		"CREATE TABLE b (data BLOB, note TEXT)",
+		"INSERT INTO b VALUES (x'00', 'x')")
+	rows, err := db.Query("SELECT data, note FROM b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	colTypes, err := rows.ColumnTypes()
+	rows.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	payloads := [][]byte{randomBytes(r, 4096), randomBytes(r, 1<<20+1), {0}, []byte("a\x00b")}
+	for _, dst := range []string{"postgres", "mysql", "sqlserver", "oracle", "sqlite3"} {
+		conv, err := newValueConverter(colTypes, []string{"data", "note"}, []string{"data", "note"}, "sqlite3", dst,
+			copyTableOptions{Table: "b", AutoCreate: true, Log: newTableLogger("b")})
+		if err != nil {
+			t.Fatalf("%s: %v", dst, err)
+		}
+		if !conv.isBinary(0) {
+			t.Fatalf("%s: BLOB 列未识别为二进制", dst)
+		}
+		for _, p := range payloads {
+			args := []interface{}{append([]byte(nil), p...), "note"}
+			if err := conv.convertRow(args); err != nil {
+				t.Fatalf("%s: %v", dst, err)
+			}
+			got, ok := args[0].([]byte)
+			if !ok {
+				t.Fatalf("%s: 二进制值转换为 %T，期望 []byte", dst, args[0])
+			}
+			if !bytes.Equal(got, p) {
+				t.Fatalf("%s: 长度 %d 的二进制值被改写", dst, len(p))
+			}
+		}
+		// 驱动以 string 返回的二进制值也按 []byte 绑定
+		args := []interface{}{"\x00\x01", nil}
+		if err := conv.convertRow(args); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := args[0].([]byte); !ok || !bytes.Equal(got, []byte{0, 1}) {
+			t.Fatalf("%s: string 形式的二进制值转换为 %#v", dst, args[0])
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package dbtool
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Callbacks 嵌入方的进度回调（Options.Callbacks），各字段可为 nil。
+//
+// 回调在执行复制的 goroutine 中同步调用，耗时直接计入复制耗时，不应阻塞。dbtool 按表清单的顺序逐张复制
+// （chunk_by 的区间也依次复制）；expand_partitions 的分区单元可能并行复制，但回调逐个调用，因此同一个 Syncer 的
+// 回调不会并发调用；不同 Syncer 的回调可能并发。同一张表的事件按 OnTableStart、OnBatchCommitted / OnRowError、
+// OnTableDone 的顺序到达，并行复制的分区单元之间的事件可能交错。
+//
+// expand_partitions 时每个分区单元各有一组事件（table 为单元名，TableResult.PartitionOf 为父表），
+// 全部单元完成后父表的汇总核对另以一次 OnTableDone 报告，之前没有对应的 OnTableStart
+type Callbacks struct {
+	// OnTableStart 开始复制一张表；estimatedRows 为计划复制的行数（源表窗口记录数），
+	// 未统计（skip_source_count、统计失败）或 chunk_by 按区间分别统计时为 -1
+	OnTableStart func(table string, estimatedRows int64)
+	// OnBatchCommitted 每提交一批后调用，rowsSoFar 为该表已写入的行数。
+	// Dry-Run 时每读取 batch_size 行报告一次；Postgres COPY、MySQL LOAD DATA 在一个事务中写入，
+	// 每写入 10000 行报告一次（此时尚未提交），提交后再报告一次
+	OnBatchCommitted func(table string, rowsSoFar int64)
+	// OnTableDone 一张表完成或失败（result.Err 非空）
+	OnTableDone func(result TableResult)
+	// OnRowError 行级错误（值转换、写入失败）：row 为该行在本次读取中的序号及目标表主键列的值。
+	// 通常该表随即失败，随后调用 OnTableDone（Err 非空）；atomic + skip_bad_rows 时写入失败的行被跳过，
+	// 该表继续复制，同一张表可能多次调用
+	OnRowError func(table string, row RowRef, err error)
+}
+
+// RowRef 行级错误中出错行的标识
+type RowRef struct {
+	// Ordinal 该行在本次读取中的序号（从 1 开始，chunk_by 时为区间内的序号）
+	Ordinal int64
+	// Key 目标表主键列及该行的值（按主键列顺序）；目标表无主键或读取主键失败时为空
+	Key []KeyValue
+}
+
+// KeyValue 一个键列的列名与值（写入目标库前的值，[]byte 已复制）
+type KeyValue struct {
+	Column string
+	Value  interface{}
+}
+
+// String 返回便于阅读的行标识，如 "第 12 行（id=5）"
+func (r RowRef) String() string {
+	if len(r.Key) == 0 {
+		return fmt.Sprintf("第 %d 行", r.Ordinal)
+	}
+	parts := make([]string, len(r.Key))
+	for i, k := range r.Key {
+		parts[i] = fmt.Sprintf("%s=%v", k.Column, k.Value)
+	}
+	return fmt.Sprintf("第 %d 行（%s）", r.Ordinal, strings.Join(parts, ", "))
+}
+
+// copyListener 复制过程中的表级与批次级事件，由 tableProgress 在执行复制的 goroutine 中同步调用
+// （嵌入方的 Callbacks、-status-file 的状态汇总、库调用的结果收集共用）
+type copyListener interface {
+	tableStart(table string, estimatedRows int64)
+	batchCommitted(table string, rowsSoFar int64)
+	rowError(table string, row RowRef, err error)
+	// tableDone detail 为该表的核对结果，失败或分区单元时为 nil
+	tableDone(result TableResult, detail *tableVerificationResult)
+}
+
+func (c Callbacks) tableStart(table string, estimatedRows int64) {
+	if c.OnTableStart != nil {
+		c.OnTableStart(table, estimatedRows)
+	}
+}
+
+func (c Callbacks) batchCommitted(table string, rowsSoFar int64) {
+	if c.OnBatchCommitted != nil {
+		c.OnBatchCommitted(table, rowsSoFar)
+	}
+}
+
+func (c Callbacks) rowError(table string, row RowRef, err error) {
+	if c.OnRowError != nil {
+		c.OnRowError(table, row, err)
+	}
+}
+
+func (c Callbacks) tableDone(result TableResult, detail *tableVerificationResult) {
+	if c.OnTableDone != nil {
+		c.OnTableDone(result)
+	}
+}
+
+// empty 是否没有设置任何回调
+func (c Callbacks) empty() bool {
+	return c.OnTableStart == nil && c.OnBatchCommitted == nil && c.OnTableDone == nil && c.OnRowError == nil
+}
+
+// newTableResult 由核对结果或错误生成表的同步结果；rows 为失败时已提交的行数
+func newTableResult(table, target, partitionOf string, started time.Time, rows int64, detail *tableVerificationResult, err error) TableResult {
+	t := TableResult{Table: table, TargetTable: target, PartitionOf: partitionOf, RowsCopied: rows, SourceCount: -1, TargetCount: -1, Duration: time.Since(started), Err: err}
+	if detail != nil {
+		t.RowsCopied, t.SourceCount, t.TargetCount, t.HasDiff = detail.MigratedCount, detail.SourceCount, detail.TargetCount, detail.HasDiff
+	}
+	return t
+}
+
+// finishTable 通知监听者一张表完成或失败
+func finishTable(listeners []copyListener, result TableResult, detail *tableVerificationResult) {
+	for _, l := range listeners {
+		l.tableDone(result, detail)
+	}
+}
+
+// serialListener 持有同一把锁调用被包装的监听者，并行复制的分区单元共用，保证回调不会并发调用
+type serialListener struct {
+	mu *sync.Mutex
+	l  copyListener
+}
+
+// serializeListeners 返回共用一把锁的监听者
+func serializeListeners(listeners []copyListener) []copyListener {
+	mu := &sync.Mutex{}
+	out := make([]copyListener, len(listeners))
+	for i, l := range listeners {
+		out[i] = serialListener{mu: mu, l: l}
+	}
+	return out
+}
+
+func (s serialListener) tableStart(table string, estimatedRows int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.l.tableStart(table, estimatedRows)
+}
+
+func (s serialListener) batchCommitted(table string, rowsSoFar int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.l.batchCommitted(table, rowsSoFar)
+}
+
+func (s serialListener) rowError(table string, row RowRef, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.l.rowError(table, row, err)
+}
+
+func (s serialListener) tableDone(result TableResult, detail *tableVerificationResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.l.tableDone(result, detail)
+}
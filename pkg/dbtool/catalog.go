@@ -0,0 +1,370 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// targetColumnInfo 目标库已存在表的列元数据（来自目标库系统目录；自动建表时也用于读取源表列元数据）
+type targetColumnInfo struct {
+	Name      string
+	DataType  string // 数据类型（大写），如 VARCHAR、DATETIME
+	FullType  string // 完整类型（大写），如 VARCHAR(100)、TINYINT(1)；取不到时同 DataType
+	CharLen   int64  // 字符长度，-1 表示 MAX，0 表示未知/不适用
+	Precision int64  // 数值精度，0 表示未知
+	Scale     int64  // 数值小数位
+	Nullable  bool
+	Default   sql.NullString // 目录中记录的默认值表达式（原样，未转换方言）
+}
+
+// targetColumns 按列名（不区分大小写）索引的目标列元数据
+type targetColumns map[string]targetColumnInfo
+
+// lookup 按列名查找目标列元数据
+func (t targetColumns) lookup(name string) (targetColumnInfo, bool) {
+	if t == nil {
+		return targetColumnInfo{}, false
+	}
+	info, ok := t[strings.ToLower(strings.Trim(strings.TrimSpace(name), "`\"[]"))]
+	return info, ok
+}
+
+// fetchTargetColumns 查询表的列元数据；表不存在时返回空结果
+func fetchTargetColumns(ctx context.Context, dst *simpleDB, table string) (targetColumns, error) {
+	driver := normalizeDriver(dst.cfg.Driver)
+
+	var query string
+	var args []interface{}
+	switch driver {
+	case "postgres", "postgresql":
+		query = `SELECT column_name, data_type, data_type, character_maximum_length, numeric_precision, numeric_scale, is_nullable, column_default
+FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1`
+		args = append(args, table)
+	case "mysql":
+		query = `SELECT column_name, data_type, column_type, character_maximum_length, numeric_precision, numeric_scale, is_nullable, column_default
+FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?`
+		args = append(args, table)
+	case "sqlserver":
+		query = `SELECT COLUMN_NAME, DATA_TYPE, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE, IS_NULLABLE, COLUMN_DEFAULT
+FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = SCHEMA_NAME() AND TABLE_NAME = @p1`
+		args = append(args, table)
+	case "oracle":
+		query = `SELECT column_name, data_type, data_type, char_length, data_precision, data_scale, nullable, data_default
+FROM user_tab_columns WHERE table_name = :1`
+		args = append(args, strings.ToUpper(table))
+	case "sqlite3":
+		return fetchTargetColumnsSQLite(ctx, dst.db, table)
+	case driverODBC:
+		// odbc 不读取目录信息
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("暂不支持从驱动 %s 读取表列信息", driver)
+	}
+
+	rows, err := dst.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 列信息失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	out := make(targetColumns)
+	for rows.Next() {
+		var name, dataType, fullType, nullable string
+		var charLen, precision, scale sql.NullInt64
+		var dflt sql.NullString
+		if err := rows.Scan(&name, &dataType, &fullType, &charLen, &precision, &scale, &nullable, &dflt); err != nil {
+			return nil, fmt.Errorf("读取表 %s 列信息失败: %w", table, err)
+		}
+		info := targetColumnInfo{
+			Name:      name,
+			DataType:  strings.ToUpper(dataType),
+			FullType:  strings.ToUpper(fullType),
+			CharLen:   charLen.Int64,
+			Precision: precision.Int64,
+			Scale:     scale.Int64,
+			Nullable:  strings.EqualFold(nullable, "YES") || strings.EqualFold(nullable, "Y"),
+			Default:   dflt,
+		}
+		if info.FullType == info.DataType && info.CharLen != 0 && strings.Contains(info.DataType, "CHAR") {
+			if info.CharLen < 0 {
+				info.FullType = fmt.Sprintf("%s(MAX)", info.DataType)
+			} else {
+				info.FullType = fmt.Sprintf("%s(%d)", info.DataType, info.CharLen)
+			}
+		}
+		out[strings.ToLower(name)] = info
+	}
+	return out, rows.Err()
+}
+
+// typeArgsRe 匹配类型声明中的括号参数，如 VARCHAR(100)、DECIMAL(18,6)
+var typeArgsRe = regexp.MustCompile(`\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)`)
+
+// fetchTargetColumnsSQLite 通过 PRAGMA table_info 读取 SQLite 表的列信息（长度/精度从声明类型解析）
+func fetchTargetColumnsSQLite(ctx context.Context, db *sql.DB, table string) (targetColumns, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", quoteIdent(table, "sqlite3")))
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 列信息失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	out := make(targetColumns)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, declType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &declType, &notNull, &dflt, &pk); err != nil {
+			return nil, fmt.Errorf("读取表 %s 列信息失败: %w", table, err)
+		}
+		info := targetColumnInfo{
+			Name:     name,
+			FullType: strings.ToUpper(declType),
+			Nullable: notNull == 0,
+			Default:  dflt,
+		}
+		info.DataType = info.FullType
+		if i := strings.Index(info.DataType, "("); i >= 0 {
+			info.DataType = strings.TrimSpace(info.DataType[:i])
+		}
+		if m := typeArgsRe.FindStringSubmatch(info.FullType); m != nil {
+			n, _ := strconv.ParseInt(m[1], 10, 64)
+			if strings.Contains(info.DataType, "CHAR") {
+				info.CharLen = n
+			} else {
+				info.Precision = n
+				if m[2] != "" {
+					info.Scale, _ = strconv.ParseInt(m[2], 10, 64)
+				}
+			}
+		}
+		out[strings.ToLower(name)] = info
+	}
+	return out, rows.Err()
+}
+
+// fetchPrimaryKey 查询指定连接上某表的主键列（按主键内顺序）；表不存在或无主键时返回空
+func fetchPrimaryKey(ctx context.Context, db *simpleDB, table string) ([]string, error) {
+	driver := normalizeDriver(db.cfg.Driver)
+
+	var query string
+	var args []interface{}
+	switch driver {
+	case "postgres", "postgresql":
+		query = `SELECT kcu.column_name
+FROM information_schema.table_constraints tc
+JOIN information_schema.key_column_usage kcu
+  ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema AND tc.table_name = kcu.table_name
+WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = current_schema() AND tc.table_name = $1
+ORDER BY kcu.ordinal_position`
+		args = append(args, table)
+	case "mysql":
+		query = `SELECT column_name FROM information_schema.key_column_usage
+WHERE table_schema = DATABASE() AND table_name = ? AND constraint_name = 'PRIMARY'
+ORDER BY ordinal_position`
+		args = append(args, table)
+	case "sqlserver":
+		query = `SELECT kcu.COLUMN_NAME
+FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE kcu
+  ON tc.CONSTRAINT_NAME = kcu.CONSTRAINT_NAME AND tc.TABLE_SCHEMA = kcu.TABLE_SCHEMA AND tc.TABLE_NAME = kcu.TABLE_NAME
+WHERE tc.CONSTRAINT_TYPE = 'PRIMARY KEY' AND tc.TABLE_SCHEMA = SCHEMA_NAME() AND tc.TABLE_NAME = @p1
+ORDER BY kcu.ORDINAL_POSITION`
+		args = append(args, table)
+	case "oracle":
+		query = `SELECT cc.column_name
+FROM user_constraints c
+JOIN user_cons_columns cc ON c.constraint_name = cc.constraint_name
+WHERE c.constraint_type = 'P' AND c.table_name = :1
+ORDER BY cc.position`
+		args = append(args, strings.ToUpper(table))
+	case "sqlite3":
+		return fetchPrimaryKeySQLite(ctx, db.db, table)
+	case driverODBC:
+		// odbc 不读取目录信息
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("暂不支持从驱动 %s 读取主键信息", driver)
+	}
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 主键失败: %w", table, err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		out = append(out, name)
+	}
+	return out, rows.Err()
+}
+
+func fetchPrimaryKeySQLite(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", quoteIdent(table, "sqlite3")))
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 主键失败: %w", table, err)
+	}
+	defer rows.Close()
+	byPos := make(map[int]string)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, declType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &declType, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		if pk > 0 {
+			byPos[pk] = name
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(byPos))
+	for i := 1; i <= len(byPos); i++ {
+		if name, ok := byPos[i]; ok {
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// generatedColumn 生成列/计算列信息
+type generatedColumn struct {
+	Name       string
+	Stored     bool   // 是否为存储列（STORED/PERSISTED），否则为虚拟列
+	Expression string // 生成表达式（取不到时为空）
+}
+
+// kind 返回生成列类型的描述，用于日志
+func (g generatedColumn) kind() string {
+	if g.Stored {
+		return "STORED"
+	}
+	return "VIRTUAL"
+}
+
+// fetchGeneratedColumns 查询表中的生成列/计算列（按小写列名索引）；表不存在时返回空结果
+// - mysql:     information_schema.columns.extra 为 STORED/VIRTUAL GENERATED（排除 8.0 的 DEFAULT_GENERATED）
+// - postgres:  information_schema.columns.is_generated（12+）
+// - sqlserver: sys.computed_columns
+// - oracle:    user_tab_cols.virtual_column
+// - sqlite3:   PRAGMA table_xinfo 的 hidden 字段（2=VIRTUAL，3=STORED，取不到表达式）
+func fetchGeneratedColumns(ctx context.Context, db *simpleDB, table string) (map[string]generatedColumn, error) {
+	driver := normalizeDriver(db.cfg.Driver)
+
+	var query string
+	var args []interface{}
+	switch driver {
+	case "mysql":
+		query = `SELECT column_name, extra LIKE '%STORED%', generation_expression
+FROM information_schema.columns
+WHERE table_schema = DATABASE() AND table_name = ? AND (extra LIKE '%VIRTUAL GENERATED%' OR extra LIKE '%STORED GENERATED%')`
+		args = append(args, table)
+	case "postgres", "postgresql":
+		query = `SELECT column_name, TRUE, generation_expression
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = $1 AND is_generated = 'ALWAYS'`
+		args = append(args, table)
+	case "sqlserver":
+		query = `SELECT name, is_persisted, definition FROM sys.computed_columns WHERE object_id = OBJECT_ID(@p1)`
+		args = append(args, table)
+	case "oracle":
+		query = `SELECT column_name, 0, data_default FROM user_tab_cols
+WHERE table_name = :1 AND virtual_column = 'YES' AND hidden_column = 'NO'`
+		args = append(args, strings.ToUpper(table))
+	case "sqlite3":
+		return fetchGeneratedColumnsSQLite(ctx, db.db, table)
+	case driverODBC:
+		// odbc 不读取目录信息
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("暂不支持从驱动 %s 读取生成列信息", driver)
+	}
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 生成列失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]generatedColumn)
+	for rows.Next() {
+		var name string
+		var stored bool
+		var expr sql.NullString
+		if err := rows.Scan(&name, &stored, &expr); err != nil {
+			return nil, fmt.Errorf("读取表 %s 生成列失败: %w", table, err)
+		}
+		out[strings.ToLower(name)] = generatedColumn{Name: name, Stored: stored, Expression: strings.TrimSpace(expr.String)}
+	}
+	return out, rows.Err()
+}
+
+func fetchGeneratedColumnsSQLite(ctx context.Context, db *sql.DB, table string) (map[string]generatedColumn, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_xinfo(%s)", quoteIdent(table, "sqlite3")))
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 生成列失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]generatedColumn)
+	for rows.Next() {
+		var cid, notNull, pk, hidden int
+		var name, declType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &declType, &notNull, &dflt, &pk, &hidden); err != nil {
+			return nil, fmt.Errorf("读取表 %s 生成列失败: %w", table, err)
+		}
+		if hidden == 2 || hidden == 3 {
+			out[strings.ToLower(name)] = generatedColumn{Name: name, Stored: hidden == 3}
+		}
+	}
+	return out, rows.Err()
+}
+
+// identityColumn Postgres 标识列（GENERATED ... AS IDENTITY）或 serial 列
+type identityColumn struct {
+	Name   string
+	Always bool // GENERATED ALWAYS AS IDENTITY：显式写入需 OVERRIDING SYSTEM VALUE
+	Serial bool // serial/bigserial（默认值为 nextval(...)）
+}
+
+// fetchIdentityColumns 查询 Postgres 表的标识列与 serial 列（按小写列名索引）；其他驱动返回空结果
+func fetchIdentityColumns(ctx context.Context, db *simpleDB, table string) (map[string]identityColumn, error) {
+	switch normalizeDriver(db.cfg.Driver) {
+	case "postgres", "postgresql":
+	default:
+		return nil, nil
+	}
+
+	rows, err := db.db.QueryContext(ctx, `SELECT column_name, COALESCE(is_identity, 'NO'), COALESCE(identity_generation, ''), COALESCE(column_default, '')
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = $1
+  AND (is_identity = 'YES' OR column_default LIKE 'nextval(%')`, table)
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 标识列失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]identityColumn)
+	for rows.Next() {
+		var name, isIdentity, generation, dflt string
+		if err := rows.Scan(&name, &isIdentity, &generation, &dflt); err != nil {
+			return nil, fmt.Errorf("读取表 %s 标识列失败: %w", table, err)
+		}
+		out[strings.ToLower(name)] = identityColumn{
+			Name:   name,
+			Always: strings.EqualFold(isIdentity, "YES") && strings.EqualFold(generation, "ALWAYS"),
+			Serial: !strings.EqualFold(isIdentity, "YES"),
+		}
+	}
+	return out, rows.Err()
+}
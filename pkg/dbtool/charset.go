@@ -0,0 +1,86 @@
+package dbtool
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// lookupCharset 根据字符集名称（如 latin1、gbk、gb18030、big5）查找编码；UTF-8 系列返回 nil（无需转换）
+func lookupCharset(name string) (encoding.Encoding, error) {
+	n := strings.ToLower(strings.TrimSpace(name))
+	switch n {
+	case "", "utf8", "utf-8", "utf8mb4", "utf8mb3", "al32utf8":
+		return nil, nil
+	}
+	if enc, err := htmlindex.Get(n); err == nil {
+		return enc, nil
+	}
+	if enc, err := ianaindex.IANA.Encoding(n); err == nil && enc != nil {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("不支持的字符集 %q", name)
+}
+
+// 非法 UTF-8 处理策略（invalid_utf8_policy）
+const (
+	invalidUTF8Replace = "replace" // 替换为 U+FFFD
+	invalidUTF8Strip   = "strip"   // 删除非法字节
+	invalidUTF8Error   = "error"   // 报错
+)
+
+// validateInvalidUTF8Policy 校验非法 UTF-8 处理策略
+func validateInvalidUTF8Policy(policy string) error {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "", invalidUTF8Replace, invalidUTF8Strip, invalidUTF8Error:
+		return nil
+	}
+	return fmt.Errorf("invalid_utf8_policy 仅支持 replace/strip/error，当前为 %q", policy)
+}
+
+// decodeText 将源库字符集的字节解码为 UTF-8 字符串，valid 为 false 表示源字节中有该字符集的非法序列。
+// x/text 的解码器遇到非法序列时不报错，而是替换为 U+FFFD；结果中出现 U+FFFD 时用 enc 编码回去与原字节比较，
+// 不一致说明 U+FFFD 是解码器替换的（源数据本身编码了 U+FFFD 时能原样编码回去）
+func decodeText(dec *encoding.Decoder, enc *encoding.Encoder, b []byte) (s string, valid bool, err error) {
+	out, err := dec.Bytes(b)
+	if err != nil {
+		return "", false, err
+	}
+	if !bytes.ContainsRune(out, utf8.RuneError) {
+		return string(out), true, nil
+	}
+	back, err := enc.Bytes(out)
+	return string(out), err == nil && bytes.Equal(back, b), nil
+}
+
+// repairDecoded 按 invalid_utf8_policy 处理解码时被替换为 U+FFFD 的非法字节
+func repairDecoded(s, policy string) (string, error) {
+	switch policy {
+	case invalidUTF8Strip:
+		return strings.ReplaceAll(s, string(utf8.RuneError), ""), nil
+	case invalidUTF8Error:
+		return "", fmt.Errorf("包含源字符集的非法字节序列")
+	default:
+		return s, nil
+	}
+}
+
+// repairUTF8 按策略修复非法 UTF-8；返回修复后的字符串及是否发生修复
+func repairUTF8(s, policy string) (string, bool, error) {
+	if utf8.ValidString(s) {
+		return s, false, nil
+	}
+	switch policy {
+	case invalidUTF8Strip:
+		return strings.ToValidUTF8(s, ""), true, nil
+	case invalidUTF8Error:
+		return "", false, fmt.Errorf("包含非法 UTF-8 字节序列")
+	default:
+		return strings.ToValidUTF8(s, "\uFFFD"), true, nil
+	}
+}
@@ -0,0 +1,276 @@
+package dbtool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chunkConfig 按日期/时间列分区间复制（chunk_by）
+type chunkConfig struct {
+	Column       string `json:"column"`                  // 日期/时间戳列
+	Start        string `json:"start"`                   // 起始时间（含），如 2024-01-01
+	End          string `json:"end"`                     // 结束时间（不含）
+	Step         string `json:"step"`                    // 区间步长，如 24h、7d
+	ProgressFile string `json:"progress_file,omitempty"` // 进度文件（默认 <目标表>.chunk.json）
+}
+
+// chunkProgress 进度文件内容：记录已完成的最后一个区间的上界，失败重跑时从该处继续
+type chunkProgress struct {
+	Table          string `json:"table"`
+	Column         string `json:"column"`
+	Start          string `json:"start"`
+	End            string `json:"end"`
+	Step           string `json:"step"`
+	CompletedUntil string `json:"completed_until"`
+}
+
+// chunkTimeLayouts chunk_by 的 start/end 支持的时间格式
+var chunkTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+const chunkTimeLayout = "2006-01-02 15:04:05"
+
+var dayStepRe = regexp.MustCompile(`^(\d+)d$`)
+
+// parseChunkTime 解析区间边界时间
+func parseChunkTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range chunkTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q（支持 2006-01-02 或 2006-01-02 15:04:05）", s)
+}
+
+// parseChunkStep 解析区间步长：Go 时长（如 24h、30m）或天数（如 7d）
+func parseChunkStep(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if m := dayStepRe.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析步长 %q（如 24h、7d）", s)
+	}
+	return d, nil
+}
+
+// chunkTimeLiteral 生成区间边界的时间字面量
+func chunkTimeLiteral(t time.Time, driver string) string {
+	v := t.Format(chunkTimeLayout)
+	if normalizeDriver(driver) == "oracle" {
+		return fmt.Sprintf("TO_TIMESTAMP('%s', 'YYYY-MM-DD HH24:MI:SS')", v)
+	}
+	return "'" + v + "'"
+}
+
+// chunkPredicate 生成 col >= lo AND col < hi 条件
+func chunkPredicate(column string, lo, hi time.Time, driver string) string {
+	col := quoteIdent(column, driver)
+	return fmt.Sprintf("%s >= %s AND %s < %s", col, chunkTimeLiteral(lo, driver), col, chunkTimeLiteral(hi, driver))
+}
+
+// andWhere 把条件追加到已有的 where 条件之后
+func andWhere(where, cond string) string {
+	if strings.TrimSpace(where) == "" {
+		return cond
+	}
+	return "(" + where + ") AND " + cond
+}
+
+// loadChunkProgress 读取进度文件；文件不存在或区间配置已变化时返回 nil
+func loadChunkProgress(path string, want chunkProgress, logger *tableLogger) (*chunkProgress, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取区间进度文件失败: %w", err)
+	}
+	var p chunkProgress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("解析区间进度文件 %s 失败: %w", path, err)
+	}
+	if p.Table != want.Table || p.Column != want.Column || p.Start != want.Start || p.End != want.End || p.Step != want.Step {
+		logger.warnf("警告：区间进度文件 %s 与当前 chunk_by 配置不一致，从头开始\n", path)
+		return nil, nil
+	}
+	return &p, nil
+}
+
+// saveChunkProgress 写入进度文件（先写临时文件再改名，避免中途失败留下半个文件）
+func saveChunkProgress(path string, p chunkProgress) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化区间进度失败: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入区间进度文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入区间进度文件失败: %w", err)
+	}
+	return nil
+}
+
+// parseChunkWindow 校验 chunk_by 配置并解析窗口 [start, end) 与步长
+func parseChunkWindow(c *chunkConfig) (start, end time.Time, step time.Duration, err error) {
+	if strings.TrimSpace(c.Column) == "" {
+		return start, end, 0, fmt.Errorf("chunk_by.column 不能为空")
+	}
+	if start, err = parseChunkTime(c.Start); err != nil {
+		return start, end, 0, fmt.Errorf("chunk_by.start: %w", err)
+	}
+	if end, err = parseChunkTime(c.End); err != nil {
+		return start, end, 0, fmt.Errorf("chunk_by.end: %w", err)
+	}
+	if !end.After(start) {
+		return start, end, 0, fmt.Errorf("chunk_by.end 必须晚于 chunk_by.start")
+	}
+	if step, err = parseChunkStep(c.Step); err != nil {
+		return start, end, 0, fmt.Errorf("chunk_by.step: %w", err)
+	}
+	if step <= 0 {
+		return start, end, 0, fmt.Errorf("chunk_by.step 必须大于 0")
+	}
+	return start, end, step, nil
+}
+
+// copyTableChunked 按 chunk_by 配置逐个区间复制，返回值含义同 copyTable：迁移记录数为各区间之和，
+// 源表与目标表记录数都按整个 [start, end) 窗口统计。
+// 每个区间与 atomic 一样在一个事务中写入、完成后提交一次再记录进度：中断时未完成的区间整体回滚，
+// 重跑从该区间继续不会重复写入已提交的批次
+func copyTableChunked(ctx context.Context, src, dst *simpleDB, opts copyTableOptions) (_ int64, _ int64, _ int64, _ float64, copyErr error) {
+	c := opts.ChunkBy
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		return 0, 0, 0, 0, fmt.Errorf("chunk_by 不能与 select_sql 同时使用")
+	}
+	if autocommitWrites(opts) {
+		return 0, 0, 0, 0, fmt.Errorf("chunk_by 每个区间在一个事务中写入，不能与 write_mode_tx=none 同时使用")
+	}
+	if strings.TrimSpace(opts.BatchTimeout) != "" {
+		return 0, 0, 0, 0, fmt.Errorf("chunk_by 每个区间只在最后提交一次，不能配置 batch_timeout")
+	}
+	start, end, step, err := parseChunkWindow(c)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	targetTable := firstNonEmpty(opts.TargetTable, opts.Table)
+	progressPath := firstNonEmpty(c.ProgressFile, targetTable+".chunk.json")
+	progress := chunkProgress{
+		Table:  opts.Table,
+		Column: c.Column,
+		Start:  start.Format(chunkTimeLayout),
+		End:    end.Format(chunkTimeLayout),
+		Step:   step.String(),
+	}
+
+	// rebuild_indexes 与 indexes=deferred 在全部区间前后各执行一次，而不是每个区间都删除重建
+	plan := opts.IndexPlan
+	if plan == nil {
+		plan = newIndexPlan(dst, opts)
+		defer func() { plan.finish(copyErr == nil) }()
+	}
+	if err := plan.dropIndexesForLoad(ctx, opts.RebuildIndexes); err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	lo := start
+	if !opts.DryRun {
+		saved, err := loadChunkProgress(progressPath, progress, opts.Log)
+		if err != nil {
+			return 0, 0, 0, 0, err
+		}
+		if saved != nil {
+			if done, err := parseChunkTime(saved.CompletedUntil); err == nil && done.After(start) {
+				lo = done
+				opts.Log.infof("从区间进度文件 %s 恢复：%s 之前的区间已完成\n", progressPath, saved.CompletedUntil)
+			}
+		}
+	}
+
+	startTime := time.Now()
+	var migrated int64
+	chunks := 0
+	for lo.Before(end) {
+		hi := lo.Add(step)
+		if hi.After(end) {
+			hi = end
+		}
+		chunks++
+		label := fmt.Sprintf("[%s, %s)", lo.Format(chunkTimeLayout), hi.Format(chunkTimeLayout))
+		opts.Log.infof("表 %s 区间 %s 开始复制\n", opts.Table, label)
+
+		chunkOpts := opts
+		chunkOpts.ChunkBy = nil
+		chunkOpts.RebuildIndexes = nil
+		chunkOpts.IndexPlan = plan
+		chunkOpts.ChunkLabel = label
+		chunkOpts.Atomic = true
+		chunkOpts.Where = andWhere(opts.Where, chunkPredicate(c.Column, lo, hi, src.cfg.Driver))
+		n, _, _, _, err := copyTable(ctx, src, dst, chunkOpts)
+		if err != nil {
+			return migrated, 0, 0, 0, fmt.Errorf("区间 %s 复制失败（已完成的区间记录在 %s，重跑时从该区间继续）: %w", label, progressPath, err)
+		}
+		migrated += n
+		opts.Log.infof("表 %s 区间 %s 完成，迁移 %d 条\n", opts.Table, label, n)
+
+		if !opts.DryRun {
+			progress.CompletedUntil = hi.Format(chunkTimeLayout)
+			if err := saveChunkProgress(progressPath, progress); err != nil {
+				return migrated, 0, 0, 0, err
+			}
+		}
+		lo = hi
+	}
+	if !opts.DryRun {
+		if err := os.Remove(progressPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			opts.Log.warnf("警告：删除区间进度文件 %s 失败: %v\n", progressPath, err)
+		}
+	}
+
+	// 源表记录数按整个窗口统计（包括之前运行已完成的区间），与目标表使用同样的软删除/增量条件
+	sourceCount := int64(-1)
+	from, err := sourceFrom(opts, src.cfg.Driver)
+	if err == nil {
+		srcWindowOpts := opts
+		srcWindowOpts.Where = andWhere(opts.Where, chunkPredicate(c.Column, start, end, src.cfg.Driver))
+		window, windowArgs := sourceWindow(srcWindowOpts, src.cfg.Driver, nil)
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", from, strings.Join(window, " AND "))
+		if err := src.db.QueryRowContext(ctx, countQuery, windowArgs...).Scan(&sourceCount); err != nil {
+			opts.Log.warnf("警告：无法获取源表窗口记录数: %v\n", err)
+			sourceCount = -1
+		}
+	}
+	// 目标表同样按整个窗口统计（区间列按字段映射换成目标列名）
+	windowOpts := opts
+	windowOpts.Where = andWhere(opts.Where, chunkPredicate(c.Column, start, end, dst.cfg.Driver))
+	targetCount := countTargetRows(ctx, dst.db, targetTable, windowOpts, dst.cfg.Driver)
+
+	durationSeconds := time.Since(startTime).Seconds()
+	opts.Log.infof("========================================\n")
+	opts.Log.infof("表 %s 按区间复制完成（%d 个区间）\n", opts.Table, chunks)
+	opts.Log.infof("========================================\n")
+	opts.Log.infof("窗口: [%s, %s)，步长 %s\n", progress.Start, progress.End, c.Step)
+	opts.Log.infof("总耗时: %.2f 秒 (%.2f 分钟)\n", durationSeconds, durationSeconds/60)
+	opts.Log.infof("源表窗口记录数: %d\n", sourceCount)
+	opts.Log.infof("目标表窗口记录数: %d\n", targetCount)
+	opts.Log.infof("本次迁移记录数: %d\n", migrated)
+	opts.Log.infof("========================================\n")
+
+	return migrated, sourceCount, targetCount, durationSeconds, nil
+}
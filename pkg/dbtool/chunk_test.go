@@ -0,0 +1,56 @@
+package dbtool
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkResumeDoesNotDuplicate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	src := openTestSQLite(t, srcPath, "CREATE TABLE ev (id INTEGER PRIMARY KEY, at TEXT)",
+		"INSERT INTO ev VALUES (1, '2024-01-01 01:00:00'), (2, '2024-01-01 02:00:00'), "+
+			"(3, '2024-01-02 01:00:00'), (4, '2024-01-02 02:00:00'), (5, '2024-01-02 03:00:00')")
+	// 目标表拒绝 id=5：第二个区间写入前两行后失败
+	dst := openTestSQLite(t, dstPath, "CREATE TABLE ev (id INTEGER CHECK (id <> 5), at TEXT)")
+	progress := filepath.Join(dir, "ev.chunk.json")
+	cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "ev", "batch_size": 1,
+ "chunk_by": {"column": "at", "start": "2024-01-01", "end": "2024-01-03", "step": "24h", "progress_file": "`+progress+`"}}]`)
+	run := func() error {
+		s, err := Open(ctx, cfg, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		_, err = s.Run(ctx)
+		return err
+	}
+	count := func() int {
+		var n int
+		if err := dst.QueryRowContext(ctx, "SELECT COUNT(*) FROM ev").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if err := run(); err == nil {
+		t.Fatal("第二个区间应复制失败")
+	}
+	// 第一个区间已提交，失败的区间整体回滚
+	if n := count(); n != 2 {
+		t.Fatalf("失败后目标表 %d 行，期望只有第一个区间的 2 行", n)
+	}
+
+	if _, err := src.ExecContext(ctx, "DELETE FROM ev WHERE id = 5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(); err != nil {
+		t.Fatal(err)
+	}
+	// 重跑从失败的区间继续，不重复写入
+	if n := count(); n != 4 {
+		t.Fatalf("重跑后目标表 %d 行，期望 4 行", n)
+	}
+}
@@ -0,0 +1,49 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// CockroachDB 使用 Postgres 协议，以 postgres 驱动连接，连接时通过 version() 识别，在 Postgres 方言之上调整：
+// - 写入使用分批提交的 INSERT（COPY 在一个事务中写入整张表，容易超出 CockroachDB 的事务大小限制）
+// - 事务总是 SERIALIZABLE，序列化冲突（40001）远比 Postgres 常见，写入或提交遇到时回滚并重放本批
+// - 状态表（state_backend=target）使用 UPSERT 代替 INSERT ... ON CONFLICT
+// - 不支持 ALTER TABLE ... SET LOGGED，UNLOGGED 建表被忽略
+const (
+	// cockroachMaxRetries 一批遇到序列化冲突时的最多重试次数
+	cockroachMaxRetries = 5
+	// cockroachBatchRows 建议的每批最多行数，超过时提示
+	cockroachBatchRows = 1000
+)
+
+// detectCockroach 判断 Postgres 协议的连接是否为 CockroachDB；查询失败时按 Postgres 处理
+func detectCockroach(ctx context.Context, db *sql.DB, logger *tableLogger) bool {
+	var v string
+	if err := db.QueryRowContext(ctx, "SELECT version()").Scan(&v); err != nil {
+		logger.debugf("查询数据库版本失败，按 Postgres 处理: %v\n", err)
+		return false
+	}
+	if !strings.Contains(v, "CockroachDB") {
+		return false
+	}
+	logger.debugf("检测到 CockroachDB: %s\n", v)
+	return true
+}
+
+// isSerializationFailure 是否为可重试的事务序列化冲突（SQLSTATE 40001）
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "40001"
+}
+
+// warnCockroachBatchSize 每批行数超过建议值时提示
+func warnCockroachBatchSize(opts copyTableOptions) {
+	if opts.BatchSize > cockroachBatchRows {
+		opts.Log.warnf("警告：batch_size=%d 超过 CockroachDB 建议的每批 %d 行，大事务更容易序列化冲突或超出事务大小限制\n", opts.BatchSize, cockroachBatchRows)
+	}
+}
@@ -0,0 +1,265 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// 列统计的类别：决定统计哪些聚合
+const (
+	statOther   = iota // 空值数、最小值、最大值
+	statNumeric        // 另加合计（精确比较）
+	statFloat          // 另加合计（浮点，按相对误差比较）
+	statBool           // 仅空值数（布尔列在部分方言上不支持 MIN/MAX/SUM）
+)
+
+// columnStats 单侧一列的聚合结果（已转为文本）
+type columnStats struct {
+	nulls, min, max, sum string
+}
+
+// verifyColumnStats 按 verify_columns 在源表与目标表上各执行一条聚合查询（空值数、最小值、最大值、数值列合计），
+// 范围与复制窗口一致；打印逐列对比并返回不一致项的描述（如 "amount 合计"）。查询失败时打印警告并返回 nil
+func verifyColumnStats(ctx context.Context, src, dst *simpleDB, opts copyTableOptions) []string {
+	mismatches, err := compareColumnStats(ctx, src, dst, opts)
+	if err != nil {
+		opts.Log.warnf("警告：表 %s 列统计核对失败: %v\n", opts.Table, err)
+		return nil
+	}
+	return mismatches
+}
+
+func compareColumnStats(ctx context.Context, src, dst *simpleDB, opts copyTableOptions) ([]string, error) {
+	targetTable := firstNonEmpty(opts.TargetTable, opts.Table)
+	srcOpts, dstOpts, err := splitWindowOpts(opts, src.cfg.Driver, dst.cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	// 源库：select_sql 表按查询结果统计，普通表按复制窗口统计
+	var srcFrom string
+	var srcArgs []interface{}
+	var srcWhere []string
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		selectSQL, args, err := bindSelectSQL(opts.SelectSQL, opts, src.cfg.Driver)
+		if err != nil {
+			return nil, fmt.Errorf("绑定 select_sql 参数失败: %w", err)
+		}
+		srcFrom, srcArgs = "("+selectSQL+") tmp", args
+	} else {
+		if srcFrom, err = sourceFrom(srcOpts, src.cfg.Driver); err != nil {
+			return nil, err
+		}
+		sample, err := buildSampleClause(opts, src.cfg.Driver)
+		if err != nil {
+			return nil, err
+		}
+		srcWhere, srcArgs = sourceWindow(srcOpts, src.cfg.Driver, sample)
+	}
+	dstFrom := quoteIdent(targetTable, dst.cfg.Driver)
+	dstCond, dstArgs, windowed := targetWindow(dstOpts, dst.cfg.Driver)
+
+	rename := columnRenames(opts)
+	srcCols := opts.VerifyColumns
+	dstCols := make([]string, len(srcCols))
+	for i, c := range srcCols {
+		dstCols[i] = firstNonEmpty(rename[strings.ToLower(c)], c)
+	}
+
+	probeArgs := srcArgs
+	if len(srcWhere) > 0 {
+		// 参数属于复制窗口条件，探测列类型的查询不带条件
+		probeArgs = nil
+	}
+	srcKinds, err := probeStatKinds(ctx, src.db, fmt.Sprintf("SELECT %s FROM %s WHERE 1=0", joinQuoted(srcCols, src.cfg.Driver), srcFrom), probeArgs)
+	if err != nil {
+		return nil, fmt.Errorf("读取源表列类型失败: %w", err)
+	}
+	dstKinds, err := probeStatKinds(ctx, dst.db, fmt.Sprintf("SELECT %s FROM %s WHERE 1=0", joinQuoted(dstCols, dst.cfg.Driver), dstFrom), nil)
+	if err != nil {
+		return nil, fmt.Errorf("读取目标表列类型失败: %w", err)
+	}
+	// 两侧类别不同时取能共同统计的部分（如 MySQL tinyint(1) -> PostgreSQL boolean 只比较空值数）
+	kinds := make([]int, len(srcCols))
+	for i := range kinds {
+		switch a, b := srcKinds[i], dstKinds[i]; {
+		case a == statBool || b == statBool:
+			kinds[i] = statBool
+		case a == statFloat || b == statFloat:
+			if a == statOther || b == statOther {
+				kinds[i] = statOther
+			} else {
+				kinds[i] = statFloat
+			}
+		case a == statNumeric && b == statNumeric:
+			kinds[i] = statNumeric
+		default:
+			kinds[i] = statOther
+		}
+	}
+
+	srcQuery := buildColumnStatsQuery(srcCols, kinds, srcFrom, src.cfg.Driver)
+	if len(srcWhere) > 0 {
+		srcQuery += " WHERE " + strings.Join(srcWhere, " AND ")
+	}
+	dstQuery := buildColumnStatsQuery(dstCols, kinds, dstFrom, dst.cfg.Driver)
+	if windowed {
+		dstQuery += " WHERE " + dstCond
+	}
+
+	srcStats, err := queryColumnStats(ctx, src.db, srcQuery, srcArgs, kinds)
+	if err != nil {
+		return nil, fmt.Errorf("源表列统计查询失败: %w", err)
+	}
+	dstStats, err := queryColumnStats(ctx, dst.db, dstQuery, dstArgs, kinds)
+	if err != nil {
+		return nil, fmt.Errorf("目标表列统计查询失败: %w", err)
+	}
+
+	opts.Log.infof("列统计核对（%s）:\n", opts.Table)
+	var mismatches []string
+	for i, col := range srcCols {
+		a, b := srcStats[i], dstStats[i]
+		items := []struct {
+			name     string
+			src, dst string
+			skip     bool
+			float    bool
+		}{
+			{"空值数", a.nulls, b.nulls, false, false},
+			{"最小值", a.min, b.min, kinds[i] == statBool, false},
+			{"最大值", a.max, b.max, kinds[i] == statBool, false},
+			{"合计", a.sum, b.sum, kinds[i] != statNumeric && kinds[i] != statFloat, kinds[i] == statFloat},
+		}
+		var parts []string
+		for _, it := range items {
+			if it.skip {
+				continue
+			}
+			if statValuesEqual(it.src, it.dst, it.float) {
+				parts = append(parts, fmt.Sprintf("%s ✅ %s", it.name, it.src))
+			} else {
+				parts = append(parts, fmt.Sprintf("%s ❌ %s / %s", it.name, it.src, it.dst))
+				mismatches = append(mismatches, col+" "+it.name)
+			}
+		}
+		opts.Log.infof("  %s: %s\n", col, strings.Join(parts, " | "))
+	}
+	return mismatches, nil
+}
+
+// probeStatKinds 执行不返回数据的查询，按列类型判断每列的统计类别
+func probeStatKinds(ctx context.Context, db *sql.DB, query string, args []interface{}) ([]int, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	kinds := make([]int, len(types))
+	for i, ct := range types {
+		name := strings.ToUpper(ct.DatabaseTypeName())
+		switch {
+		case strings.Contains(name, "BOOL") || name == "BIT":
+			kinds[i] = statBool
+		case strings.Contains(name, "FLOAT") || strings.Contains(name, "DOUBLE") || strings.Contains(name, "REAL"):
+			kinds[i] = statFloat
+		case isNumericTypeName(name):
+			kinds[i] = statNumeric
+		default:
+			kinds[i] = statOther
+		}
+	}
+	return kinds, nil
+}
+
+// buildColumnStatsQuery 生成单条聚合查询：每列依次为 空值数、最小值、最大值、合计（按类别省略）
+func buildColumnStatsQuery(cols []string, kinds []int, from, driver string) string {
+	var exprs []string
+	for i, c := range cols {
+		col := quoteIdent(c, driver)
+		exprs = append(exprs, fmt.Sprintf("COUNT(*) - COUNT(%s)", col))
+		if kinds[i] == statBool {
+			continue
+		}
+		exprs = append(exprs, fmt.Sprintf("MIN(%s)", col), fmt.Sprintf("MAX(%s)", col))
+		if kinds[i] == statNumeric && normalizeDriver(driver) == "sqlserver" {
+			// SQL Server 的 SUM(int) 结果仍为 int，大表容易溢出
+			exprs = append(exprs, fmt.Sprintf("SUM(CAST(%s AS DECIMAL(38, 10)))", col))
+		} else if kinds[i] == statNumeric || kinds[i] == statFloat {
+			exprs = append(exprs, fmt.Sprintf("SUM(%s)", col))
+		}
+	}
+	return fmt.Sprintf("SELECT %s FROM %s", strings.Join(exprs, ", "), from)
+}
+
+// queryColumnStats 执行聚合查询并把结果按列拆分为规范化文本
+func queryColumnStats(ctx context.Context, db *sql.DB, query string, args []interface{}, kinds []int) ([]columnStats, error) {
+	n := 0
+	for _, k := range kinds {
+		switch k {
+		case statBool:
+			n++
+		case statOther:
+			n += 3
+		default:
+			n += 4
+		}
+	}
+	vals := make([]interface{}, n)
+	ptrs := make([]interface{}, n)
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := db.QueryRowContext(ctx, query, args...).Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	out := make([]columnStats, len(kinds))
+	pos := 0
+	take := func() string {
+		v := normalizeDiffValue(vals[pos])
+		pos++
+		return v
+	}
+	for i, k := range kinds {
+		out[i].nulls = take()
+		if k == statBool {
+			continue
+		}
+		out[i].min, out[i].max = take(), take()
+		if k != statOther {
+			out[i].sum = take()
+		}
+	}
+	return out, nil
+}
+
+// statValuesEqual 比较规范化后的统计值；浮点合计按 1e-9 的相对误差比较
+func statValuesEqual(a, b string, float bool) bool {
+	if a == b {
+		return true
+	}
+	ra, okA := new(big.Rat).SetString(a)
+	rb, okB := new(big.Rat).SetString(b)
+	if !okA || !okB {
+		return false
+	}
+	if ra.Cmp(rb) == 0 {
+		return true
+	}
+	if !float {
+		return false
+	}
+	diff := new(big.Rat).Sub(ra, rb)
+	scale := new(big.Rat).Abs(ra)
+	if bs := new(big.Rat).Abs(rb); bs.Cmp(scale) > 0 {
+		scale = bs
+	}
+	return new(big.Rat).Abs(diff).Cmp(new(big.Rat).Mul(scale, big.NewRat(1, 1000000000))) <= 0
+}
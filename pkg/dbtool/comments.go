@@ -0,0 +1,149 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// tableComments 源表的表注释与列注释（列注释按小写源列名索引）
+type tableComments struct {
+	table   string
+	columns map[string]string
+}
+
+// fetchComments 从源库目录读取表注释与列注释；不支持注释的驱动（如 SQLite）返回 nil
+func fetchComments(ctx context.Context, db *simpleDB, table string) (*tableComments, error) {
+	driver := normalizeDriver(db.cfg.Driver)
+
+	var tableQuery, columnQuery string
+	var args []interface{}
+	switch driver {
+	case "mysql":
+		tableQuery = `SELECT table_comment FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
+		columnQuery = `SELECT column_name, column_comment FROM information_schema.columns
+WHERE table_schema = DATABASE() AND table_name = ? AND column_comment <> ''`
+		args = append(args, table)
+	case "postgres", "postgresql":
+		tableQuery = `SELECT obj_description(c.oid, 'pg_class')
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema() AND c.relname = $1`
+		columnQuery = `SELECT a.attname, col_description(c.oid, a.attnum)
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema() AND c.relname = $1
+  AND a.attnum > 0 AND NOT a.attisdropped AND col_description(c.oid, a.attnum) IS NOT NULL`
+		args = append(args, table)
+	case "sqlserver":
+		tableQuery = `SELECT CAST(value AS NVARCHAR(4000)) FROM sys.extended_properties
+WHERE class = 1 AND major_id = OBJECT_ID(@p1) AND minor_id = 0 AND name = 'MS_Description'`
+		columnQuery = `SELECT c.name, CAST(ep.value AS NVARCHAR(4000))
+FROM sys.extended_properties ep
+JOIN sys.columns c ON c.object_id = ep.major_id AND c.column_id = ep.minor_id
+WHERE ep.class = 1 AND ep.major_id = OBJECT_ID(@p1) AND ep.name = 'MS_Description'`
+		args = append(args, table)
+	case "oracle":
+		tableQuery = `SELECT comments FROM user_tab_comments WHERE table_name = :1`
+		columnQuery = `SELECT column_name, comments FROM user_col_comments WHERE table_name = :1 AND comments IS NOT NULL`
+		args = append(args, strings.ToUpper(table))
+	default:
+		return nil, nil
+	}
+
+	out := &tableComments{columns: make(map[string]string)}
+	var tc sql.NullString
+	if err := db.db.QueryRowContext(ctx, tableQuery, args...).Scan(&tc); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("读取表 %s 的注释失败: %w", table, err)
+	}
+	out.table = tc.String
+
+	rows, err := db.db.QueryContext(ctx, columnQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("读取表 %s 的列注释失败: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var comment sql.NullString
+		if err := rows.Scan(&name, &comment); err != nil {
+			return nil, fmt.Errorf("读取表 %s 的列注释失败: %w", table, err)
+		}
+		if comment.String != "" {
+			out.columns[strings.ToLower(name)] = comment.String
+		}
+	}
+	return out, rows.Err()
+}
+
+// column 返回源列的注释
+func (c *tableComments) column(srcName string) string {
+	if c == nil {
+		return ""
+	}
+	return c.columns[strings.ToLower(srcName)]
+}
+
+// sqlLiteral 把文本转为目标库的字符串字面量（MySQL 还需转义反斜杠）
+func sqlLiteral(s, driver string) string {
+	if normalizeDriver(driver) == "mysql" {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// mysqlColumnComment 返回 MySQL 列定义中内联的 COMMENT 子句（无注释时为空）
+func mysqlColumnComment(comments *tableComments, srcName string) string {
+	if c := comments.column(srcName); c != "" {
+		return " COMMENT " + sqlLiteral(c, "mysql")
+	}
+	return ""
+}
+
+// commentStatements 生成建表后设置注释的语句（Postgres/Oracle 使用 COMMENT ON，SQL Server 使用 sp_addextendedproperty）
+// MySQL 的注释内联在建表语句中，其他目标库没有对应机制，均返回空
+func commentStatements(table string, colTypes []*sql.ColumnType, driver string, comments *tableComments, opts copyTableOptions) []string {
+	if comments == nil {
+		return nil
+	}
+	driver = normalizeDriver(driver)
+
+	rename := make(map[string]string)
+	for _, c := range opts.Columns {
+		if srcCol := strings.TrimSpace(c.Source); srcCol != "" {
+			rename[srcCol] = firstNonEmpty(strings.TrimSpace(c.Target), srcCol)
+		}
+	}
+
+	var stmts []string
+	switch driver {
+	case "postgres", "postgresql", "oracle":
+		qt := quoteIdent(table, driver)
+		if comments.table != "" {
+			stmts = append(stmts, fmt.Sprintf("COMMENT ON TABLE %s IS %s", qt, sqlLiteral(comments.table, driver)))
+		}
+		for _, ct := range colTypes {
+			if c := comments.column(ct.Name()); c != "" {
+				name := firstNonEmpty(rename[ct.Name()], ct.Name())
+				stmts = append(stmts, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", qt, quoteIdent(name, driver), sqlLiteral(c, driver)))
+			}
+		}
+	case "sqlserver":
+		// sp_addextendedproperty 的参数不能是表达式，先把当前 schema 放入变量
+		const prefix = "DECLARE @schema sysname = SCHEMA_NAME(); EXEC sp_addextendedproperty @name = N'MS_Description', "
+		if comments.table != "" {
+			stmts = append(stmts, fmt.Sprintf(prefix+"@value = N%s, @level0type = N'SCHEMA', @level0name = @schema, @level1type = N'TABLE', @level1name = N%s",
+				sqlLiteral(comments.table, driver), sqlLiteral(table, driver)))
+		}
+		for _, ct := range colTypes {
+			if c := comments.column(ct.Name()); c != "" {
+				name := firstNonEmpty(rename[ct.Name()], ct.Name())
+				stmts = append(stmts, fmt.Sprintf(prefix+"@value = N%s, @level0type = N'SCHEMA', @level0name = @schema, @level1type = N'TABLE', @level1name = N%s, @level2type = N'COLUMN', @level2name = N%s",
+					sqlLiteral(c, driver), sqlLiteral(table, driver), sqlLiteral(name, driver)))
+			}
+		}
+	}
+	return stmts
+}
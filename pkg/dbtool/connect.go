@@ -0,0 +1,113 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// 连接重试默认值：默认只尝试一次（不重试）
+const (
+	defaultConnectRetryInterval = time.Second
+	maxConnectRetryInterval     = 30 * time.Second
+)
+
+// connectRetry 数据源的连接重试参数
+type connectRetry struct {
+	attempts   int           // 最多尝试次数（含第一次）
+	interval   time.Duration // 第一次重试前的等待，之后逐次翻倍（最多 30s）
+	maxElapsed time.Duration // 从第一次尝试起的总时长上限，0 表示只按次数限制
+}
+
+// resolveConnectRetry 解析数据源的连接重试参数
+func resolveConnectRetry(cfg dbConfig) (connectRetry, error) {
+	r := connectRetry{attempts: 1, interval: defaultConnectRetryInterval}
+	if cfg.ConnectRetryAttempts < 0 {
+		return r, fmt.Errorf("connect_retry_attempts 不能为负数")
+	}
+	if cfg.ConnectRetryAttempts > 0 {
+		r.attempts = cfg.ConnectRetryAttempts
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"connect_retry_interval", cfg.ConnectRetryInterval, &r.interval},
+		{"connect_retry_max_elapsed", cfg.ConnectRetryMaxElapsed, &r.maxElapsed},
+	} {
+		if s := strings.TrimSpace(d.value); s != "" {
+			v, err := time.ParseDuration(s)
+			if err != nil || v <= 0 {
+				return r, fmt.Errorf("%s 无效: %q（如 2s、1m）", d.name, d.value)
+			}
+			*d.dst = v
+		}
+	}
+	if r.maxElapsed > 0 && cfg.ConnectRetryAttempts == 0 {
+		// 只配置了总时长时按时长限制
+		r.attempts = 0
+	}
+	return r, nil
+}
+
+// pingWithRetry 检查连接，暂时性错误（连接被拒绝、数据库启动中等）按退避重试；认证失败立即返回。
+// 每次尝试的超时为 pingTimeout
+func pingWithRetry(db *sql.DB, cfg dbConfig, retry connectRetry, pingTimeout time.Duration) error {
+	start := time.Now()
+	wait := retry.interval
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				cfg.log.infof("数据库连接成功 (%s)，第 %d 次尝试\n", cfg.Driver, attempt)
+			}
+			return nil
+		}
+		if isAuthFailure(err) {
+			return fmt.Errorf("认证失败，不重试: %w", err)
+		}
+		if retry.attempts > 0 && attempt >= retry.attempts {
+			if attempt > 1 {
+				return fmt.Errorf("已尝试 %d 次: %w", attempt, err)
+			}
+			return err
+		}
+		if retry.maxElapsed > 0 && time.Since(start)+wait > retry.maxElapsed {
+			return fmt.Errorf("重试 %s 后仍失败（已尝试 %d 次）: %w", time.Since(start).Round(time.Second), attempt, err)
+		}
+		cfg.log.warnf("警告：数据库连接失败 (%s)，%s 后第 %d 次重试: %v\n", cfg.Driver, wait, attempt+1, err)
+		time.Sleep(wait)
+		wait = min(wait*2, maxConnectRetryInterval)
+	}
+}
+
+// isAuthFailure 判断连接错误是否为认证失败（用户名或密码错误、无权访问），这类错误重试没有意义
+func isAuthFailure(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// 28000 invalid_authorization_specification，28P01 invalid_password
+		return pqErr.Code.Class() == "28"
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		// 1044 无权访问数据库，1045 用户名或密码错误
+		return myErr.Number == 1044 || myErr.Number == 1045
+	}
+	var msErr mssql.Error
+	if errors.As(err, &msErr) {
+		// 18456 登录失败
+		return msErr.Number == 18456
+	}
+	// go-ora 等驱动只有错误文本
+	return strings.Contains(err.Error(), "ORA-01017")
+}
@@ -0,0 +1,1154 @@
+package dbtool
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+)
+
+// isBinaryType 判断数据库类型名是否为二进制类型（BLOB/BYTEA/VARBINARY/RAW/IMAGE 等）
+// 注意 Oracle 的 BINARY_FLOAT/BINARY_DOUBLE 是浮点类型，不属于二进制
+func isBinaryType(dbType string) bool {
+	dbType = strings.ToUpper(strings.TrimSpace(dbType))
+	if dbType == "" || strings.HasPrefix(dbType, "BINARY_") {
+		return false
+	}
+	switch {
+	case strings.Contains(dbType, "BLOB"), strings.Contains(dbType, "BYTEA"), strings.Contains(dbType, "IMAGE"):
+		return true
+	case strings.Contains(dbType, "BINARY"):
+		// BINARY / VARBINARY / VARBINARY(MAX)
+		return true
+	case strings.HasSuffix(dbType, "RAW"):
+		// Oracle: RAW / LONG RAW（go-ora 返回 LongRaw / VarRaw / LongVarRaw）
+		return true
+	}
+	return false
+}
+
+// valueColumn 描述一个插入列及其对应的源列信息，用于写入前的值转换
+type valueColumn struct {
+	Target string // 目标列名
+	Source string // 源列名（找不到对应源列时为空）
+	DBType string // 源列数据库类型（大写）
+	Binary bool   // 是否为二进制列，二进制值始终以 []byte 传递
+	LOB    bool   // 是否为大字段（LOB/TEXT）列
+	Time   bool   // 是否为日期/时间戳列
+	Zoned  bool   // 时间列是否自带时区
+	Float  bool   // 是否为浮点列（按扫描类型判断，仅此类列检查 NaN/Inf）
+	Padded bool   // 源列为定长 CHAR/NCHAR，值可能带尾部空格
+
+	Decoder *encoding.Decoder // 源字符集解码器（源为 UTF-8 或二进制列时为 nil）
+	Encoder *encoding.Encoder // 源字符集编码器，校验解码结果中的 U+FFFD 是否来自非法字节
+
+	EmptyPolicy string // 目标为 Oracle 时空字符串的处理策略（empty_string_policy）
+	EmptyValue  string // EmptyPolicy 为 sentinel 时的替换值
+	NullPolicy  string // 源为 Oracle 时文本列 NULL 的处理策略（oracle_null_policy）
+	NotNull     bool   // 目标列不允许 NULL（来自目标库目录或字段映射 nullable=false）
+
+	TargetType string    // 目标列数据类型（来自目标库目录或建表映射，未知时为空）
+	MaxChars   int       // 目标字符列的最大长度（按 LengthUnit 计量），0 表示未知或不限
+	LengthUnit int       // 目标列长度的计量单位（lengthRunes/lengthUTF16/lengthBytes）
+	Precision  int       // 目标定点数列（DECIMAL/NUMERIC/NUMBER）的精度，0 表示未知
+	Scale      int       // 目标定点数列的小数位
+	Boolean    bool      // 目标列为布尔语义（BOOLEAN/BIT(1)/TINYINT(1)/NUMBER(1)，SQL Server 的 BIT）
+	IntBool    bool      // 目标列以整数保存布尔值（TINYINT(1)/NUMBER(1)），数值只接受 0/1
+	HasRange   bool      // 目标列日期范围受限（如 SQL Server DATETIME）
+	MinTime    time.Time // 目标列可表示的最小时间
+	MaxTime    time.Time // 目标列可表示的最大时间
+}
+
+// valueConverter 在写入目标库前对每一行参数做统一转换，并按表统计各类替换次数
+type valueConverter struct {
+	srcDriver string
+	dstDriver string
+	columns   []valueColumn
+
+	// lobSpillThreshold 大字段写入端溢写阈值（字节），<=0 表示不启用
+	lobSpillThreshold int64
+	spillLogged       bool // 已说明过溢写不降低读取时的内存峰值
+	// times 时间值时区规范化（未配置时区时为 nil）
+	times *timeNormalizer
+	// zeroDatePolicy / zeroDateValue 零值日期处理策略
+	zeroDatePolicy string
+	zeroDateValue  string
+	// dateRangePolicy / dateRangeSentinel 超出目标列日期范围时的处理策略
+	dateRangePolicy   string
+	dateRangeSentinel string
+	// floatPolicy NaN/±Inf 浮点值的处理策略（为空表示不检查）
+	floatPolicy string
+	// nulPolicy 字符串中 NUL 字节（0x00）的处理策略（仅目标为 postgres 时生效），nulReplacement 为替换文本
+	nulPolicy      string
+	nulReplacement string
+	nulWarned      bool
+	// invalidUTF8Policy 非法 UTF-8 的处理策略（为空表示不检查）
+	invalidUTF8Policy string
+	// overflowPolicy 字符串超出目标列长度时的处理策略（为空表示不检查）
+	overflowPolicy string
+	overflowMarker string
+	overflowLog    bool
+	// numericPolicy 数值超出目标列精度时的处理策略（为空表示不检查）
+	numericPolicy string
+	// trimCharPadding 去除定长 CHAR 列值的尾部空格
+	trimCharPadding bool
+
+	// keyIndexes 目标表主键列在插入列中的下标，用于在日志/错误中标识行
+	keyIndexes []int
+	keyNames   []string
+	// rowNum 当前处理到的行号（从 1 开始）
+	rowNum int64
+
+	stats     map[string]int64
+	statOrder []string
+
+	// spills 已溢写、尚未删除的临时文件（removeSpilled 删除）
+	spills []*spilledValue
+
+	log *tableLogger
+}
+
+// newValueConverter 根据源列类型与插入列构建转换器
+// - colTypes:   源查询结果的列类型（顺序与 sourceCols 一致）
+// - insertCols: 插入目标库的列名（buildInsertColumns 的结果）
+func newValueConverter(colTypes []*sql.ColumnType, sourceCols, insertCols []string, srcDriver, dstDriver string, opts copyTableOptions) (*valueConverter, error) {
+	c := &valueConverter{
+		log:               opts.Log,
+		srcDriver:         normalizeDriver(srcDriver),
+		dstDriver:         normalizeDriver(dstDriver),
+		stats:             make(map[string]int64),
+		lobSpillThreshold: opts.LobSpillThreshold,
+		trimCharPadding:   opts.TrimCharPadding,
+	}
+
+	times, err := newTimeNormalizer(opts.SourceTimezone, opts.TimestampOutput)
+	if err != nil {
+		return nil, err
+	}
+	c.times = times
+
+	if err := validateZeroDatePolicy(opts.ZeroDatePolicy, opts.ZeroDateValue); err != nil {
+		return nil, err
+	}
+	c.zeroDatePolicy = strings.ToLower(strings.TrimSpace(opts.ZeroDatePolicy))
+	c.zeroDateValue = opts.ZeroDateValue
+
+	if err := validateDateRangePolicy(opts.DateRangePolicy, opts.DateRangeSentinel); err != nil {
+		return nil, err
+	}
+	c.dateRangePolicy = strings.ToLower(strings.TrimSpace(opts.DateRangePolicy))
+	if c.dateRangePolicy == "" {
+		c.dateRangePolicy = dateRangeError
+	}
+	c.dateRangeSentinel = opts.DateRangeSentinel
+
+	c.floatPolicy = strings.ToLower(strings.TrimSpace(opts.NaNPolicy))
+	switch c.floatPolicy {
+	case "":
+		// MySQL/Oracle 不接受 NaN/Inf，未配置时默认报错并给出行列信息，避免驱动返回难以定位的错误
+		if c.dstDriver == "mysql" || c.dstDriver == "oracle" {
+			c.floatPolicy = nanPolicyError
+		}
+	case nanPolicyNull, nanPolicyClamp, nanPolicyError:
+	default:
+		return nil, fmt.Errorf("nan_policy 仅支持 null/clamp/error，当前为 %q", opts.NaNPolicy)
+	}
+
+	c.nulPolicy = strings.ToLower(strings.TrimSpace(opts.NulBytePolicy))
+	c.nulReplacement = opts.NulByteReplacement
+	switch c.nulPolicy {
+	case "":
+		c.nulPolicy = nulPolicyStrip
+	case nulPolicyStrip, nulPolicyReplace, nulPolicyKeep:
+	default:
+		return nil, fmt.Errorf("nul_byte_policy 仅支持 strip/replace/keep，当前为 %q", opts.NulBytePolicy)
+	}
+	if c.dstDriver != "postgres" && c.dstDriver != "postgresql" {
+		c.nulPolicy = nulPolicyKeep
+	}
+
+	if err := validateInvalidUTF8Policy(opts.InvalidUTF8Policy); err != nil {
+		return nil, err
+	}
+	c.invalidUTF8Policy = strings.ToLower(strings.TrimSpace(opts.InvalidUTF8Policy))
+	c.overflowPolicy = strings.ToLower(strings.TrimSpace(opts.StringOverflow))
+	switch c.overflowPolicy {
+	case "", overflowError, overflowTruncate, overflowTruncateMarker:
+	default:
+		return nil, fmt.Errorf("string_overflow 仅支持 error/truncate/truncate_with_marker，当前为 %q", opts.StringOverflow)
+	}
+	c.overflowMarker = opts.StringOverflowMarker
+	if c.overflowMarker == "" {
+		c.overflowMarker = "..."
+	}
+	c.overflowLog = opts.StringOverflowLog
+
+	c.numericPolicy = strings.ToLower(strings.TrimSpace(opts.NumericOverflow))
+	switch c.numericPolicy {
+	case "", numericError, numericRound, numericNull:
+	default:
+		return nil, fmt.Errorf("numeric_overflow 仅支持 error/round/null，当前为 %q", opts.NumericOverflow)
+	}
+
+	if err := validateEmptyStringPolicy(opts.EmptyStringPolicy, opts.EmptyStringValue); err != nil {
+		return nil, err
+	}
+	if err := validateOracleNullPolicy(opts.OracleNullPolicy); err != nil {
+		return nil, err
+	}
+
+	sourceEnc, err := lookupCharset(opts.SourceCharset)
+	if err != nil {
+		return nil, fmt.Errorf("源库 charset: %w", err)
+	}
+
+	// 目标列名 -> 字段映射配置（用于识别 target_type 指定为二进制的列）
+	targetCfg := make(map[string]columnMapping)
+	for _, m := range opts.Columns {
+		src := strings.TrimSpace(m.Source)
+		if src == "" {
+			continue
+		}
+		tgt := strings.TrimSpace(m.Target)
+		if tgt == "" {
+			tgt = src
+		}
+		targetCfg[tgt] = m
+	}
+
+	indexes := sourceIndexForInsertColumns(sourceCols, insertCols, opts)
+	c.columns = make([]valueColumn, len(insertCols))
+	for i, name := range insertCols {
+		col := valueColumn{Target: name}
+		if idx := indexes[i]; idx >= 0 {
+			col.Source = sourceCols[idx]
+			if idx < len(colTypes) && colTypes[idx] != nil {
+				col.DBType = strings.ToUpper(colTypes[idx].DatabaseTypeName())
+			}
+		}
+		col.Binary = isBinaryType(col.DBType)
+		col.LOB = isLargeObjectType(col.DBType)
+		col.Time = isTimeType(col.DBType)
+		col.Zoned = isZonedTimeType(col.DBType)
+		col.Padded = isFixedCharType(col.DBType, c.srcDriver)
+		if idx := indexes[i]; idx >= 0 && idx < len(colTypes) && colTypes[idx] != nil {
+			col.Float = isFloatScanType(colTypes[idx].ScanType())
+		}
+
+		// 预估目标列类型：字段映射 target_type 优先，其次自动建表的类型映射
+		if m, ok := targetCfg[name]; ok && strings.TrimSpace(m.TargetType) != "" {
+			col.TargetType = strings.ToUpper(strings.TrimSpace(m.TargetType))
+			col.Binary = isBinaryType(m.TargetType)
+		} else if opts.AutoCreate && indexes[i] >= 0 && indexes[i] < len(colTypes) {
+			col.TargetType = mapColumnType(colTypes[indexes[i]], dstDriver)
+		}
+		col.Boolean = isBooleanType(col.TargetType, c.dstDriver)
+		col.IntBool = isIntBoolTypeName(col.TargetType)
+		col.Precision, col.Scale = parseDecimalType(col.TargetType)
+		if c.dstDriver == "sqlserver" {
+			// 目标表已存在时由 applyTargetColumns 按实际列类型覆盖；sqldump 没有目标表，按预估的类型检查
+			col.MinTime, col.MaxTime, col.HasRange = sqlServerDateRange(col.TargetType)
+		}
+
+		// 字符集解码：字段映射的 charset 覆盖数据源的 charset，二进制列不解码
+		enc := sourceEnc
+		if m, ok := targetCfg[name]; ok && strings.TrimSpace(m.Charset) != "" {
+			if enc, err = lookupCharset(m.Charset); err != nil {
+				return nil, fmt.Errorf("列 %s 的 charset: %w", name, err)
+			}
+		}
+		if enc != nil && !col.Binary {
+			col.Decoder, col.Encoder = enc.NewDecoder(), enc.NewEncoder()
+		}
+
+		// Oracle 空字符串/NULL：字段映射的策略覆盖表级策略
+		emptyPolicy, emptyValue, nullPolicy := opts.EmptyStringPolicy, opts.EmptyStringValue, opts.OracleNullPolicy
+		if m, ok := targetCfg[name]; ok {
+			if strings.TrimSpace(m.EmptyStringPolicy) != "" {
+				emptyPolicy, emptyValue = m.EmptyStringPolicy, m.EmptyStringValue
+				if err := validateEmptyStringPolicy(emptyPolicy, emptyValue); err != nil {
+					return nil, fmt.Errorf("列 %s 的 %w", name, err)
+				}
+			}
+			if strings.TrimSpace(m.OracleNullPolicy) != "" {
+				nullPolicy = m.OracleNullPolicy
+				if err := validateOracleNullPolicy(nullPolicy); err != nil {
+					return nil, fmt.Errorf("列 %s 的 %w", name, err)
+				}
+			}
+			if m.Nullable != nil && !*m.Nullable {
+				col.NotNull = true
+			}
+		}
+		if !col.Binary && c.dstDriver == "oracle" {
+			col.EmptyPolicy = strings.ToLower(strings.TrimSpace(emptyPolicy))
+			col.EmptyValue = emptyValue
+		}
+		if c.srcDriver == "oracle" && c.dstDriver != "oracle" && isTextTypeName(col.DBType) {
+			col.NullPolicy = strings.ToLower(strings.TrimSpace(nullPolicy))
+		}
+		c.columns[i] = col
+	}
+	return c, nil
+}
+
+// applyTargetColumns 根据目标库已有表的列元数据补充各插入列的目标类型信息
+func (c *valueConverter) applyTargetColumns(target targetColumns) {
+	for i := range c.columns {
+		col := &c.columns[i]
+		info, ok := target.lookup(col.Target)
+		if !ok {
+			continue
+		}
+		col.TargetType = info.DataType
+		col.Boolean = isBooleanTargetColumn(info, c.dstDriver)
+		col.IntBool = col.Boolean && isIntBoolTargetColumn(info)
+		col.NotNull = !info.Nullable
+		if strings.Contains(info.DataType, "CHAR") && info.CharLen > 0 {
+			col.MaxChars = int(info.CharLen)
+			if c.dstDriver == "sqlserver" {
+				col.LengthUnit = sqlServerLengthUnit(info.DataType)
+			}
+		}
+		if isDecimalTypeName(info.DataType) && info.Precision > 0 {
+			col.Precision, col.Scale = int(info.Precision), int(info.Scale)
+		}
+		if c.dstDriver == "sqlserver" {
+			col.MinTime, col.MaxTime, col.HasRange = sqlServerDateRange(info.DataType)
+		}
+	}
+}
+
+// setKeyColumns 设置目标表主键列，用于在日志与错误中标识具体行
+func (c *valueConverter) setKeyColumns(keys []string) {
+	c.keyIndexes = nil
+	c.keyNames = nil
+	for _, k := range keys {
+		for i := range c.columns {
+			if strings.EqualFold(c.columns[i].Target, k) {
+				c.keyIndexes = append(c.keyIndexes, i)
+				c.keyNames = append(c.keyNames, c.columns[i].Target)
+				break
+			}
+		}
+	}
+}
+
+// rowKey 返回当前行的标识（主键值；无主键时为行号）
+func (c *valueConverter) rowKey(args []interface{}) string {
+	return c.rowRef(int(c.rowNum), args).String()
+}
+
+// rowRef 返回第 n 行（读取顺序中的序号）的结构化标识：序号加目标表主键列的值
+func (c *valueConverter) rowRef(n int, args []interface{}) RowRef {
+	ref := RowRef{Ordinal: int64(n)}
+	for i, idx := range c.keyIndexes {
+		if idx >= len(args) {
+			continue
+		}
+		v := args[idx]
+		if b, ok := v.([]byte); ok {
+			// 扫描缓冲区在下一行复用
+			v = append([]byte(nil), b...)
+		}
+		ref.Key = append(ref.Key, KeyValue{Column: c.keyNames[i], Value: v})
+	}
+	return ref
+}
+
+// isBinary 返回第 i 个插入列是否为二进制列
+func (c *valueConverter) isBinary(i int) bool {
+	return i >= 0 && i < len(c.columns) && c.columns[i].Binary
+}
+
+// isDecimal 第 i 个插入列的源列是否为定点数（驱动通常以文本返回）
+func (c *valueConverter) isDecimal(i int) bool {
+	return i >= 0 && i < len(c.columns) && isDecimalTypeName(c.columns[i].DBType)
+}
+
+// convertRow 就地转换一行参数（顺序与插入列一致）
+// 二进制列保持 []byte；非二进制列中驱动返回的 []byte 文本转为 string，
+// 避免 lib/pq 等驱动把文本按 bytea 编码写入。出错时删除本行已溢写的临时文件
+func (c *valueConverter) convertRow(args []interface{}) error {
+	if err := c.convertValues(args); err != nil {
+		discardSpilled(args)
+		return err
+	}
+	return nil
+}
+
+// convertValues 逐列转换一行参数
+func (c *valueConverter) convertValues(args []interface{}) error {
+	c.rowNum++
+	nulRow := false
+	truncatedRow := false
+	numericRow := false
+	for i, v := range args {
+		if i >= len(c.columns) {
+			continue
+		}
+		col := &c.columns[i]
+
+		// Oracle 源的 NULL 可能原本是空字符串，按 oracle_null_policy 还原
+		if v == nil {
+			if col.NullPolicy == oracleNullEmpty || (col.NullPolicy == oracleNullNotNull && col.NotNull) {
+				args[i] = ""
+				c.count("Oracle NULL 转为空字符串", 1)
+			}
+			continue
+		}
+
+		if col.Time || col.HasRange {
+			out, err := c.convertTime(col, v)
+			if err != nil {
+				return err
+			}
+			args[i] = out
+			if out == nil {
+				continue
+			}
+			v = out
+		}
+
+		if col.Float && c.floatPolicy != "" {
+			out, err := c.checkFloat(col, v)
+			if err != nil {
+				return err
+			}
+			args[i] = out
+			if out == nil {
+				continue
+			}
+			v = out
+		}
+
+		if col.Precision > 0 && c.numericPolicy != "" {
+			out, changed, err := c.checkDecimal(col, v, args)
+			if err != nil {
+				return err
+			}
+			if changed {
+				args[i] = out
+				numericRow = true
+				if out == nil {
+					continue
+				}
+				v = out
+			}
+		}
+
+		if col.Boolean {
+			b, err := c.toTargetBool(col, v)
+			if err != nil {
+				return err
+			}
+			args[i] = b
+			continue
+		}
+
+		if col.Binary {
+			if s, ok := v.(string); ok {
+				args[i] = []byte(s)
+			}
+		} else if b, ok := v.([]byte); ok {
+			if col.Decoder != nil {
+				str, valid, err := decodeText(col.Decoder, col.Encoder, b)
+				if err != nil {
+					return fmt.Errorf("列 %s 字符集解码失败: %w", col.Target, err)
+				}
+				// 解码器已把非法字节替换为 U+FFFD，之后的 UTF-8 检查看不出来，在这里按 invalid_utf8_policy 处理
+				if !valid && c.invalidUTF8Policy != "" {
+					if str, err = repairDecoded(str, c.invalidUTF8Policy); err != nil {
+						return fmt.Errorf("列 %s %w", col.Target, err)
+					}
+					c.count("非法 UTF-8 修复", 1)
+				}
+				args[i] = str
+				c.count("字符集转换为 UTF-8", 1)
+			} else {
+				args[i] = string(b)
+			}
+		}
+
+		// 定长 CHAR 列去除尾部空格，需在长度检查之前（二进制列不处理）
+		if c.trimCharPadding && col.Padded && !col.Binary {
+			if str, ok := args[i].(string); ok && strings.HasSuffix(str, " ") {
+				args[i] = strings.TrimRight(str, " ")
+				c.count("CHAR 列去除尾部空格", 1)
+			}
+		}
+
+		// 非法 UTF-8 修复（二进制列不处理）
+		if !col.Binary && c.invalidUTF8Policy != "" {
+			if str, ok := args[i].(string); ok {
+				fixed, repaired, err := repairUTF8(str, c.invalidUTF8Policy)
+				if err != nil {
+					return fmt.Errorf("列 %s %w", col.Target, err)
+				}
+				if repaired {
+					args[i] = fixed
+					c.count("非法 UTF-8 修复", 1)
+				}
+			}
+		}
+
+		// Postgres 文本不允许包含 NUL 字节（二进制列不处理）
+		if !col.Binary && c.nulPolicy != nulPolicyKeep {
+			if str, ok := args[i].(string); ok && strings.IndexByte(str, 0) >= 0 {
+				args[i] = c.stripNul(str)
+				nulRow = true
+			}
+		}
+
+		// Oracle 会把空字符串存为 NULL，按 empty_string_policy 替换
+		if col.EmptyPolicy != "" && col.EmptyPolicy != emptyStringNull {
+			if str, ok := args[i].(string); ok && str == "" {
+				if col.EmptyPolicy == emptyStringSpace {
+					args[i] = " "
+				} else {
+					args[i] = col.EmptyValue
+				}
+				c.count("空字符串替换（目标为 Oracle）", 1)
+			}
+		}
+
+		// 字符串超出目标列长度（按目标列的计量单位计算，通常为字符而非字节）；
+		// 字节数不小于任何一种计量方式的长度，先按字节数快速排除
+		if col.MaxChars > 0 && c.overflowPolicy != "" {
+			if str, ok := args[i].(string); ok && len(str) > col.MaxChars && textLength(str, col.LengthUnit) > col.MaxChars {
+				if c.overflowPolicy == overflowError {
+					return fmt.Errorf("列 %s 的值长度 %d 超过目标列长度 %d（%s）",
+						col.Target, textLength(str, col.LengthUnit), col.MaxChars, c.rowKey(args))
+				}
+				args[i] = c.truncateString(str, col.MaxChars, col.LengthUnit)
+				truncatedRow = true
+				if c.overflowLog {
+					c.log.infof("字符串超长已截断: 列 %s, %s\n", col.Target, c.rowKey(args))
+				}
+			}
+		}
+
+		// 超过阈值的大字段溢写到临时文件，写入时按块读取
+		if c.lobSpillThreshold > 0 && col.LOB && valueSize(args[i]) > c.lobSpillThreshold {
+			spilled, err := spillValue(args[i], col.Binary)
+			if err != nil {
+				return fmt.Errorf("列 %s: %w", col.Target, err)
+			}
+			args[i] = spilled
+			c.spills = append(c.spills, spilled)
+			if !c.spillLogged {
+				c.spillLogged = true
+				c.log.infof("列 %s 的值超过 lob_spill_threshold（%d 字节），溢写到临时文件；读取时源库驱动仍会把整个值读入内存一次\n", col.Target, c.lobSpillThreshold)
+			}
+			c.count("大字段溢写到临时文件", 1)
+		}
+	}
+	if nulRow {
+		c.count("含 NUL 字节的行（已清理）", 1)
+	}
+	if truncatedRow {
+		c.count("字符串超长截断的行", 1)
+	}
+	if numericRow {
+		c.count("数值超出目标精度的行", 1)
+	}
+	return nil
+}
+
+// removeSpilled 删除已溢写的临时文件（所在的行已提交，或表复制结束）
+func (c *valueConverter) removeSpilled() {
+	for _, s := range c.spills {
+		_ = os.Remove(s.path)
+	}
+	c.spills = c.spills[:0]
+}
+
+// 目标字符列长度的计量单位
+const (
+	lengthRunes = iota // 字符（rune）：MySQL、Postgres、Oracle、SQLite
+	lengthUTF16        // UTF-16 码元：SQL Server NVARCHAR/NCHAR，增补平面字符（如 emoji）占 2 个
+	lengthBytes        // 字节：SQL Server VARCHAR/CHAR
+)
+
+// sqlServerLengthUnit SQL Server 字符列长度的计量单位：NVARCHAR/NCHAR 按字符（UTF-16 码元），VARCHAR/CHAR 按字节
+func sqlServerLengthUnit(dataType string) int {
+	if strings.HasPrefix(strings.ToUpper(dataType), "N") {
+		return lengthUTF16
+	}
+	return lengthBytes
+}
+
+// runeLength 单个字符按 unit 计量的长度
+func runeLength(r rune, unit int) int {
+	switch unit {
+	case lengthBytes:
+		return utf8.RuneLen(r)
+	case lengthUTF16:
+		if r >= 0x10000 {
+			return 2
+		}
+	}
+	return 1
+}
+
+// textLength 字符串按 unit 计量的长度
+func textLength(s string, unit int) int {
+	switch unit {
+	case lengthBytes:
+		return len(s)
+	case lengthRunes:
+		return utf8.RuneCountInString(s)
+	}
+	n := 0
+	for _, r := range s {
+		n += runeLength(r, unit)
+	}
+	return n
+}
+
+// truncateText 按字符边界截断 s，使其按 unit 计量的长度不超过 limit
+func truncateText(s string, limit, unit int) string {
+	n := 0
+	for i, r := range s {
+		if n += runeLength(r, unit); n > limit {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// truncateString 按字符边界截断字符串，长度按目标列的计量单位计算；
+// truncate_with_marker 时以标记结尾且总长度不超过 maxChars
+func (c *valueConverter) truncateString(s string, maxChars, unit int) string {
+	keep := maxChars
+	marker := ""
+	if c.overflowPolicy == overflowTruncateMarker {
+		marker = c.overflowMarker
+		keep = maxChars - textLength(marker, unit)
+		if keep < 0 {
+			keep = 0
+			marker = truncateText(marker, maxChars, unit)
+		}
+	}
+	return truncateText(s, keep, unit) + marker
+}
+
+// stripNul 按 nul_byte_policy 移除或替换字符串中的 NUL 字节，首次出现时打印警告
+func (c *valueConverter) stripNul(s string) string {
+	if !c.nulWarned {
+		c.nulWarned = true
+		c.log.warnf("警告：字符串中包含 NUL 字节（0x00），Postgres 不支持，将按 nul_byte_policy=%s 处理\n", c.nulPolicy)
+	}
+	if c.nulPolicy == nulPolicyReplace {
+		return strings.ReplaceAll(s, "\x00", c.nulReplacement)
+	}
+	return strings.ReplaceAll(s, "\x00", "")
+}
+
+// convertTime 处理时间列：零值日期替换 -> 时区规范化 -> 目标列日期范围检查
+func (c *valueConverter) convertTime(col *valueColumn, v interface{}) (interface{}, error) {
+	// 零值日期（0000-00-00）按策略替换，需在时区解析之前处理
+	if c.zeroDatePolicy != "" && isZeroDate(v) {
+		replaced, err := c.replaceZeroDate(col)
+		if err != nil {
+			return nil, err
+		}
+		c.count("零值日期替换", 1)
+		return replaced, nil
+	}
+
+	// 时间列按源时区解析为 time.Time（兼容 string/[]byte/time.Time 三种形式）
+	if c.times != nil {
+		if t, ok := c.times.normalize(v, col.Zoned); ok {
+			v = t
+			c.count("时间值按时区规范化", 1)
+		} else {
+			c.count("时间值无法解析（保持原值）", 1)
+		}
+	}
+
+	if !col.HasRange {
+		return v, nil
+	}
+	t, ok := asTime(v)
+	if !ok {
+		return v, nil
+	}
+	wall := wallClock(t)
+	if !wall.Before(col.MinTime) && !wall.After(col.MaxTime) {
+		return v, nil
+	}
+	switch c.dateRangePolicy {
+	case dateRangeClamp:
+		c.count("日期超出目标列范围（截断到边界）", 1)
+		if wall.Before(col.MinTime) {
+			return col.MinTime, nil
+		}
+		return col.MaxTime, nil
+	case dateRangeNull:
+		c.count("日期超出目标列范围（置为 NULL）", 1)
+		return nil, nil
+	case dateRangeSentinel:
+		c.count("日期超出目标列范围（替换为哨兵值）", 1)
+		return c.dateRangeSentinel, nil
+	default:
+		return nil, fmt.Errorf("列 %s 的值 %s 超出目标列类型 %s 的范围 [%s, %s]",
+			col.Target, wall.Format("2006-01-02 15:04:05"), col.TargetType,
+			col.MinTime.Format("2006-01-02"), col.MaxTime.Format("2006-01-02"))
+	}
+}
+
+// checkDecimal 检查数值能否被目标列 DECIMAL(p,s) 精确表示；changed=true 表示按策略替换了值
+// - 小数位超过 s：round 策略四舍五入到 s 位，null 策略置空，error 策略报错
+// - 整数位超过 p-s：无法通过舍入修复，round/error 策略报错，null 策略置空
+func (c *valueConverter) checkDecimal(col *valueColumn, v interface{}, args []interface{}) (interface{}, bool, error) {
+	r, ok := toRat(v)
+	if !ok {
+		return v, false, nil
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(col.Scale)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	needsRound := !scaled.IsInt()
+
+	// 四舍五入（远离零）到 s 位小数后的整数表示
+	rounded := roundRat(scaled)
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(col.Precision)), nil)
+	overflow := new(big.Int).Abs(rounded).Cmp(limit) >= 0
+
+	if !needsRound && !overflow {
+		return v, false, nil
+	}
+	if c.numericPolicy == numericNull {
+		return nil, true, nil
+	}
+	if overflow || c.numericPolicy == numericError {
+		return nil, false, fmt.Errorf("列 %s 的值 %s 无法用目标类型 DECIMAL(%d,%d) 表示（%s）",
+			col.Target, r.FloatString(col.Scale+4), col.Precision, col.Scale, c.rowKey(args))
+	}
+	return new(big.Rat).SetFrac(rounded, scale).FloatString(col.Scale), true, nil
+}
+
+// toTargetBool 将 bool/整数/"0"/"1"/"t"/"f"/"true"/"false" 等值统一为目标驱动需要的布尔表示：
+// postgres/sqlserver 使用 bool，mysql/oracle/sqlite 使用 0/1 整数
+func (c *valueConverter) toTargetBool(col *valueColumn, v interface{}) (interface{}, error) {
+	b, ok := parseBoolValue(v)
+	if !ok {
+		return nil, fmt.Errorf("列 %s 的值 %v 无法转换为布尔值", col.Target, v)
+	}
+	if col.IntBool && !isZeroOrOne(v) {
+		// TINYINT(1)/NUMBER(1) 仍是整数列，2、-1 等值按非零转成 1 会丢失原值
+		return nil, fmt.Errorf("列 %s 为 %s，值 %v 不是 0 或 1", col.Target, col.TargetType, v)
+	}
+	switch c.dstDriver {
+	case "postgres", "postgresql", "sqlserver":
+		return b, nil
+	default:
+		if b {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+}
+
+// checkFloat 按 nan_policy 处理 NaN/±Inf：null 置空；clamp 将 ±Inf 截断为最大/最小有限值（NaN 无法截断，置为 NULL）；error 报错
+func (c *valueConverter) checkFloat(col *valueColumn, v interface{}) (interface{}, error) {
+	var f float64
+	switch x := v.(type) {
+	case float64:
+		f = x
+	case float32:
+		f = float64(x)
+	default:
+		return v, nil
+	}
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return v, nil
+	}
+	switch c.floatPolicy {
+	case nanPolicyNull:
+		c.count("NaN/Inf 置为 NULL", 1)
+		return nil, nil
+	case nanPolicyClamp:
+		if math.IsInf(f, 1) {
+			c.count("Inf 截断为最大有限值", 1)
+			return math.MaxFloat64, nil
+		}
+		if math.IsInf(f, -1) {
+			c.count("Inf 截断为最小有限值", 1)
+			return -math.MaxFloat64, nil
+		}
+		c.count("NaN/Inf 置为 NULL", 1)
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("列 %s 的值为 %v，目标库不支持（nan_policy=error）", col.Target, f)
+	}
+}
+
+// replaceZeroDate 按 zero_date_policy 返回零值日期的替换值
+func (c *valueConverter) replaceZeroDate(col *valueColumn) (interface{}, error) {
+	switch c.zeroDatePolicy {
+	case zeroDateNull:
+		return nil, nil
+	case zeroDateMinDate:
+		return minDateFor(c.dstDriver), nil
+	case zeroDateCustom:
+		return c.zeroDateValue, nil
+	default:
+		return nil, fmt.Errorf("列 %s 的值为零值日期 0000-00-00（zero_date_policy=error）", col.Target)
+	}
+}
+
+// count 累加某类转换的计数
+func (c *valueConverter) count(name string, n int64) {
+	if _, ok := c.stats[name]; !ok {
+		c.statOrder = append(c.statOrder, name)
+	}
+	c.stats[name] += n
+}
+
+// logStats 打印本表的值转换统计（仅打印发生过的转换）
+func (c *valueConverter) logStats() {
+	if c == nil || len(c.statOrder) == 0 {
+		return
+	}
+	c.log.infof("值转换统计:\n")
+	for _, name := range c.statOrder {
+		c.log.infof("  %s: %d\n", name, c.stats[name])
+	}
+}
+
+// hexValue 将二进制值编码为十六进制文本（LOAD DATA 中配合 UNHEX 使用）
+func hexValue(v interface{}) string {
+	switch b := v.(type) {
+	case []byte:
+		return hex.EncodeToString(b)
+	case string:
+		return hex.EncodeToString([]byte(b))
+	default:
+		return ""
+	}
+}
+
+// sourceIndexForInsertColumns 计算每个插入列对应的源列下标（找不到为 -1）
+// 解析规则与 reorderArgs 一致：优先字段映射，其次同名列
+func sourceIndexForInsertColumns(sourceCols, insertCols []string, opts copyTableOptions) []int {
+	sourceIndex := make(map[string]int, len(sourceCols))
+	for i, name := range sourceCols {
+		sourceIndex[name] = i
+	}
+
+	targetToSource := make(map[string]string)
+	for _, c := range opts.Columns {
+		src := strings.TrimSpace(c.Source)
+		if src == "" {
+			continue
+		}
+		tgt := strings.TrimSpace(c.Target)
+		if tgt == "" {
+			tgt = src
+		}
+		targetToSource[tgt] = src
+	}
+
+	out := make([]int, len(insertCols))
+	for i, targetCol := range insertCols {
+		out[i] = -1
+		if srcCol, ok := targetToSource[targetCol]; ok {
+			if idx, ok2 := sourceIndex[srcCol]; ok2 {
+				out[i] = idx
+				continue
+			}
+		}
+		if idx, ok := sourceIndex[targetCol]; ok {
+			out[i] = idx
+		}
+	}
+	return out
+}
+
+// NaN/±Inf 浮点值处理策略（nan_policy）
+const (
+	nanPolicyNull  = "null"
+	nanPolicyClamp = "clamp"
+	nanPolicyError = "error"
+)
+
+// 字符串超长处理策略（string_overflow）
+const (
+	overflowError          = "error"                // 报错，指出列与行
+	overflowTruncate       = "truncate"             // 按字符截断
+	overflowTruncateMarker = "truncate_with_marker" // 截断并以标记（默认 ...）结尾
+)
+
+// 目标为 Oracle 时空字符串的处理策略（empty_string_policy）
+const (
+	emptyStringNull     = "null"     // 不处理，由 Oracle 存为 NULL（默认）
+	emptyStringSpace    = "space"    // 替换为单个空格
+	emptyStringSentinel = "sentinel" // 替换为 empty_string_value
+)
+
+// validateEmptyStringPolicy 校验空字符串处理策略配置
+func validateEmptyStringPolicy(policy, value string) error {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "", emptyStringNull, emptyStringSpace:
+		return nil
+	case emptyStringSentinel:
+		if value == "" {
+			return fmt.Errorf("empty_string_policy 为 sentinel 时必须配置非空的 empty_string_value")
+		}
+		return nil
+	default:
+		return fmt.Errorf("empty_string_policy 仅支持 null/space/sentinel，当前为 %q", policy)
+	}
+}
+
+// 源为 Oracle 时文本列 NULL 的处理策略（oracle_null_policy）
+const (
+	oracleNullKeep    = "null"     // 保持 NULL（默认）
+	oracleNullEmpty   = "empty"    // 所有文本列的 NULL 转为空字符串
+	oracleNullNotNull = "not_null" // 仅目标列为 NOT NULL 时转为空字符串
+)
+
+// validateOracleNullPolicy 校验 Oracle NULL 处理策略配置
+func validateOracleNullPolicy(policy string) error {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "", oracleNullKeep, oracleNullEmpty, oracleNullNotNull:
+		return nil
+	default:
+		return fmt.Errorf("oracle_null_policy 仅支持 null/empty/not_null，当前为 %q", policy)
+	}
+}
+
+// isFixedCharType 判断源列是否为定长字符类型（CHAR/NCHAR/BPCHAR）
+// go-ora 对 VARCHAR2/NVARCHAR2 报告的类型名也是 NCHAR，因此源为 Oracle 时只认 CHAR
+func isFixedCharType(dbType, srcDriver string) bool {
+	base := strings.ToUpper(strings.TrimSpace(dbType))
+	if i := strings.Index(base, "("); i >= 0 {
+		base = strings.TrimSpace(base[:i])
+	}
+	switch base {
+	case "CHAR", "BPCHAR", "CHARACTER":
+		return true
+	case "NCHAR", "NATIONAL CHARACTER":
+		return srcDriver != "oracle"
+	}
+	return false
+}
+
+// isTextTypeName 判断源列类型是否为文本类型（CHAR/VARCHAR/NCHAR/CLOB/LONG 等）
+func isTextTypeName(dbType string) bool {
+	dbType = strings.ToUpper(strings.TrimSpace(dbType))
+	return strings.Contains(dbType, "CHAR") || strings.Contains(dbType, "CLOB") ||
+		strings.Contains(dbType, "TEXT") || dbType == "LONG"
+}
+
+// 字符串 NUL 字节处理策略（nul_byte_policy）
+const (
+	nulPolicyStrip   = "strip"   // 移除（目标为 postgres 时的默认值）
+	nulPolicyReplace = "replace" // 替换为 nul_byte_replacement
+	nulPolicyKeep    = "keep"    // 不处理
+)
+
+// isFloatScanType 判断驱动报告的扫描类型是否为浮点
+func isFloatScanType(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	switch t {
+	case reflect.TypeOf(float64(0)), reflect.TypeOf(float32(0)), reflect.TypeOf(sql.NullFloat64{}):
+		return true
+	}
+	return false
+}
+
+// isBooleanTypeName 判断类型声明是否为布尔语义：BOOLEAN/BOOL/BIT(1)/TINYINT(1)/NUMBER(1)。
+// 不带长度的 BIT 在 MySQL/Postgres 中可能是多位的位串，不按布尔处理
+func isBooleanTypeName(typeName string) bool {
+	switch normalizeTypeName(typeName) {
+	case "BOOLEAN", "BOOL", "BIT(1)":
+		return true
+	}
+	return isIntBoolTypeName(typeName)
+}
+
+// isIntBoolTypeName 判断类型声明是否为以整数保存布尔值的 TINYINT(1)/NUMBER(1)
+func isIntBoolTypeName(typeName string) bool {
+	switch normalizeTypeName(typeName) {
+	case "TINYINT(1)", "NUMBER(1)", "NUMBER(1,0)":
+		return true
+	}
+	return false
+}
+
+// isBooleanType 按目标驱动判断类型声明是否为布尔语义：SQL Server 的 BIT 没有长度，只能保存 0/1
+func isBooleanType(typeName, driver string) bool {
+	if driver == "sqlserver" && normalizeTypeName(typeName) == "BIT" {
+		return true
+	}
+	return isBooleanTypeName(typeName)
+}
+
+func normalizeTypeName(typeName string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(typeName), " ", ""))
+}
+
+// isBooleanTargetColumn 根据目标库目录的列信息判断是否为布尔语义列。
+// MySQL 目录的 DataType 对任意长度的位串都是 BIT，按含长度的 FullType 判断
+func isBooleanTargetColumn(info targetColumnInfo, driver string) bool {
+	if isBooleanType(info.FullType, driver) || isBooleanTypeName(info.DataType) {
+		return true
+	}
+	return isIntBoolTargetColumn(info)
+}
+
+// isIntBoolTargetColumn 目标列是否为 TINYINT(1)/NUMBER(1)
+func isIntBoolTargetColumn(info targetColumnInfo) bool {
+	if isIntBoolTypeName(info.FullType) {
+		return true
+	}
+	return info.DataType == "NUMBER" && info.Precision == 1 && info.Scale == 0
+}
+
+// isZeroOrOne 数值是否为 0 或 1；bool 与文本形式的布尔值不是数值，不受限制
+func isZeroOrOne(v interface{}) bool {
+	switch x := v.(type) {
+	case int64:
+		return x == 0 || x == 1
+	case int32:
+		return x == 0 || x == 1
+	case int:
+		return x == 0 || x == 1
+	case uint8:
+		return x == 0 || x == 1
+	case float64:
+		return x == 0 || x == 1
+	}
+	return true
+}
+
+// parseBoolValue 解析各驱动返回的布尔值表示
+func parseBoolValue(v interface{}) (bool, bool) {
+	switch x := v.(type) {
+	case bool:
+		return x, true
+	case int64:
+		return x != 0, true
+	case int32:
+		return x != 0, true
+	case int:
+		return x != 0, true
+	case uint8:
+		return x != 0, true
+	case float64:
+		return x != 0, true
+	case []byte:
+		// MySQL BIT(1) 以单字节 0x00/0x01 返回
+		if len(x) == 1 && (x[0] == 0 || x[0] == 1) {
+			return x[0] == 1, true
+		}
+		return parseBoolString(string(x))
+	case string:
+		return parseBoolString(x)
+	}
+	return false, false
+}
+
+func parseBoolString(s string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, true
+	case "0", "f", "false", "n", "no", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// 数值超出目标精度的处理策略（numeric_overflow）
+const (
+	numericError = "error" // 报错，指出列与行
+	numericRound = "round" // 小数位四舍五入到目标 scale
+	numericNull  = "null"  // 置为 NULL
+)
+
+// isDecimalTypeName 判断是否为定点数类型
+func isDecimalTypeName(dataType string) bool {
+	switch strings.ToUpper(strings.TrimSpace(dataType)) {
+	case "DECIMAL", "NUMERIC", "NUMBER":
+		return true
+	}
+	return false
+}
+
+// parseDecimalType 从类型声明（如 DECIMAL(18,6)、NUMBER(10)）解析精度与小数位，非定点数返回 0, 0
+func parseDecimalType(typeName string) (int, int) {
+	t := strings.ToUpper(strings.TrimSpace(typeName))
+	base := t
+	if i := strings.Index(base, "("); i >= 0 {
+		base = strings.TrimSpace(base[:i])
+	}
+	if !isDecimalTypeName(base) {
+		return 0, 0
+	}
+	m := typeArgsRe.FindStringSubmatch(t)
+	if m == nil {
+		return 0, 0
+	}
+	p, _ := strconv.Atoi(m[1])
+	s := 0
+	if m[2] != "" {
+		s, _ = strconv.Atoi(m[2])
+	}
+	return p, s
+}
+
+// toRat 将整数/浮点/数值文本转换为精确有理数，其他类型返回 false
+func toRat(v interface{}) (*big.Rat, bool) {
+	switch x := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(x), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(x)), true
+	case int:
+		return new(big.Rat).SetInt64(int64(x)), true
+	case uint64:
+		return new(big.Rat).SetUint64(x), true
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, false
+		}
+		// 按最短十进制表示解析，避免二进制浮点误差被误判为超出小数位
+		return new(big.Rat).SetString(strconv.FormatFloat(x, 'g', -1, 64))
+	case float32:
+		return new(big.Rat).SetString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	case []byte:
+		return new(big.Rat).SetString(strings.TrimSpace(string(x)))
+	case string:
+		return new(big.Rat).SetString(strings.TrimSpace(x))
+	}
+	return nil, false
+}
+
+// roundRat 将有理数四舍五入（远离零）为整数
+func roundRat(r *big.Rat) *big.Int {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(m, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if neg {
+		q.Neg(q)
+	}
+	return q
+}
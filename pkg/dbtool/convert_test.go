@@ -0,0 +1,243 @@
+package dbtool
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newBoolConverter 为目标列 flag（target_type 为 targetType）构建写入 dst 的转换器
+func newBoolConverter(t *testing.T, dst, targetType string) *valueConverter {
+	t.Helper()
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "src.db"), "CREATE TABLE b (flag TEXT)")
+	rows, err := db.Query("SELECT flag FROM b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	colTypes, err := rows.ColumnTypes()
+	rows.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv, err := newValueConverter(colTypes, []string{"flag"}, []string{"flag"}, "sqlite3", dst, copyTableOptions{
+		Table:   "b",
+		Columns: []columnMapping{{Source: "flag", TargetType: targetType}},
+		Log:     newTableLogger("b"),
+	})
+	if err != nil {
+		t.Fatalf("%s: %v", dst, err)
+	}
+	return conv
+}
+
+func TestBoolConversion(t *testing.T) {
+	inputs := []struct {
+		in   interface{}
+		want bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"TRUE", true},
+		{" False ", false},
+		{"1", true},
+		{"0", false},
+		{"t", true},
+		{"f", false},
+		{[]byte("true"), true},
+		{[]byte("0"), false},
+		{[]byte{1}, true}, // MySQL BIT(1)
+		{[]byte{0}, false},
+		{true, true},
+		{false, false},
+		{int64(1), true},
+		{int64(0), false},
+	}
+	for _, dst := range []string{"postgres", "sqlserver", "mysql", "oracle", "sqlite3"} {
+		conv := newBoolConverter(t, dst, "BOOLEAN")
+		for _, c := range inputs {
+			args := []interface{}{c.in}
+			if err := conv.convertRow(args); err != nil {
+				t.Fatalf("%s: %#v: %v", dst, c.in, err)
+			}
+			var want interface{} = c.want
+			if dst != "postgres" && dst != "sqlserver" {
+				want = int64(0)
+				if c.want {
+					want = int64(1)
+				}
+			}
+			if args[0] != want {
+				t.Errorf("%s: %#v 转换为 %#v，期望 %#v", dst, c.in, args[0], want)
+			}
+		}
+
+		// NULL 原样保留
+		args := []interface{}{nil}
+		if err := conv.convertRow(args); err != nil {
+			t.Fatalf("%s: NULL: %v", dst, err)
+		}
+		if args[0] != nil {
+			t.Errorf("%s: NULL 转换为 %#v", dst, args[0])
+		}
+
+		// 无法识别的值报错并指出列名
+		if err := conv.convertRow([]interface{}{"maybe"}); err == nil {
+			t.Errorf("%s: 值 maybe 应转换失败", dst)
+		}
+	}
+}
+
+func TestBooleanTargetColumn(t *testing.T) {
+	cases := []struct {
+		driver string
+		info   targetColumnInfo
+		want   bool
+	}{
+		{"postgres", targetColumnInfo{DataType: "BOOLEAN", FullType: "BOOLEAN"}, true},
+		{"sqlserver", targetColumnInfo{DataType: "BIT", FullType: "BIT"}, true},
+		{"mysql", targetColumnInfo{DataType: "BIT", FullType: "BIT(1)"}, true},
+		{"mysql", targetColumnInfo{DataType: "TINYINT", FullType: "TINYINT(1)"}, true},
+		{"oracle", targetColumnInfo{DataType: "NUMBER", FullType: "NUMBER", Precision: 1}, true},
+		// 多位的位串不是布尔列
+		{"mysql", targetColumnInfo{DataType: "BIT", FullType: "BIT(8)"}, false},
+		{"postgres", targetColumnInfo{DataType: "BIT", FullType: "BIT"}, false},
+		{"mysql", targetColumnInfo{DataType: "TINYINT", FullType: "TINYINT(4)"}, false},
+		{"oracle", targetColumnInfo{DataType: "NUMBER", FullType: "NUMBER", Precision: 10}, false},
+		{"mysql", targetColumnInfo{DataType: "VARCHAR", FullType: "VARCHAR(1)"}, false},
+	}
+	for _, c := range cases {
+		if got := isBooleanTargetColumn(c.info, c.driver); got != c.want {
+			t.Errorf("%s %s: %v，期望 %v", c.driver, c.info.FullType, got, c.want)
+		}
+	}
+}
+
+func TestIntBoolAcceptsOnlyZeroOrOne(t *testing.T) {
+	for _, c := range []struct{ dst, targetType string }{{"mysql", "TINYINT(1)"}, {"oracle", "NUMBER(1)"}} {
+		conv := newBoolConverter(t, c.dst, c.targetType)
+		for _, in := range []interface{}{int64(0), int64(1), "true", false} {
+			if err := conv.convertRow([]interface{}{in}); err != nil {
+				t.Errorf("%s: %#v: %v", c.targetType, in, err)
+			}
+		}
+		for _, in := range []interface{}{int64(2), int64(-1), 0.5, "2"} {
+			if err := conv.convertRow([]interface{}{in}); err == nil {
+				t.Errorf("%s: 值 %#v 不是 0 或 1，应转换失败", c.targetType, in)
+			}
+		}
+	}
+	// BOOLEAN 列按非零为真
+	conv := newBoolConverter(t, "postgres", "BOOLEAN")
+	args := []interface{}{int64(2)}
+	if err := conv.convertRow(args); err != nil || args[0] != true {
+		t.Errorf("BOOLEAN: 2 转换为 %#v（%v），期望 true", args[0], err)
+	}
+}
+
+func TestCharsetInvalidBytesPolicy(t *testing.T) {
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "src.db"), "CREATE TABLE c (name TEXT)")
+	rows, err := db.Query("SELECT name FROM c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	colTypes, err := rows.ColumnTypes()
+	rows.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// GB18030 中 "中" 为 D6 D0，U+FFFD 为 84 31 A4 37；单独的 0xFF 是非法字节
+	valid, withFFFD, invalid := []byte{0xD6, 0xD0}, []byte{0xD6, 0xD0, 0x84, 0x31, 0xA4, 0x37}, []byte{0xD6, 0xD0, 0xFF}
+	cases := []struct {
+		policy string
+		in     []byte
+		want   string
+		fail   bool
+	}{
+		{"error", valid, "中", false},
+		{"error", withFFFD, "中�", false},
+		{"error", invalid, "", true},
+		{"strip", invalid, "中", false},
+		{"replace", invalid, "中�", false},
+	}
+	for _, c := range cases {
+		conv, err := newValueConverter(colTypes, []string{"name"}, []string{"name"}, "sqlite3", "postgres", copyTableOptions{
+			Table:             "c",
+			Columns:           []columnMapping{{Source: "name", Charset: "gb18030"}},
+			InvalidUTF8Policy: c.policy,
+			Log:               newTableLogger("c"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		args := []interface{}{c.in}
+		err = conv.convertRow(args)
+		if c.fail {
+			if err == nil {
+				t.Errorf("%s %x: 非法字节应报错，得到 %q", c.policy, c.in, args[0])
+			}
+			continue
+		}
+		if err != nil || args[0] != c.want {
+			t.Errorf("%s %x: %q %v，期望 %q", c.policy, c.in, args[0], err, c.want)
+		}
+	}
+}
+
+func TestTimeNormalizerZonedText(t *testing.T) {
+	n, err := newTimeNormalizer("Asia/Shanghai", "utc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 3, 1, 2, 30, 0, 0, time.UTC)
+	// 带时区偏移的文本按自身偏移解析，不计为无法解析
+	for _, s := range []string{
+		"2024-03-01T10:30:00+08:00",
+		"2024-03-01 10:30:00+08",
+		"2024-03-01 10:30:00.000000+08:00",
+		"2024-03-01 10:30:00.0000000 +08:00",
+		"2024-03-01 10:30:00 +0800 CST",
+		"2024-03-01 02:30:00Z",
+		"2024-03-01 10:30:00", // 无时区按源时区解释
+	} {
+		got, ok := n.normalize(s, true)
+		if !ok || !got.Equal(want) {
+			t.Errorf("%q: %v %v，期望 %v", s, got, ok, want)
+		}
+	}
+	if _, ok := n.normalize("not a time", false); ok {
+		t.Error("无法解析的文本应返回 ok=false")
+	}
+}
+
+func TestStringOverflowLengthUnit(t *testing.T) {
+	c := &valueConverter{overflowPolicy: overflowTruncate}
+	s := "数据库迁移😀ab"
+	cases := []struct {
+		unit   int
+		length int
+		cut    string // 截断到 6 的结果
+	}{
+		{lengthRunes, 8, "数据库迁移😀"},
+		// SQL Server NVARCHAR 按 UTF-16 码元计：emoji 占 2 个
+		{lengthUTF16, 9, "数据库迁移"},
+		// SQL Server VARCHAR 按字节计：每个汉字 3 字节
+		{lengthBytes, 21, "数据"},
+	}
+	for _, tc := range cases {
+		if got := textLength(s, tc.unit); got != tc.length {
+			t.Errorf("单位 %d: 长度 %d，期望 %d", tc.unit, got, tc.length)
+		}
+		if got := c.truncateString(s, 6, tc.unit); got != tc.cut {
+			t.Errorf("单位 %d: 截断为 %q，期望 %q", tc.unit, got, tc.cut)
+		}
+	}
+	if sqlServerLengthUnit("NVARCHAR") != lengthUTF16 || sqlServerLengthUnit("NCHAR") != lengthUTF16 || sqlServerLengthUnit("VARCHAR") != lengthBytes {
+		t.Error("SQL Server 计量单位")
+	}
+
+	// 带标记截断时标记同样按单位计长
+	c = &valueConverter{overflowPolicy: overflowTruncateMarker, overflowMarker: "…"}
+	if got := c.truncateString(s, 7, lengthBytes); got != "数…" {
+		t.Errorf("带标记截断: %q", got)
+	}
+}
@@ -0,0 +1,188 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Postgres 源表的服务端游标读取：在只读事务中 DECLARE 游标，按 source_fetch_size 分批 FETCH，
+// 对复制循环而言与一次查询的结果集相同（sourceRows）。pg_cursor 取值：
+// - auto（默认）：源表记录数达到 pgCursorMinRows 或未统计时使用游标
+// - on：总是使用；off：不使用，直接查询
+
+const (
+	pgCursorAuto = "auto"
+	pgCursorOn   = "on"
+	pgCursorOff  = "off"
+
+	// pgCursorMinRows pg_cursor=auto 时使用游标的源表记录数下限
+	pgCursorMinRows = 1000000
+	// defaultSourceFetchSize 未配置 source_fetch_size 时每次 FETCH 的行数
+	defaultSourceFetchSize = 10000
+	// pgCursorName 游标名（每次复制使用独立的事务，或在一致性快照中读完即关闭，不会冲突）
+	pgCursorName = "dbtool_cursor"
+	// pgCursorCloseTimeout 关闭游标与回滚事务的超时（复制已取消时也要执行）
+	pgCursorCloseTimeout = 5 * time.Second
+)
+
+// sourceRows 源表查询的结果集：*sql.Rows 或 Postgres 游标（cursorRows）
+type sourceRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+	Columns() ([]string, error)
+	ColumnTypes() ([]*sql.ColumnType, error)
+}
+
+// validatePGCursor 检查 pg_cursor 的取值，为空时返回 auto
+func validatePGCursor(s string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(s)); v {
+	case "":
+		return pgCursorAuto, nil
+	case pgCursorAuto, pgCursorOn, pgCursorOff:
+		return v, nil
+	}
+	return "", fmt.Errorf("pg_cursor 无效: %q（可选 auto、on、off）", s)
+}
+
+// querySource 执行源表查询：配置了 consistent_snapshot 时在快照事务中执行，指定了 ReadIsolation 时在该隔离级别的事务中执行；
+// 源库为 Postgres 且按 pg_cursor 需要时使用服务端游标
+func querySource(ctx context.Context, src *simpleDB, opts copyTableOptions, sourceCount int64, query string, args ...interface{}) (sourceRows, error) {
+	if opts.Snapshot == nil && opts.ReadIsolation != sql.LevelDefault {
+		tx, err := src.db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.ReadIsolation})
+		if err != nil {
+			return nil, fmt.Errorf("开启读取事务失败（%s）: %w", opts.ReadIsolation, err)
+		}
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+		return &txRows{Rows: rows, tx: tx}, nil
+	}
+	db := sourceDB(src, opts)
+	if !isPostgresDriver(normalizeDriver(src.cfg.Driver)) {
+		return db.QueryContext(ctx, query, args...)
+	}
+	mode, err := validatePGCursor(opts.PGCursor)
+	if err != nil {
+		return nil, err
+	}
+	if mode == pgCursorOff || mode == pgCursorAuto && sourceCount >= 0 && sourceCount < pgCursorMinRows {
+		return db.QueryContext(ctx, query, args...)
+	}
+	fetch := opts.SourceFetchSize
+	if fetch <= 0 {
+		fetch = defaultSourceFetchSize
+	}
+	opts.Log.infof("使用服务端游标读取源表（每次 FETCH %d 行）\n", fetch)
+	if opts.Snapshot != nil {
+		return declareCursor(ctx, opts.Snapshot.q, nil, query, fetch, args...)
+	}
+	return openCursor(ctx, src.db, query, fetch, args...)
+}
+
+// cursorRows 按批 FETCH 的游标结果集；ctx 取消时 database/sql 回滚事务，游标随之关闭
+type cursorRows struct {
+	ctx   context.Context
+	q     sourceQuerier // 游标所在的事务
+	tx    *sql.Tx       // 为游标专门开启的事务，关闭时回滚；在一致性快照中声明时为 nil
+	fetch int
+
+	cur    *sql.Rows // 当前 FETCH 的结果
+	n      int       // 当前 FETCH 已读取的行数
+	done   bool
+	err    error
+	closed bool
+}
+
+// openCursor 开启只读事务并声明游标，执行第一次 FETCH（列信息取自其结果）
+func openCursor(ctx context.Context, db *sql.DB, query string, fetch int, args ...interface{}) (*cursorRows, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("开启游标事务失败: %w", err)
+	}
+	return declareCursor(ctx, tx, tx, query, fetch, args...)
+}
+
+// declareCursor 在事务 q 中声明游标并执行第一次 FETCH；tx 非空时为游标专用的事务，失败或关闭时回滚
+func declareCursor(ctx context.Context, q sourceQuerier, tx *sql.Tx, query string, fetch int, args ...interface{}) (*cursorRows, error) {
+	if _, err := q.ExecContext(ctx, "DECLARE "+pgCursorName+" NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		if tx != nil {
+			_ = tx.Rollback()
+		}
+		return nil, fmt.Errorf("声明游标失败: %w", err)
+	}
+	c := &cursorRows{ctx: ctx, q: q, tx: tx, fetch: fetch}
+	if err := c.fetchNext(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// fetchNext 读取下一批
+func (c *cursorRows) fetchNext() error {
+	rows, err := c.q.QueryContext(c.ctx, fmt.Sprintf("FETCH FORWARD %d FROM %s", c.fetch, pgCursorName))
+	if err != nil {
+		return fmt.Errorf("从游标读取失败: %w", err)
+	}
+	c.cur, c.n = rows, 0
+	return nil
+}
+
+func (c *cursorRows) Next() bool {
+	for !c.done && c.err == nil {
+		if c.cur.Next() {
+			c.n++
+			return true
+		}
+		if c.err = c.cur.Err(); c.err != nil {
+			return false
+		}
+		_ = c.cur.Close()
+		if c.n < c.fetch {
+			// 不足一批说明游标已读完
+			c.done = true
+			return false
+		}
+		c.err = c.fetchNext()
+	}
+	return false
+}
+
+func (c *cursorRows) Scan(dest ...interface{}) error { return c.cur.Scan(dest...) }
+
+func (c *cursorRows) Err() error { return c.err }
+
+func (c *cursorRows) Columns() ([]string, error) { return c.cur.Columns() }
+
+func (c *cursorRows) ColumnTypes() ([]*sql.ColumnType, error) { return c.cur.ColumnTypes() }
+
+// Close 关闭游标并结束游标专用的事务（只读事务，回滚即可）；复制已取消时事务已由 database/sql 回滚
+func (c *cursorRows) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.cur != nil {
+		_ = c.cur.Close()
+	}
+	if c.ctx.Err() == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), pgCursorCloseTimeout)
+		_, _ = c.q.ExecContext(ctx, "CLOSE "+pgCursorName)
+		cancel()
+	}
+	if c.tx == nil {
+		return nil
+	}
+	if err := c.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+	return nil
+}
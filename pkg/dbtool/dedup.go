@@ -0,0 +1,234 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dedupOrderBy 返回 ROW_NUMBER() 的排序表达式：dedup_order_by 优先，未配置时按去重键排序（保留任意一行）
+func dedupOrderBy(opts copyTableOptions, driver string) string {
+	if o := strings.TrimSpace(opts.DedupOrderBy); o != "" {
+		return o
+	}
+	return joinQuoted(opts.DedupKeys, driver)
+}
+
+// buildDedupQuery 用窗口函数改写源查询，每个去重键只保留 dedup_order_by 排序后的第一行：
+// SELECT cols FROM (SELECT cols, ROW_NUMBER() OVER (PARTITION BY keys ORDER BY ...) AS dbtool_rn FROM ... WHERE ...) d WHERE dbtool_rn = 1
+// fromExpr 为表（可带抽样后缀）或 (select_sql) tmp，args 为 select_sql 或 where 条件的绑定参数；
+// selectCols 为 * 时先查询列清单，避免把 dbtool_rn 读出来
+func buildDedupQuery(ctx context.Context, src *simpleDB, opts copyTableOptions, fromExpr string, args []interface{}, where []string, selectCols string) (string, error) {
+	cols := selectCols
+	if strings.TrimSpace(cols) == "*" {
+		probeArgs := args
+		if len(where) > 0 {
+			// 参数属于复制窗口条件，探测列的查询不带条件
+			probeArgs = nil
+		}
+		rows, err := sourceDB(src, opts).QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1=0", fromExpr), probeArgs...)
+		if err != nil {
+			return "", fmt.Errorf("读取源表列失败: %w", err)
+		}
+		names, err := rows.Columns()
+		rows.Close()
+		if err != nil {
+			return "", fmt.Errorf("读取源表列失败: %w", err)
+		}
+		cols = joinQuoted(names, src.cfg.Driver)
+	}
+	inner := fmt.Sprintf("SELECT %s, ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) AS dbtool_rn FROM %s%s",
+		cols, joinQuoted(opts.DedupKeys, src.cfg.Driver), dedupOrderBy(opts, src.cfg.Driver), fromExpr, whereSuffix(where))
+	return fmt.Sprintf("SELECT %s FROM (%s) d WHERE dbtool_rn = 1", cols, inner), nil
+}
+
+// countDedupRows 统计去重前后的源表记录数，返回去重后的记录数（用于数据核对），并打印丢弃的重复行数
+func countDedupRows(ctx context.Context, src *simpleDB, opts copyTableOptions, fromExpr string, args []interface{}, where []string) int64 {
+	var raw, distinct int64
+	rawQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", fromExpr, whereSuffix(where))
+	if err := sourceDB(src, opts).QueryRowContext(ctx, rawQuery, args...).Scan(&raw); err != nil {
+		opts.Log.warnf("警告：无法获取源表记录数: %v\n", err)
+		return -1
+	}
+	distinctQuery := fmt.Sprintf("SELECT COUNT(*) FROM (SELECT DISTINCT %s FROM %s%s) dk",
+		joinQuoted(opts.DedupKeys, src.cfg.Driver), fromExpr, whereSuffix(where))
+	if err := sourceDB(src, opts).QueryRowContext(ctx, distinctQuery, args...).Scan(&distinct); err != nil {
+		opts.Log.warnf("警告：无法获取源表去重后记录数: %v\n", err)
+		return -1
+	}
+	opts.Log.infof("源表记录数: %d，按 (%s) 去重后 %d 条，丢弃重复 %d 条\n", raw, strings.Join(opts.DedupKeys, ", "), distinct, raw-distinct)
+	return distinct
+}
+
+// supportsWindowFunctions 源库是否支持窗口函数（ROW_NUMBER() OVER）：MySQL 8.0、MariaDB 10.2、SQLite 3.25 之前不支持，
+// 其他源库均支持；查询版本失败时按支持处理
+func supportsWindowFunctions(ctx context.Context, src *simpleDB) bool {
+	driver := normalizeDriver(src.cfg.Driver)
+	var query string
+	switch driver {
+	case "mysql":
+		query = "SELECT VERSION()"
+	case "sqlite3":
+		query = "SELECT sqlite_version()"
+	default:
+		return true
+	}
+	var v string
+	if err := src.db.QueryRowContext(ctx, query).Scan(&v); err != nil {
+		src.cfg.log.warnf("警告：查询源库版本失败（%v），按支持窗口函数处理\n", err)
+		return true
+	}
+	switch {
+	case driver == "sqlite3":
+		return versionAtLeast(v, 3, 25)
+	case strings.Contains(strings.ToLower(v), "mariadb"):
+		return versionAtLeast(v, 10, 2)
+	default:
+		return versionAtLeast(v, 8, 0)
+	}
+}
+
+// versionAtLeast 版本号（如 5.7.44-log、10.6.12-MariaDB）的主次版本是否不低于 major.minor；无法解析时返回 true
+func versionAtLeast(v string, major, minor int) bool {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return true
+	}
+	maj, err1 := strconv.Atoi(parts[0])
+	mnr, err2 := strconv.Atoi(strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err1 != nil || err2 != nil {
+		return true
+	}
+	return maj > major || maj == major && mnr >= minor
+}
+
+// buildDedupStreamQuery 不支持窗口函数时的源查询：按去重键、再按 dedup_order_by 排序读取，由 dedupRows 逐行去重
+func buildDedupStreamQuery(opts copyTableOptions, fromExpr string, where []string, selectCols, driver string) string {
+	order := joinQuoted(opts.DedupKeys, driver)
+	if o := strings.TrimSpace(opts.DedupOrderBy); o != "" {
+		order += ", " + o
+	}
+	return fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s", selectCols, fromExpr, whereSuffix(where), order)
+}
+
+// dedupRows 按去重键排序读取时流式去重：同一去重键的连续行只保留第一行（即 dedup_order_by 排序后的第一行），
+// 与 ROW_NUMBER() = 1 的结果相同；limit 大于 0 时按去重后的行数截止。键值按读到的值比较（字符串区分大小写）
+type dedupRows struct {
+	sourceRows
+	keyIdx  []int
+	row     []interface{}
+	prevKey []interface{}
+	limit   int64
+	kept    int64
+	dropped int64
+	err     error
+	log     *tableLogger
+}
+
+// newDedupRows 包装按去重键排序的结果集；去重键必须出现在查询结果中
+func newDedupRows(rows sourceRows, opts copyTableOptions) (*dedupRows, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	d := &dedupRows{sourceRows: rows, row: make([]interface{}, len(cols)), limit: opts.Limit, log: opts.Log}
+	for _, key := range opts.DedupKeys {
+		found := -1
+		for i, c := range cols {
+			if strings.EqualFold(c, strings.TrimSpace(key)) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			return nil, fmt.Errorf("流式去重需要去重键 %s 出现在查询结果中（源库不支持窗口函数）", key)
+		}
+		d.keyIdx = append(d.keyIdx, found)
+	}
+	return d, nil
+}
+
+// Next 读取下一个去重键的第一行，跳过与上一行去重键相同的行
+func (d *dedupRows) Next() bool {
+	if d.limit > 0 && d.kept >= d.limit {
+		return false
+	}
+	ptrs := make([]interface{}, len(d.row))
+	for d.sourceRows.Next() {
+		row := make([]interface{}, len(d.row))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := d.sourceRows.Scan(ptrs...); err != nil {
+			d.err = err
+			return false
+		}
+		key := make([]interface{}, len(d.keyIdx))
+		for i, j := range d.keyIdx {
+			key[i] = row[j]
+		}
+		if d.prevKey != nil && sameDedupKey(key, d.prevKey) {
+			d.dropped++
+			continue
+		}
+		d.row, d.prevKey = row, key
+		d.kept++
+		return true
+	}
+	return false
+}
+
+// Scan 返回当前行（复制循环按 *interface{} 接收）
+func (d *dedupRows) Scan(dest ...interface{}) error {
+	if len(dest) != len(d.row) {
+		return fmt.Errorf("扫描列数 %d 与结果列数 %d 不一致", len(dest), len(d.row))
+	}
+	for i, p := range dest {
+		ptr, ok := p.(*interface{})
+		if !ok {
+			return fmt.Errorf("流式去重需要以 *interface{} 接收列值")
+		}
+		*ptr = d.row[i]
+	}
+	return nil
+}
+
+func (d *dedupRows) Err() error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.sourceRows.Err()
+}
+
+// Close 关闭结果集并打印读取时丢弃的重复行数
+func (d *dedupRows) Close() error {
+	if d.dropped > 0 {
+		d.log.infof("流式去重丢弃重复 %d 条\n", d.dropped)
+	}
+	return d.sourceRows.Close()
+}
+
+// sameDedupKey 两个去重键是否相同（[]byte 按内容、时间按时刻比较）
+func sameDedupKey(a, b []interface{}) bool {
+	for i := range a {
+		x, y := a[i], b[i]
+		if bx, ok := x.([]byte); ok {
+			x = string(bx)
+		}
+		if by, ok := y.([]byte); ok {
+			y = string(by)
+		}
+		if tx, ok := x.(time.Time); ok {
+			if ty, ok := y.(time.Time); !ok || !tx.Equal(ty) {
+				return false
+			}
+			continue
+		}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
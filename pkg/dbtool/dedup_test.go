@@ -0,0 +1,85 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVersionAtLeast(t *testing.T) {
+	cases := []struct {
+		v            string
+		major, minor int
+		want         bool
+	}{
+		{"5.7.44-log", 8, 0, false},
+		{"8.0.36", 8, 0, true},
+		{"10.1.48-MariaDB", 10, 2, false},
+		{"10.6.12-MariaDB-1:10.6.12+maria~ubu2004", 10, 2, true},
+		{"3.24.0", 3, 25, false},
+		{"3.45.1", 3, 25, true},
+	}
+	for _, c := range cases {
+		if got := versionAtLeast(c.v, c.major, c.minor); got != c.want {
+			t.Errorf("%s >= %d.%d: %v，期望 %v", c.v, c.major, c.minor, got, c.want)
+		}
+	}
+}
+
+func TestDedupRowsKeepsFirstPerKey(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "src.db"),
+		"CREATE TABLE c (tenant INTEGER, code TEXT, v INTEGER)",
+		"INSERT INTO c VALUES (1, 'a', 1), (1, 'a', 3), (1, 'b', 1), (2, 'a', 2), (1, 'a', 2), (2, 'a', 5)")
+	opts := copyTableOptions{DedupKeys: []string{"tenant", "code"}, DedupOrderBy: "v DESC", Log: newTableLogger("c")}
+
+	read := func(opts copyTableOptions) ([]string, int64) {
+		t.Helper()
+		query := buildDedupStreamQuery(opts, "c", nil, "*", "sqlite3")
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := newDedupRows(rows, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		var got []string
+		for d.Next() {
+			row := make([]interface{}, 3)
+			if err := d.Scan(&row[0], &row[1], &row[2]); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, fmt.Sprintf("%v/%s/%v", row[0], row[1], row[2]))
+		}
+		if err := d.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return got, d.dropped
+	}
+
+	// 每个键保留 v 最大的一行，与 ROW_NUMBER() OVER (PARTITION BY tenant, code ORDER BY v DESC) = 1 相同
+	got, dropped := read(opts)
+	if want := "1/a/3,1/b/1,2/a/5"; strings.Join(got, ",") != want || dropped != 3 {
+		t.Fatalf("去重结果 %v（丢弃 %d 条），期望 %s（丢弃 3 条）", got, dropped, want)
+	}
+
+	// limit 按去重后的行数截止
+	opts.Limit = 2
+	if got, _ := read(opts); len(got) != 2 {
+		t.Fatalf("limit=2 时读到 %v", got)
+	}
+
+	// 去重键不在查询结果中时无法流式去重
+	rows, err := db.QueryContext(ctx, "SELECT v FROM c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if _, err := newDedupRows(rows, opts); err == nil {
+		t.Fatal("去重键不在结果中时应报错")
+	}
+}
@@ -0,0 +1,130 @@
+package dbtool
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	numericDefaultRe = regexp.MustCompile(`^[-+]?\d+(\.\d+)?$`)
+	// pgCastRe 匹配 Postgres 默认值末尾的类型转换，如 'abc'::character varying
+	pgCastRe = regexp.MustCompile(`::[a-zA-Z_][a-zA-Z0-9_ ]*(\(\d+(,\s*\d+)?\))?(\[\])?$`)
+	// currentTimeDefaultRe 各方言表示“当前时间”的默认值写法
+	currentTimeDefaultRe = regexp.MustCompile(`(?i)^(current_timestamp(\(\d*\))?|now\(\)|getdate\(\)|sysdatetime\(\)|sysdate|systimestamp|localtimestamp(\(\d*\))?|datetime\('now'(,\s*'localtime')?\))$`)
+)
+
+// translateDefaults 把源表目录中的列默认值转换为目标库写法，返回按小写源列名索引的 DEFAULT 表达式；
+// 字段映射中已配置 default_value 的列、生成列不处理，无法转换的表达式打印警告后跳过
+func translateDefaults(cols targetColumns, srcDriver, dstDriver string, meta *sourceTableMeta, opts copyTableOptions) map[string]string {
+	configured := make(map[string]bool)
+	for _, c := range opts.Columns {
+		if strings.TrimSpace(c.DefaultValue) != "" {
+			configured[strings.ToLower(strings.TrimSpace(c.Source))] = true
+		}
+	}
+
+	out := make(map[string]string)
+	var skipped []string
+	for key, info := range cols {
+		if !info.Default.Valid || configured[key] {
+			continue
+		}
+		if _, ok := meta.generated[key]; ok {
+			continue
+		}
+		expr, ok := translateDefault(info.Default.String, info.DataType, srcDriver, dstDriver)
+		if !ok {
+			skipped = append(skipped, info.Name+"="+strings.TrimSpace(info.Default.String))
+			continue
+		}
+		if expr != "" {
+			out[key] = expr
+		}
+	}
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		opts.Log.warnf("警告：以下列的默认值无法转换为目标库写法，自动建表时不设置默认值: %s\n", strings.Join(skipped, ", "))
+	}
+	return out
+}
+
+// translateDefault 转换单个默认值表达式；返回空字符串且 ok=true 表示无默认值（NULL）
+// 支持：数字与字符串字面量、布尔值、当前时间函数；序列（nextval）与其他函数返回 ok=false
+func translateDefault(expr, dataType, srcDriver, dstDriver string) (string, bool) {
+	srcDriver = normalizeDriver(srcDriver)
+	dstDriver = normalizeDriver(dstDriver)
+
+	e := strings.TrimSpace(expr)
+	// SQL Server 默认值带括号，如 ((0))、(getdate())、('abc')
+	for len(e) >= 2 && e[0] == '(' && e[len(e)-1] == ')' {
+		e = strings.TrimSpace(e[1 : len(e)-1])
+	}
+	if srcDriver == "postgres" || srcDriver == "postgresql" {
+		e = strings.TrimSpace(pgCastRe.ReplaceAllString(e, ""))
+		for len(e) >= 2 && e[0] == '(' && e[len(e)-1] == ')' {
+			e = strings.TrimSpace(e[1 : len(e)-1])
+		}
+	}
+	if e == "" || strings.EqualFold(e, "NULL") {
+		return "", true
+	}
+
+	switch {
+	case currentTimeDefaultRe.MatchString(e):
+		if dstDriver == "oracle" {
+			return "SYSDATE", true
+		}
+		return "CURRENT_TIMESTAMP", true
+	case numericDefaultRe.MatchString(e):
+		return e, true
+	case strings.EqualFold(e, "true") || strings.EqualFold(e, "false") || e == "b'1'" || e == "b'0'":
+		v := strings.EqualFold(e, "true") || e == "b'1'"
+		switch dstDriver {
+		case "postgres", "postgresql":
+			if v {
+				return "TRUE", true
+			}
+			return "FALSE", true
+		default:
+			if v {
+				return "1", true
+			}
+			return "0", true
+		}
+	case isQuotedLiteral(e):
+		return sqlLiteral(unquoteLiteral(e), dstDriver), true
+	case srcDriver == "mysql" && !isNumericTypeName(dataType) && !strings.Contains(e, "("):
+		// MySQL 目录中的字符串默认值不带引号
+		return sqlLiteral(e, dstDriver), true
+	}
+	return "", false
+}
+
+// isQuotedLiteral 判断是否为单引号字符串字面量（允许 SQL Server 的 N 前缀）
+func isQuotedLiteral(e string) bool {
+	e = strings.TrimPrefix(strings.TrimPrefix(e, "N"), "n")
+	if len(e) < 2 || e[0] != '\'' || e[len(e)-1] != '\'' {
+		return false
+	}
+	// 中间的单引号必须成对出现（否则是 'a' || 'b' 之类的表达式）
+	inner := e[1 : len(e)-1]
+	return !strings.Contains(strings.ReplaceAll(inner, "''", ""), "'")
+}
+
+// unquoteLiteral 去掉字符串字面量的引号并还原转义的单引号
+func unquoteLiteral(e string) string {
+	e = strings.TrimPrefix(strings.TrimPrefix(e, "N"), "n")
+	return strings.ReplaceAll(e[1:len(e)-1], "''", "'")
+}
+
+// isNumericTypeName 判断目录中的数据类型名是否为数值/位类型
+func isNumericTypeName(dataType string) bool {
+	t := strings.ToUpper(dataType)
+	for _, p := range []string{"INT", "DEC", "NUMERIC", "NUMBER", "FLOAT", "DOUBLE", "REAL", "BIT", "BOOL", "MONEY"} {
+		if strings.Contains(t, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// defaultDeleteMaxPercent sync_deletes 默认的删除比例上限（占目标表范围内行数的百分比）
+const defaultDeleteMaxPercent = 10.0
+
+// deleteScope 返回 sync_deletes 比对键集合时使用的范围：只保留用户 where（键范围可在 where 中表达），
+// 去掉增量条件、抽样与 chunk_by——增量窗口之外的行同样要参与比对，否则会被误判为已删除
+func deleteScope(opts copyTableOptions) copyTableOptions {
+	scope := opts
+	scope.IncrementalKey, scope.Since, scope.Until = "", "", ""
+	scope.SinceValue, scope.UntilValue = nil, nil
+	scope.IncrementalKeys, scope.SinceTuple, scope.UntilTuple = nil, nil, nil
+	scope.SamplePercent = 0
+	scope.ChunkBy = nil
+	return scope
+}
+
+// syncDeletes 删除目标表中源表已不存在的行：按键列（key_columns > 源表主键 > 目标表主键）排序后
+// 流式归并源表与目标表的键集合，找出只在目标表中的键，再分批按键删除。
+// 将删除的行数超过 sync_deletes_max_percent 时中止；dry-run 只统计不删除。返回删除（dry-run 时为将删除）的行数
+func syncDeletes(ctx context.Context, src, dst *simpleDB, opts copyTableOptions) (int64, error) {
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		return 0, fmt.Errorf("使用 select_sql 的表不支持 sync_deletes")
+	}
+	targetTable := firstNonEmpty(opts.TargetTable, opts.Table)
+	exists, err := checkTableExists(ctx, dst, targetTable)
+	if err != nil {
+		return 0, fmt.Errorf("检查目标表 %s 是否存在失败: %w", targetTable, err)
+	}
+	if !exists {
+		opts.Log.infof("sync_deletes：目标表 %s 不存在，跳过\n", targetTable)
+		return 0, nil
+	}
+
+	scope := deleteScope(opts)
+	from, err := sourceFrom(scope, src.cfg.Driver)
+	if err != nil {
+		return 0, err
+	}
+	rename := columnRenames(opts)
+	keys, err := resolveDiffKeys(ctx, src, dst, opts, targetTable, rename)
+	if err != nil {
+		return 0, err
+	}
+	dstKeys := make([]string, len(keys))
+	for i, k := range keys {
+		dstKeys[i] = firstNonEmpty(rename[strings.ToLower(k)], k)
+	}
+
+	srcKinds, err := probeKeyKinds(ctx, src.db, fmt.Sprintf("SELECT %s FROM %s WHERE 1=0", joinQuoted(keys, src.cfg.Driver), from))
+	if err != nil {
+		return 0, fmt.Errorf("读取源表键列类型失败: %w", err)
+	}
+	dstKinds, err := probeKeyKinds(ctx, dst.db, fmt.Sprintf("SELECT %s FROM %s WHERE 1=0",
+		joinQuoted(dstKeys, dst.cfg.Driver), quoteIdent(targetTable, dst.cfg.Driver)))
+	if err != nil {
+		return 0, fmt.Errorf("读取目标表键列类型失败: %w", err)
+	}
+
+	srcQuery := fmt.Sprintf("SELECT %s FROM %s", joinQuoted(keys, src.cfg.Driver), from)
+	window, srcArgs := sourceWindow(scope, src.cfg.Driver, nil)
+	if len(window) > 0 {
+		srcQuery += " WHERE " + strings.Join(window, " AND ")
+	}
+	srcQuery += " ORDER BY " + keyOrderBy(keys, srcKinds, src.cfg.Driver)
+	dstQuery := fmt.Sprintf("SELECT %s FROM %s", joinQuoted(dstKeys, dst.cfg.Driver), quoteIdent(targetTable, dst.cfg.Driver))
+	cond, dstArgs, windowed := targetWindow(scope, dst.cfg.Driver)
+	if windowed {
+		dstQuery += " WHERE " + cond
+	}
+	dstQuery += " ORDER BY " + keyOrderBy(dstKeys, dstKinds, dst.cfg.Driver)
+
+	opts.Log.infof("sync_deletes：按键列 (%s) 比对源表与目标表\n", strings.Join(keys, ", "))
+	orphans, targetRows, err := targetOnlyKeys(ctx, src, dst, srcQuery, srcArgs, dstQuery, dstArgs, len(keys), srcKinds)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(orphans))
+	if n == 0 {
+		opts.Log.infof("sync_deletes：目标表 %s 没有需要删除的行（比对 %d 行）\n", targetTable, targetRows)
+		return 0, nil
+	}
+
+	maxPercent := opts.SyncDeletesMaxPercent
+	if maxPercent <= 0 {
+		maxPercent = defaultDeleteMaxPercent
+	}
+	percent := float64(n) * 100 / float64(targetRows)
+	if percent > maxPercent {
+		return 0, fmt.Errorf("sync_deletes 将删除目标表 %s 的 %d 行（占 %.2f%%），超过上限 %.2f%%，已中止（可调整 sync_deletes_max_percent）",
+			targetTable, n, percent, maxPercent)
+	}
+	if opts.DryRun {
+		opts.Log.infof("[DRY-RUN] sync_deletes：将删除目标表 %s 中源表已不存在的 %d 行（占 %.2f%%）\n", targetTable, n, percent)
+		return n, nil
+	}
+	if err := deleteByKeys(ctx, dst, targetTable, dstKeys, orphans); err != nil {
+		return 0, err
+	}
+	opts.Log.infof("sync_deletes：已删除目标表 %s 中源表已不存在的 %d 行（占 %.2f%%）\n", targetTable, n, percent)
+	return n, nil
+}
+
+// targetOnlyKeys 归并两个按键排序的结果集，返回只在目标表中出现的键以及目标表参与比对的行数
+func targetOnlyKeys(ctx context.Context, src, dst *simpleDB, srcQuery string, srcArgs []interface{}, dstQuery string, dstArgs []interface{}, nKeys int, kinds []int) ([][]interface{}, int64, error) {
+	srcRows, err := src.db.QueryContext(ctx, srcQuery, srcArgs...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询源表键失败: %w", err)
+	}
+	defer srcRows.Close()
+	dstRows, err := dst.db.QueryContext(ctx, dstQuery, dstArgs...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询目标表键失败: %w", err)
+	}
+	defer dstRows.Close()
+
+	sc := &diffCursor{rows: srcRows, nKeys: nKeys, kinds: kinds}
+	dc := &diffCursor{rows: dstRows, nKeys: nKeys, kinds: kinds}
+	if err := sc.next(); err != nil {
+		return nil, 0, err
+	}
+	if err := dc.next(); err != nil {
+		return nil, 0, err
+	}
+	var orphans [][]interface{}
+	var targetRows int64
+	for dc.ok {
+		c := 1
+		if sc.ok {
+			c = compareDiffKeys(sc.key(), dc.key(), kinds)
+		}
+		switch {
+		case c < 0:
+			err = sc.next()
+		case c > 0:
+			// 目标表的键在源表中不存在（目标值原样用于删除时的绑定参数）
+			orphans = append(orphans, dc.key())
+			targetRows++
+			err = dc.next()
+		default:
+			targetRows++
+			if err = sc.next(); err == nil {
+				err = dc.next()
+			}
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return orphans, targetRows, nil
+}
+
+// deleteByKeys 分批按键删除目标表行：单列键使用 IN，复合键展开为 (k1 = ? AND k2 = ?) OR ...
+// 每批单独提交；每批的绑定参数数量控制在 2000 以内（SQL Server 单条语句最多 2100 个参数）
+func deleteByKeys(ctx context.Context, dst *simpleDB, table string, keyCols []string, keys [][]interface{}) error {
+	driver := dst.cfg.Driver
+	batch := 500
+	if limit := 2000 / len(keyCols); limit < batch {
+		batch = limit
+	}
+	cols := make([]string, len(keyCols))
+	for i, c := range keyCols {
+		cols[i] = quoteIdent(c, driver)
+	}
+	for start := 0; start < len(keys); start += batch {
+		end := start + batch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		var args []interface{}
+		var where string
+		if len(cols) == 1 {
+			holders := make([]string, 0, end-start)
+			for _, key := range keys[start:end] {
+				args = append(args, key[0])
+				holders = append(holders, placeholder(len(args), driver))
+			}
+			where = fmt.Sprintf("%s IN (%s)", cols[0], strings.Join(holders, ", "))
+		} else {
+			terms := make([]string, 0, end-start)
+			for _, key := range keys[start:end] {
+				parts := make([]string, len(cols))
+				for i, col := range cols {
+					args = append(args, key[i])
+					parts[i] = fmt.Sprintf("%s = %s", col, placeholder(len(args), driver))
+				}
+				terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+			}
+			where = strings.Join(terms, " OR ")
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s", quoteIdent(table, driver), where)
+		if _, err := dst.db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("删除目标表 %s 的行失败（已删除 %d 行）: %w", table, start, err)
+		}
+	}
+	return nil
+}
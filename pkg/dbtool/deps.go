@@ -0,0 +1,232 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// foreignKey 一条外键依赖：Child 表引用 Parent 表，两端均为 schema.table
+type foreignKey struct {
+	Child  string
+	Parent string
+}
+
+// defaultSchema 返回未写 schema 前缀的表名所在的 schema（配置了 schema 时即为该 schema）
+func defaultSchema(ctx context.Context, db *simpleDB, schema string) (string, error) {
+	if schema != "" {
+		return schema, nil
+	}
+	var query string
+	switch normalizeDriver(db.cfg.Driver) {
+	case "mysql":
+		query = "SELECT DATABASE()"
+	case "postgres", "postgresql":
+		return "public", nil
+	case "sqlserver":
+		return "dbo", nil
+	case "oracle":
+		query = "SELECT USER FROM dual"
+	case "sqlite3":
+		return "main", nil
+	default:
+		return "", fmt.Errorf("暂不支持从驱动 %s 读取外键依赖", db.cfg.Driver)
+	}
+	var name sql.NullString
+	if err := db.db.QueryRowContext(ctx, query).Scan(&name); err != nil {
+		return "", fmt.Errorf("查询当前 schema 失败: %w", err)
+	}
+	if !name.Valid || name.String == "" {
+		return "", fmt.Errorf("未选择数据库，请在 table_list.schema 中指定 schema")
+	}
+	return name.String, nil
+}
+
+// fetchForeignKeys 从源库目录读取外键依赖：names 中各表所在 schema（未写前缀的按 schema）内的子表及其引用的父表，
+// 父表可以在其他 schema 中。返回的外键两端均为 schema 限定名，另返回未写前缀的表名所在的 schema
+func fetchForeignKeys(ctx context.Context, db *simpleDB, schema string, names []string) ([]foreignKey, string, error) {
+	driver := normalizeDriver(db.cfg.Driver)
+	schema, err := defaultSchema(ctx, db, schema)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var schemas []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		s, _ := splitTableKey(name, schema)
+		if driver == "oracle" {
+			s = strings.ToUpper(s)
+		}
+		if !seen[strings.ToLower(s)] {
+			seen[strings.ToLower(s)] = true
+			schemas = append(schemas, s)
+		}
+	}
+	if len(schemas) == 0 {
+		return nil, schema, nil
+	}
+	var args []interface{}
+	marks := make([]string, len(schemas))
+	for i, s := range schemas {
+		marks[i] = placeholder(i+1, driver)
+		args = append(args, s)
+	}
+	in := "(" + strings.Join(marks, ", ") + ")"
+
+	var query string
+	switch driver {
+	case "mysql":
+		query = `SELECT constraint_schema, table_name, unique_constraint_schema, referenced_table_name
+FROM information_schema.referential_constraints WHERE constraint_schema IN ` + in
+	case "postgres", "postgresql":
+		query = `SELECT n.nspname, cl.relname, pn.nspname, pcl.relname
+FROM pg_constraint c
+JOIN pg_class cl ON cl.oid = c.conrelid
+JOIN pg_class pcl ON pcl.oid = c.confrelid
+JOIN pg_namespace n ON n.oid = cl.relnamespace
+JOIN pg_namespace pn ON pn.oid = pcl.relnamespace
+WHERE c.contype = 'f' AND n.nspname IN ` + in
+	case "sqlserver":
+		query = `SELECT OBJECT_SCHEMA_NAME(parent_object_id), OBJECT_NAME(parent_object_id),
+	OBJECT_SCHEMA_NAME(referenced_object_id), OBJECT_NAME(referenced_object_id)
+FROM sys.foreign_keys WHERE OBJECT_SCHEMA_NAME(parent_object_id) IN ` + in
+	case "oracle":
+		query = `SELECT c.owner, c.table_name, p.owner, p.table_name
+FROM all_constraints c
+JOIN all_constraints p ON c.r_owner = p.owner AND c.r_constraint_name = p.constraint_name
+WHERE c.constraint_type = 'R' AND c.owner IN ` + in
+	case "sqlite3":
+		// 只读取主库中的表，外键不能跨库引用
+		query = `SELECT 'main', m.name, 'main', p."table" FROM sqlite_master m JOIN pragma_foreign_key_list(m.name) p WHERE m.type = 'table'`
+		args = nil
+	}
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("查询外键依赖失败: %w", err)
+	}
+	defer rows.Close()
+	var out []foreignKey
+	for rows.Next() {
+		var childSchema, child, parentSchema, parent string
+		if err := rows.Scan(&childSchema, &child, &parentSchema, &parent); err != nil {
+			return nil, "", fmt.Errorf("读取外键依赖失败: %w", err)
+		}
+		out = append(out, foreignKey{Child: childSchema + "." + child, Parent: parentSchema + "." + parent})
+	}
+	return out, schema, rows.Err()
+}
+
+// splitTableKey 拆分表名中的 schema 前缀并去掉引号；未写前缀时使用 schema
+func splitTableKey(name, schema string) (string, string) {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		schema, name = name[:i], name[i+1:]
+	}
+	unquote := func(s string) string { return strings.Trim(strings.TrimSpace(s), "`\"[]") }
+	return unquote(schema), unquote(name)
+}
+
+// tableKey 规范化表名用于比较：保留 schema（未写前缀时使用 schema），去掉引号，转小写。
+// 不同 schema 下的同名表（a.orders 与 b.orders）是不同的键
+func tableKey(name, schema string) string {
+	s, table := splitTableKey(name, schema)
+	return strings.ToLower(s + "." + table)
+}
+
+// sortTablesByDependencies 按外键依赖对表名做拓扑排序（父表在前），无依赖关系的表保持原有相对顺序；
+// 未写 schema 前缀的表名按 schema 比较。存在循环依赖的表无法排序，按原有顺序追加在最后并通过 cyclic 返回
+func sortTablesByDependencies(names []string, schema string, fks []foreignKey) (ordered, cyclic []string) {
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := index[tableKey(name, schema)]; !ok {
+			index[tableKey(name, schema)] = i
+		}
+	}
+
+	// indegree[i] 为表 i 在清单内尚未排入的父表数；children[p] 为引用表 p 的子表
+	indegree := make([]int, len(names))
+	children := make(map[int][]int)
+	seen := make(map[[2]int]bool)
+	for _, fk := range fks {
+		c, okC := index[tableKey(fk.Child, schema)]
+		p, okP := index[tableKey(fk.Parent, schema)]
+		if !okC || !okP || c == p || seen[[2]int{c, p}] {
+			// 清单外的表与自引用不影响顺序
+			continue
+		}
+		seen[[2]int{c, p}] = true
+		indegree[c]++
+		children[p] = append(children[p], c)
+	}
+
+	done := make([]bool, len(names))
+	for len(ordered) < len(names) {
+		// 每次取原有顺序中第一个可执行的表，保证结果稳定
+		next := -1
+		for i := range names {
+			if !done[i] && indegree[i] == 0 && index[tableKey(names[i], schema)] == i {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		done[next] = true
+		ordered = append(ordered, names[next])
+		for _, c := range children[next] {
+			indegree[c]--
+		}
+	}
+	for i, name := range names {
+		if !done[i] && index[tableKey(name, schema)] == i {
+			cyclic = append(cyclic, name)
+		}
+	}
+	ordered = append(ordered, cyclic...)
+	return ordered, cyclic
+}
+
+// orderTablesByDependencies 读取源库外键并按依赖顺序重排表配置；同一源表的多条配置保持相对顺序
+func orderTablesByDependencies(ctx context.Context, src *simpleDB, schema string, tables []configTable) ([]configTable, error) {
+	var names []string
+	for _, t := range tables {
+		names = append(names, t.SourceTable)
+	}
+	fks, schema, err := fetchForeignKeys(ctx, src, schema, names)
+	if err != nil {
+		return nil, err
+	}
+
+	names = names[:0]
+	groups := make(map[string][]configTable)
+	for _, t := range tables {
+		key := tableKey(t.SourceTable, schema)
+		if _, ok := groups[key]; !ok {
+			names = append(names, t.SourceTable)
+		}
+		groups[key] = append(groups[key], t)
+	}
+
+	ordered, cyclic := sortTablesByDependencies(names, schema, fks)
+	warnCyclicTables(cyclic, src.cfg.log)
+
+	out := make([]configTable, 0, len(tables))
+	for _, name := range ordered {
+		out = append(out, groups[tableKey(name, schema)]...)
+	}
+	src.cfg.log.infof("已按外键依赖排序表清单（%d 张表）\n", len(ordered))
+	return out, nil
+}
+
+// warnCyclicTables 提示存在循环外键依赖的表
+func warnCyclicTables(cyclic []string, logger *tableLogger) {
+	if len(cyclic) == 0 {
+		return
+	}
+	logger.warnf("警告：以下表存在循环外键依赖，无法排序，按原有顺序放在最后: %s\n", strings.Join(cyclic, ", "))
+	logger.infof("提示：可在目标数据源上配置 disable_fk_checks（MySQL）或 session_replication_role（Postgres）跳过外键检查\n")
+}
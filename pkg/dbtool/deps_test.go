@@ -0,0 +1,53 @@
+package dbtool
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSortTablesByDependenciesKeepsSchema(t *testing.T) {
+	// a.orders 引用 a.users；b.orders 与 a 下的表无关，但 b.items 引用 b.orders
+	fks := []foreignKey{
+		{Child: "a.orders", Parent: "a.users"},
+		{Child: "b.items", Parent: "b.orders"},
+	}
+	names := []string{"b.items", "orders", "b.orders", "users"}
+	ordered, cyclic := sortTablesByDependencies(names, "a", fks)
+	if len(cyclic) != 0 {
+		t.Fatalf("不应有循环依赖: %v", cyclic)
+	}
+	if got, want := strings.Join(ordered, ","), "b.orders,b.items,users,orders"; got != want {
+		t.Fatalf("排序结果 %s，期望 %s", got, want)
+	}
+}
+
+func TestTableKey(t *testing.T) {
+	cases := []struct {
+		name, schema, want string
+	}{
+		{"orders", "a", "a.orders"},
+		{"B.Orders", "a", "b.orders"},
+		{`"b"."orders"`, "a", "b.orders"},
+		{"[dbo].[Orders]", "a", "dbo.orders"},
+	}
+	for _, c := range cases {
+		if got := tableKey(c.name, c.schema); got != c.want {
+			t.Errorf("%s: %s，期望 %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFetchForeignKeysQualified(t *testing.T) {
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "src.db"),
+		"CREATE TABLE users (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id))")
+	fks, schema, err := fetchForeignKeys(context.Background(), &simpleDB{db: db, cfg: dbConfig{Driver: "sqlite3"}}, "", []string{"orders", "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema != "main" || len(fks) != 1 || fks[0] != (foreignKey{Child: "main.orders", Parent: "main.users"}) {
+		t.Fatalf("schema %q，外键 %+v", schema, fks)
+	}
+}
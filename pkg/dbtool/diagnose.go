@@ -0,0 +1,257 @@
+package dbtool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDiagnoseThreshold = 1000 // 区间内记录数不超过该值时停止二分
+	maxDiagnoseIntervals     = 200  // 最多定位的区间数（超过后停止，避免差异过多时查询失控）
+)
+
+// diagnoseInterval 源表与目标表记录数不一致的键区间 (Since, Until]
+type diagnoseInterval struct {
+	Since       string
+	Until       string
+	SourceCount int64
+	TargetCount int64
+}
+
+// keyRange 二分使用的键空间：整数键或时间键（按秒）
+type keyRange struct {
+	isTime bool
+	driver string
+}
+
+func (k keyRange) literal(v int64) string {
+	if k.isTime {
+		return chunkTimeLiteral(time.Unix(v, 0).UTC(), k.driver)
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+func (k keyRange) format(v int64) string {
+	if k.isTime {
+		return time.Unix(v, 0).UTC().Format(chunkTimeLayout)
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// diagnoseDiff 在键空间上二分定位记录数不一致的区间：
+// 两侧按 (lo, hi] 统计 COUNT(*)，不一致且区间记录数超过阈值时继续拆分，最后输出可直接用于补数的 since/until 区间，
+// 并写入 <目标表>.diagnose.json（每项是一个可放入 tables 的表配置）。
+// 键列优先使用 incremental_key，否则使用单列的 key_columns/源表主键；只支持整数键与时间键
+func diagnoseDiff(ctx context.Context, src, dst *simpleDB, opts copyTableOptions) error {
+	if strings.TrimSpace(opts.SelectSQL) != "" || opts.SamplePercent > 0 {
+		return fmt.Errorf("使用 select_sql 或抽样的表不支持差异定位")
+	}
+	targetTable := firstNonEmpty(opts.TargetTable, opts.Table)
+	srcOpts, dstOpts, err := splitWindowOpts(opts, src.cfg.Driver, dst.cfg.Driver)
+	if err != nil {
+		return err
+	}
+	from, err := sourceFrom(srcOpts, src.cfg.Driver)
+	if err != nil {
+		return err
+	}
+	rename := columnRenames(opts)
+
+	key := strings.TrimSpace(opts.IncrementalKey)
+	if key == "" {
+		keys, err := resolveDiffKeys(ctx, src, dst, opts, targetTable, rename)
+		if err != nil {
+			return err
+		}
+		if len(keys) != 1 {
+			return fmt.Errorf("差异定位需要单列键（当前键列 %s），请配置 incremental_key", strings.Join(keys, ", "))
+		}
+		key = keys[0]
+	}
+	targetKey := firstNonEmpty(rename[strings.ToLower(key)], key)
+
+	kinds, err := probeKeyKinds(ctx, src.db, fmt.Sprintf("SELECT %s FROM %s WHERE 1=0", quoteIdent(key, src.cfg.Driver), from))
+	if err != nil {
+		return fmt.Errorf("读取键列 %s 类型失败: %w", key, err)
+	}
+	isTime := kinds[0] != keyKindNumeric
+
+	// 键空间取两侧窗口内 MIN/MAX 的并集
+	srcWindow, srcArgs := sourceWindow(srcOpts, src.cfg.Driver, nil)
+	lo, hi, okSrc, err := keyBounds(ctx, src.db, fmt.Sprintf("SELECT MIN(%[1]s), MAX(%[1]s) FROM %[2]s%[3]s",
+		quoteIdent(key, src.cfg.Driver), from, whereSuffix(srcWindow)), srcArgs, isTime)
+	if err != nil {
+		return fmt.Errorf("查询源表键范围失败: %w", err)
+	}
+	dstCond, dstArgs, _ := targetWindow(dstOpts, dst.cfg.Driver)
+	var dstWindow []string
+	if dstCond != "" {
+		dstWindow = append(dstWindow, dstCond)
+	}
+	tlo, thi, okDst, err := keyBounds(ctx, dst.db, fmt.Sprintf("SELECT MIN(%[1]s), MAX(%[1]s) FROM %[2]s%[3]s",
+		quoteIdent(targetKey, dst.cfg.Driver), quoteIdent(targetTable, dst.cfg.Driver), whereSuffix(dstWindow)), dstArgs, isTime)
+	if err != nil {
+		return fmt.Errorf("查询目标表键范围失败: %w", err)
+	}
+	switch {
+	case !okSrc && !okDst:
+		opts.Log.infof("表 %s 两侧窗口内都没有数据，无需定位\n", opts.Table)
+		return nil
+	case !okSrc:
+		lo, hi = tlo, thi
+	case okDst:
+		if tlo < lo {
+			lo = tlo
+		}
+		if thi > hi {
+			hi = thi
+		}
+	}
+
+	threshold := opts.DiagnoseThreshold
+	if threshold <= 0 {
+		threshold = defaultDiagnoseThreshold
+	}
+	srcRange := keyRange{isTime: isTime, driver: src.cfg.Driver}
+	dstRange := keyRange{isTime: isTime, driver: dst.cfg.Driver}
+	countRange := func(a, b int64) (int64, int64, error) {
+		srcCond := fmt.Sprintf("%[1]s > %[2]s AND %[1]s <= %[3]s", quoteIdent(key, src.cfg.Driver), srcRange.literal(a), srcRange.literal(b))
+		var sc int64
+		q := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", from, whereSuffix(append(append([]string(nil), srcWindow...), srcCond)))
+		if err := src.db.QueryRowContext(ctx, q, srcArgs...).Scan(&sc); err != nil {
+			return 0, 0, fmt.Errorf("统计源表区间记录数失败: %w", err)
+		}
+		rangeOpts := dstOpts
+		rangeOpts.Where = andWhere(dstOpts.Where, fmt.Sprintf("%[1]s > %[2]s AND %[1]s <= %[3]s",
+			quoteIdent(key, dst.cfg.Driver), dstRange.literal(a), dstRange.literal(b)))
+		tc := countTargetRows(ctx, dst.db, targetTable, rangeOpts, dst.cfg.Driver)
+		if tc < 0 {
+			return 0, 0, fmt.Errorf("统计目标表区间记录数失败")
+		}
+		return sc, tc, nil
+	}
+
+	opts.Log.infof("开始定位表 %s 的差异区间：键列 %s，范围 (%s, %s]\n", opts.Table, key, srcRange.format(lo-1), srcRange.format(hi))
+	var intervals []diagnoseInterval
+	truncated := false
+	var bisect func(a, b int64) error
+	bisect = func(a, b int64) error {
+		if len(intervals) >= maxDiagnoseIntervals {
+			truncated = true
+			return nil
+		}
+		sc, tc, err := countRange(a, b)
+		if err != nil {
+			return err
+		}
+		if sc == tc {
+			return nil
+		}
+		if b-a <= 1 || (sc <= threshold && tc <= threshold) {
+			// 与上一个区间相邻时合并
+			if n := len(intervals); n > 0 && intervals[n-1].Until == srcRange.format(a) {
+				intervals[n-1].Until = srcRange.format(b)
+				intervals[n-1].SourceCount += sc
+				intervals[n-1].TargetCount += tc
+				return nil
+			}
+			intervals = append(intervals, diagnoseInterval{Since: srcRange.format(a), Until: srcRange.format(b), SourceCount: sc, TargetCount: tc})
+			return nil
+		}
+		mid := a + (b-a)/2
+		if err := bisect(a, mid); err != nil {
+			return err
+		}
+		return bisect(mid, b)
+	}
+	if err := bisect(lo-1, hi); err != nil {
+		return err
+	}
+
+	if len(intervals) == 0 {
+		opts.Log.infof("表 %s 未定位到不一致的区间（差异可能来自键范围外或统计期间的并发写入）\n", opts.Table)
+		return nil
+	}
+	opts.Log.infof("表 %s 共定位到 %d 个不一致区间（键列 %s）:\n", opts.Table, len(intervals), key)
+	entries := make([]configTable, 0, len(intervals))
+	for _, iv := range intervals {
+		opts.Log.infof("  since=%s until=%s  源表 %d 条，目标表 %d 条\n", iv.Since, iv.Until, iv.SourceCount, iv.TargetCount)
+		entries = append(entries, configTable{
+			SourceTable:    opts.Table,
+			TargetTable:    opts.TargetTable,
+			Where:          opts.Where,
+			Columns:        opts.Columns,
+			IncrementalKey: key,
+			Since:          iv.Since,
+			Until:          iv.Until,
+		})
+	}
+	if truncated {
+		opts.Log.warnf("警告：不一致区间超过 %d 个，只列出前 %d 个\n", maxDiagnoseIntervals, maxDiagnoseIntervals)
+	}
+
+	path := targetTable + ".diagnose.json"
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化差异区间失败: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("写入差异区间文件失败: %w", err)
+	}
+	opts.Log.infof("补数用的表配置已写入 %s（可直接放入 tables 重跑，注意先清理目标表中这些区间的数据或使用 upsert）\n", path)
+	return nil
+}
+
+// keyBounds 查询键的 MIN/MAX 并转为整数（时间键为 Unix 秒）；没有数据时 ok=false
+func keyBounds(ctx context.Context, db dbExecutor, query string, args []interface{}, isTime bool) (lo, hi int64, ok bool, err error) {
+	var minV, maxV interface{}
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&minV, &maxV); err != nil {
+		return 0, 0, false, err
+	}
+	if minV == nil || maxV == nil {
+		return 0, 0, false, nil
+	}
+	if lo, err = keyToInt(minV, isTime, false); err != nil {
+		return 0, 0, false, err
+	}
+	if hi, err = keyToInt(maxV, isTime, true); err != nil {
+		return 0, 0, false, err
+	}
+	return lo, hi, true, nil
+}
+
+// keyToInt 把键值转换为二分使用的整数；时间键按秒取整（上界向上取整，保证区间覆盖）
+func keyToInt(v interface{}, isTime, ceil bool) (int64, error) {
+	if isTime {
+		t, ok := v.(time.Time)
+		if !ok {
+			var err error
+			if t, err = parseChunkTime(diffValueString(v)); err != nil {
+				return 0, fmt.Errorf("差异定位只支持整数键或时间键: %w", err)
+			}
+		}
+		// 按墙上时间换算，与 since/until 字面量的比较方式一致
+		sec := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC).Unix()
+		if ceil && t.Nanosecond() > 0 {
+			sec++
+		}
+		return sec, nil
+	}
+	n, err := strconv.ParseInt(diffValueString(v), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("差异定位只支持整数键或时间键（键值 %v）", v)
+	}
+	return n, nil
+}
+
+func whereSuffix(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
@@ -0,0 +1,493 @@
+package dbtool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// diffOptions -diff-keys 的运行参数
+type diffOptions struct {
+	Values  bool   // 同时比较非键列的哈希
+	MaxKeys int    // 每张表每类差异最多写入文件的键数（统计不受限制）
+	Dir     string // 差异文件输出目录
+}
+
+// diffCounts 单张表的行级差异统计
+type diffCounts struct {
+	onlySource int64
+	onlyTarget int64
+	valueDiff  int64
+}
+
+func (c diffCounts) total() int64 {
+	return c.onlySource + c.onlyTarget + c.valueDiff
+}
+
+// runDiffKeys 按键逐行比对：两侧按键排序流式读取，归并比较，把只在源库、只在目标库（以及值不一致）的键写入每张表一个差异文件。
+// 只执行 SELECT；存在差异时以 exitVerifyDiff 退出
+func runDiffKeys(configPath string, dopts diffOptions) {
+	if dopts.MaxKeys <= 0 {
+		dopts.MaxKeys = 1000
+	}
+	if err := os.MkdirAll(dopts.Dir, 0o755); err != nil {
+		fatalf("创建差异文件目录失败: %v", err)
+	}
+
+	cfg, tables, src, dst := openVerifyTargets(configPath)
+	defer src.Close()
+	defer dst.Close()
+
+	startTime := time.Now()
+	diffTables, failed := 0, 0
+	for i, t := range tables {
+		if strings.TrimSpace(t.SourceTable) == "" {
+			infof("第 %d 个表配置 source_table 为空，跳过", i)
+			continue
+		}
+		opts := tableOptions(cfg, t, true, 0)
+		if err := validateIncremental(opts); err != nil {
+			fatalf("表 %s 配置错误: %v", opts.Table, err)
+		}
+		if isAutoSince(opts.Since) {
+			warnf("警告：表 %s 的 since=auto 在复制后无法还原，按不带 since 的窗口处理\n", opts.Table)
+			opts.Since = ""
+		}
+		if err := resolveSoftDelete(context.Background(), src, &opts); err != nil {
+			fatalf("表 %s 软删除配置无效: %v", opts.Table, err)
+		}
+		if err := resolveIncrementalTypes(context.Background(), src, &opts); err != nil {
+			fatalf("表 %s 增量条件无效: %v", opts.Table, err)
+		}
+		infof("开始逐行比对表: source=%s, target=%s\n", opts.Table, firstNonEmpty(opts.TargetTable, opts.Table))
+
+		counts, path, err := diffTable(context.Background(), src, dst, opts, dopts)
+		if err != nil {
+			warnf("警告：表 %s 逐行比对失败: %v\n", opts.Table, err)
+			failed++
+			continue
+		}
+		if counts.total() == 0 {
+			infof("  ✅ %s: 无差异\n", opts.Table)
+			continue
+		}
+		diffTables++
+		infof("  ❌ %s: 仅源库 %d 条, 仅目标库 %d 条, 值不一致 %d 条（详见 %s）\n",
+			opts.Table, counts.onlySource, counts.onlyTarget, counts.valueDiff, path)
+	}
+
+	infof("########################################\n")
+	log.Printf("逐行比对完成: 共 %d 张表, 存在差异 %d 张, 比对失败 %d 张, 耗时 %.2f 秒\n",
+		len(tables), diffTables, failed, time.Since(startTime).Seconds())
+	infof("########################################\n")
+
+	if diffTables > 0 {
+		_ = src.Close()
+		_ = dst.Close()
+		os.Exit(exitVerifyDiff)
+	}
+}
+
+// diffTable 比对单张表，返回差异统计与差异文件路径（无差异时不保留文件）
+func diffTable(ctx context.Context, src, dst *simpleDB, opts copyTableOptions, dopts diffOptions) (diffCounts, string, error) {
+	var counts diffCounts
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		return counts, "", fmt.Errorf("使用 select_sql 的表不支持 -diff-keys")
+	}
+	targetTable := firstNonEmpty(opts.TargetTable, opts.Table)
+	srcOpts, dstOpts, err := splitWindowOpts(opts, src.cfg.Driver, dst.cfg.Driver)
+	if err != nil {
+		return counts, "", err
+	}
+	from, err := sourceFrom(srcOpts, src.cfg.Driver)
+	if err != nil {
+		return counts, "", err
+	}
+
+	rename := columnRenames(opts)
+	targetName := func(col string) string {
+		return firstNonEmpty(rename[strings.ToLower(col)], col)
+	}
+
+	keys, err := resolveDiffKeys(ctx, src, dst, opts, targetTable, rename)
+	if err != nil {
+		return counts, "", err
+	}
+	var values []string
+	if dopts.Values {
+		if values, err = diffValueColumns(ctx, src, from, opts, keys); err != nil {
+			return counts, "", err
+		}
+	}
+
+	srcCols := append(append([]string(nil), keys...), values...)
+	dstCols := make([]string, len(srcCols))
+	for i, c := range srcCols {
+		dstCols[i] = targetName(c)
+	}
+
+	// 只有字符串键按二进制排序，数值/日期等类型按原生顺序（与 Go 侧比较方式一致）
+	srcKinds, err := probeKeyKinds(ctx, src.db, fmt.Sprintf("SELECT %s FROM %s WHERE 1=0", joinQuoted(keys, src.cfg.Driver), from))
+	if err != nil {
+		return counts, "", fmt.Errorf("读取源表键列类型失败: %w", err)
+	}
+	dstKinds, err := probeKeyKinds(ctx, dst.db, fmt.Sprintf("SELECT %s FROM %s WHERE 1=0",
+		joinQuoted(dstCols[:len(keys)], dst.cfg.Driver), quoteIdent(targetTable, dst.cfg.Driver)))
+	if err != nil {
+		return counts, "", fmt.Errorf("读取目标表键列类型失败: %w", err)
+	}
+	for i := range keys {
+		if srcKinds[i] != dstKinds[i] {
+			opts.Log.warnf("警告：键列 %s 在源库与目标库的类型类别不同，排序可能不一致导致误报\n", keys[i])
+		}
+	}
+
+	srcQuery := fmt.Sprintf("SELECT %s FROM %s", joinQuoted(srcCols, src.cfg.Driver), from)
+	window, srcArgs := sourceWindow(srcOpts, src.cfg.Driver, nil)
+	if len(window) > 0 {
+		srcQuery += " WHERE " + strings.Join(window, " AND ")
+	}
+	srcQuery += " ORDER BY " + keyOrderBy(keys, srcKinds, src.cfg.Driver)
+
+	dstQuery := fmt.Sprintf("SELECT %s FROM %s", joinQuoted(dstCols, dst.cfg.Driver), quoteIdent(targetTable, dst.cfg.Driver))
+	cond, dstArgs, windowed := targetWindow(dstOpts, dst.cfg.Driver)
+	if windowed {
+		dstQuery += " WHERE " + cond
+	}
+	dstQuery += " ORDER BY " + keyOrderBy(dstCols[:len(keys)], dstKinds, dst.cfg.Driver)
+
+	srcRows, err := src.db.QueryContext(ctx, srcQuery, srcArgs...)
+	if err != nil {
+		return counts, "", fmt.Errorf("查询源表失败: %w", err)
+	}
+	defer srcRows.Close()
+	dstRows, err := dst.db.QueryContext(ctx, dstQuery, dstArgs...)
+	if err != nil {
+		return counts, "", fmt.Errorf("查询目标表失败: %w", err)
+	}
+	defer dstRows.Close()
+
+	path := filepath.Join(dopts.Dir, targetTable+".diff.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		return counts, "", fmt.Errorf("创建差异文件失败: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "# 源表 %s -> 目标表 %s，键列 %s\n", opts.Table, targetTable, strings.Join(keys, ", "))
+	if len(values) > 0 {
+		fmt.Fprintf(w, "# 比较值列 %s\n", strings.Join(values, ", "))
+	}
+
+	report := func(kind string, n *int64, key []interface{}) {
+		*n++
+		if *n <= int64(dopts.MaxKeys) {
+			fmt.Fprintf(w, "%s\t%s\n", kind, formatDiffKey(keys, key))
+		}
+	}
+
+	sc := &diffCursor{rows: srcRows, nKeys: len(keys), kinds: srcKinds}
+	dc := &diffCursor{rows: dstRows, nKeys: len(keys), kinds: srcKinds}
+	if err := sc.next(); err != nil {
+		f.Close()
+		return counts, "", err
+	}
+	if err := dc.next(); err != nil {
+		f.Close()
+		return counts, "", err
+	}
+	for sc.ok || dc.ok {
+		var c int
+		switch {
+		case !dc.ok:
+			c = -1
+		case !sc.ok:
+			c = 1
+		default:
+			c = compareDiffKeys(sc.key(), dc.key(), srcKinds)
+		}
+		switch {
+		case c < 0:
+			report("only_source", &counts.onlySource, sc.key())
+			err = sc.next()
+		case c > 0:
+			report("only_target", &counts.onlyTarget, dc.key())
+			err = dc.next()
+		default:
+			if len(values) > 0 && sc.valueHash() != dc.valueHash() {
+				report("value_diff", &counts.valueDiff, sc.key())
+			}
+			if err = sc.next(); err == nil {
+				err = dc.next()
+			}
+		}
+		if err != nil {
+			f.Close()
+			return counts, "", err
+		}
+	}
+
+	for _, c := range []struct {
+		kind string
+		n    int64
+	}{{"only_source", counts.onlySource}, {"only_target", counts.onlyTarget}, {"value_diff", counts.valueDiff}} {
+		if c.n > int64(dopts.MaxKeys) {
+			fmt.Fprintf(w, "# %s 共 %d 条，仅列出前 %d 条\n", c.kind, c.n, dopts.MaxKeys)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return counts, "", fmt.Errorf("写入差异文件失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return counts, "", fmt.Errorf("写入差异文件失败: %w", err)
+	}
+	if counts.total() == 0 {
+		_ = os.Remove(path)
+		return counts, "", nil
+	}
+	return counts, path, nil
+}
+
+// resolveDiffKeys 确定比对使用的键列（源列名）：key_columns > 源表主键 > 目标表主键（按字段映射换回源列名）
+func resolveDiffKeys(ctx context.Context, src, dst *simpleDB, opts copyTableOptions, targetTable string, rename map[string]string) ([]string, error) {
+	if len(opts.KeyColumns) > 0 {
+		return opts.KeyColumns, nil
+	}
+	if opts.Partition == "" {
+		if keys, err := fetchPrimaryKey(ctx, src, opts.Table); err == nil && len(keys) > 0 {
+			return keys, nil
+		}
+	}
+	keys, err := fetchPrimaryKey(ctx, dst, targetTable)
+	if err == nil && len(keys) > 0 {
+		reverse := make(map[string]string, len(rename))
+		for s, t := range rename {
+			reverse[strings.ToLower(t)] = s
+		}
+		out := make([]string, len(keys))
+		for i, k := range keys {
+			out[i] = firstNonEmpty(reverse[strings.ToLower(k)], k)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("无法确定表 %s 的主键，请在表配置中设置 key_columns", opts.Table)
+}
+
+// diffValueColumns 返回参与值比较的非键列（源列名）：配置了 columns 时取映射中的列，否则取源表全部列
+func diffValueColumns(ctx context.Context, src *simpleDB, from string, opts copyTableOptions, keys []string) ([]string, error) {
+	isKey := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		isKey[strings.ToLower(k)] = true
+	}
+	var cols []string
+	if len(opts.Columns) > 0 {
+		for _, c := range opts.Columns {
+			if s := strings.TrimSpace(c.Source); s != "" && !c.Skip {
+				cols = append(cols, s)
+			}
+		}
+	} else {
+		rows, err := src.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1=0", from))
+		if err != nil {
+			return nil, fmt.Errorf("读取源表列失败: %w", err)
+		}
+		names, err := rows.Columns()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取源表列失败: %w", err)
+		}
+		cols = names
+	}
+	out := make([]string, 0, len(cols))
+	for _, c := range cols {
+		if !isKey[strings.ToLower(c)] {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// 键列类别：决定排序方式与比较方式
+const (
+	keyKindOther   = iota // 日期、UUID 等：按原生顺序排序，按文本比较
+	keyKindNumeric        // 数值：按数值比较
+	keyKindString         // 字符串：两侧都按二进制排序，按字节比较（规避排序规则差异）
+)
+
+// probeKeyKinds 执行不返回数据的查询，按列类型判断每个键列的类别
+func probeKeyKinds(ctx context.Context, db *sql.DB, query string) ([]int, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	kinds := make([]int, len(types))
+	for i, ct := range types {
+		name := strings.ToUpper(ct.DatabaseTypeName())
+		switch {
+		case strings.Contains(name, "CHAR") || strings.Contains(name, "TEXT") || strings.Contains(name, "CLOB") || strings.Contains(name, "STRING"):
+			kinds[i] = keyKindString
+		case isNumericTypeName(name):
+			kinds[i] = keyKindNumeric
+		default:
+			kinds[i] = keyKindOther
+		}
+	}
+	return kinds, nil
+}
+
+// keyOrderBy 生成按键列排序的 ORDER BY 列表，字符串键使用各方言的二进制排序
+func keyOrderBy(cols []string, kinds []int, driver string) string {
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		col := quoteIdent(c, driver)
+		if kinds[i] != keyKindString {
+			parts[i] = col
+			continue
+		}
+		switch normalizeDriver(driver) {
+		case "postgres", "postgresql":
+			parts[i] = col + ` COLLATE "C"`
+		case "mysql":
+			parts[i] = "BINARY " + col
+		case "sqlserver":
+			parts[i] = col + " COLLATE Latin1_General_BIN2"
+		case "oracle":
+			parts[i] = fmt.Sprintf("NLSSORT(%s, 'NLS_SORT=BINARY')", col)
+		default:
+			parts[i] = col
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func joinQuoted(cols []string, driver string) string {
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = quoteIdent(c, driver)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// diffCursor 按键排序的结果集游标
+type diffCursor struct {
+	rows  *sql.Rows
+	nKeys int
+	kinds []int
+	vals  []interface{}
+	ok    bool
+}
+
+func (c *diffCursor) next() error {
+	if !c.rows.Next() {
+		c.ok = false
+		return c.rows.Err()
+	}
+	cols, err := c.rows.Columns()
+	if err != nil {
+		return err
+	}
+	c.vals = make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range c.vals {
+		ptrs[i] = &c.vals[i]
+	}
+	if err := c.rows.Scan(ptrs...); err != nil {
+		return fmt.Errorf("读取比对数据失败: %w", err)
+	}
+	c.ok = true
+	return nil
+}
+
+func (c *diffCursor) key() []interface{} {
+	return c.vals[:c.nKeys]
+}
+
+// valueHash 非键列规范化后的哈希（数值去掉多余的零，布尔值按 1/0，时间按 UTC）
+func (c *diffCursor) valueHash() uint64 {
+	h := fnv.New64a()
+	for _, v := range c.vals[c.nKeys:] {
+		h.Write([]byte(normalizeDiffValue(v)))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// compareDiffKeys 比较两行的键：数值按数值，字符串按字节，其他按文本
+func compareDiffKeys(a, b []interface{}, kinds []int) int {
+	for i := range a {
+		x, y := diffValueString(a[i]), diffValueString(b[i])
+		if kinds[i] == keyKindNumeric {
+			rx, okx := new(big.Rat).SetString(x)
+			ry, oky := new(big.Rat).SetString(y)
+			if okx && oky {
+				if c := rx.Cmp(ry); c != 0 {
+					return c
+				}
+				continue
+			}
+		}
+		if c := bytes.Compare([]byte(x), []byte(y)); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// diffValueString 把扫描得到的值转为文本（键比较与输出使用）
+func diffValueString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(x)
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05.999999999")
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// normalizeDiffValue 值比较用的规范化文本：能解析为数值的统一为最简分数形式，时间统一为 UTC
+func normalizeDiffValue(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC().Format("2006-01-02 15:04:05.999999999")
+	}
+	s := diffValueString(v)
+	if r, ok := new(big.Rat).SetString(s); ok {
+		return r.RatString()
+	}
+	return s
+}
+
+// formatDiffKey 输出 col=value 形式的键
+func formatDiffKey(cols []string, key []interface{}) string {
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		parts[i] = c + "=" + diffValueString(key[i])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,6 @@
+//go:build odbc
+
+package dbtool
+
+// ODBC 驱动依赖 cgo 与 unixODBC，只在 -tags odbc 时编译，见 odbc.go
+import _ "github.com/alexbrainman/odbc"
@@ -0,0 +1,281 @@
+package dbtool
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 结构化连接配置：未配置 dsn 时由 host、port、user、password、database、ssl_mode、params 按驱动生成 DSN，
+// 用户名与密码按各驱动的语法转义；配置了 dsn 时以 dsn 为准
+
+// defaultPorts 各驱动的默认端口（未配置 port 时使用）
+var defaultPorts = map[string]int{
+	"mysql":     3306,
+	"postgres":  5432,
+	"sqlserver": 1433,
+	"oracle":    1521,
+}
+
+// hasConnectionFields 是否配置了结构化连接字段
+func (c dbConfig) hasConnectionFields() bool {
+	return strings.TrimSpace(c.Host) != "" || strings.TrimSpace(c.Database) != ""
+}
+
+// resolveDSN 未配置 dsn（或 dsn 为 env）时按结构化字段生成，未配置的字段取驱动的常用环境变量；
+// 都未配置时返回错误并列出检查过的环境变量
+func resolveDSN(c *dbConfig) error {
+	if strings.EqualFold(strings.TrimSpace(c.DSN), dsnFromEnv) {
+		c.DSN = ""
+	}
+	if strings.TrimSpace(c.DSN) != "" {
+		if c.hasConnectionFields() {
+			c.log.debugf("数据源 (%s) 同时配置了 dsn 与 host/database，以 dsn 为准\n", c.Driver)
+		}
+		return nil
+	}
+	used, err := applyEnv(c)
+	if err != nil {
+		return err
+	}
+	if !c.hasConnectionFields() {
+		if names := envVarNames(c.Driver); len(names) > 0 {
+			return fmt.Errorf("未配置 dsn，也未配置 host/database，环境变量 %s 均未设置", strings.Join(names, "、"))
+		}
+		return fmt.Errorf("未配置 dsn，也未配置 host/database")
+	}
+	dsn, err := buildDSN(*c)
+	if err != nil {
+		if len(used) > 0 {
+			return fmt.Errorf("%w（使用了环境变量 %s）", err, strings.Join(used, "、"))
+		}
+		return err
+	}
+	registerSecret(c.Password)
+	c.DSN = dsn
+	fromEnv := ""
+	if len(used) > 0 {
+		fromEnv = "（取自环境变量 " + strings.Join(used, "、") + "）"
+	}
+	c.log.debugf("数据源 (%s) 连接参数%s: host=%s port=%d user=%s database=%s ssl_mode=%s，DSN: %s\n",
+		c.Driver, fromEnv, c.Host, c.Port, c.User, c.Database, c.SSLMode, redactDSN(dsn))
+	return nil
+}
+
+// buildDSN 按驱动的语法由结构化字段生成 DSN
+func buildDSN(c dbConfig) (string, error) {
+	driver := normalizeDriver(c.Driver)
+	if isPostgresDriver(driver) {
+		driver = "postgres"
+	}
+	host := strings.TrimSpace(c.Host)
+	port := c.Port
+	if port == 0 {
+		port = defaultPorts[driver]
+	}
+	addr := host
+	if host != "" && port > 0 {
+		addr = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	params := make(map[string]string, len(c.Params))
+	for k, v := range c.Params {
+		params[k] = v
+	}
+	ssl := strings.ToLower(strings.TrimSpace(c.SSLMode))
+	if ssl != "" && !validSSLModes[ssl] {
+		return "", fmt.Errorf("ssl_mode 无效: %q（可选 disable、require、verify-ca、verify-full）", c.SSLMode)
+	}
+	if driver != "sqlite3" && host == "" {
+		return "", fmt.Errorf("驱动 %s 需要配置 host", driver)
+	}
+
+	switch driver {
+	case "mysql":
+		mc := mysql.NewConfig()
+		mc.User, mc.Passwd, mc.Net, mc.Addr, mc.DBName = c.User, c.Password, "tcp", addr, c.Database
+		if tls := mysqlTLS[ssl]; tls != "" {
+			setDefault(params, "tls", tls)
+		}
+		if len(params) > 0 {
+			mc.Params = params
+		}
+		return mc.FormatDSN(), nil
+	case "postgres":
+		if ssl != "" {
+			setDefault(params, "sslmode", ssl)
+		}
+		u := url.URL{Scheme: "postgres", Host: addr, Path: "/" + c.Database, RawQuery: encodeParams(params)}
+		if c.User != "" {
+			u.User = url.UserPassword(c.User, c.Password)
+		}
+		return u.String(), nil
+	case "sqlserver":
+		if c.Database != "" {
+			setDefault(params, "database", c.Database)
+		}
+		switch ssl {
+		case "disable":
+			setDefault(params, "encrypt", "disable")
+		case "require":
+			setDefault(params, "encrypt", "true")
+			setDefault(params, "TrustServerCertificate", "true")
+		case "verify-ca", "verify-full":
+			setDefault(params, "encrypt", "true")
+		}
+		u := url.URL{Scheme: "sqlserver", Host: addr, RawQuery: encodeParams(params)}
+		if c.User != "" {
+			u.User = url.UserPassword(c.User, c.Password)
+		}
+		return u.String(), nil
+	case "oracle":
+		// database 为服务名
+		switch ssl {
+		case "require":
+			setDefault(params, "SSL", "true")
+			setDefault(params, "SSL VERIFY", "false")
+		case "verify-ca", "verify-full":
+			setDefault(params, "SSL", "true")
+		}
+		u := url.URL{Scheme: "oracle", Host: addr, Path: "/" + c.Database, RawQuery: encodeParams(params)}
+		if c.User != "" {
+			u.User = url.UserPassword(c.User, c.Password)
+		}
+		return u.String(), nil
+	case "sqlite3":
+		// database 为数据库文件路径
+		if q := encodeParams(params); q != "" {
+			return c.Database + "?" + q, nil
+		}
+		return c.Database, nil
+	case driverODBC:
+		// ODBC 连接串：值中含 ; 等特殊字符时用 {} 包起来（} 写两次）
+		var parts []string
+		add := func(k, v string) {
+			if v != "" {
+				parts = append(parts, k+"="+odbcValue(v))
+			}
+		}
+		add("SERVER", host)
+		if c.Port > 0 {
+			add("PORT", strconv.Itoa(c.Port))
+		}
+		add("DATABASE", c.Database)
+		add("UID", c.User)
+		add("PWD", c.Password)
+		for _, k := range sortedKeys(params) {
+			add(k, params[k])
+		}
+		return strings.Join(parts, ";"), nil
+	default:
+		return "", fmt.Errorf("驱动 %s 不支持按 host/database 生成 DSN，请配置 dsn", c.Driver)
+	}
+}
+
+// validSSLModes ssl_mode 的可选值（与 Postgres sslmode 同名，其它驱动映射为各自的参数）
+var validSSLModes = map[string]bool{"disable": true, "require": true, "verify-ca": true, "verify-full": true}
+
+// mysqlTLS ssl_mode 对应的 go-sql-driver/mysql tls 参数
+var mysqlTLS = map[string]string{"disable": "false", "require": "skip-verify", "verify-ca": "true", "verify-full": "true"}
+
+// setDefault params 中没有 key 时设置（params 中显式配置的优先）
+func setDefault(params map[string]string, key, value string) {
+	for k := range params {
+		if strings.EqualFold(k, key) {
+			return
+		}
+	}
+	params[key] = value
+}
+
+// encodeParams 按键排序编码为 URL 查询串（生成的 DSN 稳定，便于比较）
+func encodeParams(params map[string]string) string {
+	var parts []string
+	for _, k := range sortedKeys(params) {
+		parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(params[k]))
+	}
+	return strings.Join(parts, "&")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// odbcValue ODBC 连接串中的值：含特殊字符时用 {} 包起来
+func odbcValue(v string) string {
+	if strings.ContainsAny(v, ";{}= ") {
+		return "{" + strings.ReplaceAll(v, "}", "}}") + "}"
+	}
+	return v
+}
+
+// connFlags 命令行单表模式下一侧（源库或目标库）的结构化连接参数
+type connFlags struct {
+	host, user, password, database, sslMode *string
+	port                                    *int
+}
+
+// registerConnFlags 注册 -<side>-host 等参数，label 为“源”或“目标”
+func registerConnFlags(side, label string) connFlags {
+	return connFlags{
+		host:     flag.String(side+"-host", "", label+"数据库主机（未指定 -"+side+"-dsn 时按结构化参数生成 DSN）"),
+		port:     flag.Int(side+"-port", 0, label+"数据库端口（0 表示驱动默认端口）"),
+		user:     flag.String(side+"-user", "", label+"数据库用户名"),
+		password: flag.String(side+"-password", "", label+"数据库密码（特殊字符无需转义）"),
+		database: flag.String(side+"-database", "", label+"数据库名（sqlite3 为文件路径，oracle 为服务名）"),
+		sslMode:  flag.String(side+"-ssl-mode", "", label+"数据库 SSL 模式: disable、require、verify-ca、verify-full"),
+	}
+}
+
+// apply 把命令行参数填入 c（不覆盖已配置的 dsn，由 resolveDSN 决定使用哪个）
+func (f connFlags) apply(c *dbConfig) {
+	c.Host, c.Port, c.User, c.Password, c.Database, c.SSLMode = *f.host, *f.port, *f.user, *f.password, *f.database, *f.sslMode
+}
+
+// runTestConnections 按配置文件测试源库与目标库的连接
+func runTestConnections(configPath string) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fatalf("加载配置文件失败: %v", err)
+	}
+	sourceCfg, targetCfg, _, err := resolveConfig(cfg, nil)
+	if err != nil {
+		fatalf("解析配置失败: %v", err)
+	}
+	testConnections(sourceCfg, targetCfg)
+}
+
+// testConnections 打印两侧实际使用的 DSN（密码隐藏）并逐一连接，任一侧失败时以退出码 1 退出
+func testConnections(source, target dbConfig) {
+	failed := false
+	for _, side := range []struct {
+		label string
+		cfg   dbConfig
+	}{{"源数据库", source}, {"目标数据库", target}} {
+		fmt.Printf("%s (%s): %s\n", side.label, side.cfg.Driver, redactDSN(side.cfg.DSN))
+		start := time.Now()
+		db, err := newSimpleDB(side.cfg)
+		if err != nil {
+			fmt.Printf("  连接失败: %v\n", redactText(err.Error()))
+			failed = true
+			continue
+		}
+		db.Close()
+		fmt.Printf("  连接成功（%s）\n", time.Since(start).Round(time.Millisecond))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 源表重复键的处理策略（check_duplicates_policy）
+const (
+	duplicatesFail  = "fail"  // 发现重复立即失败（默认）
+	duplicatesWarn  = "warn"  // 打印警告后继续复制
+	duplicatesDedup = "dedup" // 打印警告后按 check_duplicates_on 去重复制（已配置 dedup_keys 时以其为准）
+)
+
+const duplicateSampleSize = 10 // 报告中列出的重复键样例数
+
+// checkDuplicates 复制前在源表上按 check_duplicates_on 执行 GROUP BY ... HAVING COUNT(*) > 1，
+// 范围与复制窗口一致（select_sql 表检查查询结果）。发现重复时按策略失败或警告，并列出部分重复键；
+// 返回是否发现了重复键。args 为 select_sql 的参数，未使用 select_sql 时为复制窗口条件的参数
+func checkDuplicates(ctx context.Context, src *simpleDB, opts copyTableOptions, from, selectSQL string, args []interface{}, window []string) (bool, error) {
+	policy := strings.ToLower(strings.TrimSpace(opts.CheckDuplicatesPolicy))
+	switch policy {
+	case "":
+		policy = duplicatesFail
+	case duplicatesFail, duplicatesWarn, duplicatesDedup:
+	default:
+		return false, fmt.Errorf("不支持的 check_duplicates_policy: %s（可选 fail / warn / dedup）", opts.CheckDuplicatesPolicy)
+	}
+
+	cols := joinQuoted(opts.CheckDuplicatesOn, src.cfg.Driver)
+	var query string
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		query = fmt.Sprintf("SELECT %s, COUNT(*) FROM (%s) tmp", cols, selectSQL)
+	} else {
+		query = fmt.Sprintf("SELECT %s, COUNT(*) FROM %s%s", cols, from, whereSuffix(window))
+	}
+	query += fmt.Sprintf(" GROUP BY %s HAVING COUNT(*) > 1", cols)
+
+	opts.Log.infof("检查源表 %s 在 (%s) 上的重复键\n", opts.Table, strings.Join(opts.CheckDuplicatesOn, ", "))
+	rows, err := sourceDB(src, opts).QueryContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("检查重复键失败: %w", err)
+	}
+	defer rows.Close()
+
+	var groups, extraRows int64
+	var samples []string
+	n := len(opts.CheckDuplicatesOn)
+	for rows.Next() {
+		vals := make([]interface{}, n+1)
+		ptrs := make([]interface{}, n+1)
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return false, fmt.Errorf("读取重复键失败: %w", err)
+		}
+		var cnt int64
+		fmt.Sscan(diffValueString(vals[n]), &cnt)
+		groups++
+		extraRows += cnt - 1
+		if len(samples) < duplicateSampleSize {
+			samples = append(samples, fmt.Sprintf("%s（%d 条）", formatDiffKey(opts.CheckDuplicatesOn, vals[:n]), cnt))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("检查重复键失败: %w", err)
+	}
+	if groups == 0 {
+		opts.Log.infof("未发现重复键\n")
+		return false, nil
+	}
+
+	msg := fmt.Sprintf("源表 %s 在 (%s) 上有 %d 个重复键，共多出 %d 行", opts.Table, strings.Join(opts.CheckDuplicatesOn, ", "), groups, extraRows)
+	if policy == duplicatesFail {
+		return true, fmt.Errorf("%s，例如: %s", msg, strings.Join(samples, "; "))
+	}
+	opts.Log.warnf("警告：%s，继续复制。示例:\n", msg)
+	for _, s := range samples {
+		opts.Log.infof("  %s\n", s)
+	}
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package dbtool
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// dsnFromEnv dsn 配置为该值时（或未配置 dsn）按驱动的常用环境变量补全连接参数
+const dsnFromEnv = "env"
+
+// envVars 各驱动读取的环境变量（与 libpq、mysql 客户端、sqlcmd 的约定一致）
+type envVars struct {
+	host, port, user, password, database, sslMode string
+}
+
+var connectionEnvVars = map[string]envVars{
+	"postgres":  {host: "PGHOST", port: "PGPORT", user: "PGUSER", password: "PGPASSWORD", database: "PGDATABASE", sslMode: "PGSSLMODE"},
+	"mysql":     {host: "MYSQL_HOST", port: "MYSQL_TCP_PORT", user: "MYSQL_USER", password: "MYSQL_PWD", database: "MYSQL_DATABASE"},
+	"sqlserver": {host: "SQLCMDSERVER", user: "SQLCMDUSER", password: "SQLCMDPASSWORD", database: "SQLCMDDBNAME"},
+}
+
+// envDriver 驱动对应的环境变量表名（postgresql 等别名归为 postgres）
+func envDriver(driver string) string {
+	driver = normalizeDriver(driver)
+	if isPostgresDriver(driver) {
+		return "postgres"
+	}
+	return driver
+}
+
+// applyEnv 用环境变量补全 c 中未配置的结构化字段（已配置的值优先），返回实际使用的环境变量名
+func applyEnv(c *dbConfig) ([]string, error) {
+	vars, ok := connectionEnvVars[envDriver(c.Driver)]
+	if !ok {
+		return nil, nil
+	}
+	var used []string
+	fill := func(dst *string, name string) {
+		if name == "" || *dst != "" {
+			return
+		}
+		if v := os.Getenv(name); v != "" {
+			*dst = v
+			used = append(used, name)
+		}
+	}
+	fill(&c.Host, vars.host)
+	fill(&c.User, vars.user)
+	fill(&c.Password, vars.password)
+	fill(&c.Database, vars.database)
+	fill(&c.SSLMode, vars.sslMode)
+	if c.Port == 0 && vars.port != "" {
+		if v := strings.TrimSpace(os.Getenv(vars.port)); v != "" {
+			port, err := strconv.Atoi(v)
+			if err != nil || port <= 0 {
+				return used, fmt.Errorf("环境变量 %s 无效: %q", vars.port, v)
+			}
+			c.Port = port
+			used = append(used, vars.port)
+		}
+	}
+	if vars.host == "SQLCMDSERVER" {
+		// sqlcmd 的服务器写法为 host,port
+		if host, port, found := strings.Cut(c.Host, ","); found && c.Port == 0 {
+			p, err := strconv.Atoi(strings.TrimSpace(port))
+			if err != nil || p <= 0 {
+				return used, fmt.Errorf("服务器地址中的端口无效: %q", c.Host)
+			}
+			c.Host, c.Port = strings.TrimSpace(host), p
+		}
+	}
+	return used, nil
+}
+
+// envVarNames 驱动读取的全部环境变量名，用于错误提示
+func envVarNames(driver string) []string {
+	vars, ok := connectionEnvVars[envDriver(driver)]
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, n := range []string{vars.host, vars.port, vars.user, vars.password, vars.database, vars.sslMode} {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
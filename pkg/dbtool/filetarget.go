@@ -0,0 +1,872 @@
+package dbtool
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 文件目标（driver: csv / jsonl / sqldump）：dsn 为输出目录（不存在时创建），不连接数据库，每张表写入目录下的文件，
+// 默认为 <目标表名>.csv / .jsonl / .sql。边读边写，内存占用与表大小无关；先写入同目录下的临时文件，
+// 整张表成功后改名，失败时不会留下写了一半的文件。每次复制覆盖同名文件，增量复制时文件中只有本次复制窗口内的行。
+// 目标记录数为写入文件的行数（不含表头）。
+// - csv：第一行为映射后的目标列名，之后每行一条记录（RFC 4180 引用规则）；二进制列为十六进制，
+//   时间为 2006-01-02 15:04:05.999999。csv_delimiter 字段分隔符（默认逗号，\t 或 tab 为制表符）、
+//   csv_null NULL 的表示（默认空串）、csv_line_ending 换行符 lf（默认）或 crlf
+// - jsonl：每行一个 JSON 对象，键为目标列名（按列顺序）；整数、浮点数与定点数列为 JSON 数字，
+//   时间为 RFC 3339 字符串，NaN/Inf 为 null；jsonl_binary 二进制列的写法 base64（默认）或 string
+// - sqldump：可执行的 SQL 文件（建表语句与多行 INSERT），见 sqldump.go
+// 通用参数：file_name 文件名模板（可含子目录），占位符 {table}（目标表名）、{date}（本轮开始日期 20060102）、
+// {part}（分片编号 0001 起）；gzip 压缩每个文件（追加 .gz）；max_file_rows / max_file_bytes 每个文件的行数 /
+// 字节数（压缩前）上限，超过时写入下一个分片，此时文件名模板必须含 {part}。
+// 建表（sqldump 除外）、删除同步、索引、事务相关的选项对文件没有意义，配置时启动即报错
+
+const (
+	driverCSV     = "csv"
+	driverJSONL   = "jsonl"
+	driverSQLDump = "sqldump"
+)
+
+// fileExtensions 各文件目标的默认扩展名
+var fileExtensions = map[string]string{driverCSV: ".csv", driverJSONL: ".jsonl", driverSQLDump: ".sql"}
+
+// jsonl_binary 的取值
+const (
+	jsonlBinaryBase64 = "base64"
+	jsonlBinaryString = "string"
+)
+
+// fileNamePlaceholder 文件名模板中的占位符
+var fileNamePlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// isFileDriver 驱动是否为文件目标
+func isFileDriver(driver string) bool {
+	switch normalizeDriver(driver) {
+	case driverCSV, driverJSONL, driverSQLDump:
+		return true
+	}
+	return false
+}
+
+// fileTarget 文件目标的输出目录与格式
+type fileTarget struct {
+	dir      string
+	format   string
+	name     string // 文件名模板
+	gzip     bool
+	maxRows  int64
+	maxBytes int64
+
+	comma rune   // csv 字段分隔符
+	crlf  bool   // csv 使用 CRLF 换行
+	null  string // csv 中 NULL 的表示
+
+	binary string // jsonl 中二进制列的写法
+
+	dialect string // sqldump 的方言
+}
+
+// openFileTarget 解析文件目标的参数并创建输出目录
+func openFileTarget(cfg dbConfig) (*fileTarget, error) {
+	dir := strings.TrimSpace(cfg.DSN)
+	if dir == "" {
+		return nil, fmt.Errorf("%s 目标的 dsn 为输出目录，不能为空", cfg.Driver)
+	}
+	f := &fileTarget{dir: dir, format: cfg.Driver, gzip: cfg.Gzip, maxRows: cfg.MaxFileRows, maxBytes: cfg.MaxFileBytes,
+		comma: ',', null: cfg.CSVNull, binary: jsonlBinaryBase64}
+	if f.maxRows < 0 || f.maxBytes < 0 {
+		return nil, fmt.Errorf("max_file_rows、max_file_bytes 不能为负数")
+	}
+	switch d := cfg.CSVDelimiter; strings.ToLower(d) {
+	case "":
+	case `\t`, "tab":
+		f.comma = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(d)
+		if size != len(d) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+			return nil, fmt.Errorf("csv_delimiter 无效: %q（需为单个字符，且不能是双引号或换行符）", d)
+		}
+		f.comma = r
+	}
+	switch v := strings.ToLower(strings.TrimSpace(cfg.CSVLineEnding)); v {
+	case "", "lf":
+	case "crlf":
+		f.crlf = true
+	default:
+		return nil, fmt.Errorf("csv_line_ending 无效: %q（可选 lf、crlf）", cfg.CSVLineEnding)
+	}
+	switch v := strings.ToLower(strings.TrimSpace(cfg.JSONLBinary)); v {
+	case "":
+	case jsonlBinaryBase64, jsonlBinaryString:
+		f.binary = v
+	default:
+		return nil, fmt.Errorf("jsonl_binary 无效: %q（可选 base64、string）", cfg.JSONLBinary)
+	}
+
+	if f.format == driverSQLDump {
+		var err error
+		if f.dialect, err = parseDumpDialect(cfg.DumpDialect); err != nil {
+			return nil, err
+		}
+	}
+
+	split := f.maxRows > 0 || f.maxBytes > 0
+	f.name = strings.TrimSpace(cfg.FileName)
+	if f.name == "" {
+		f.name = "{table}"
+		if split {
+			f.name += "_{part}"
+		}
+		f.name += fileExtensions[f.format]
+	}
+	if f.gzip && !strings.HasSuffix(f.name, ".gz") {
+		f.name += ".gz"
+	}
+	for _, m := range fileNamePlaceholder.FindAllStringSubmatch(f.name, -1) {
+		if m[1] != "table" && m[1] != "date" && m[1] != "part" {
+			return nil, fmt.Errorf("file_name 中的占位符 %s 无效（可用 {table}、{date}、{part}）", m[0])
+		}
+	}
+	if !strings.Contains(f.name, "{table}") {
+		return nil, fmt.Errorf("file_name 必须包含 {table}，否则各表会写入同一个文件")
+	}
+	if split && !strings.Contains(f.name, "{part}") {
+		return nil, fmt.Errorf("配置了 max_file_rows / max_file_bytes 时 file_name 必须包含 {part}")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	return f, nil
+}
+
+// path 按文件名模板生成目标表第 part 个分片的路径（表名中的路径分隔符等替换为下划线）
+func (f *fileTarget) path(table string, date time.Time, part int) string {
+	table = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, table)
+	name := fileNamePlaceholder.ReplaceAllStringFunc(f.name, func(m string) string {
+		switch m {
+		case "{table}":
+			return table
+		case "{date}":
+			return date.Format("20060102")
+		}
+		return fmt.Sprintf("%04d", part)
+	})
+	return filepath.Join(f.dir, name)
+}
+
+// checkFileTargetConfig 检查源库或目标库为文件时配置中不可用的功能
+func checkFileTargetConfig(cfg *toolConfig, run runOptions, source, target dbConfig) error {
+	if isFileDriver(source.Driver) {
+		return fmt.Errorf("%s 只能作为目标（把表导出为文件），不能作为源库", source.Driver)
+	}
+	if !isFileDriver(target.Driver) {
+		return nil
+	}
+	var unsupported []string
+	if strings.EqualFold(strings.TrimSpace(cfg.StateBackend), stateBackendTarget) {
+		unsupported = append(unsupported, "state_backend=target（可用 -state 状态文件）")
+	}
+	if strings.TrimSpace(cfg.HistoryTable) != "" {
+		unsupported = append(unsupported, "history_table")
+	}
+	if firstNonEmpty(strings.TrimSpace(run.LockName), strings.TrimSpace(cfg.LockName)) != "" {
+		unsupported = append(unsupported, "lock_name（可用 -lock-file）")
+	}
+	for _, s := range append(append([]syncScript(nil), cfg.BeforeSync...), cfg.AfterSync...) {
+		name := strings.TrimSpace(s.Source)
+		if name == "target" || isFileDriver(cfg.Sources[name].Driver) {
+			unsupported = append(unsupported, fmt.Sprintf("before_sync / after_sync 在文件目标 %s 上执行 SQL", name))
+			break
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%s 目标不支持: %s", target.Driver, strings.Join(unsupported, "、"))
+	}
+	return nil
+}
+
+// validateFileTarget 检查目标为文件时表配置中不可用的功能
+func validateFileTarget(opts copyTableOptions, target string) error {
+	if !isFileDriver(target) {
+		return nil
+	}
+	var unsupported []string
+	add := func(used bool, name string) {
+		if used {
+			unsupported = append(unsupported, name)
+		}
+	}
+	add(opts.AutoCreate && normalizeDriver(target) != driverSQLDump, "auto_create（每张表写入新文件，不需要建表）")
+	add(opts.SyncDeletes, "sync_deletes（文件每次整体覆盖，没有可删除的目标行）")
+	add(opts.SyncSequences || strings.TrimSpace(opts.SequenceName) != "", "sync_sequences")
+	add(strings.TrimSpace(opts.Indexes) != "" && !strings.EqualFold(strings.TrimSpace(opts.Indexes), indexesNone), "indexes")
+	add(len(opts.RebuildIndexes) > 0, "rebuild_indexes")
+	add(opts.PostgresUnlogged || opts.PostgresSetLogged, "postgres_unlogged / postgres_set_logged")
+	add(opts.Atomic, "atomic（文件写完后才改名，本身就是整表生效）")
+	add(opts.SkipBadRows, "skip_bad_rows")
+	add(strings.TrimSpace(opts.PostBatchSQL) != "", "post_batch_sql")
+	add(strings.TrimSpace(opts.WriteModeTx) != "", "write_mode_tx")
+	add(strings.TrimSpace(opts.BatchTimeout) != "", "batch_timeout")
+	add(opts.ChunkBy != nil, "chunk_by（各区间会覆盖同一个文件）")
+	add(opts.PartitionOf != "", "分区单元（table_list.expand_partitions，各分区会覆盖同一个文件）")
+	add(isAutoSince(opts.Since), "since=auto（无法从文件读取已复制到的位置，可用 -state 记录增量水位）")
+	add(len(opts.VerifyColumns) > 0, "verify_columns")
+	add(opts.DiagnoseDiff, "diagnose_diff")
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%s 目标不支持: %s", target, strings.Join(unsupported, "、"))
+	}
+	return nil
+}
+
+// copyTableToFile 把源表的行逐行写入目标表对应的文件，返回写入的行数；ddl 为 sqldump 写在第一个文件中的建表语句
+func copyTableToFile(ctx context.Context, files *fileTarget, rows sourceRows, cols, insertColumns []string, conv *valueConverter, targetTable, ddl string, opts copyTableOptions, startTime time.Time) (_ int64, _ int64, _ int64, _ float64, copyErr error) {
+	date := opts.AuditRunStart
+	if date.IsZero() {
+		date = startTime
+	}
+	if opts.DryRun {
+		opts.Log.infof("Dry-Run 模式，不写入文件，实际运行时将写入 %s（列: %s）\n", files.path(targetTable, date, 1), strings.Join(insertColumns, ", "))
+		if ddl != "" {
+			opts.Log.infof("文件中的建表语句（%s）：\n%s\n", files.dialect, ddl)
+		}
+		return 0, 0, 0, 0, logDryRunSamples(rows, cols, insertColumns, conv, opts)
+	}
+
+	enc := newRowEncoder(files, targetTable, insertColumns, conv, ddl, opts.BatchSize)
+	out := &fileOutput{target: files, enc: enc, table: targetTable, date: date}
+	defer func() {
+		if copyErr != nil {
+			out.abort()
+		}
+	}()
+	if err := out.open(); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	opts.Log.infof("导出到 %s 文件: %s\n", files.format, out.cur.path)
+
+	totalCount := 0
+	batchCount := 0
+	valuePtrs := make([]interface{}, len(cols))
+	valueHolders := make([]interface{}, len(cols))
+
+	for rows.Next() {
+		for i := range valueHolders {
+			valueHolders[i] = nil
+			valuePtrs[i] = &valueHolders[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("扫描源表行失败: %w", err)
+		}
+		if err := opts.RateLimiter.wait(ctx, 1); err != nil {
+			return 0, 0, 0, 0, err
+		}
+		opts.Watermark.observe(valueHolders)
+
+		args := reorderArgs(cols, insertColumns, valueHolders, opts)
+		if err := conv.convertRow(args); err != nil {
+			opts.Progress.rowError(conv.rowRef(totalCount+1, args), err)
+			return 0, 0, 0, 0, fmt.Errorf("第 %d 行值转换失败: %w", totalCount+1, err)
+		}
+		if hasSpilled(args) {
+			// 溢写的大字段不读回内存，写入时逐块编码
+			clear(valueHolders)
+			pieces, size := enc.encodePieces(args)
+			if err := out.writePieces(pieces, size); err != nil {
+				return 0, 0, 0, 0, err
+			}
+		} else {
+			line, err := enc.encode(args)
+			if err != nil {
+				opts.Progress.rowError(conv.rowRef(totalCount+1, args), err)
+				return 0, 0, 0, 0, fmt.Errorf("第 %d 行: %w", totalCount+1, err)
+			}
+			if err := out.write(line); err != nil {
+				return 0, 0, 0, 0, err
+			}
+		}
+
+		totalCount++
+		batchCount++
+		opts.Progress.add(1)
+
+		if batchCount >= opts.BatchSize {
+			if err := out.flush(); err != nil {
+				return 0, 0, 0, 0, err
+			}
+			elapsed := time.Since(startTime)
+			opts.Log.debugf("已写入 %d 条记录 (速度: %.0f 条/秒)\n", totalCount, float64(totalCount)/elapsed.Seconds())
+			opts.Progress.reportRows()
+			batchCount = 0
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("遍历源表行时出错%s: %w", brokenSide(err, true), err)
+	}
+	if err := out.commit(); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	opts.Progress.batchCommitted()
+
+	durationSeconds := time.Since(startTime).Seconds()
+	opts.Log.infof("========================================\n")
+	opts.Log.infof("表 %s 导出完成\n", opts.Table)
+	opts.Log.infof("========================================\n")
+	opts.Log.infof("导出记录数: %d\n", totalCount)
+	opts.Log.infof("导出文件（%d 个）:\n", len(out.parts))
+	for _, p := range out.parts {
+		opts.Log.infof("  %s: %d 行\n", p.path, p.rows)
+	}
+	logEffectiveRate(opts, int64(totalCount), durationSeconds)
+	conv.logStats()
+	opts.Log.infof("========================================\n")
+
+	return int64(totalCount), 0, int64(totalCount), durationSeconds, nil
+}
+
+// fileOutput 一张表的输出文件：当前分片达到 max_file_rows / max_file_bytes 时切换到下一个分片，全部写完后改名
+type fileOutput struct {
+	target *fileTarget
+	enc    *rowEncoder // 提供每个分片的开头、结尾与 sqldump 的 INSERT 语句
+	table  string
+	date   time.Time
+
+	parts []*filePart
+	cur   *filePart
+}
+
+// filePart 一个输出文件（分片）
+type filePart struct {
+	path    string
+	tmp     *os.File
+	buf     *bufio.Writer
+	gz      *gzip.Writer
+	w       io.Writer
+	rows    int64
+	bytes   int64 // 压缩前的字节数
+	inGroup int   // sqldump 当前 INSERT 语句中已写入的行数
+	done    bool
+}
+
+// open 开始写入下一个分片（写入同目录下的临时文件）
+func (o *fileOutput) open() error {
+	part := len(o.parts) + 1
+	path := o.target.path(o.table, o.date, part)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	p := &filePart{path: path, tmp: tmp, buf: bufio.NewWriterSize(tmp, 64<<10)}
+	p.w = p.buf
+	if o.target.gzip {
+		p.gz = gzip.NewWriter(p.buf)
+		p.w = p.gz
+	}
+	o.parts = append(o.parts, p)
+	o.cur = p
+	return p.put(o.enc.partHeader(part))
+}
+
+// write 写入一行；当前分片已有的行数或加上这一行后的字节数超过上限时，先切换到下一个分片。
+// sqldump 的行写在多行 INSERT 中：语句的第一行前写入语句开头，写满一条语句的行数后写入结尾
+func (o *fileOutput) write(line []byte) error {
+	p, err := o.startRow(int64(len(line)))
+	if err != nil {
+		return err
+	}
+	if err := p.put(line); err != nil {
+		return err
+	}
+	return o.endRow(p)
+}
+
+// writePieces 写入含溢写大字段的一行，size 为编码后的字节数（用于 max_file_bytes）
+func (o *fileOutput) writePieces(pieces []rowPiece, size int64) error {
+	p, err := o.startRow(size)
+	if err != nil {
+		return err
+	}
+	for _, pc := range pieces {
+		if err := p.put(pc.text); err != nil {
+			return err
+		}
+		if pc.value != nil {
+			if err := o.enc.writeSpilled(p, pc.value, pc.col); err != nil {
+				return err
+			}
+		}
+	}
+	return o.endRow(p)
+}
+
+// startRow 开始写入 size 字节的一行：必要时切换到下一个分片，sqldump 写入语句开头或两组值之间的分隔
+func (o *fileOutput) startRow(size int64) (*filePart, error) {
+	p, t := o.cur, o.target
+	if p.rows > 0 && ((t.maxRows > 0 && p.rows >= t.maxRows) || (t.maxBytes > 0 && p.bytes+size > t.maxBytes)) {
+		if err := o.closePart(p); err != nil {
+			return nil, err
+		}
+		if err := o.open(); err != nil {
+			return nil, err
+		}
+		p = o.cur
+	}
+	if d := o.enc.dump; d != nil {
+		prefix := d.rowSep
+		if p.inGroup == 0 {
+			prefix = d.insert
+		}
+		if err := p.put([]byte(prefix)); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// endRow 一行写完：计数，sqldump 写满一条语句的行数后写入结尾
+func (o *fileOutput) endRow(p *filePart) error {
+	p.rows++
+	if d := o.enc.dump; d != nil {
+		if p.inGroup++; p.inGroup >= d.rowsPerInsert {
+			p.inGroup = 0
+			return p.put([]byte(d.insertEnd))
+		}
+	}
+	return nil
+}
+
+// flush 把缓冲的数据写入当前分片的临时文件
+func (o *fileOutput) flush() error {
+	if err := o.cur.buf.Flush(); err != nil {
+		return fmt.Errorf("写入文件 %s 失败: %w", o.cur.path, err)
+	}
+	return nil
+}
+
+// closePart 写入分片的结尾（结束未写满的 INSERT 语句、sqldump 的提交语句）并关闭
+func (o *fileOutput) closePart(p *filePart) error {
+	if d := o.enc.dump; d != nil && p.inGroup > 0 {
+		p.inGroup = 0
+		if err := p.put([]byte(d.insertEnd)); err != nil {
+			return err
+		}
+	}
+	if err := p.put(o.enc.partFooter()); err != nil {
+		return err
+	}
+	return p.close()
+}
+
+// put 写入分片并累计字节数
+func (p *filePart) put(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	n, err := p.w.Write(b)
+	p.bytes += int64(n)
+	if err != nil {
+		return fmt.Errorf("写入文件 %s 失败: %w", p.path, err)
+	}
+	return nil
+}
+
+// close 结束一个分片的写入并关闭临时文件
+func (p *filePart) close() error {
+	if p.done {
+		return nil
+	}
+	p.done = true
+	if p.gz != nil {
+		if err := p.gz.Close(); err != nil {
+			p.tmp.Close()
+			return fmt.Errorf("压缩文件 %s 失败: %w", p.path, err)
+		}
+	}
+	if err := p.buf.Flush(); err != nil {
+		p.tmp.Close()
+		return fmt.Errorf("写入文件 %s 失败: %w", p.path, err)
+	}
+	if err := p.tmp.Chmod(0o644); err != nil {
+		p.tmp.Close()
+		return fmt.Errorf("设置文件 %s 权限失败: %w", p.path, err)
+	}
+	if err := p.tmp.Close(); err != nil {
+		return fmt.Errorf("关闭文件 %s 失败: %w", p.path, err)
+	}
+	return nil
+}
+
+// commit 结束最后一个分片，把所有分片的临时文件改名为正式文件名
+func (o *fileOutput) commit() error {
+	if err := o.closePart(o.cur); err != nil {
+		return err
+	}
+	for _, p := range o.parts {
+		if err := os.Rename(p.tmp.Name(), p.path); err != nil {
+			return fmt.Errorf("重命名临时文件失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// abort 复制失败：关闭并删除所有分片的临时文件（已改名的文件保留）
+func (o *fileOutput) abort() {
+	for _, p := range o.parts {
+		if !p.done {
+			p.done = true
+			p.tmp.Close()
+		}
+		os.Remove(p.tmp.Name())
+	}
+}
+
+// rowEncoder 把一行插入列的值编码为文件中的一行（csv、jsonl 含换行符；sqldump 为 VALUES 中的一组值）
+type rowEncoder struct {
+	target  *fileTarget
+	columns []string
+	conv    *valueConverter
+
+	buf    bytes.Buffer
+	csv    *csv.Writer
+	record []string
+	json   *json.Encoder
+	keys   [][]byte // jsonl 中各列的键（已编码）
+	header []byte   // csv 的表头
+	dump   *sqlDump
+}
+
+func newRowEncoder(f *fileTarget, table string, columns []string, conv *valueConverter, ddl string, batchSize int) *rowEncoder {
+	e := &rowEncoder{target: f, columns: columns, conv: conv}
+	switch f.format {
+	case driverCSV:
+		e.csv = csv.NewWriter(&e.buf)
+		e.csv.Comma = f.comma
+		e.csv.UseCRLF = f.crlf
+		e.record = make([]string, len(columns))
+		e.csv.Write(columns)
+		e.csv.Flush()
+		e.header = append([]byte(nil), e.buf.Bytes()...)
+	case driverJSONL:
+		e.json = json.NewEncoder(&e.buf)
+		e.json.SetEscapeHTML(false)
+		for _, c := range columns {
+			e.keys = append(e.keys, append([]byte(nil), e.encodeJSON(c)...))
+		}
+	case driverSQLDump:
+		e.dump = newSQLDump(f.dialect, table, columns, ddl, batchSize)
+	}
+	return e
+}
+
+// partHeader 第 part 个文件开头的内容：csv 为表头，sqldump 为会话设置、建表语句（第一个文件）与开启事务的语句，jsonl 没有
+func (e *rowEncoder) partHeader(part int) []byte {
+	if e.dump != nil {
+		return []byte(e.dump.header(part))
+	}
+	return e.header
+}
+
+// partFooter 每个文件结尾的内容（sqldump 提交事务、解锁）
+func (e *rowEncoder) partFooter() []byte {
+	if e.dump != nil {
+		return []byte(e.dump.footer())
+	}
+	return nil
+}
+
+// encode 编码一行；返回的切片在下一次调用前有效
+func (e *rowEncoder) encode(args []interface{}) ([]byte, error) {
+	e.buf.Reset()
+	switch {
+	case e.dump != nil:
+		e.buf.WriteString(e.dump.rowStart)
+		for i, arg := range args {
+			if i > 0 {
+				e.buf.WriteString(", ")
+			}
+			e.buf.WriteString(e.dump.literal(arg, e.conv.isBinary(i), e.conv.isDecimal(i)))
+		}
+		e.buf.WriteByte(')')
+		return e.buf.Bytes(), nil
+	case e.csv != nil:
+		for i, arg := range args {
+			e.record[i] = csvValue(arg, e.conv.isBinary(i), e.target.null)
+		}
+		e.csv.Write(e.record)
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return nil, fmt.Errorf("写入 CSV 失败: %w", err)
+		}
+		return e.buf.Bytes(), nil
+	}
+
+	var line []byte
+	line = append(line, '{')
+	for i, arg := range args {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = append(line, e.keys[i]...)
+		line = append(line, ':')
+		line = e.appendJSONValue(line, i, arg)
+	}
+	line = append(line, '}', '\n')
+	return line, nil
+}
+
+// rowPiece 含溢写大字段的一行编码后的一段：先写入 text，再逐块写入溢写的值 value（最后一段没有）
+type rowPiece struct {
+	text  []byte
+	value *spilledValue
+	col   int
+}
+
+// fileChunkSize 文件目标逐块编码溢写值时每块的字节数（3 的倍数，base64 分块编码后可直接拼接）
+const fileChunkSize = lobChunkSize / 3 * 3
+
+// encodePieces 编码含溢写大字段的一行：溢写的值在文件中的前后部分（引号、十六进制前缀等）写在相邻的段中，
+// 值本身由 writeSpilled 逐块编码。返回各段与编码后的字节数
+func (e *rowEncoder) encodePieces(args []interface{}) ([]rowPiece, int64) {
+	var pieces []rowPiece
+	var text []byte
+	size := int64(0)
+	for i, arg := range args {
+		switch {
+		case i == 0 && e.dump != nil:
+			text = append(text, e.dump.rowStart...)
+		case i == 0 && e.json != nil:
+			text = append(text, '{')
+		case i > 0 && e.dump != nil:
+			text = append(text, ", "...)
+		case i > 0 && e.json != nil:
+			text = append(text, ',')
+		case i > 0:
+			text = utf8.AppendRune(text, e.target.comma)
+		}
+		if e.json != nil {
+			text = append(text, e.keys[i]...)
+			text = append(text, ':')
+		}
+		s, ok := arg.(*spilledValue)
+		if !ok {
+			switch {
+			case e.dump != nil:
+				text = append(text, e.dump.literal(arg, e.conv.isBinary(i), e.conv.isDecimal(i))...)
+			case e.json != nil:
+				text = e.appendJSONValue(text, i, arg)
+			default:
+				text = append(text, e.csvField(csvValue(arg, e.conv.isBinary(i), e.target.null))...)
+			}
+			continue
+		}
+		// CSV 中文本总是加引号（不读取内容无法判断是否需要），十六进制不需要
+		prefix, suffix := `"`, `"`
+		if e.csv != nil && e.hexSpilled(i) {
+			prefix, suffix = "", ""
+		} else if e.dump != nil && e.conv.isBinary(i) {
+			prefix, suffix = e.dump.binaryAffixes()
+		} else if e.dump != nil {
+			prefix, suffix = e.dump.textAffixes()
+		}
+		text = append(text, prefix...)
+		size += int64(len(text)) + e.spilledSize(s, i)
+		pieces = append(pieces, rowPiece{text: text, value: s, col: i})
+		text = []byte(suffix)
+	}
+	switch {
+	case e.dump != nil:
+		text = append(text, ')')
+	case e.json != nil:
+		text = append(text, '}', '\n')
+	case e.target.crlf:
+		text = append(text, '\r', '\n')
+	default:
+		text = append(text, '\n')
+	}
+	size += int64(len(text))
+	return append(pieces, rowPiece{text: text}), size
+}
+
+// csvField 一个字段在 CSV 中的写法（按 RFC 4180 需要时加引号）
+func (e *rowEncoder) csvField(s string) []byte {
+	e.buf.Reset()
+	e.csv.Write([]string{s})
+	e.csv.Flush()
+	return bytes.TrimRight(e.buf.Bytes(), "\r\n")
+}
+
+// hexSpilled 溢写的值是否编码为十六进制或 base64（否则为文本，按字符边界分块）
+func (e *rowEncoder) hexSpilled(col int) bool {
+	return e.conv.isBinary(col) && (e.json == nil || e.target.binary == jsonlBinaryBase64)
+}
+
+// spilledSize 溢写的值编码后的字节数（文本按转义前的长度估算）
+func (e *rowEncoder) spilledSize(s *spilledValue, col int) int64 {
+	switch {
+	case !e.hexSpilled(col):
+		return s.size
+	case e.json != nil:
+		return int64(base64.StdEncoding.EncodedLen(int(s.size)))
+	}
+	return s.size * 2
+}
+
+// writeSpilled 从溢写文件逐块读取、编码并写入分片，不把整个值读入内存
+func (e *rowEncoder) writeSpilled(p *filePart, s *spilledValue, col int) error {
+	r, err := s.chunks(fileChunkSize, !e.hexSpilled(col))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	var out []byte
+	for {
+		chunk, err := r.next()
+		if err != nil {
+			return err
+		}
+		if chunk == nil {
+			return nil
+		}
+		out = e.encodeChunk(out[:0], chunk, col)
+		if err := p.put(out); err != nil {
+			return err
+		}
+	}
+}
+
+// encodeChunk 编码溢写值的一块（不含前后的引号），追加到 dst
+func (e *rowEncoder) encodeChunk(dst, chunk []byte, col int) []byte {
+	switch {
+	case e.hexSpilled(col) && e.json != nil:
+		n := len(dst)
+		dst = append(dst, make([]byte, base64.StdEncoding.EncodedLen(len(chunk)))...)
+		base64.StdEncoding.Encode(dst[n:], chunk)
+		return dst
+	case e.hexSpilled(col):
+		n := len(dst)
+		dst = append(dst, make([]byte, hex.EncodedLen(len(chunk)))...)
+		hex.Encode(dst[n:], chunk)
+		return dst
+	case e.dump != nil:
+		lit := sqlLiteral(string(chunk), e.dump.dialect)
+		return append(dst, lit[1:len(lit)-1]...)
+	case e.json != nil:
+		enc := e.encodeJSON(string(chunk))
+		return append(dst, enc[1:len(enc)-1]...)
+	}
+	// CSV 引号内的双引号写两遍；换行符与 encoding/csv 一致（crlf 时 \n 写为 \r\n，单独的 \r 去掉）
+	for _, b := range chunk {
+		switch {
+		case b == '"':
+			dst = append(dst, '"', '"')
+		case b == '\r' && e.target.crlf:
+		case b == '\n' && e.target.crlf:
+			dst = append(dst, '\r', '\n')
+		default:
+			dst = append(dst, b)
+		}
+	}
+	return dst
+}
+
+// appendJSONValue 追加一个值的 JSON 表示：数值列为数字，时间为 RFC 3339，二进制列按 jsonl_binary
+func (e *rowEncoder) appendJSONValue(line []byte, i int, v interface{}) []byte {
+	switch x := v.(type) {
+	case nil:
+		return append(line, "null"...)
+	case bool:
+		return strconv.AppendBool(line, x)
+	case int64:
+		return strconv.AppendInt(line, x, 10)
+	case int, int8, int16, int32, uint, uint8, uint16, uint32, uint64:
+		return append(line, fmt.Sprintf("%d", x)...)
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return append(line, "null"...)
+		}
+		return append(line, e.encodeJSON(x)...)
+	case float32:
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return append(line, "null"...)
+		}
+		return append(line, e.encodeJSON(x)...)
+	case time.Time:
+		return append(line, e.encodeJSON(x.Format(time.RFC3339Nano))...)
+	case []byte:
+		if e.conv.isBinary(i) {
+			if e.target.binary == jsonlBinaryBase64 {
+				return append(line, e.encodeJSON(base64.StdEncoding.EncodeToString(x))...)
+			}
+			return append(line, e.encodeJSON(string(x))...)
+		}
+		return e.appendJSONText(line, i, string(x))
+	case string:
+		return e.appendJSONText(line, i, x)
+	}
+	return append(line, e.encodeJSON(fmt.Sprintf("%v", v))...)
+}
+
+// appendJSONText 追加文本值：定点数列（驱动以文本返回）是合法数字时原样作为 JSON 数字，不损失精度
+func (e *rowEncoder) appendJSONText(line []byte, i int, s string) []byte {
+	if e.conv.isDecimal(i) && isNumberText(s) {
+		return append(line, s...)
+	}
+	return append(line, e.encodeJSON(s)...)
+}
+
+// isNumberText 文本是否为合法的十进制数字（可直接作为 JSON 数字或 SQL 数值字面量）
+func isNumberText(s string) bool {
+	return s != "" && (s[0] == '-' || (s[0] >= '0' && s[0] <= '9')) && json.Valid([]byte(s))
+}
+
+// encodeJSON 编码一个 JSON 值（不转义 HTML 字符），返回的切片在下一次调用前有效
+func (e *rowEncoder) encodeJSON(v interface{}) []byte {
+	e.buf.Reset()
+	if err := e.json.Encode(v); err != nil {
+		return []byte("null")
+	}
+	return bytes.TrimSuffix(e.buf.Bytes(), []byte("\n"))
+}
+
+// csvValue 一个值在 CSV 中的文本：NULL 为 null，二进制列为十六进制，时间精确到微秒
+func csvValue(v interface{}, binary bool, null string) string {
+	switch x := v.(type) {
+	case nil:
+		return null
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05.999999")
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	}
+	if binary {
+		return hexValue(v)
+	}
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,151 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 自动建表时源表生成列的处理方式（generated_columns）
+const (
+	generatedRecreate = "recreate" // 源库与目标库方言相同时重建生成表达式（默认），否则按普通列创建
+	generatedPlain    = "plain"    // 始终按普通列创建，并写入源表中计算好的值
+)
+
+// generatedColumnsForDDL 返回自动建表时需要重建生成表达式的源表生成列；
+// 方言不同、配置为 plain 或取不到表达式的列按普通列创建（返回结果中不包含）
+func generatedColumnsForDDL(ctx context.Context, src, dst *simpleDB, opts copyTableOptions) (map[string]generatedColumn, error) {
+	policy := strings.ToLower(strings.TrimSpace(opts.GeneratedColumns))
+	switch policy {
+	case "":
+		policy = generatedRecreate
+	case generatedRecreate, generatedPlain:
+	default:
+		return nil, fmt.Errorf("generated_columns 仅支持 recreate/plain，当前为 %q", opts.GeneratedColumns)
+	}
+
+	gen, err := fetchGeneratedColumns(ctx, src, opts.Table)
+	if err != nil {
+		opts.Log.warnf("警告：%v，自动建表时不识别源表生成列\n", err)
+		return nil, nil
+	}
+	if len(gen) == 0 {
+		return nil, nil
+	}
+
+	sameDialect := normalizeDriver(src.cfg.Driver) == normalizeDriver(dst.cfg.Driver)
+	out := make(map[string]generatedColumn)
+	var plain []string
+	for key, g := range gen {
+		if policy == generatedRecreate && sameDialect && g.Expression != "" {
+			out[key] = g
+		} else {
+			plain = append(plain, g.Name)
+		}
+	}
+	if len(plain) > 0 {
+		sort.Strings(plain)
+		reason := "generated_columns=plain"
+		if policy == generatedRecreate {
+			reason = "源库与目标库方言不同或取不到生成表达式"
+		}
+		opts.Log.infof("源表生成列 %s 将按普通列创建（%s）\n", strings.Join(plain, ", "), reason)
+	}
+	return out, nil
+}
+
+// generatedColumnDDL 生成目标库中生成列的列定义
+func generatedColumnDDL(quotedName, targetType string, g generatedColumn, driver string) string {
+	switch normalizeDriver(driver) {
+	case "sqlserver":
+		def := fmt.Sprintf("%s AS (%s)", quotedName, g.Expression)
+		if g.Stored {
+			def += " PERSISTED"
+		}
+		return def
+	case "postgres", "postgresql":
+		// Postgres 仅支持 STORED 生成列
+		return fmt.Sprintf("%s %s GENERATED ALWAYS AS (%s) STORED", quotedName, targetType, g.Expression)
+	case "oracle":
+		return fmt.Sprintf("%s %s GENERATED ALWAYS AS (%s) VIRTUAL", quotedName, targetType, g.Expression)
+	default:
+		return fmt.Sprintf("%s %s GENERATED ALWAYS AS (%s) %s", quotedName, targetType, g.Expression, g.kind())
+	}
+}
+
+// excludeGeneratedColumns 从插入列中去掉目标表的生成列/计算列，并打印跳过的列及原因
+func excludeGeneratedColumns(insertCols []string, generated map[string]generatedColumn, logger *tableLogger) []string {
+	if len(generated) == 0 {
+		return insertCols
+	}
+	out := make([]string, 0, len(insertCols))
+	var skipped []string
+	for _, name := range insertCols {
+		if g, ok := generated[strings.ToLower(strings.Trim(name, "`\"[]"))]; ok {
+			skipped = append(skipped, fmt.Sprintf("%s（目标表 %s 生成列）", name, g.kind()))
+			continue
+		}
+		out = append(out, name)
+	}
+	if len(skipped) > 0 {
+		logger.infof("跳过不可写入的生成列: %s\n", strings.Join(skipped, ", "))
+	}
+	return out
+}
+
+// Postgres 标识列/serial 列的写入方式（identity_columns）
+const (
+	identityOverride = "override" // 写入源值，GENERATED ALWAYS 列使用 OVERRIDING SYSTEM VALUE（默认）
+	identitySkip     = "skip"     // 不写入，由目标库生成新值
+)
+
+// applyIdentityColumns 按 identity_columns 处理插入列中的标识列/serial 列
+// 返回处理后的插入列，以及 INSERT 是否需要 OVERRIDING SYSTEM VALUE
+func applyIdentityColumns(insertCols []string, identity map[string]identityColumn, policy string, logger *tableLogger) ([]string, bool, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "":
+		policy = identityOverride
+	case identityOverride, identitySkip:
+	default:
+		return nil, false, fmt.Errorf("identity_columns 仅支持 override/skip，当前为 %q", policy)
+	}
+	if len(identity) == 0 {
+		return insertCols, false, nil
+	}
+
+	out := make([]string, 0, len(insertCols))
+	var found []string
+	overriding := false
+	for _, name := range insertCols {
+		id, ok := identity[strings.ToLower(strings.Trim(name, "`\"[]"))]
+		if !ok {
+			out = append(out, name)
+			continue
+		}
+		kind := "serial"
+		if id.Always {
+			kind = "GENERATED ALWAYS"
+		} else if !id.Serial {
+			kind = "GENERATED BY DEFAULT"
+		}
+		found = append(found, fmt.Sprintf("%s（%s）", name, kind))
+		if policy == identitySkip {
+			continue
+		}
+		if id.Always {
+			overriding = true
+		}
+		out = append(out, name)
+	}
+	if len(found) == 0 {
+		return insertCols, false, nil
+	}
+	if policy == identitySkip {
+		logger.infof("目标表标识列不写入，由目标库生成: %s\n", strings.Join(found, ", "))
+	} else {
+		logger.infof("目标表标识列写入源值: %s（复制后请同步序列）\n", strings.Join(found, ", "))
+	}
+	return out, overriding, nil
+}
@@ -0,0 +1,206 @@
+package dbtool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyErrorMaxBytes 历史表 error_text 的最大长度（Oracle VARCHAR2(4000)）
+const historyErrorMaxBytes = 4000
+
+// newRunID 生成一轮同步的标识：时间加随机后缀，如 20240501-023012-9f3c1a
+func newRunID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// historyTableDDL 返回运行历史表的建表语句（只追加，不设主键）
+func historyTableDDL(table, driver string) string {
+	text, long, ts, num := "VARCHAR(255)", "TEXT", "TIMESTAMP", "BIGINT"
+	switch normalizeDriver(driver) {
+	case "mysql":
+		ts = "DATETIME(6)"
+	case "sqlserver":
+		text, long, ts = "NVARCHAR(255)", "NVARCHAR(MAX)", "DATETIME2"
+	case "oracle":
+		text, long, num = "VARCHAR2(255)", "VARCHAR2(4000)", "NUMBER(19)"
+	case "sqlite3":
+		text, ts, num = "TEXT", "TEXT", "INTEGER"
+	}
+	return fmt.Sprintf(`CREATE TABLE %s (
+  run_id %s NOT NULL,
+  started_at %s,
+  finished_at %s,
+  source_table %s NOT NULL,
+  target_table %s,
+  rows_copied %s,
+  source_count %s,
+  target_count %s,
+  diff %s,
+  status %s NOT NULL,
+  error_text %s,
+  hostname %s,
+  dbtool_version %s
+)`, quoteIdent(table, driver), text, ts, ts, text, text, num, num, num, num, text, long, text, text)
+}
+
+// historyRecorder history_table：每轮每张表在目标库的历史表中追加一行运行记录。
+// 写入失败只打印警告，不影响同步结果；dry-run 时不建表也不写入
+type historyRecorder struct {
+	runner *syncRunner
+	table  string
+	dryRun bool
+	host   string
+
+	mu      sync.Mutex
+	ensured bool
+	targets map[string]string // 源表（或分区父表）-> 目标表
+}
+
+// newHistoryRecorder 未配置 history_table 时返回 nil
+func newHistoryRecorder(r *syncRunner) *historyRecorder {
+	table := strings.TrimSpace(r.cfg.HistoryTable)
+	if table == "" {
+		return nil
+	}
+	h := &historyRecorder{runner: r, table: table, dryRun: r.run.DryRun, targets: tableTargets(r.tables)}
+	h.host, _ = os.Hostname()
+	if h.dryRun {
+		r.log.infof("[DRY-RUN] 不写入运行历史表 %s\n", table)
+	}
+	return h
+}
+
+// tableTargets 返回表清单中源表（或分区父表）到目标表的对应关系
+func tableTargets(tables []configTable) map[string]string {
+	targets := make(map[string]string)
+	for _, t := range tables {
+		// 分区单元失败时按单元名记录，目标表与父表相同
+		name := firstNonEmpty(t.PartitionOf, t.SourceTable)
+		targets[name] = firstNonEmpty(t.TargetTable, name)
+		targets[t.SourceTable] = targets[name]
+	}
+	return targets
+}
+
+// pass 返回一轮同步的观察者，同一轮的记录使用相同的 run_id
+func (h *historyRecorder) pass(runID string) *historyPass {
+	return &historyPass{h: h, runID: runID, started: make(map[string]time.Time), passStart: time.Now()}
+}
+
+// ensure 历史表不存在时创建（每个进程只检查一次，失败时下次写入前重试）
+func (h *historyRecorder) ensure(ctx context.Context, dst *simpleDB) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ensured {
+		return nil
+	}
+	exists, err := checkTableExists(ctx, dst, h.table)
+	if err != nil {
+		return fmt.Errorf("检查历史表 %s 是否存在失败: %w", h.table, err)
+	}
+	if !exists {
+		h.runner.log.infof("创建运行历史表 %s\n", h.table)
+		if _, err := dst.db.ExecContext(ctx, historyTableDDL(h.table, dst.cfg.Driver)); err != nil {
+			return fmt.Errorf("创建历史表 %s 失败: %w", h.table, err)
+		}
+	}
+	h.ensured = true
+	return nil
+}
+
+// historyPass 一轮同步的历史记录
+type historyPass struct {
+	h         *historyRecorder
+	runID     string
+	passStart time.Time
+
+	mu      sync.Mutex
+	started map[string]time.Time
+}
+
+// tableStarted 实现 passObserver：记录表的开始时间
+func (p *historyPass) tableStarted(table string, progress *tableProgress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.started[table]; !ok {
+		p.started[table] = time.Now()
+	}
+}
+
+// tableFinished 实现 passObserver：表完成或失败时写入一行；分区单元不单独记录，由父表的汇总行记录
+func (p *historyPass) tableFinished(table string, result *tableVerificationResult, err error) {
+	if (result == nil && err == nil) || table == "" || p.h.dryRun {
+		return
+	}
+	p.mu.Lock()
+	started, ok := p.started[table]
+	p.mu.Unlock()
+	if !ok {
+		// 分区父表没有单独的开始事件，按本轮开始时间记录
+		started = p.passStart
+	}
+	if werr := p.write(table, started, result, err); werr != nil {
+		p.h.runner.log.warnf("警告：写入运行历史表失败: %v\n", werr)
+	}
+}
+
+// write 追加一行运行记录
+func (p *historyPass) write(table string, started time.Time, result *tableVerificationResult, runErr error) error {
+	h := p.h
+	_, dst := h.runner.conns()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := h.ensure(ctx, dst); err != nil {
+		return err
+	}
+
+	driver := dst.cfg.Driver
+	var rowsCopied, sourceCount, targetCount, diff interface{}
+	status := "success"
+	switch {
+	case runErr != nil:
+		status = "failed"
+	case result.HasDiff:
+		status = "diff"
+	}
+	if result != nil {
+		rowsCopied = result.MigratedCount
+		if result.SourceCount >= 0 {
+			sourceCount = result.SourceCount
+		}
+		if result.TargetCount >= 0 {
+			targetCount = result.TargetCount
+		}
+		if !result.NotCompared {
+			diff = result.Diff
+		}
+	}
+	var errValue interface{}
+	if runErr != nil {
+		errValue = truncateUTF8(errText(runErr), historyErrorMaxBytes)
+	}
+	var startedAt, finishedAt interface{} = started, time.Now()
+	if normalizeDriver(driver) == "sqlite3" {
+		startedAt, finishedAt = started.Format("2006-01-02 15:04:05"), time.Now().Format("2006-01-02 15:04:05")
+	}
+
+	const cols = "run_id, started_at, finished_at, source_table, target_table, rows_copied, source_count, target_count, diff, status, error_text, hostname, dbtool_version"
+	ph := make([]string, 13)
+	for i := range ph {
+		ph[i] = placeholder(i+1, driver)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(h.table, driver), cols, strings.Join(ph, ", "))
+	if _, err := dst.db.ExecContext(ctx, query, p.runID, startedAt, finishedAt, table, h.targets[table],
+		rowsCopied, sourceCount, targetCount, diff, status, errValue, h.host, version); err != nil {
+		return fmt.Errorf("写入历史表 %s 失败: %w", h.table, err)
+	}
+	return nil
+}
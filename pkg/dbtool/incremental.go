@@ -0,0 +1,366 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// incrementalKeys 返回表的增量列：配置了 incremental_keys 时为复合增量列，否则为 incremental_key（未配置时为空）
+func incrementalKeys(opts copyTableOptions) []string {
+	if len(opts.IncrementalKeys) > 0 {
+		return opts.IncrementalKeys
+	}
+	if key := strings.TrimSpace(opts.IncrementalKey); key != "" {
+		return []string{key}
+	}
+	return nil
+}
+
+// validateIncremental 校验增量配置：复合增量列不能与标量的 incremental_key/since/until 混用，
+// since_values/until_values 的个数必须与 incremental_keys 一致；显式配置的 incremental_key_type 必须能解析 since/until
+func validateIncremental(opts copyTableOptions) error {
+	if len(opts.IncrementalKeys) == 0 {
+		if len(opts.SinceTuple) > 0 || len(opts.UntilTuple) > 0 {
+			return fmt.Errorf("since_values/until_values 需要配合 incremental_keys 使用")
+		}
+		if strings.TrimSpace(opts.IncrementalKey) == "" {
+			return nil
+		}
+		return validateKeyTypes(opts)
+	}
+	if strings.TrimSpace(opts.IncrementalKey) != "" {
+		return fmt.Errorf("incremental_keys 不能与 incremental_key 同时配置")
+	}
+	if strings.TrimSpace(opts.Since) != "" || strings.TrimSpace(opts.Until) != "" {
+		return fmt.Errorf("配置 incremental_keys 时请使用 since_values/until_values，不能使用 since/until")
+	}
+	for _, k := range opts.IncrementalKeys {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("incremental_keys 中存在空列名")
+		}
+	}
+	n := len(opts.IncrementalKeys)
+	if len(opts.SinceTuple) > 0 && len(opts.SinceTuple) != n {
+		return fmt.Errorf("since_values 有 %d 个值，与 incremental_keys 的 %d 列不一致", len(opts.SinceTuple), n)
+	}
+	if len(opts.UntilTuple) > 0 && len(opts.UntilTuple) != n {
+		return fmt.Errorf("until_values 有 %d 个值，与 incremental_keys 的 %d 列不一致", len(opts.UntilTuple), n)
+	}
+	return validateKeyTypes(opts)
+}
+
+// validateKeyTypes 显式配置了 incremental_key_type 时，启动前检查 since/until 能否按该类型解析
+func validateKeyTypes(opts copyTableOptions) error {
+	types, err := configuredKeyTypes(opts)
+	if err != nil || types == nil {
+		return err
+	}
+	check := opts
+	check.SinceTuple = append([]interface{}(nil), opts.SinceTuple...)
+	check.UntilTuple = append([]interface{}(nil), opts.UntilTuple...)
+	return applyKeyTypes(&check, types, check.SourceTimezone)
+}
+
+// supportsRowValues 判断方言是否支持行值比较 (a, b) > (x, y)
+func supportsRowValues(driver string) bool {
+	switch normalizeDriver(driver) {
+	case "postgres", "postgresql", "mysql":
+		return true
+	}
+	return false
+}
+
+// tuplePredicate 生成复合增量列的元组比较条件，op 为 ">" 或 "<="：
+// 支持行值比较的方言生成 (a, b) > (x, y)，其余方言展开为 a > x OR (a = x AND b > y)。
+// value(i) 返回第 i 列比较值的 SQL 表达式，按表达式在条件中出现的顺序调用（每次出现调用一次，便于按位置绑定参数）
+func tuplePredicate(cols []string, op string, rowValue bool, value func(i int) string) string {
+	if rowValue {
+		vals := make([]string, len(cols))
+		for i := range cols {
+			vals[i] = value(i)
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(cols, ", "), op, strings.Join(vals, ", "))
+	}
+	// 前面的列严格比较，最后一列使用原运算符（<= 时最后一列取等）
+	strict := strings.TrimSuffix(op, "=")
+	terms := make([]string, len(cols))
+	for i := range cols {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("%s = %s", cols[j], value(j)))
+		}
+		cmp := strict
+		if i == len(cols)-1 {
+			cmp = op
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %s", cols[i], cmp, value(i)))
+		terms[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// tupleString 返回元组的展示形式，如 (2024-01-01 00:00:00, 100)
+func tupleString(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = diffValueString(v)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// stringTuple 把配置中的 since_values/until_values 转换为元组（未配置时为 nil）
+func stringTuple(values []string) []interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = strings.TrimSpace(v)
+	}
+	return out
+}
+
+// 增量列的比较类型（incremental_key_type）
+const (
+	keyTypeInt       = "int"
+	keyTypeFloat     = "float"
+	keyTypeString    = "string"
+	keyTypeTimestamp = "timestamp"
+)
+
+// sinceLayouts 未配置 since_format 时时间类型 since/until 支持的格式
+var sinceLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// configuredKeyTypes 解析 incremental_key_type（复合增量列按逗号分隔、与 incremental_keys 一一对应），未配置时返回 nil（按源列类型自动识别）
+func configuredKeyTypes(opts copyTableOptions) ([]string, error) {
+	raw := strings.TrimSpace(opts.IncrementalKeyType)
+	if raw == "" || strings.EqualFold(raw, "auto") {
+		return nil, nil
+	}
+	parts := strings.Split(raw, ",")
+	if n := len(incrementalKeys(opts)); len(parts) != n {
+		return nil, fmt.Errorf("incremental_key_type 有 %d 个类型，与增量列数 %d 不一致", len(parts), n)
+	}
+	types := make([]string, len(parts))
+	for i, p := range parts {
+		switch t := strings.ToLower(strings.TrimSpace(p)); t {
+		case keyTypeInt, keyTypeFloat, keyTypeString, keyTypeTimestamp:
+			types[i] = t
+		default:
+			return nil, fmt.Errorf("不支持的 incremental_key_type: %s（可选 int、float、string、timestamp）", p)
+		}
+	}
+	return types, nil
+}
+
+// boundParser 解析 since/until 配置值；时间值按 since_timezone（未配置时按源库时区，再无则视为 UTC 墙上时间）解析，
+// 再换算为源库时区的时刻，使源库字面量的墙上时间与源表无时区列的含义一致
+type boundParser struct {
+	layout string
+	loc    *time.Location // 解析边界值的时区
+	srcLoc *time.Location // 源库无时区时间值所在的时区，未配置时为 nil
+}
+
+// newBoundParser 按表配置与源库时区构建解析器
+func newBoundParser(opts copyTableOptions, sourceTZ string) (*boundParser, error) {
+	p := &boundParser{layout: strings.TrimSpace(opts.SinceFormat), loc: time.UTC}
+	if tz := strings.TrimSpace(sourceTZ); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("加载源库时区 %q 失败: %w", tz, err)
+		}
+		p.srcLoc, p.loc = loc, loc
+	}
+	if tz := strings.TrimSpace(opts.SinceTimezone); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("since_timezone: 加载时区 %q 失败: %w", tz, err)
+		}
+		p.loc = loc
+	}
+	return p, nil
+}
+
+// parse 按增量列类型解析一个配置值：
+// int 解析为 int64，float 保留原文的十进制数（json.Number），timestamp 按 since_format（未配置时按常见格式）解析为 time.Time
+func (p *boundParser) parse(s, keyType string) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	switch keyType {
+	case keyTypeInt:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是整数", s)
+		}
+		return n, nil
+	case keyTypeFloat:
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("%q 不是数值", s)
+		}
+		return json.Number(s), nil
+	case keyTypeTimestamp:
+		layouts := sinceLayouts
+		if p.layout != "" {
+			layouts = []string{p.layout}
+		}
+		for _, l := range layouts {
+			if t, err := time.ParseInLocation(l, s, p.loc); err == nil {
+				if p.srcLoc != nil {
+					t = t.In(p.srcLoc)
+				}
+				return t, nil
+			}
+		}
+		if p.layout != "" {
+			return nil, fmt.Errorf("%q 不符合 since_format %q", s, p.layout)
+		}
+		return nil, fmt.Errorf("%q 无法解析为时间（可配置 since_format）", s)
+	default:
+		return s, nil
+	}
+}
+
+// keyTypeOf 按源库报告的列类型识别增量列的比较类型
+func keyTypeOf(ct *sql.ColumnType) string {
+	name := strings.ToUpper(ct.DatabaseTypeName())
+	switch {
+	case strings.Contains(name, "DATE") || strings.Contains(name, "TIME"):
+		return keyTypeTimestamp
+	case isNumericTypeName(name) && !isBooleanTypeName(name) && name != "BIT":
+		if strings.Contains(name, "INT") {
+			return keyTypeInt
+		}
+		if precision, scale, ok := ct.DecimalSize(); ok && precision > 0 && scale == 0 {
+			return keyTypeInt
+		}
+		return keyTypeFloat
+	}
+	return keyTypeString
+}
+
+// detectKeyTypes 查询源表增量列的类型；select_sql 按查询结果中的同名列探测，
+// 结果中没有增量列时按字符串处理（由驱动按绑定参数推断）
+func detectKeyTypes(ctx context.Context, src *simpleDB, opts copyTableOptions, keys []string) ([]string, error) {
+	types := make([]string, len(keys))
+	cols := make([]string, len(keys))
+	for i, k := range keys {
+		cols[i] = quoteIdent(k, src.cfg.Driver)
+	}
+	var query string
+	var args []interface{}
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		// 探测时按配置的原文绑定 since/until（WHERE 1 = 0 不会比较）
+		selectSQL, selectArgs, err := bindSelectSQL(opts.SelectSQL, opts, src.cfg.Driver)
+		if err != nil {
+			return nil, err
+		}
+		query, args = fmt.Sprintf("SELECT %s FROM (%s) tmp WHERE 1 = 0", strings.Join(cols, ", "), selectSQL), selectArgs
+	} else {
+		from, err := sourceFrom(opts, src.cfg.Driver)
+		if err != nil {
+			return nil, err
+		}
+		query = fmt.Sprintf("SELECT %s FROM %s WHERE 1 = 0", strings.Join(cols, ", "), from)
+	}
+	rows, err := src.db.QueryContext(ctx, query, args...)
+	if err != nil && strings.TrimSpace(opts.SelectSQL) != "" {
+		opts.Log.warnf("警告：无法从 select_sql 的结果探测增量列类型（%v），since/until 按字符串绑定，可配置 incremental_key_type\n", err)
+		for i := range types {
+			types[i] = keyTypeString
+		}
+		return types, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询增量列类型失败: %w", err)
+	}
+	defer rows.Close()
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("查询增量列类型失败: %w", err)
+	}
+	for i, ct := range colTypes {
+		types[i] = keyTypeOf(ct)
+	}
+	return types, nil
+}
+
+// resolveIncrementalTypes 按增量列类型把 since/until（含复合增量列的元组）解析为类型化的值，
+// 目标库与 select_sql 按类型绑定参数，源库条件按类型生成字面量（数值不加引号，时间使用时间字面量）。
+// 已是类型化的值（since=auto、状态中的水位）保持不变
+func resolveIncrementalTypes(ctx context.Context, src *simpleDB, opts *copyTableOptions) error {
+	keys := incrementalKeys(*opts)
+	if len(keys) == 0 || !hasIncrementalBounds(*opts) {
+		return nil
+	}
+	types, err := configuredKeyTypes(*opts)
+	if err != nil {
+		return err
+	}
+	if types == nil {
+		if types, err = detectKeyTypes(ctx, src, *opts, keys); err != nil {
+			return err
+		}
+	}
+	if err := applyKeyTypes(opts, types, firstNonEmpty(opts.SourceTimezone, src.cfg.Timezone)); err != nil {
+		return err
+	}
+	opts.Log.infof("增量列 %s 按 %s 比较\n", strings.Join(keys, ", "), strings.Join(types, ", "))
+	return nil
+}
+
+// hasIncrementalBounds 判断是否配置了增量起点或终点
+func hasIncrementalBounds(opts copyTableOptions) bool {
+	return strings.TrimSpace(opts.Since) != "" || strings.TrimSpace(opts.Until) != "" ||
+		len(opts.SinceTuple) > 0 || len(opts.UntilTuple) > 0
+}
+
+// applyKeyTypes 按类型解析尚未类型化的 since/until 值（sourceTZ 为源库无时区时间值所在的时区）
+func applyKeyTypes(opts *copyTableOptions, types []string, sourceTZ string) error {
+	p, err := newBoundParser(*opts, sourceTZ)
+	if err != nil {
+		return err
+	}
+	if len(opts.IncrementalKeys) == 0 {
+		if s := strings.TrimSpace(opts.Since); s != "" && opts.SinceValue == nil && !isAutoSince(s) {
+			v, err := p.parse(s, types[0])
+			if err != nil {
+				return fmt.Errorf("since: %w", err)
+			}
+			opts.SinceValue = v
+		}
+		if s := strings.TrimSpace(opts.Until); s != "" && opts.UntilValue == nil {
+			v, err := p.parse(s, types[0])
+			if err != nil {
+				return fmt.Errorf("until: %w", err)
+			}
+			opts.UntilValue = v
+		}
+		return nil
+	}
+	for _, b := range []struct {
+		name  string
+		tuple []interface{}
+	}{{"since_values", opts.SinceTuple}, {"until_values", opts.UntilTuple}} {
+		for i, v := range b.tuple {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			typed, err := p.parse(s, types[i])
+			if err != nil {
+				return fmt.Errorf("%s[%d]（%s）: %w", b.name, i, opts.IncrementalKeys[i], err)
+			}
+			b.tuple[i] = typed
+		}
+	}
+	return nil
+}
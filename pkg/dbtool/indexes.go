@@ -0,0 +1,314 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// indexInfo 表上的一个索引
+type indexInfo struct {
+	Name       string
+	Unique     bool
+	Primary    bool
+	Columns    []string // 键列
+	Include    []string // 非键列（Postgres / SQL Server 的 INCLUDE 列），不参与唯一性与排序
+	Expression bool     // 含表达式列（无法按列清单重建）
+	Definition string   // 原生建索引语句（Postgres pg_get_indexdef / SQLite sqlite_master.sql），取不到时为空
+}
+
+// fetchIndexes 查询表上的索引（含主键），键列与 INCLUDE 列各按索引内顺序
+func fetchIndexes(ctx context.Context, db *simpleDB, table string) ([]indexInfo, error) {
+	driver := normalizeDriver(db.cfg.Driver)
+
+	var query string
+	var args []interface{}
+	switch driver {
+	case "mysql":
+		query = `SELECT index_name, non_unique = 0, index_name = 'PRIMARY', column_name, '', 0
+FROM information_schema.statistics
+WHERE table_schema = DATABASE() AND table_name = ?
+ORDER BY index_name, seq_in_index`
+		args = append(args, table)
+	case "postgres", "postgresql":
+		// indkey 中前 indnkeyatts 个为键列，其后为 INCLUDE 列
+		query = `SELECT i.relname, ix.indisunique, ix.indisprimary, a.attname, pg_get_indexdef(ix.indexrelid), k.ord > ix.indnkeyatts
+FROM pg_index ix
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON TRUE
+LEFT JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE n.nspname = current_schema() AND t.relname = $1
+ORDER BY i.relname, k.ord`
+		args = append(args, table)
+	case "sqlserver":
+		query = `SELECT i.name, i.is_unique, i.is_primary_key, c.name, '', ic.is_included_column
+FROM sys.indexes i
+JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+WHERE i.object_id = OBJECT_ID(@p1) AND i.name IS NOT NULL
+ORDER BY i.name, ic.is_included_column, ic.key_ordinal, ic.index_column_id`
+		args = append(args, table)
+	case "oracle":
+		query = `SELECT i.index_name, CASE WHEN i.uniqueness = 'UNIQUE' THEN 1 ELSE 0 END,
+  CASE WHEN c.constraint_type = 'P' THEN 1 ELSE 0 END, ic.column_name, '', 0
+FROM user_indexes i
+JOIN user_ind_columns ic ON ic.index_name = i.index_name
+LEFT JOIN user_constraints c ON c.index_name = i.index_name AND c.constraint_type = 'P'
+WHERE i.table_name = :1
+ORDER BY i.index_name, ic.column_position`
+		args = append(args, strings.ToUpper(table))
+	case "sqlite3":
+		query = `SELECT il.name, il."unique", il.origin = 'pk', ii.name, COALESCE(m.sql, ''), 0
+FROM pragma_index_list(?) il
+JOIN pragma_index_info(il.name) ii
+LEFT JOIN sqlite_master m ON m.type = 'index' AND m.name = il.name
+ORDER BY il.name, ii.seqno`
+		args = append(args, table)
+	case driverODBC:
+		// odbc 不读取目录信息
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("暂不支持从驱动 %s 读取索引信息", driver)
+	}
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 索引失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	var out []indexInfo
+	byName := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var unique, primary, included interface{}
+		var col, def sql.NullString
+		if err := rows.Scan(&name, &unique, &primary, &col, &def, &included); err != nil {
+			return nil, fmt.Errorf("读取表 %s 索引失败: %w", table, err)
+		}
+		i, ok := byName[name]
+		if !ok {
+			u, _ := parseBoolValue(unique)
+			p, _ := parseBoolValue(primary)
+			out = append(out, indexInfo{Name: name, Unique: u, Primary: p, Definition: def.String})
+			i = len(out) - 1
+			byName[name] = i
+		}
+		if inc, _ := parseBoolValue(included); inc && col.Valid && col.String != "" {
+			out[i].Include = append(out[i].Include, col.String)
+		} else if col.Valid && col.String != "" {
+			out[i].Columns = append(out[i].Columns, col.String)
+		} else {
+			out[i].Expression = true
+		}
+	}
+	return out, rows.Err()
+}
+
+// createIndexSQL 按列清单生成建索引语句（用于跨库重建，表达式索引无法生成）；
+// INCLUDE 列只在 Postgres / SQL Server 上保留，其他库只按键列建索引（唯一性不受影响）
+func createIndexSQL(idx indexInfo, table string, driver string) string {
+	quoteAll := func(cols []string) string {
+		quoted := make([]string, len(cols))
+		for i, c := range cols {
+			quoted[i] = quoteIdent(c, driver)
+		}
+		return strings.Join(quoted, ", ")
+	}
+	unique := ""
+	if idx.Unique {
+		unique = "UNIQUE "
+	}
+	stmt := fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, quoteIdent(idx.Name, driver), quoteIdent(table, driver), quoteAll(idx.Columns))
+	if len(idx.Include) > 0 && supportsIndexInclude(driver) {
+		stmt += fmt.Sprintf(" INCLUDE (%s)", quoteAll(idx.Include))
+	}
+	return stmt
+}
+
+// supportsIndexInclude 目标库是否支持 CREATE INDEX ... INCLUDE (...)
+func supportsIndexInclude(driver string) bool {
+	switch normalizeDriver(driver) {
+	case "postgres", "postgresql", "sqlserver":
+		return true
+	}
+	return false
+}
+
+// dropIndexSQL 生成删除索引语句
+func dropIndexSQL(name, table, driver string) string {
+	switch normalizeDriver(driver) {
+	case "mysql", "sqlserver":
+		return fmt.Sprintf("DROP INDEX %s ON %s", quoteIdent(name, driver), quoteIdent(table, driver))
+	default:
+		return fmt.Sprintf("DROP INDEX %s", quoteIdent(name, driver))
+	}
+}
+
+// 自动建表时的索引处理方式（indexes）
+const (
+	indexesNone     = "none"     // 不创建主键与索引（默认，与原有行为一致）
+	indexesCreate   = "create"   // 建表时一并创建主键与二级索引
+	indexesDeferred = "deferred" // 建表时只创建主键，二级/唯一索引在数据复制成功后再创建
+)
+
+// sourceIndexesForDDL 读取源表主键与二级索引，并把列名映射为目标列名；返回主键列与二级索引建索引语句
+func sourceIndexesForDDL(ctx context.Context, src *simpleDB, targetTable, dstDriver string, opts copyTableOptions) ([]string, []string, error) {
+	indexes, err := fetchIndexes(ctx, src, opts.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 源列名 -> 目标列名（未在字段映射中出现的列视为未复制）
+	rename := make(map[string]string)
+	for _, c := range opts.Columns {
+		srcCol := strings.TrimSpace(c.Source)
+		if srcCol == "" {
+			continue
+		}
+		rename[strings.ToLower(srcCol)] = firstNonEmpty(strings.TrimSpace(c.Target), srcCol)
+	}
+	mapColumns := func(cols []string) ([]string, bool) {
+		if len(opts.Columns) == 0 {
+			return cols, true
+		}
+		out := make([]string, len(cols))
+		for i, c := range cols {
+			t, ok := rename[strings.ToLower(c)]
+			if !ok {
+				return nil, false
+			}
+			out[i] = t
+		}
+		return out, true
+	}
+
+	var primaryKey, statements []string
+	for _, idx := range indexes {
+		cols, ok := mapColumns(idx.Columns)
+		include, okInclude := mapColumns(idx.Include)
+		if idx.Expression || !ok || !okInclude {
+			opts.Log.infof("索引 %s 含表达式或未复制的列，自动建表时跳过\n", idx.Name)
+			continue
+		}
+		if idx.Primary {
+			primaryKey = cols
+			continue
+		}
+		if len(include) > 0 && !supportsIndexInclude(dstDriver) {
+			opts.Log.infof("目标库不支持 INCLUDE，索引 %s 只按键列创建（不含 %s）\n", idx.Name, strings.Join(include, ", "))
+		}
+		mapped := idx
+		mapped.Columns, mapped.Include = cols, include
+		statements = append(statements, createIndexSQL(mapped, targetTable, dstDriver))
+	}
+	return primaryKey, statements, nil
+}
+
+// indexPlan 单张表在复制前后需要执行的索引操作；按区间复制、分区单元共用同一个计划，
+// 全部区间/单元复制结束后由创建者调用一次 finish
+type indexPlan struct {
+	dst      *simpleDB
+	table    string
+	deferred []string // 复制成功后创建的索引（自动建表且 indexes=deferred）
+	rebuild  []string // 复制前已删除、复制结束后（无论成功与否）需要重建的索引
+	dryRun   bool
+	log      *tableLogger
+	prepared bool    // 已完成复制前的索引处理（自动建表或删除 rebuild_indexes），多个单元共用计划时只处理一次
+	unlogged bool    // 本次运行以 UNLOGGED 创建了目标表，postgres_set_logged 只转换这样的表
+	done     bool    // 已执行过 finish
+	seconds  float64 // 创建索引的耗时（不计入复制耗时）
+}
+
+// newIndexPlan 为写入目标表的复制创建索引计划
+func newIndexPlan(dst *simpleDB, opts copyTableOptions) *indexPlan {
+	return &indexPlan{dst: dst, table: firstNonEmpty(opts.TargetTable, opts.Table), dryRun: opts.DryRun, log: opts.Log}
+}
+
+// dropIndexesForLoad 对已存在的目标表，在复制前删除 rebuild_indexes 中列出的索引；
+// 删除前先读取并打印索引定义，保证复制失败时也能重建
+func (p *indexPlan) dropIndexesForLoad(ctx context.Context, names []string) error {
+	if len(names) == 0 || p.prepared {
+		return nil
+	}
+	p.prepared = true
+	existing, err := fetchIndexes(ctx, p.dst, p.table)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]indexInfo, len(existing))
+	for _, idx := range existing {
+		byName[strings.ToLower(idx.Name)] = idx
+	}
+
+	for _, name := range names {
+		idx, ok := byName[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			p.log.warnf("警告：目标表 %s 上不存在索引 %s，跳过\n", p.table, name)
+			continue
+		}
+		if idx.Primary {
+			return fmt.Errorf("rebuild_indexes 不能包含主键索引 %s", idx.Name)
+		}
+		def := idx.Definition
+		if def == "" {
+			if idx.Expression {
+				return fmt.Errorf("索引 %s 含表达式列，无法自动重建，请从 rebuild_indexes 中移除", idx.Name)
+			}
+			def = createIndexSQL(idx, p.table, p.dst.cfg.Driver)
+		}
+		drop := dropIndexSQL(idx.Name, p.table, p.dst.cfg.Driver)
+		p.log.infof("已记录索引定义: %s\n", def)
+		if p.dryRun {
+			p.log.infof("Dry-Run 模式，将执行: %s\n", drop)
+			p.rebuild = append(p.rebuild, def)
+			continue
+		}
+		if _, err := p.dst.db.ExecContext(ctx, drop); err != nil {
+			return fmt.Errorf("删除索引 %s 失败: %w", idx.Name, err)
+		}
+		p.rebuild = append(p.rebuild, def)
+	}
+	return nil
+}
+
+// finish 复制结束后创建延后的索引并单独统计耗时；预先删除的索引即使复制失败也会重建。
+// 重复调用时只有第一次生效
+func (p *indexPlan) finish(succeeded bool) {
+	if p == nil || p.done {
+		return
+	}
+	p.done = true
+	statements := append([]string(nil), p.rebuild...)
+	if succeeded {
+		statements = append(statements, p.deferred...)
+	} else if len(p.deferred) > 0 {
+		p.log.infof("表 %s 复制失败，跳过延后创建的索引\n", p.table)
+	}
+	if len(statements) == 0 {
+		return
+	}
+
+	start := time.Now()
+	failed := 0
+	for _, stmt := range statements {
+		if p.dryRun {
+			p.log.infof("Dry-Run 模式，复制完成后将执行: %s\n", stmt)
+			continue
+		}
+		if _, err := p.dst.db.ExecContext(context.Background(), stmt); err != nil {
+			failed++
+			p.log.errorf("❌ 创建索引失败: %s: %v\n", stmt, err)
+			continue
+		}
+		p.log.infof("已创建索引: %s\n", stmt)
+	}
+	if !p.dryRun {
+		p.seconds = time.Since(start).Seconds()
+		p.log.infof("索引创建耗时: %.2f 秒（%d 个，失败 %d 个，不计入复制耗时）\n", p.seconds, len(statements), failed)
+	}
+}
@@ -0,0 +1,77 @@
+package dbtool
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateIndexSQLInclude(t *testing.T) {
+	idx := indexInfo{Name: "ix_orders_user", Unique: true, Columns: []string{"user_id"}, Include: []string{"amount"}}
+	cases := []struct {
+		driver  string
+		include bool
+	}{
+		{"postgres", true},
+		{"sqlserver", true},
+		// 其他库不支持 INCLUDE，只按键列建索引，唯一性不变
+		{"mysql", false},
+		{"sqlite3", false},
+	}
+	for _, c := range cases {
+		stmt := createIndexSQL(idx, "orders", c.driver)
+		if !strings.HasPrefix(stmt, "CREATE UNIQUE INDEX") || !strings.Contains(stmt, "("+quoteIdent("user_id", c.driver)+")") {
+			t.Errorf("%s: %s", c.driver, stmt)
+		}
+		if got := strings.Contains(stmt, "INCLUDE ("+quoteIdent("amount", c.driver)+")"); got != c.include {
+			t.Errorf("%s: %s，期望包含 INCLUDE: %v", c.driver, stmt, c.include)
+		}
+	}
+}
+
+func TestDeferredIndexesAfterAllChunks(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	openTestSQLite(t, srcPath, "CREATE TABLE ev (id INTEGER PRIMARY KEY, name TEXT, at TEXT)",
+		"CREATE INDEX idx_ev_name ON ev (name)",
+		"INSERT INTO ev VALUES (1, 'a', '2024-01-01 01:00:00'), (2, 'b', '2024-01-02 01:00:00')")
+	dst := openTestSQLite(t, dstPath)
+	cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "ev", "auto_create": true, "indexes": "deferred",
+ "chunk_by": {"column": "at", "start": "2024-01-01", "end": "2024-01-03", "step": "24h", "progress_file": "`+filepath.Join(dir, "ev.chunk.json")+`"}}]`)
+	logs := &recordLogger{}
+	r, err := openSyncRunner(context.Background(), cfg, runOptions{RunOnce: true, Log: newOutputLogger(logs)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	summary, err := r.runPass(passOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 索引在最后一个区间完成之后才创建，而不是第一个区间建表之后
+	lastChunk, created := -1, -1
+	for i, line := range logs.lines {
+		if strings.Contains(line, "区间 [2024-01-02 00:00:00, 2024-01-03 00:00:00) 完成") {
+			lastChunk = i
+		}
+		if strings.Contains(line, "已创建索引") && created < 0 {
+			created = i
+		}
+	}
+	if lastChunk < 0 || created < lastChunk {
+		t.Fatalf("索引创建（第 %d 行）应在最后一个区间完成（第 %d 行）之后: %q", created, lastChunk, logs.lines)
+	}
+	var n int
+	if err := dst.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_ev_name'").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("目标表上没有延后创建的索引")
+	}
+	// 汇总中索引耗时与复制耗时分开统计
+	if res := summary.results[0]; res.IndexSeconds <= 0 || res.CopySeconds <= 0 {
+		t.Fatalf("复制耗时 %.4f 秒，索引耗时 %.4f 秒，期望分别统计", res.CopySeconds, res.IndexSeconds)
+	}
+}
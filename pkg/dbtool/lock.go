@@ -0,0 +1,278 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exitLockHeld 锁已被其他 dbtool 持有时的退出码（便于定时任务区分“跳过”与“失败”）
+const exitLockHeld = 3
+
+// lockInfo 写入锁文件的持有者信息
+type lockInfo struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+func currentLockInfo() lockInfo {
+	host, _ := os.Hostname()
+	return lockInfo{PID: os.Getpid(), Host: host, StartedAt: time.Now()}
+}
+
+func (i lockInfo) String() string {
+	return fmt.Sprintf("pid %d，主机 %s，开始于 %s", i.PID, i.Host, i.StartedAt.Format("2006-01-02 15:04:05"))
+}
+
+// lockHeldError 锁已被其他进程（或其他主机）持有
+type lockHeldError struct {
+	what   string // 锁文件路径或数据库锁名
+	holder string // 持有者信息，未知时为空
+}
+
+func (e *lockHeldError) Error() string {
+	if e.holder == "" {
+		return fmt.Sprintf("%s 已被另一个 dbtool 持有", e.what)
+	}
+	return fmt.Sprintf("%s 已被另一个 dbtool 持有（%s）", e.what, e.holder)
+}
+
+// exitOnLockError 获取锁失败时退出：锁被占用时以 exitLockHeld 退出，其它错误按致命错误处理
+func exitOnLockError(err error) {
+	var held *lockHeldError
+	if errors.As(err, &held) {
+		errorf("%v，本次不运行\n", err)
+		releaseFileLocks()
+		os.Exit(exitLockHeld)
+	}
+	fatalf("%v", err)
+}
+
+// fileLock -lock-file：单机上防止重叠运行。
+// Unix 上锁随文件描述符释放，进程以任何方式退出都不会留下有效的锁；其它平台以 O_EXCL 创建的锁文件本身就是锁，
+// 由 fatalf 与 Main 中的 recover 在退出前删除，被强制结束时留下的锁文件按其中的 pid 判断持有者是否已退出
+type fileLock struct {
+	path string
+	f    *os.File
+}
+
+// heldFileLocks 本进程持有的锁文件，致命错误与 panic 退出前释放（os.Exit 不执行 defer）
+var heldFileLocks struct {
+	mu    sync.Mutex
+	locks map[*fileLock]bool
+}
+
+// releaseFileLocks 释放本进程持有的全部锁文件
+func releaseFileLocks() {
+	heldFileLocks.mu.Lock()
+	locks := heldFileLocks.locks
+	heldFileLocks.locks = nil
+	heldFileLocks.mu.Unlock()
+	for l := range locks {
+		l.close()
+	}
+}
+
+// releaseLocksOnPanic 在 Main 中 defer：panic 时先释放锁文件再继续 panic（非 Unix 平台的锁文件不会随进程退出失效）
+func releaseLocksOnPanic() {
+	if r := recover(); r != nil {
+		releaseFileLocks()
+		panic(r)
+	}
+}
+
+// acquireFileLock 以非阻塞方式获取锁文件，成功后写入本进程的信息
+func acquireFileLock(path string) (*fileLock, error) {
+	f, err := openLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("写入锁文件失败: %w", err)
+	}
+	data, _ := json.Marshal(currentLockInfo())
+	if _, err := f.WriteAt(append(data, '\n'), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("写入锁文件失败: %w", err)
+	}
+	infof("已获取锁文件 %s\n", path)
+	l := &fileLock{path: path, f: f}
+	heldFileLocks.mu.Lock()
+	if heldFileLocks.locks == nil {
+		heldFileLocks.locks = make(map[*fileLock]bool)
+	}
+	heldFileLocks.locks[l] = true
+	heldFileLocks.mu.Unlock()
+	return l, nil
+}
+
+// readLockHolder 读取锁文件中的持有者信息
+func readLockHolder(f io.ReaderAt) string {
+	buf := make([]byte, 1024)
+	n, _ := f.ReadAt(buf, 0)
+	var info lockInfo
+	if n == 0 || json.Unmarshal(buf[:n], &info) != nil || info.PID == 0 {
+		return ""
+	}
+	return info.String()
+}
+
+// release 清空并释放锁文件（nil 时不做任何事）
+func (l *fileLock) release() {
+	if l == nil {
+		return
+	}
+	heldFileLocks.mu.Lock()
+	held := heldFileLocks.locks[l]
+	delete(heldFileLocks.locks, l)
+	heldFileLocks.mu.Unlock()
+	if held {
+		l.close()
+	}
+}
+
+func (l *fileLock) close() {
+	_ = l.f.Truncate(0)
+	closeLockFile(l.path, l.f)
+}
+
+// dbLock lock_name：在目标库上持有的会话级锁，防止多台主机对同一目标重叠运行。
+// 锁属于一条专用连接，进程退出时随连接断开由数据库释放
+type dbLock struct {
+	db   *simpleDB
+	conn *sql.Conn
+	name string
+}
+
+// dbLockKey Postgres advisory lock 使用的 64 位键
+func dbLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// acquireTargetLock 在目标库上以非阻塞方式获取名为 name 的锁（Postgres、MySQL、SQL Server）
+func acquireTargetLock(cfg dbConfig, name string) (*dbLock, error) {
+	driver := normalizeDriver(cfg.Driver)
+	if !isPostgresDriver(driver) && driver != "mysql" && driver != "sqlserver" {
+		return nil, fmt.Errorf("lock_name 只支持 Postgres、MySQL、SQL Server 目标库，当前为 %s", cfg.Driver)
+	}
+	db, err := newSimpleDB(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("连接目标库获取锁失败: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("连接目标库获取锁失败: %w", err)
+	}
+	l := &dbLock{db: db, conn: conn, name: name}
+
+	var ok bool
+	switch {
+	case isPostgresDriver(driver):
+		err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", dbLockKey(name)).Scan(&ok)
+	case driver == "mysql":
+		var got sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&got)
+		ok = got.Valid && got.Int64 == 1
+	default:
+		var code int
+		err = conn.QueryRowContext(ctx, `DECLARE @r INT;
+EXEC @r = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = 0;
+SELECT @r`, name).Scan(&code)
+		ok = code >= 0
+	}
+	if err != nil {
+		l.close()
+		return nil, fmt.Errorf("获取目标库锁 %s 失败: %w", name, err)
+	}
+	if !ok {
+		holder := l.holder(ctx, driver)
+		l.close()
+		return nil, &lockHeldError{what: "目标库锁 " + name, holder: holder}
+	}
+	cfg.log.infof("已获取目标库锁 %s\n", name)
+	return l, nil
+}
+
+// holder 查询持有锁的会话（尽力而为，查询失败时返回空）
+func (l *dbLock) holder(ctx context.Context, driver string) string {
+	var desc string
+	switch {
+	case isPostgresDriver(driver):
+		key := uint64(dbLockKey(l.name))
+		var pid int
+		var addr, app string
+		var since time.Time
+		err := l.conn.QueryRowContext(ctx, `SELECT a.pid, COALESCE(host(a.client_addr), 'local'), a.application_name, a.backend_start
+FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory' AND l.granted AND l.classid::bigint = $1 AND l.objid::bigint = $2 AND l.objsubid = 1
+LIMIT 1`, int64(key>>32), int64(key&0xffffffff)).Scan(&pid, &addr, &app, &since)
+		if err == nil {
+			desc = fmt.Sprintf("会话 pid %d，客户端 %s %s，连接于 %s", pid, addr, app, since.Format("2006-01-02 15:04:05"))
+		}
+	case driver == "mysql":
+		var id sql.NullInt64
+		if err := l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?)", l.name).Scan(&id); err != nil || !id.Valid {
+			return ""
+		}
+		var host string
+		var secs int64
+		if err := l.conn.QueryRowContext(ctx, "SELECT HOST, TIME FROM information_schema.PROCESSLIST WHERE ID = ?", id.Int64).Scan(&host, &secs); err != nil {
+			return fmt.Sprintf("连接 ID %d", id.Int64)
+		}
+		desc = fmt.Sprintf("连接 ID %d，客户端 %s，已持续 %d 秒", id.Int64, host, secs)
+	default:
+		var sid int
+		var host, program string
+		var since time.Time
+		err := l.conn.QueryRowContext(ctx, `SELECT TOP 1 s.session_id, ISNULL(s.host_name, ''), ISNULL(s.program_name, ''), s.login_time
+FROM sys.dm_tran_locks l JOIN sys.dm_exec_sessions s ON s.session_id = l.request_session_id
+WHERE l.resource_type = 'APPLICATION' AND l.request_status = 'GRANT' AND CHARINDEX(LEFT(@p1, 32), l.resource_description) > 0`, l.name).Scan(&sid, &host, &program, &since)
+		if err == nil {
+			desc = fmt.Sprintf("会话 %d，主机 %s %s，登录于 %s", sid, host, strings.TrimSpace(program), since.Format("2006-01-02 15:04:05"))
+		}
+	}
+	return desc
+}
+
+// release 释放目标库锁并关闭专用连接（nil 时不做任何事）
+func (l *dbLock) release() {
+	if l == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var err error
+	switch driver := normalizeDriver(l.db.cfg.Driver); {
+	case isPostgresDriver(driver):
+		_, err = l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", dbLockKey(l.name))
+	case driver == "mysql":
+		_, err = l.conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", l.name)
+	default:
+		_, err = l.conn.ExecContext(ctx, "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", l.name)
+	}
+	if err != nil {
+		// 连接关闭后数据库同样会释放会话级锁
+		l.db.cfg.log.warnf("警告：释放目标库锁 %s 失败: %v\n", l.name, err)
+	}
+	l.close()
+}
+
+func (l *dbLock) close() {
+	_ = l.conn.Close()
+	_ = l.db.Close()
+}
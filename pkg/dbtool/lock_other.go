@@ -0,0 +1,52 @@
+//go:build !unix
+
+package dbtool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// openLockFile 以 O_EXCL 创建锁文件；文件已存在时，若持有者是本机已退出的进程则视为残留并接管，否则返回 lockHeldError
+func openLockFile(path string) (*os.File, error) {
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("创建锁文件失败: %w", err)
+		}
+		data, _ := os.ReadFile(path)
+		var info lockInfo
+		_ = json.Unmarshal(data, &info)
+		host, _ := os.Hostname()
+		if attempt == 0 && info.PID != 0 && info.Host == host && !processAlive(info.PID) {
+			warnf("警告：锁文件 %s 的持有者（%s）已不存在，接管该锁\n", path, info)
+			_ = os.Remove(path)
+			continue
+		}
+		holder := ""
+		if info.PID != 0 {
+			holder = info.String()
+		}
+		return nil, &lockHeldError{what: "锁文件 " + path, holder: holder}
+	}
+}
+
+// processAlive 判断本机进程是否仍在运行
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
+
+// closeLockFile 关闭并删除锁文件
+func closeLockFile(path string, f *os.File) {
+	_ = f.Close()
+	_ = os.Remove(path)
+}
@@ -0,0 +1,66 @@
+package dbtool
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// lockExitHelper 在子进程中持有锁文件后以致命错误或 panic 退出
+func lockExitHelper(mode, path string) {
+	defer releaseLocksOnPanic()
+	if _, err := acquireFileLock(path); err != nil {
+		os.Exit(9)
+	}
+	if mode == "fatal" {
+		fatalf("致命错误")
+	}
+	panic("崩溃")
+}
+
+func TestAbnormalExitReleasesLockFile(t *testing.T) {
+	if mode := os.Getenv("DBTOOL_LOCK_TEST"); mode != "" {
+		lockExitHelper(mode, os.Getenv("DBTOOL_LOCK_PATH"))
+		return
+	}
+	for _, mode := range []string{"fatal", "panic"} {
+		path := filepath.Join(t.TempDir(), "run.lock")
+		cmd := exec.Command(os.Args[0], "-test.run=^TestAbnormalExitReleasesLockFile$")
+		cmd.Env = append(os.Environ(), "DBTOOL_LOCK_TEST="+mode, "DBTOOL_LOCK_PATH="+path)
+		err := cmd.Run()
+		var exit *exec.ExitError
+		if !errors.As(err, &exit) || exit.ExitCode() == 9 {
+			t.Fatalf("%s: 子进程应异常退出: %v", mode, err)
+		}
+		// 非 Unix 平台删除锁文件，Unix 平台清空持有者信息
+		if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+			t.Errorf("%s: 退出后锁文件仍记录持有者: %s", mode, data)
+		}
+		l, err := acquireFileLock(path)
+		if err != nil {
+			t.Fatalf("%s: 退出后无法重新获取锁: %v", mode, err)
+		}
+		l.release()
+	}
+}
+
+func TestReleaseFileLocksOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.lock")
+	l, err := acquireFileLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acquireFileLock(path); err == nil {
+		t.Fatal("锁被持有时应获取失败")
+	}
+	releaseFileLocks()
+	// 已随 releaseFileLocks 释放，defer 中的 release 不再处理
+	l.release()
+	l2, err := acquireFileLock(path)
+	if err != nil {
+		t.Fatalf("释放后无法重新获取锁: %v", err)
+	}
+	l2.release()
+}
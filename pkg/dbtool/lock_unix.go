@@ -0,0 +1,32 @@
+//go:build unix
+
+package dbtool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// openLockFile 打开锁文件并加非阻塞的排他 flock；已被持有时返回 lockHeldError
+func openLockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开锁文件失败: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		holder := readLockHolder(f)
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, &lockHeldError{what: "锁文件 " + path, holder: holder}
+		}
+		return nil, fmt.Errorf("锁定锁文件失败: %w", err)
+	}
+	return f, nil
+}
+
+// closeLockFile 关闭文件即释放 flock；文件保留，避免删除与其他进程加锁之间的竞争
+func closeLockFile(path string, f *os.File) {
+	_ = f.Close()
+}
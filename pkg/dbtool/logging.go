@@ -0,0 +1,283 @@
+package dbtool
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// logLevel 日志级别：低于当前级别的日志不输出；汇总报告与致命错误不受级别限制
+type logLevel int32
+
+const (
+	levelDebug logLevel = iota // 每批提交、生成的 SELECT/INSERT SQL 与批次耗时
+	levelInfo                  // 默认：表的开始、结束与核对结果
+	levelWarn                  // 警告（统计失败、降级处理等）
+	levelError                 // 错误（-quiet 时只输出错误与最终汇总）
+)
+
+// currentLogLevel 控制台的日志级别，默认 info
+var currentLogLevel atomic.Int32
+
+// fileLogLevel 日志文件（-log-file）的级别：-quiet 只影响控制台，文件仍按 -log-level / log_level 记录
+var fileLogLevel atomic.Int32
+
+// logFile 为 nil 时所有日志只输出到控制台。所有输出都经过 redactWriter 隐藏连接密码
+var (
+	logFile        *rotatingFile
+	consoleLogger  = log.New(redactWriter{os.Stderr}, "", log.LstdFlags)
+	fileOnlyLogger *log.Logger
+)
+
+func init() {
+	log.SetOutput(redactWriter{os.Stderr})
+	currentLogLevel.Store(int32(levelInfo))
+	fileLogLevel.Store(int32(levelInfo))
+}
+
+// parseLogLevel 解析 -log-level / log_level
+func parseLogLevel(s string) (logLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return levelDebug, nil
+	case "", "info":
+		return levelInfo, nil
+	case "warn", "warning":
+		return levelWarn, nil
+	case "error", "quiet":
+		return levelError, nil
+	default:
+		return levelInfo, fmt.Errorf("日志级别无效: %q（可选 debug、info、warn、error）", s)
+	}
+}
+
+// setLogLevel 设置控制台与日志文件的级别
+func setLogLevel(l logLevel) {
+	currentLogLevel.Store(int32(l))
+	fileLogLevel.Store(int32(l))
+}
+
+// consoleEnabled 该级别的日志是否输出到控制台
+func consoleEnabled(l logLevel) bool {
+	return int32(l) >= currentLogLevel.Load()
+}
+
+// logEnabled 该级别的日志是否有任一输出（用于避免在关闭时构造开销较大的日志内容）
+func logEnabled(l logLevel) bool {
+	return consoleEnabled(l) || (logFile != nil && int32(l) >= fileLogLevel.Load())
+}
+
+// logf 按级别分别输出到控制台与日志文件
+func logf(l logLevel, format string, args ...interface{}) {
+	if logFile == nil {
+		if consoleEnabled(l) {
+			log.Printf(format, args...)
+		}
+		return
+	}
+	if !logEnabled(l) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if consoleEnabled(l) {
+		consoleLogger.Output(3, msg)
+	}
+	if int32(l) >= fileLogLevel.Load() {
+		fileOnlyLogger.Output(3, msg)
+	}
+}
+
+// debugf 输出 debug 级别日志
+func debugf(format string, args ...interface{}) { logf(levelDebug, format, args...) }
+
+// infof 输出 info 级别日志
+func infof(format string, args ...interface{}) { logf(levelInfo, format, args...) }
+
+// warnf 输出 warn 级别日志
+func warnf(format string, args ...interface{}) { logf(levelWarn, format, args...) }
+
+// errorf 输出 error 级别日志（非致命错误，如单轮同步失败）
+func errorf(format string, args ...interface{}) { logf(levelError, format, args...) }
+
+// fatalf 输出致命错误并以退出码 1 退出；退出前释放 -lock-file 锁文件（os.Exit 不执行 defer）
+func fatalf(format string, args ...interface{}) {
+	releaseFileLocks()
+	log.Output(2, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// configureLogLevel 按 -quiet、-log-level、配置中的 log_level 的优先级设置日志级别；
+// 在连接数据库之前调用，单表模式与配置文件模式一致
+func configureLogLevel(flagLevel string, quiet bool, configPath string) {
+	name, source := flagLevel, "-log-level"
+	if strings.TrimSpace(name) == "" && strings.TrimSpace(configPath) != "" {
+		// 配置读取失败时在后续流程中报错，这里只取 log_level
+		if cfg, err := loadConfig(configPath); err == nil {
+			name, source = cfg.LogLevel, "log_level"
+		}
+	}
+	l, err := parseLogLevel(name)
+	if err != nil {
+		fatalf("%s: %v", source, err)
+	}
+	setLogLevel(l)
+	if quiet {
+		currentLogLevel.Store(int32(levelError))
+	}
+}
+
+// openLogFile -log-file：之后的日志同时写入文件（按大小轮转）；汇总报告与致命错误经标准 log 同时写入两处。
+// 写入不经缓冲直接落到文件，fatalf 退出前的内容不会丢失
+func openLogFile(path string, maxSizeMB int64, maxBackups int) error {
+	f, err := newRotatingFile(path, maxSizeMB<<20, maxBackups)
+	if err != nil {
+		return err
+	}
+	logFile = f
+	fileOnlyLogger = log.New(redactWriter{f}, "", log.LstdFlags)
+	log.SetOutput(redactWriter{io.MultiWriter(os.Stderr, f)})
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	log.Printf("日志文件: %s\n", abs)
+	return nil
+}
+
+// rotatingFile 按大小轮转的日志文件：超过 maxSize 时把 path 依次改名为 path.1、path.2 ……，最多保留 maxBackups 个旧文件
+type rotatingFile struct {
+	path       string
+	maxSize    int64 // 0 表示不轮转
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// newRotatingFile 以追加方式打开日志文件
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("创建日志目录失败: %w", err)
+		}
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// Write 实现 io.Writer；单条日志不会被拆到两个文件
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			// 轮转失败时继续写当前文件，不丢日志
+			fmt.Fprintf(os.Stderr, "警告：日志文件轮转失败: %v\n", err)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件并依次改名，删除超出保留个数的旧文件（调用方持有 r.mu）
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups <= 0 {
+		_ = os.Remove(r.path)
+	} else {
+		_ = os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			_ = r.open()
+			return err
+		}
+	}
+	return r.open()
+}
+
+// Close 同步并关闭日志文件
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.f.Sync()
+	return r.f.Close()
+}
+
+// tableLogger 处理单张表期间的日志：每行带 [schema.table] 前缀，便于在多表运行的日志中区分各行所属的表；
+// nil 时不带前缀、写控制台与 -log-file。汇总报告不经过它
+type tableLogger struct {
+	prefix string
+	out    Logger // 嵌入方通过 Options.Logger 注入的日志接收者，nil 时写控制台与 -log-file
+}
+
+// newOutputLogger 不带前缀、交给 out 的日志（Syncer 的连接、表清单等表级以外的日志）；out 为 nil 时返回 nil
+func newOutputLogger(out Logger) *tableLogger {
+	if out == nil {
+		return nil
+	}
+	return &tableLogger{out: out}
+}
+
+// newTableLogger 创建表级日志
+func newTableLogger(table string) *tableLogger {
+	// 前缀拼接在格式串中，表名里的 % 需要转义
+	return &tableLogger{prefix: "[" + strings.ReplaceAll(table, "%", "%%") + "] "}
+}
+
+// forTable 输出到同一接收者的表级日志
+func (t *tableLogger) forTable(table string) *tableLogger {
+	l := newTableLogger(table)
+	if t != nil {
+		l.out = t.out
+	}
+	return l
+}
+
+func (t *tableLogger) logf(l logLevel, format string, args ...interface{}) {
+	if t == nil {
+		logf(l, format, args...)
+		return
+	}
+	format = t.prefix + strings.TrimLeft(format, "\n")
+	if t.out == nil {
+		logf(l, format, args...)
+		return
+	}
+	// 接收者的消息已隐藏连接密码，末尾不带换行
+	if consoleEnabled(l) {
+		t.out.Printf("%s", strings.TrimRight(redactText(fmt.Sprintf(format, args...)), "\n"))
+	}
+}
+
+func (t *tableLogger) debugf(format string, args ...interface{}) { t.logf(levelDebug, format, args...) }
+func (t *tableLogger) infof(format string, args ...interface{})  { t.logf(levelInfo, format, args...) }
+func (t *tableLogger) warnf(format string, args ...interface{})  { t.logf(levelWarn, format, args...) }
+func (t *tableLogger) errorf(format string, args ...interface{}) { t.logf(levelError, format, args...) }
@@ -0,0 +1,101 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// max_memory 全局内存预算：缓存中（已读取、尚未提交）的行按 rowSize 估算字节数计入预算，
+// 与 batch_bytes 口径一致。超过预算时：
+// - 本批立即提交（单行本身超过预算时也立即提交），释放占用
+// - 其它持有者占满预算时，读取方等待释放后再缓存新行
+// - 未配置 lob_spill_threshold 时按预算的 1/16 溢写大字段到临时文件
+
+// minMemorySpillThreshold 按 max_memory 推算的大字段溢写阈值下限
+const minMemorySpillThreshold = 64 << 10
+
+// memoryBudget 缓存中行的字节数预算，多张表、多个 goroutine 共用同一个实例；nil 表示不限制
+type memoryBudget struct {
+	limit int64
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	inFlight int64
+}
+
+// newMemoryBudget 创建内存预算；limit 为 0 时返回 nil（不限制）
+func newMemoryBudget(limit int64) (*memoryBudget, error) {
+	if limit < 0 {
+		return nil, fmt.Errorf("max_memory 不能为负数")
+	}
+	if limit == 0 {
+		return nil, nil
+	}
+	m := &memoryBudget{limit: limit}
+	m.cond = sync.NewCond(&m.mu)
+	return m, nil
+}
+
+// acquire 计入 n 字节；held 为调用方自己已持有的字节数。其它持有者占用的字节加上 n 超过预算时等待释放，
+// 只有调用方自己持有时不等待（由调用方提交本批释放），避免单个读取方等待自己
+func (m *memoryBudget) acquire(ctx context.Context, n, held int64) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.inFlight-held > 0 && m.inFlight+n > m.limit {
+		// ctx 取消时唤醒等待
+		stop := context.AfterFunc(ctx, func() {
+			m.mu.Lock()
+			m.cond.Broadcast()
+			m.mu.Unlock()
+		})
+		defer stop()
+		for m.inFlight-held > 0 && m.inFlight+n > m.limit {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			m.cond.Wait()
+		}
+	}
+	m.inFlight += n
+	return nil
+}
+
+// release 释放 n 字节
+func (m *memoryBudget) release(n int64) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.mu.Lock()
+	m.inFlight -= n
+	m.cond.Broadcast()
+	m.mu.Unlock()
+}
+
+// exceeded 缓存中的字节数是否已达到预算
+func (m *memoryBudget) exceeded() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inFlight >= m.limit
+}
+
+// buffered 缓存中的字节数（nil 时为 0）
+func (m *memoryBudget) buffered() int64 {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inFlight
+}
+
+// spillThreshold 未配置 lob_spill_threshold 时的大字段溢写阈值
+func (m *memoryBudget) spillThreshold() int64 {
+	return max(m.limit/16, minMemorySpillThreshold)
+}
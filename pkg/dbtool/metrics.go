@@ -0,0 +1,311 @@
+package dbtool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tableDurationBuckets table_duration_seconds 直方图的桶上界（秒）
+var tableDurationBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200}
+
+// tableMetrics 单张表累计的指标
+type tableMetrics struct {
+	rows      int64
+	batches   int64
+	rowErrors int64
+	// 最近一次核对的记录数，-1 表示未统计
+	sourceRows int64
+	targetRows int64
+	// table_duration_seconds 直方图
+	buckets  []int64
+	count    int64
+	sum      float64
+	running  *tableProgress // 正在复制时的进度（抓取时计入 rows/batches）
+	observed bool           // 已有核对结果
+}
+
+// metricsRegistry 常驻运行（-loop、schedule、-serve）期间持续累计的 Prometheus 指标，
+// 作为 passObserver 接收表级事件；一次性运行时可推送到 pushgateway
+type metricsRegistry struct {
+	tableLabels bool // false 时不输出 table 标签，按全部表汇总（表很多时控制标签基数）
+
+	mu          sync.Mutex
+	tables      map[string]*tableMetrics
+	order       []string
+	runs        map[string]int64 // 按结果统计的运行轮数
+	lastSuccess time.Time
+}
+
+// newMetricsRegistry 创建指标注册表
+func newMetricsRegistry(tableLabels bool) *metricsRegistry {
+	return &metricsRegistry{tableLabels: tableLabels, tables: make(map[string]*tableMetrics), runs: make(map[string]int64)}
+}
+
+// table 返回表的指标（调用方持有 m.mu）
+func (m *metricsRegistry) table(name string) *tableMetrics {
+	t, ok := m.tables[name]
+	if !ok {
+		t = &tableMetrics{sourceRows: -1, targetRows: -1, buckets: make([]int64, len(tableDurationBuckets))}
+		m.tables[name] = t
+		m.order = append(m.order, name)
+	}
+	return t
+}
+
+// tableStarted 实现 passObserver
+func (m *metricsRegistry) tableStarted(table string, progress *tableProgress) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.table(table).running = progress
+}
+
+// tableFinished 实现 passObserver
+func (m *metricsRegistry) tableFinished(table string, result *tableVerificationResult, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.table(table)
+	if p := t.running; p != nil {
+		t.rows += p.rows.Load()
+		t.batches += p.batches.Load()
+		t.rowErrors += p.rowErrors.Load()
+		seconds := time.Since(p.started).Seconds()
+		for i, le := range tableDurationBuckets {
+			if seconds <= le {
+				t.buckets[i]++
+			}
+		}
+		t.count++
+		t.sum += seconds
+		t.running = nil
+	}
+	if result != nil {
+		t.sourceRows, t.targetRows, t.observed = result.SourceCount, result.TargetCount, true
+	}
+}
+
+// passStarted 实现 passLifecycle
+func (m *metricsRegistry) passStarted(tables int) {}
+
+// passFinished 实现 passLifecycle：记录运行结果与最近一次成功的时间
+func (m *metricsRegistry) passFinished(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := "success"
+	if err != nil {
+		status = "failure"
+	} else {
+		m.lastSuccess = time.Now()
+	}
+	m.runs[status]++
+}
+
+// escapeLabel 转义 Prometheus 标签值
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// render 按 Prometheus 文本格式（0.0.4）输出全部指标
+func (m *metricsRegistry) render(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type sample struct {
+		labels string
+		value  float64
+	}
+	// series 按是否输出 table 标签生成各表（或汇总）的样本
+	series := func(value func(t *tableMetrics) (float64, bool)) []sample {
+		var out []sample
+		var total float64
+		seen := false
+		for _, name := range m.order {
+			v, ok := value(m.tables[name])
+			if !ok {
+				continue
+			}
+			if m.tableLabels {
+				out = append(out, sample{labels: fmt.Sprintf(`{table="%s"}`, escapeLabel(name)), value: v})
+			} else {
+				total += v
+				seen = true
+			}
+		}
+		if !m.tableLabels && seen {
+			out = append(out, sample{value: total})
+		}
+		return out
+	}
+	write := func(name, typ, help string, samples []sample) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, s := range samples {
+			fmt.Fprintf(w, "%s%s %s\n", name, s.labels, strconv.FormatFloat(s.value, 'f', -1, 64))
+		}
+	}
+
+	write("dbtool_rows_copied_total", "counter", "已复制到目标库的行数", series(func(t *tableMetrics) (float64, bool) {
+		n := t.rows
+		if t.running != nil {
+			n += t.running.rows.Load()
+		}
+		return float64(n), true
+	}))
+	var batches int64
+	for _, t := range m.tables {
+		batches += t.batches
+		if t.running != nil {
+			batches += t.running.batches.Load()
+		}
+	}
+	write("dbtool_batches_committed_total", "counter", "已提交的批次（事务）数", []sample{{value: float64(batches)}})
+	write("dbtool_row_errors_total", "counter", "导致表复制失败的行错误数（值转换、写入失败）", series(func(t *tableMetrics) (float64, bool) {
+		n := t.rowErrors
+		if t.running != nil {
+			n += t.running.rowErrors.Load()
+		}
+		return float64(n), true
+	}))
+
+	// 直方图：table 标签与 le 标签组合
+	fmt.Fprintf(w, "# HELP dbtool_table_duration_seconds 单张表的复制耗时\n# TYPE dbtool_table_duration_seconds histogram\n")
+	writeHistogram := func(label string, buckets []int64, count int64, sum float64) {
+		sep := ""
+		if label != "" {
+			sep = ","
+		}
+		for i, le := range tableDurationBuckets {
+			fmt.Fprintf(w, "dbtool_table_duration_seconds_bucket{%s%sle=\"%v\"} %d\n", label, sep, le, buckets[i])
+		}
+		fmt.Fprintf(w, "dbtool_table_duration_seconds_bucket{%s%sle=\"+Inf\"} %d\n", label, sep, count)
+		braced := ""
+		if label != "" {
+			braced = "{" + label + "}"
+		}
+		fmt.Fprintf(w, "dbtool_table_duration_seconds_sum%s %s\n", braced, strconv.FormatFloat(sum, 'f', -1, 64))
+		fmt.Fprintf(w, "dbtool_table_duration_seconds_count%s %d\n", braced, count)
+	}
+	if m.tableLabels {
+		for _, name := range m.order {
+			t := m.tables[name]
+			if t.count > 0 {
+				writeHistogram(fmt.Sprintf(`table="%s"`, escapeLabel(name)), t.buckets, t.count, t.sum)
+			}
+		}
+	} else {
+		total := make([]int64, len(tableDurationBuckets))
+		var count int64
+		var sum float64
+		for _, t := range m.tables {
+			for i, n := range t.buckets {
+				total[i] += n
+			}
+			count += t.count
+			sum += t.sum
+		}
+		writeHistogram("", total, count, sum)
+	}
+
+	var current []sample
+	running := 0
+	for _, name := range m.order {
+		if m.tables[name].running != nil {
+			running++
+			if m.tableLabels {
+				current = append(current, sample{labels: fmt.Sprintf(`{table="%s"}`, escapeLabel(name)), value: 1})
+			}
+		}
+	}
+	if !m.tableLabels {
+		current = []sample{{value: float64(running)}}
+	}
+	write("dbtool_current_table", "gauge", "正在复制的表（值为 1；不输出 table 标签时为正在复制的表数）", current)
+
+	write("dbtool_source_rows", "gauge", "最近一次核对的源表记录数", series(func(t *tableMetrics) (float64, bool) {
+		return float64(t.sourceRows), t.observed && t.sourceRows >= 0
+	}))
+	write("dbtool_target_rows", "gauge", "最近一次核对的目标表记录数", series(func(t *tableMetrics) (float64, bool) {
+		return float64(t.targetRows), t.observed && t.targetRows >= 0
+	}))
+
+	statuses := make([]string, 0, len(m.runs))
+	for status := range m.runs {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	var runs []sample
+	for _, status := range statuses {
+		runs = append(runs, sample{labels: fmt.Sprintf(`{status="%s"}`, status), value: float64(m.runs[status])})
+	}
+	write("dbtool_runs_total", "counter", "已结束的同步轮数（按结果）", runs)
+	var success []sample
+	if !m.lastSuccess.IsZero() {
+		success = []sample{{value: float64(m.lastSuccess.Unix())}}
+	}
+	write("dbtool_run_success_timestamp_seconds", "gauge", "最近一次成功结束的同步时间（Unix 秒）", success)
+}
+
+// ServeHTTP 输出 /metrics
+func (m *metricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.render(w)
+}
+
+// listen 在 addr 上提供 /metrics；返回的函数用于关闭
+func (m *metricsRegistry) listen(addr string) (func(), error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		return nil, fmt.Errorf("指标服务启动失败: %w", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	infof("Prometheus 指标: http://%s/metrics\n", addr)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, nil
+}
+
+// push 把当前指标推送到 pushgateway（PUT <url>/metrics/job/dbtool，url 已含 /metrics/job/ 时原样使用）；
+// 失败只打印警告，不影响运行结果
+func (m *metricsRegistry) push(url string) {
+	target := strings.TrimRight(url, "/")
+	if !strings.Contains(target, "/metrics/job/") {
+		target += "/metrics/job/dbtool"
+	}
+	var buf bytes.Buffer
+	m.render(&buf)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, &buf)
+	if err != nil {
+		warnf("警告：推送指标失败: %v\n", err)
+		return
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		warnf("警告：推送指标到 %s 失败: %v\n", target, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		warnf("警告：推送指标到 %s 失败: HTTP %d %s\n", target, resp.StatusCode, strings.TrimSpace(string(body)))
+		return
+	}
+	infof("指标已推送到 %s\n", target)
+}
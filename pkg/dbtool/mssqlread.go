@@ -0,0 +1,81 @@
+package dbtool
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SQL Server 源表的读取方式（mssql_read_hint，数据源级或表级，表级优先）：
+// - NOLOCK：生成的查询在表名后追加 WITH (NOLOCK)，不加共享锁、不阻塞业务写入，但会脏读
+// - READ UNCOMMITTED：读取在 READ UNCOMMITTED 隔离级别的事务中执行，效果同 NOLOCK，自定义 select_sql 也生效
+// - SNAPSHOT：读取在快照隔离的事务中执行，读到事务开始时的一致数据、不阻塞写入；需要数据库开启 ALLOW_SNAPSHOT_ISOLATION
+
+const (
+	mssqlHintNoLock          = "NOLOCK"
+	mssqlHintReadUncommitted = "READ UNCOMMITTED"
+	mssqlHintSnapshot        = "SNAPSHOT"
+)
+
+// mssqlReadMode 解析后的读取方式
+type mssqlReadMode struct {
+	tableHint string             // 追加在表名后的提示，如 " WITH (NOLOCK)"
+	isolation sql.IsolationLevel // 读取事务的隔离级别，LevelDefault 表示不开启事务
+}
+
+// normalizeMSSQLReadHint 规范化 mssql_read_hint（不区分大小写，空格、下划线、连字符等价），为空时返回空串
+func normalizeMSSQLReadHint(s string) (string, error) {
+	v := strings.ToUpper(strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '_' || r == '-' }), " "))
+	switch v {
+	case "":
+		return "", nil
+	case mssqlHintNoLock, mssqlHintReadUncommitted, mssqlHintSnapshot:
+		return v, nil
+	case "READUNCOMMITTED":
+		return mssqlHintReadUncommitted, nil
+	}
+	return "", fmt.Errorf("mssql_read_hint 无效: %q（可选 NOLOCK、READ UNCOMMITTED、SNAPSHOT）", s)
+}
+
+// resolveMSSQLRead 按表级与数据源级的 mssql_read_hint 确定读取方式并说明取舍；
+// 非 SQL Server 源库忽略（打印警告），自定义 select_sql 不追加 NOLOCK（打印警告）
+func resolveMSSQLRead(opts copyTableOptions, src *simpleDB) (mssqlReadMode, error) {
+	hint, err := normalizeMSSQLReadHint(firstNonEmpty(opts.MSSQLReadHint, src.cfg.MSSQLReadHint))
+	if err != nil || hint == "" {
+		return mssqlReadMode{}, err
+	}
+	if normalizeDriver(src.cfg.Driver) != "sqlserver" {
+		opts.Log.warnf("警告：mssql_read_hint 仅对 SQL Server 源库生效，源库为 %s，已忽略\n", src.cfg.Driver)
+		return mssqlReadMode{}, nil
+	}
+	if opts.Snapshot != nil {
+		return mssqlReadMode{}, fmt.Errorf("配置了 consistent_snapshot 时所有表在同一 SNAPSHOT 事务中读取，不能再使用 mssql_read_hint=%s", hint)
+	}
+	switch hint {
+	case mssqlHintNoLock:
+		if strings.TrimSpace(opts.SelectSQL) != "" {
+			opts.Log.warnf("警告：表 %s 使用自定义 SELECT 查询，不追加 WITH (NOLOCK)；需要时请在 select_sql 中自行添加，或改用 mssql_read_hint=READ UNCOMMITTED\n", opts.Table)
+			return mssqlReadMode{}, nil
+		}
+		opts.Log.infof("读取源表使用 WITH (NOLOCK)：不加共享锁、不阻塞业务写入，但可能读到未提交（随后回滚）的数据，或因页拆分重复、遗漏行；数据核对出现差异时请考虑这一点\n")
+		return mssqlReadMode{tableHint: " WITH (NOLOCK)"}, nil
+	case mssqlHintReadUncommitted:
+		opts.Log.infof("读取源表使用 READ UNCOMMITTED 隔离级别：不加共享锁、不阻塞业务写入，但可能读到未提交（随后回滚）的数据，或因页拆分重复、遗漏行；数据核对出现差异时请考虑这一点\n")
+		return mssqlReadMode{isolation: sql.LevelReadUncommitted}, nil
+	default:
+		opts.Log.infof("读取源表使用 SNAPSHOT 隔离级别：读到查询开始时的一致数据、不阻塞业务写入（需要数据库开启 ALLOW_SNAPSHOT_ISOLATION，tempdb 保存行版本）\n")
+		return mssqlReadMode{isolation: sql.LevelSnapshot}, nil
+	}
+}
+
+// txRows 在专用读取事务中执行的查询结果，关闭时结束事务（只读，回滚即可）
+type txRows struct {
+	*sql.Rows
+	tx *sql.Tx
+}
+
+func (r *txRows) Close() error {
+	err := r.Rows.Close()
+	_ = r.tx.Rollback()
+	return err
+}
@@ -0,0 +1,261 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL 源表的读取方式（表级 mysql_read）：
+// - stream（默认）：一条查询流式读取。go-sql-driver 本身逐行读取、不缓存结果集（与 interpolateParams 无关），
+//   但读取方（写入目标库）过慢时，源库发送结果等待超过 net_write_timeout 会断开连接，因此连接时默认设置较大的 net_write_timeout
+// - resume：按主键排序流式读取，读取端连接断开时从已读到的最后一个主键起改为分页读取，继续复制
+// - page：按主键分页（keyset），每页 source_fetch_size 行，每页一条短查询，单页失败时重试该页
+// 分页只适用于生成的查询（有主键，未配置 select_sql、去重、抽样与 limit），不适用时按 stream 读取
+
+const (
+	mysqlReadStream = "stream"
+	mysqlReadResume = "resume"
+	mysqlReadPage   = "page"
+
+	// mysqlNetWriteTimeout 未在 DSN 中配置时设置的源库会话 net_write_timeout（秒）
+	mysqlNetWriteTimeout = "3600"
+	// mysqlReadRetries 分页读取时连续失败的最多重试次数
+	mysqlReadRetries = 5
+)
+
+// withMySQLStreaming MySQL 连接默认设置 net_write_timeout（DSN 已指定时不覆盖），避免写入较慢时源库中断流式读取
+func withMySQLStreaming(driver, dsn string) string {
+	if driver != "mysql" {
+		return dsn
+	}
+	return dsnWithParam(driver, dsn, "net_write_timeout", mysqlNetWriteTimeout)
+}
+
+// logMySQLStreaming 打印 MySQL 连接中影响流式读取的参数
+func logMySQLStreaming(driver, dsn string, logger *tableLogger) {
+	if driver != "mysql" {
+		return
+	}
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return
+	}
+	timeout := func(d time.Duration) string {
+		if d == 0 {
+			return "不限"
+		}
+		return d.String()
+	}
+	logger.debugf("MySQL 读取参数: readTimeout=%s writeTimeout=%s maxAllowedPacket=%d interpolateParams=%v net_write_timeout=%s\n",
+		timeout(mc.ReadTimeout), timeout(mc.WriteTimeout), mc.MaxAllowedPacket, mc.InterpolateParams, mc.Params["net_write_timeout"])
+}
+
+// validateMySQLRead 检查 mysql_read 的取值，为空时返回 stream
+func validateMySQLRead(s string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(s)); v {
+	case "":
+		return mysqlReadStream, nil
+	case mysqlReadStream, mysqlReadResume, mysqlReadPage:
+		return v, nil
+	}
+	return "", fmt.Errorf("mysql_read 无效: %q（可选 stream、resume、page）", s)
+}
+
+// keysetSource 按主键读取所需的查询组成部分
+type keysetSource struct {
+	columns string        // SELECT 列清单
+	from    string        // FROM 表达式
+	where   []string      // 复制窗口条件
+	args    []interface{} // 复制窗口条件的绑定参数
+	sampled bool          // 配置了抽样（不支持分页）
+}
+
+// openMySQLKeyset 源库为 MySQL 且 mysql_read 为 resume / page 时按主键读取；不适用时返回 nil（由调用方按 stream 读取）
+func openMySQLKeyset(ctx context.Context, src *simpleDB, opts copyTableOptions, q keysetSource) (sourceRows, error) {
+	if normalizeDriver(src.cfg.Driver) != "mysql" {
+		return nil, nil
+	}
+	mode, err := validateMySQLRead(opts.MySQLRead)
+	if err != nil || mode == mysqlReadStream {
+		return nil, err
+	}
+	if q.sampled || opts.Limit > 0 {
+		opts.Log.warnf("警告：表 %s 配置了抽样或 limit，mysql_read=%s 不适用，按 stream 读取\n", opts.Table, mode)
+		return nil, nil
+	}
+	keys, err := fetchPrimaryKey(ctx, src, opts.Table)
+	if err != nil || len(keys) == 0 {
+		opts.Log.warnf("警告：表 %s 没有主键（或查询失败: %v），mysql_read=%s 不适用，按 stream 读取\n", opts.Table, err, mode)
+		return nil, nil
+	}
+	fetch := opts.SourceFetchSize
+	if fetch <= 0 {
+		fetch = defaultSourceFetchSize
+	}
+	k := &keysetRows{ctx: ctx, db: sourceDB(src, opts), log: opts.Log, src: q, keys: keys, fetch: fetch, paging: mode == mysqlReadPage}
+	// 一致性快照所在的连接断开后无法在同一快照中续读
+	k.noRetry = opts.Snapshot != nil
+	if k.paging {
+		opts.Log.infof("按主键 (%s) 分页读取源表（每页 %d 行）\n", strings.Join(keys, ", "), fetch)
+	} else {
+		opts.Log.infof("按主键 (%s) 排序流式读取源表，读取中断时从最后读到的主键起分页续读\n", strings.Join(keys, ", "))
+	}
+	if err := k.query(); err != nil {
+		return nil, err
+	}
+	if k.keyIdx, err = k.keyIndexes(); err != nil {
+		k.Close()
+		return nil, err
+	}
+	return k, nil
+}
+
+// keysetRows 按主键排序读取的结果集：流式读取中断后或 page 模式下按 (主键) > (最后读到的值) 分页查询
+type keysetRows struct {
+	ctx    context.Context
+	db     sourceQuerier
+	log    *tableLogger
+	src    keysetSource
+	keys   []string
+	keyIdx []int // 主键在结果列中的位置
+	fetch  int
+	paging bool
+
+	cur     *sql.Rows
+	n       int           // 当前查询已读取的行数
+	last    []interface{} // 最后读到的主键值
+	retries int           // 连续失败次数
+	noRetry bool          // 在一致性快照中读取，连接断开时不重试
+	done    bool
+	err     error
+}
+
+// query 从最后读到的主键之后开始查询（流式读取不加 LIMIT）
+func (k *keysetRows) query() error {
+	where := append([]string(nil), k.src.where...)
+	args := append([]interface{}(nil), k.src.args...)
+	if k.last != nil {
+		cols := make([]string, len(k.keys))
+		marks := make([]string, len(k.keys))
+		for i, key := range k.keys {
+			cols[i], marks[i] = quoteIdent(key, "mysql"), "?"
+		}
+		where = append(where, fmt.Sprintf("(%s) > (%s)", strings.Join(cols, ", "), strings.Join(marks, ", ")))
+		args = append(args, k.last...)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", k.src.columns, k.src.from)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	order := make([]string, len(k.keys))
+	for i, key := range k.keys {
+		order[i] = quoteIdent(key, "mysql")
+	}
+	query += " ORDER BY " + strings.Join(order, ", ")
+	if k.paging {
+		query += fmt.Sprintf(" LIMIT %d", k.fetch)
+	}
+	k.log.debugf("源表查询 SQL: %s\n", query)
+	rows, err := k.db.QueryContext(k.ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	k.cur, k.n = rows, 0
+	return nil
+}
+
+// keyIndexes 主键在结果列中的位置（结果中不含主键列时无法分页）
+func (k *keysetRows) keyIndexes() ([]int, error) {
+	cols, err := k.cur.Columns()
+	if err != nil {
+		return nil, err
+	}
+	idx := make([]int, len(k.keys))
+	for i, key := range k.keys {
+		idx[i] = -1
+		for j, c := range cols {
+			if strings.EqualFold(c, key) {
+				idx[i] = j
+				break
+			}
+		}
+		if idx[i] < 0 {
+			return nil, fmt.Errorf("读取的列中不含主键列 %s，无法按主键分页（请在 columns 中保留该列或改用 mysql_read=stream）", key)
+		}
+	}
+	return idx, nil
+}
+
+func (k *keysetRows) Next() bool {
+	var err error
+	for !k.done && k.err == nil {
+		if k.cur != nil {
+			if k.cur.Next() {
+				k.n++
+				return true
+			}
+			err = k.cur.Err()
+			_ = k.cur.Close()
+			k.cur = nil
+			if err == nil {
+				if !k.paging || k.n < k.fetch {
+					k.done = true
+					return false
+				}
+				k.retries = 0
+			}
+		}
+		if err != nil {
+			if !isConnectionError(err) || k.noRetry || k.retries >= mysqlReadRetries || k.ctx.Err() != nil {
+				k.err = err
+				return false
+			}
+			k.retries++
+			k.paging = true
+			k.log.warnf("警告：读取源表时源库连接断开（%v），%d 秒后第 %d 次从最后读到的主键起分页续读\n", err, k.retries, k.retries)
+			select {
+			case <-k.ctx.Done():
+				k.err = k.ctx.Err()
+				return false
+			case <-time.After(time.Duration(k.retries) * time.Second):
+			}
+		}
+		err = k.query()
+	}
+	return false
+}
+
+// Scan 读取当前行并记录主键值（复制循环按 *interface{} 接收，值已由 database/sql 复制）
+func (k *keysetRows) Scan(dest ...interface{}) error {
+	if err := k.cur.Scan(dest...); err != nil {
+		return err
+	}
+	last := make([]interface{}, len(k.keyIdx))
+	for i, j := range k.keyIdx {
+		p, ok := dest[j].(*interface{})
+		if !ok {
+			return fmt.Errorf("按主键分页读取需要以 *interface{} 接收主键列")
+		}
+		last[i] = *p
+	}
+	k.last = last
+	return nil
+}
+
+func (k *keysetRows) Err() error { return k.err }
+
+func (k *keysetRows) Columns() ([]string, error) { return k.cur.Columns() }
+
+func (k *keysetRows) ColumnTypes() ([]*sql.ColumnType, error) { return k.cur.ColumnTypes() }
+
+func (k *keysetRows) Close() error {
+	if k.cur == nil {
+		return nil
+	}
+	return k.cur.Close()
+}
@@ -0,0 +1,400 @@
+package dbtool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultNotifyTimeout 发送通知的默认超时
+const defaultNotifyTimeout = 10 * time.Second
+
+// notifyRetryDelay 通知发送失败后重试前的等待时间
+const notifyRetryDelay = 2 * time.Second
+
+// 通知事件
+const (
+	notifyRunFinished = "run_finished" // 一轮同步结束（成功、存在差异或失败）
+	notifyTableFailed = "table_failed" // 单张表失败（on_table_failure）
+	notifyTest        = "test"         // -notify-test 发送的示例
+)
+
+// notificationConfig 配置根下的 notifications：同步结束或失败时发送通知
+type notificationConfig struct {
+	WebhookURL string `json:"webhook_url,omitempty"` // 以 POST JSON 发送通知的地址
+	// Timeout 单次发送的超时（如 10s），默认 10s；失败时重试一次
+	Timeout string `json:"timeout,omitempty"`
+	// OnTableFailure 除了运行结束时的通知外，每张失败的表再单独发送一次
+	OnTableFailure bool `json:"on_table_failure,omitempty"`
+	// Policy 何时发送：always（默认，每轮结束都发送）/ failure（只在失败时）/ diff（失败或存在差异时）；
+	// 各渠道可用自己的 policy 覆盖
+	Policy   string          `json:"policy,omitempty"`
+	DingTalk *dingtalkConfig `json:"dingtalk,omitempty"` // 钉钉机器人
+	WeCom    *wecomConfig    `json:"wecom,omitempty"`    // 企业微信群机器人
+	Slack    *slackConfig    `json:"slack,omitempty"`    // Slack incoming webhook
+	Email    *emailConfig    `json:"email,omitempty"`    // SMTP 邮件
+}
+
+// 通知策略
+const (
+	notifyPolicyAlways  = "always"
+	notifyPolicyFailure = "failure"
+	notifyPolicyDiff    = "diff"
+)
+
+// parseNotifyPolicy 校验策略，空值继承 fallback
+func parseNotifyPolicy(field, v, fallback string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(v)); p {
+	case "":
+		return fallback, nil
+	case notifyPolicyAlways, notifyPolicyFailure, notifyPolicyDiff:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%s 无效: %q（可选 always、failure、diff）", field, v)
+	}
+}
+
+// policyAllows 按策略判断是否发送：测试通知总是发送，单表失败视为失败
+func policyAllows(policy string, n runNotification) bool {
+	if n.Event == notifyTest {
+		return true
+	}
+	failed := n.Status == "failed" || n.Event == notifyTableFailed
+	switch policy {
+	case notifyPolicyFailure:
+		return failed
+	case notifyPolicyDiff:
+		return failed || n.Status == "diff"
+	default:
+		return true
+	}
+}
+
+// runNotification 通知内容
+type runNotification struct {
+	Event           string                    `json:"event"`  // run_finished / table_failed / test
+	Status          string                    `json:"status"` // success / diff / failed / stopped
+	Host            string                    `json:"host,omitempty"`
+	StartedAt       time.Time                 `json:"started_at"`
+	FinishedAt      time.Time                 `json:"finished_at"`
+	DurationSeconds float64                   `json:"duration_seconds"`
+	Tables          int                       `json:"tables"`
+	DiffTables      int                       `json:"diff_tables"`
+	TotalRows       int64                     `json:"total_rows"` // 迁移的总行数
+	Table           string                    `json:"table,omitempty"`
+	Error           string                    `json:"error,omitempty"` // 第一条错误信息
+	Results         []tableVerificationResult `json:"results"`         // 各表核对结果（差异见 diff / has_diff）
+	Version         string                    `json:"dbtool_version"`
+
+	reports []notifyAttachment // 运行结束时按 -report-json / -report-html 生成的报告，邮件作为附件发送
+}
+
+// notifyAttachment 通知附带的报告文件
+type notifyAttachment struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// notifySender 一种通知渠道
+type notifySender interface {
+	name() string
+	send(ctx context.Context, n runNotification) error
+}
+
+// webhookSender 以 JSON POST 到 webhook_url
+type webhookSender struct {
+	url string
+}
+
+func (w *webhookSender) name() string { return "webhook" }
+
+func (w *webhookSender) send(ctx context.Context, n runNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+	_, err = postJSONResponse(ctx, w.url, body)
+	return err
+}
+
+// postJSONResponse 发送 JSON 请求并返回响应体（最多 64KB），非 2xx 响应视为失败
+func postJSONResponse(ctx context.Context, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	text, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		if len(text) > 512 {
+			text = text[:512]
+		}
+		return nil, fmt.Errorf("HTTP %d %s", resp.StatusCode, strings.TrimSpace(string(text)))
+	}
+	return text, nil
+}
+
+// notifyChannel 一个通知渠道及其发送策略
+type notifyChannel struct {
+	sender notifySender
+	policy string
+}
+
+// notifier 按 notifications 配置发送通知；作为 passObserver 收集各表结果，一轮结束时发送汇总
+type notifier struct {
+	cfg     notificationConfig
+	timeout time.Duration
+	senders []notifyChannel
+	log     *tableLogger  // 发送结果的日志输出，nil 时写控制台
+	reports reportOptions // 启用的报告输出，运行结束的邮件附带相同内容的报告
+
+	mu       sync.Mutex
+	started  time.Time
+	summary  *verificationSummary
+	firstErr string
+}
+
+// newNotifier 根据配置创建通知；未配置任何渠道时返回 nil
+func newNotifier(cfg *notificationConfig) (*notifier, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	n := &notifier{cfg: *cfg, timeout: defaultNotifyTimeout, summary: &verificationSummary{}}
+	if t := strings.TrimSpace(cfg.Timeout); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("notifications.timeout 无效: %q", cfg.Timeout)
+		}
+		n.timeout = d
+	}
+	policy, err := parseNotifyPolicy("notifications.policy", cfg.Policy, notifyPolicyAlways)
+	if err != nil {
+		return nil, err
+	}
+	if url := strings.TrimSpace(cfg.WebhookURL); url != "" {
+		n.senders = append(n.senders, notifyChannel{sender: &webhookSender{url: url}, policy: policy})
+	}
+	if d := cfg.DingTalk; d != nil {
+		if strings.TrimSpace(d.WebhookURL) == "" && strings.TrimSpace(d.AccessToken) == "" {
+			return nil, fmt.Errorf("notifications.dingtalk 需要 webhook_url 或 access_token")
+		}
+		p, err := parseNotifyPolicy("notifications.dingtalk.policy", d.Policy, policy)
+		if err != nil {
+			return nil, err
+		}
+		n.senders = append(n.senders, notifyChannel{sender: &dingtalkSender{cfg: *d}, policy: p})
+	}
+	if w := cfg.WeCom; w != nil {
+		if strings.TrimSpace(w.WebhookURL) == "" && strings.TrimSpace(w.Key) == "" {
+			return nil, fmt.Errorf("notifications.wecom 需要 webhook_url 或 key")
+		}
+		p, err := parseNotifyPolicy("notifications.wecom.policy", w.Policy, policy)
+		if err != nil {
+			return nil, err
+		}
+		n.senders = append(n.senders, notifyChannel{sender: &wecomSender{cfg: *w}, policy: p})
+	}
+	if sl := cfg.Slack; sl != nil {
+		if strings.TrimSpace(sl.WebhookURL) == "" {
+			return nil, fmt.Errorf("notifications.slack 需要 webhook_url")
+		}
+		p, err := parseNotifyPolicy("notifications.slack.policy", sl.Policy, policy)
+		if err != nil {
+			return nil, err
+		}
+		n.senders = append(n.senders, notifyChannel{sender: &slackSender{cfg: *sl}, policy: p})
+	}
+	if em := cfg.Email; em != nil {
+		sender, err := newEmailSender(*em)
+		if err != nil {
+			return nil, err
+		}
+		p, err := parseNotifyPolicy("notifications.email.policy", em.Policy, policy)
+		if err != nil {
+			return nil, err
+		}
+		n.senders = append(n.senders, notifyChannel{sender: sender, policy: p})
+	}
+	if len(n.senders) == 0 {
+		return nil, nil
+	}
+	return n, nil
+}
+
+// passStarted 实现 passLifecycle
+func (n *notifier) passStarted(tables int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.started, n.summary, n.firstErr = time.Now(), &verificationSummary{}, ""
+}
+
+// tableStarted 实现 passObserver
+func (n *notifier) tableStarted(table string, progress *tableProgress) {}
+
+// tableFinished 实现 passObserver：记录核对结果；表失败且 on_table_failure 时立即通知
+func (n *notifier) tableFinished(table string, result *tableVerificationResult, err error) {
+	n.mu.Lock()
+	if result != nil {
+		n.summary.add(*result)
+	}
+	if err != nil && n.firstErr == "" {
+		n.firstErr = errText(err)
+	}
+	n.mu.Unlock()
+	if err != nil && n.cfg.OnTableFailure {
+		msg := n.build(notifyTableFailed, err)
+		msg.Table = table
+		n.deliver(msg)
+	}
+}
+
+// passFinished 实现 passLifecycle：发送运行结束的通知
+func (n *notifier) passFinished(err error) {
+	n.deliver(n.build(notifyRunFinished, err))
+}
+
+// build 按当前收集的结果生成通知
+func (n *notifier) build(event string, err error) runNotification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	host, _ := os.Hostname()
+	msg := runNotification{
+		Event:           event,
+		Status:          "success",
+		Host:            host,
+		StartedAt:       n.started,
+		FinishedAt:      now,
+		DurationSeconds: now.Sub(n.started).Seconds(),
+		Tables:          len(n.summary.results),
+		DiffTables:      n.summary.diffTables,
+		TotalRows:       n.summary.totalMigrated,
+		Error:           n.firstErr,
+		Results:         append([]tableVerificationResult{}, n.summary.results...),
+		Version:         version,
+	}
+	switch {
+	case errors.Is(err, errSyncStopped):
+		msg.Status = "stopped"
+	case err != nil:
+		msg.Status = "failed"
+		if msg.Error == "" {
+			msg.Error = errText(err)
+		}
+	case n.summary.diffTables > 0:
+		msg.Status = "diff"
+	}
+	if event == notifyRunFinished {
+		msg.reports = n.reportAttachments()
+	}
+	return msg
+}
+
+// reportAttachments 按启用的报告输出生成附件，文件名与 -report-json / -report-html 的文件名相同；生成失败只打印警告
+func (n *notifier) reportAttachments() []notifyAttachment {
+	var out []notifyAttachment
+	if n.reports.JSON != "" {
+		if data, err := n.summary.jsonReport(); err != nil {
+			n.log.warnf("警告：%v\n", err)
+		} else {
+			out = append(out, notifyAttachment{name: filepath.Base(n.reports.JSON), contentType: "application/json; charset=UTF-8", data: data})
+		}
+	}
+	if n.reports.HTML != "" {
+		if data, err := n.summary.htmlReport(); err != nil {
+			n.log.warnf("警告：%v\n", err)
+		} else {
+			out = append(out, notifyAttachment{name: filepath.Base(n.reports.HTML), contentType: "text/html; charset=UTF-8", data: data})
+		}
+	}
+	return out
+}
+
+// deliver 通过策略允许的渠道发送通知，每个渠道失败时重试一次；发送失败只打印警告，不影响运行结果
+func (n *notifier) deliver(msg runNotification) error {
+	var failed []string
+	for _, ch := range n.senders {
+		if !policyAllows(ch.policy, msg) {
+			continue
+		}
+		s := ch.sender
+		var err error
+		for attempt := 1; attempt <= 2; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+			err = s.send(ctx, msg)
+			cancel()
+			if err == nil {
+				break
+			}
+			if attempt == 1 {
+				n.log.warnf("警告：发送 %s 通知失败，%s 后重试: %v\n", s.name(), notifyRetryDelay, err)
+				time.Sleep(notifyRetryDelay)
+			}
+		}
+		if err != nil {
+			n.log.warnf("警告：发送 %s 通知失败: %v\n", s.name(), err)
+			failed = append(failed, s.name())
+			continue
+		}
+		n.log.infof("已发送 %s 通知（%s，%s）\n", s.name(), msg.Event, msg.Status)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("通知发送失败: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// notifyStartupFailure 同步开始前失败（配置、连接）时尽量发送失败通知：能读到配置中的 notifications 才发送
+func notifyStartupFailure(configPath string, cause error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return
+	}
+	n, err := newNotifier(cfg.Notifications)
+	if err != nil || n == nil {
+		return
+	}
+	n.started = time.Now()
+	_ = n.deliver(n.build(notifyRunFinished, cause))
+}
+
+// runNotifyTest -notify-test：按配置发送一条示例通知，用于联调；发送失败时退出码为 1
+func runNotifyTest(configPath string) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fatalf("加载配置文件失败: %v", err)
+	}
+	n, err := newNotifier(cfg.Notifications)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if n == nil {
+		fatalf("配置中没有 notifications（webhook_url、dingtalk、wecom、slack、email 等）")
+	}
+	now := time.Now()
+	n.started = now.Add(-90 * time.Second)
+	sample := newVerificationResult("example_orders", 1000, 998, 998)
+	n.summary.add(newVerificationResult("example_users", 500, 500, 500))
+	n.summary.add(sample)
+	n.firstErr = "示例错误：这是一条 -notify-test 发送的测试通知"
+	msg := n.build(notifyTest, nil)
+	msg.Status = "diff"
+	if err := n.deliver(msg); err != nil {
+		fatalf("%v", err)
+	}
+}
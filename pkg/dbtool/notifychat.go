@@ -0,0 +1,231 @@
+package dbtool
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 各机器人消息的长度上限（字节），留出余量，超出时截断差异表列表与错误信息
+const (
+	dingtalkMaxBytes = 18000 // 钉钉 markdown 约 20000 字节
+	wecomMaxBytes    = 4000  // 企业微信 markdown content 最长 4096 字节
+	slackMaxBytes    = 3900  // Slack 建议 text 不超过 4000 字符
+)
+
+// chatMaxDiffTables 消息中最多列出的差异表数
+const chatMaxDiffTables = 20
+
+// dingtalkConfig 钉钉自定义机器人
+type dingtalkConfig struct {
+	WebhookURL  string `json:"webhook_url,omitempty"`  // 完整的机器人地址（含 access_token）
+	AccessToken string `json:"access_token,omitempty"` // 只配置 token 时使用默认地址
+	Secret      string `json:"secret,omitempty"`       // 加签密钥（SEC 开头），配置后按 HMAC-SHA256 签名
+	Policy      string `json:"policy,omitempty"`       // 覆盖 notifications.policy
+}
+
+// wecomConfig 企业微信群机器人
+type wecomConfig struct {
+	WebhookURL string `json:"webhook_url,omitempty"` // 完整的机器人地址（含 key）
+	Key        string `json:"key,omitempty"`         // 只配置 key 时使用默认地址
+	Policy     string `json:"policy,omitempty"`      // 覆盖 notifications.policy
+}
+
+// slackConfig Slack incoming webhook
+type slackConfig struct {
+	WebhookURL string `json:"webhook_url,omitempty"`
+	Policy     string `json:"policy,omitempty"` // 覆盖 notifications.policy
+}
+
+// chatSummary 生成机器人消息正文（markdown）：标题、表数、行数、耗时、差异表与第一条错误，总长度不超过 limit 字节。
+// bold 为加粗标记（钉钉/企业微信为 **，Slack 为 *）
+func chatSummary(n runNotification, limit int, bold string) string {
+	title := "dbtool 同步完成"
+	switch n.Status {
+	case "failed":
+		title = "dbtool 同步失败"
+	case "diff":
+		title = "dbtool 同步完成（存在差异）"
+	case "stopped":
+		title = "dbtool 同步已中断"
+	}
+	switch n.Event {
+	case notifyTableFailed:
+		title = "dbtool 表同步失败: " + n.Table
+	case notifyTest:
+		title = "dbtool 测试通知"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s%s\n", bold, title, bold)
+	if n.Host != "" {
+		fmt.Fprintf(&b, "- 主机: %s\n", n.Host)
+	}
+	if n.Version != "" {
+		fmt.Fprintf(&b, "- 版本: %s\n", n.Version)
+	}
+	fmt.Fprintf(&b, "- 表数: %d（差异 %d）\n", n.Tables, n.DiffTables)
+	fmt.Fprintf(&b, "- 迁移行数: %d\n", n.TotalRows)
+	fmt.Fprintf(&b, "- 耗时: %s\n", time.Duration(n.DurationSeconds*float64(time.Second)).Round(time.Second))
+
+	errText := ""
+	if n.Error != "" {
+		// 错误信息最多占三分之一，给差异表列表留出空间
+		errText = truncateUTF8(fmt.Sprintf("- 错误: %s", n.Error), limit/3) + "\n"
+	}
+	// 先保证头部与错误信息，差异表列表按剩余长度逐条加入
+	budget := limit - b.Len() - len(errText) - 64
+	var diffs []string
+	shown := 0
+	total := 0
+	for _, r := range n.Results {
+		if !r.HasDiff {
+			continue
+		}
+		total++
+		line := fmt.Sprintf("  - %s%s%s: 源 %d / 目标 %d（%+d）\n", bold, r.TableName, bold, r.SourceCount, r.TargetCount, r.Diff)
+		if shown >= chatMaxDiffTables || budget-len(line) < 0 {
+			continue
+		}
+		budget -= len(line)
+		diffs = append(diffs, line)
+		shown++
+	}
+	if total > 0 {
+		b.WriteString("- 差异表:\n")
+		for _, line := range diffs {
+			b.WriteString(line)
+		}
+		if total > shown {
+			fmt.Fprintf(&b, "  - …… 另有 %d 张差异表未列出\n", total-shown)
+		}
+	}
+	b.WriteString(errText)
+	return truncateUTF8(b.String(), limit)
+}
+
+// truncateUTF8 按字节截断到 limit 以内（不截断多字节字符），截断时追加提示
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	const suffix = "\n……（已截断）"
+	cut := limit - len(suffix)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + suffix
+}
+
+// checkRobotResponse 钉钉、企业微信在 HTTP 200 时以 errcode 表示结果
+func checkRobotResponse(body []byte) error {
+	var resp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if len(body) == 0 || json.Unmarshal(body, &resp) != nil {
+		return nil
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("errcode=%d %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
+
+// dingtalkSender 钉钉机器人（markdown 消息）
+type dingtalkSender struct {
+	cfg dingtalkConfig
+}
+
+func (d *dingtalkSender) name() string { return "dingtalk" }
+
+// signedURL 配置了 secret 时追加 timestamp 与 sign 参数：sign = Base64(HMAC-SHA256(timestamp + "\n" + secret))
+func (d *dingtalkSender) signedURL(now time.Time) string {
+	addr := strings.TrimSpace(d.cfg.WebhookURL)
+	if addr == "" {
+		addr = "https://oapi.dingtalk.com/robot/send?access_token=" + url.QueryEscape(strings.TrimSpace(d.cfg.AccessToken))
+	}
+	secret := strings.TrimSpace(d.cfg.Secret)
+	if secret == "" {
+		return addr
+	}
+	ts := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	sep := "?"
+	if strings.Contains(addr, "?") {
+		sep = "&"
+	}
+	return addr + sep + "timestamp=" + ts + "&sign=" + url.QueryEscape(sign)
+}
+
+func (d *dingtalkSender) send(ctx context.Context, n runNotification) error {
+	text := chatSummary(n, dingtalkMaxBytes, "**")
+	title, _, _ := strings.Cut(strings.Trim(text, "*"), "*")
+	body, err := json.Marshal(map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": title, "text": strings.ReplaceAll(text, "\n", "\n\n")},
+	})
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+	resp, err := postJSONResponse(ctx, d.signedURL(time.Now()), body)
+	if err != nil {
+		return err
+	}
+	return checkRobotResponse(resp)
+}
+
+// wecomSender 企业微信群机器人（markdown 消息）
+type wecomSender struct {
+	cfg wecomConfig
+}
+
+func (w *wecomSender) name() string { return "wecom" }
+
+func (w *wecomSender) send(ctx context.Context, n runNotification) error {
+	addr := strings.TrimSpace(w.cfg.WebhookURL)
+	if addr == "" {
+		addr = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=" + url.QueryEscape(strings.TrimSpace(w.cfg.Key))
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": chatSummary(n, wecomMaxBytes, "**")},
+	})
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+	resp, err := postJSONResponse(ctx, addr, body)
+	if err != nil {
+		return err
+	}
+	return checkRobotResponse(resp)
+}
+
+// slackSender Slack incoming webhook（mrkdwn 文本）
+type slackSender struct {
+	cfg slackConfig
+}
+
+func (s *slackSender) name() string { return "slack" }
+
+func (s *slackSender) send(ctx context.Context, n runNotification) error {
+	body, err := json.Marshal(map[string]string{"text": chatSummary(n, slackMaxBytes, "*")})
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+	_, err = postJSONResponse(ctx, strings.TrimSpace(s.cfg.WebhookURL), body)
+	return err
+}
@@ -0,0 +1,285 @@
+package dbtool
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP 的 TLS 方式
+const (
+	smtpTLSStartTLS = "starttls" // 明文连接后升级（通常 587 端口）
+	smtpTLSImplicit = "implicit" // 连接即 TLS（通常 465 端口）
+	smtpTLSNone     = "none"     // 不加密（仅限内网中继）
+)
+
+// emailConfig notifications.email：以邮件发送运行结束的汇总
+type emailConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"` // 默认 implicit 为 465，其余为 587（none 为 25）
+	// TLS starttls（默认；端口为 465 时默认 implicit）/ implicit / none
+	TLS                string   `json:"tls,omitempty"`
+	InsecureSkipVerify bool     `json:"insecure_skip_verify,omitempty"` // 不校验服务器证书（自签名的内网中继）
+	From               string   `json:"from"`
+	To                 []string `json:"to"`
+	Cc                 []string `json:"cc,omitempty"`
+	Username           string   `json:"username,omitempty"` // 配置后使用 PLAIN 认证
+	// 密码三选一：password 明文、password_env 环境变量名、password_file 文件路径（去掉首尾空白）
+	Password      string `json:"password,omitempty"`
+	PasswordEnv   string `json:"password_env,omitempty"`
+	PasswordFile  string `json:"password_file,omitempty"`
+	SubjectPrefix string `json:"subject_prefix,omitempty"` // 主题前缀，默认 [dbtool]
+	AttachJSON    bool   `json:"attach_json,omitempty"`    // 附带 JSON 格式的运行报告（与 webhook 内容相同）
+	Policy        string `json:"policy,omitempty"`         // 覆盖 notifications.policy
+}
+
+// emailSender 通过 SMTP 发送邮件
+type emailSender struct {
+	cfg      emailConfig
+	tlsMode  string
+	port     int
+	password string
+}
+
+// newEmailSender 校验 email 配置并解析密码
+func newEmailSender(cfg emailConfig) (*emailSender, error) {
+	cfg.Host = strings.TrimSpace(cfg.Host)
+	if cfg.Host == "" || strings.TrimSpace(cfg.From) == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("notifications.email 需要 host、from 和 to")
+	}
+	for _, a := range append(append([]string{cfg.From}, cfg.To...), cfg.Cc...) {
+		if _, err := mail.ParseAddress(a); err != nil {
+			return nil, fmt.Errorf("notifications.email 地址无效 %q: %w", a, err)
+		}
+	}
+	e := &emailSender{cfg: cfg, port: cfg.Port}
+	switch mode := strings.ToLower(strings.TrimSpace(cfg.TLS)); mode {
+	case "":
+		e.tlsMode = smtpTLSStartTLS
+		if e.port == 465 {
+			e.tlsMode = smtpTLSImplicit
+		}
+	case smtpTLSStartTLS, smtpTLSImplicit, smtpTLSNone:
+		e.tlsMode = mode
+	case "tls", "ssl":
+		e.tlsMode = smtpTLSImplicit
+	default:
+		return nil, fmt.Errorf("notifications.email.tls 无效: %q（可选 starttls、implicit、none）", cfg.TLS)
+	}
+	if e.port == 0 {
+		switch e.tlsMode {
+		case smtpTLSImplicit:
+			e.port = 465
+		case smtpTLSNone:
+			e.port = 25
+		default:
+			e.port = 587
+		}
+	}
+	switch {
+	case cfg.Password != "":
+		e.password = cfg.Password
+	case cfg.PasswordEnv != "":
+		v, ok := os.LookupEnv(cfg.PasswordEnv)
+		if !ok {
+			return nil, fmt.Errorf("notifications.email.password_env: 环境变量 %s 未设置", cfg.PasswordEnv)
+		}
+		e.password = v
+	case cfg.PasswordFile != "":
+		data, err := os.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 notifications.email.password_file 失败: %w", err)
+		}
+		e.password = strings.TrimSpace(string(data))
+	}
+	return e, nil
+}
+
+func (e *emailSender) name() string { return "email" }
+
+// subject 邮件主题：[dbtool] 状态 主机 表数/行数
+func (e *emailSender) subject(n runNotification) string {
+	prefix := e.cfg.SubjectPrefix
+	if prefix == "" {
+		prefix = "[dbtool]"
+	}
+	status := map[string]string{"success": "同步成功", "diff": "同步完成（存在差异）", "failed": "同步失败", "stopped": "同步已中断"}[n.Status]
+	switch n.Event {
+	case notifyTableFailed:
+		status = "表同步失败: " + n.Table
+	case notifyTest:
+		status = "测试通知"
+	}
+	s := fmt.Sprintf("%s %s（%d 张表，%d 行）", prefix, status, n.Tables, n.TotalRows)
+	if n.Host != "" {
+		s += " @" + n.Host
+	}
+	return s
+}
+
+// body 邮件正文：摘要加全部表的核对结果
+func (e *emailSender) body(n runNotification) string {
+	var b strings.Builder
+	b.WriteString(chatSummary(n, 1<<20, ""))
+	if len(n.Results) > 0 {
+		b.WriteString("\n各表结果:\n")
+		for _, r := range n.Results {
+			mark := ""
+			if r.HasDiff {
+				mark = "  <-- 差异"
+			}
+			fmt.Fprintf(&b, "  %-40s 源 %d / 目标 %d / 迁移 %d%s\n", r.TableName, r.SourceCount, r.TargetCount, r.MigratedCount, mark)
+		}
+	}
+	if !n.StartedAt.IsZero() {
+		fmt.Fprintf(&b, "\n开始: %s\n结束: %s\n", n.StartedAt.Format(time.RFC3339), n.FinishedAt.Format(time.RFC3339))
+	}
+	return b.String()
+}
+
+// message 组装 MIME 邮件（正文 UTF-8 base64）：attach_json 时附带通知的 JSON，
+// 启用 -report-json / -report-html 时附带相同内容的报告文件
+func (e *emailSender) message(n runNotification) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", e.cfg.From)
+	header("To", strings.Join(e.cfg.To, ", "))
+	if len(e.cfg.Cc) > 0 {
+		header("Cc", strings.Join(e.cfg.Cc, ", "))
+	}
+	header("Subject", mime.BEncoding.Encode("UTF-8", e.subject(n)))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+
+	part := func(h textproto.MIMEHeader, data []byte) error {
+		h.Set("Content-Transfer-Encoding", "base64")
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		enc := base64.StdEncoding.EncodeToString(data)
+		for len(enc) > 76 {
+			fmt.Fprintf(w, "%s\r\n", enc[:76])
+			enc = enc[76:]
+		}
+		_, err = fmt.Fprintf(w, "%s\r\n", enc)
+		return err
+	}
+	if err := part(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=UTF-8"}}, []byte(e.body(n))); err != nil {
+		return nil, err
+	}
+	if e.cfg.AttachJSON {
+		data, err := json.MarshalIndent(n, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("序列化通知失败: %w", err)
+		}
+		h := textproto.MIMEHeader{
+			"Content-Type":        {"application/json; charset=UTF-8"},
+			"Content-Disposition": {`attachment; filename="dbtool-report.json"`},
+		}
+		if err := part(h, data); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range n.reports {
+		h := textproto.MIMEHeader{
+			"Content-Type":        {a.contentType},
+			"Content-Disposition": {mime.FormatMediaType("attachment", map[string]string{"filename": a.name})},
+		}
+		if err := part(h, a.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// envelopeAddr 取出地址中的邮箱部分（"dbtool <etl@example.com>" -> etl@example.com），用于 MAIL FROM / RCPT TO
+func envelopeAddr(a string) string {
+	if addr, err := mail.ParseAddress(a); err == nil {
+		return addr.Address
+	}
+	return strings.TrimSpace(a)
+}
+
+// send 连接 SMTP 服务器发送邮件；连接与整个会话受 ctx 的超时限制
+func (e *emailSender) send(ctx context.Context, n runNotification) error {
+	msg, err := e.message(n)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(e.cfg.Host, strconv.Itoa(e.port))
+	tlsCfg := &tls.Config{ServerName: e.cfg.Host, InsecureSkipVerify: e.cfg.InsecureSkipVerify}
+
+	var conn net.Conn
+	dialer := &net.Dialer{}
+	if e.tlsMode == smtpTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsCfg}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接 SMTP 服务器 %s 失败: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, e.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP 握手失败: %w", err)
+	}
+	defer c.Close()
+
+	if e.tlsMode == smtpTLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP 服务器 %s 不支持 STARTTLS（可设置 tls 为 implicit 或 none）", addr)
+		}
+		if err := c.StartTLS(tlsCfg); err != nil {
+			return fmt.Errorf("STARTTLS 失败: %w", err)
+		}
+	}
+	if e.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.cfg.Username, e.password, e.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %w", err)
+		}
+	}
+	if err := c.Mail(envelopeAddr(e.cfg.From)); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM 失败: %w", err)
+	}
+	for _, rcpt := range append(append([]string{}, e.cfg.To...), e.cfg.Cc...) {
+		if err := c.Rcpt(envelopeAddr(rcpt)); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s 失败: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA 失败: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("写入邮件内容失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,123 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ODBC 数据源（driver: odbc）用于只能通过 ODBC 访问的老系统（Sybase、Informix 等）。dbtool 不知道 ODBC 背后是
+// 哪种数据库，因此只做基于 SELECT / INSERT 的复制：
+// - 表清单必须显式列出，每张表必须配置 columns（或 select_sql）
+// - 占位符为 ?，标识符不加引号（需要引号时在配置中写成 "Name"，原样使用）
+// - 不读取目录信息（列类型、主键、生成列等），自动建表时按数值列 NUMERIC、其余 TEXT 粗略映射
+// - 依赖方言语法或目录信息的功能不可用，配置了这些功能时报错并列出
+//
+// ODBC 驱动（github.com/alexbrainman/odbc，已在 go.mod 中声明）依赖 cgo 与 unixODBC，默认不编译进来，需要时：
+//
+//	go build -tags odbc
+const driverODBC = "odbc"
+
+// isODBC 判断驱动是否为 odbc
+func isODBC(driver string) bool {
+	return normalizeDriver(driver) == driverODBC
+}
+
+// odbcDriverBuilt 当前构建是否包含 ODBC 驱动
+func odbcDriverBuilt() bool {
+	for _, d := range sql.Drivers() {
+		if d == driverODBC {
+			return true
+		}
+	}
+	return false
+}
+
+// checkODBCConfig 检查源库或目标库为 odbc 时配置中不可用的全局功能
+func checkODBCConfig(cfg *toolConfig, source, target dbConfig) error {
+	var unsupported []string
+	if isODBC(source.Driver) {
+		if cfg.TableList != nil && cfg.TableList.FromSource {
+			unsupported = append(unsupported, "table_list.from_source（无法拉取表清单，请在 tables 中显式列出）")
+		}
+		if cfg.TableList != nil && cfg.TableList.ExpandPartitions {
+			unsupported = append(unsupported, "table_list.expand_partitions")
+		}
+		if cfg.OrderByDependencies {
+			unsupported = append(unsupported, "order_by_dependencies")
+		}
+	}
+	if isODBC(target.Driver) {
+		if strings.EqualFold(strings.TrimSpace(cfg.StateBackend), stateBackendTarget) {
+			unsupported = append(unsupported, "state_backend=target（可用 -state 状态文件）")
+		}
+		if strings.TrimSpace(cfg.HistoryTable) != "" {
+			unsupported = append(unsupported, "history_table")
+		}
+		if strings.TrimSpace(cfg.LockName) != "" {
+			unsupported = append(unsupported, "lock_name（可用 -lock-file）")
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("odbc 数据源不支持: %s", strings.Join(unsupported, "、"))
+	}
+	return nil
+}
+
+// checkODBCOptions 检查源库或目标库为 odbc 时表配置中不可用的功能
+func checkODBCOptions(source, target string, opts copyTableOptions) error {
+	if !isODBC(source) && !isODBC(target) {
+		return nil
+	}
+	if len(opts.Columns) == 0 && strings.TrimSpace(opts.SelectSQL) == "" {
+		return fmt.Errorf("odbc 数据源需要在 columns 中显式列出要复制的列（或使用 select_sql）")
+	}
+	var unsupported []string
+	add := func(used bool, name string) {
+		if used {
+			unsupported = append(unsupported, name)
+		}
+	}
+	if isODBC(source) {
+		add(opts.Limit > 0 && strings.TrimSpace(opts.SelectSQL) == "", "limit")
+		add(opts.SamplePercent > 0, "sample_percent")
+		add(len(opts.DedupKeys) > 0, "dedup_keys")
+		add(len(opts.CheckDuplicatesOn) > 0, "check_duplicates_on")
+		add(opts.ChunkBy != nil, "chunk_by")
+		add(strings.TrimSpace(opts.Partition) != "", "partition")
+		add(opts.DiagnoseDiff, "diagnose_diff")
+		add(opts.CopyComments, "copy_comments")
+	}
+	if isODBC(target) {
+		add(opts.SyncDeletes, "sync_deletes")
+		add(opts.SyncSequences || strings.TrimSpace(opts.SequenceName) != "", "sync_sequences")
+		add(strings.TrimSpace(opts.Indexes) != "" && !strings.EqualFold(strings.TrimSpace(opts.Indexes), "none"), "indexes")
+		add(len(opts.RebuildIndexes) > 0, "rebuild_indexes")
+	}
+	add(len(opts.VerifyColumns) > 0, "verify_columns")
+	if len(unsupported) > 0 {
+		return fmt.Errorf("odbc 数据源不支持: %s", strings.Join(unsupported, "、"))
+	}
+	return nil
+}
+
+// mapODBCType 按驱动报告的类型名粗略映射：数值类型为 NUMERIC，其余为 TEXT
+func mapODBCType(dbType string) string {
+	for _, t := range []string{"INT", "DECIMAL", "NUMERIC", "NUMBER", "FLOAT", "DOUBLE", "REAL", "MONEY"} {
+		if strings.Contains(dbType, t) {
+			return "NUMERIC"
+		}
+	}
+	return "TEXT"
+}
+
+// odbcTableExists 目标表是否存在：执行不返回行的查询，成功即存在
+func odbcTableExists(ctx context.Context, dst *simpleDB, table string) bool {
+	rows, err := dst.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", quoteIdent(table, driverODBC)))
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
@@ -0,0 +1,77 @@
+package dbtool
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Oracle 源库的读取参数（数据源级）：go-ora 默认每次往返只预取 25 行，高延迟链路上读取主要耗在往返上。
+// oracle_fetch_rows 设置 DSN 参数 PREFETCH_ROWS；oracle_lob_fetch 设置 LOB FETCH：
+// pre（驱动默认，LOB 随行一起取回）或 post（行取回后再单独读取 LOB）。DSN 中已指定的参数优先
+
+// goOraDefaultPrefetchRows go-ora 未配置 PREFETCH_ROWS 时的预取行数
+const goOraDefaultPrefetchRows = 25
+
+// withOracleFetch 按 oracle_fetch_rows、oracle_lob_fetch 在 Oracle DSN 中加入读取参数，并打印实际生效的值；
+// 非 Oracle 数据源配置了这两项时忽略（打印警告）
+func withOracleFetch(cfg dbConfig, dsn string) (string, error) {
+	if cfg.Driver != "oracle" {
+		if cfg.OracleFetchRows != 0 || cfg.OracleLobFetch != "" {
+			cfg.log.warnf("警告：数据源 (%s) 配置了 oracle_fetch_rows / oracle_lob_fetch，仅对 oracle 生效，已忽略\n", cfg.Driver)
+		}
+		return dsn, nil
+	}
+	if cfg.OracleFetchRows < 0 {
+		return "", fmt.Errorf("oracle_fetch_rows 不能为负数")
+	}
+	if cfg.OracleFetchRows > 0 {
+		dsn = oracleDSNParam(dsn, "PREFETCH_ROWS", strconv.Itoa(cfg.OracleFetchRows))
+	}
+	if lob := strings.ToUpper(strings.TrimSpace(cfg.OracleLobFetch)); lob != "" {
+		if lob != "PRE" && lob != "POST" {
+			return "", fmt.Errorf("oracle_lob_fetch 无效: %q（可选 pre、post）", cfg.OracleLobFetch)
+		}
+		dsn = oracleDSNParam(dsn, "LOB FETCH", lob)
+	}
+	rows, lob := oracleFetchParams(dsn)
+	cfg.log.infof("Oracle 读取参数: PREFETCH_ROWS=%s，LOB FETCH=%s\n", rows, lob)
+	return dsn, nil
+}
+
+// oracleDSNParam 在 oracle:// URL 中加入参数（已指定时不覆盖，参数名不区分大小写）；非 URL 形式原样返回
+func oracleDSNParam(dsn, key, value string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || !strings.EqualFold(u.Scheme, "oracle") {
+		return dsn
+	}
+	q := u.Query()
+	for k := range q {
+		if strings.EqualFold(k, key) {
+			return dsn
+		}
+	}
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// oracleFetchParams DSN 中实际生效的 PREFETCH_ROWS 与 LOB FETCH（未指定时为驱动默认值）
+func oracleFetchParams(dsn string) (rows, lob string) {
+	rows = strconv.Itoa(goOraDefaultPrefetchRows) + "（驱动默认）"
+	lob = "PRE（驱动默认）"
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return rows, lob
+	}
+	for k, v := range u.Query() {
+		switch strings.ToUpper(k) {
+		case "PREFETCH_ROWS":
+			rows = v[0]
+		case "LOB FETCH":
+			lob = strings.ToUpper(v[0])
+		}
+	}
+	return rows, lob
+}
@@ -0,0 +1,101 @@
+package dbtool
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oracleTestDSN 未指定读取参数的 Oracle DSN
+const oracleTestDSN = "oracle://scott:tiger@db:1521/orcl"
+
+// effectivePrefetch DSN 中实际生效的每次往返预取行数
+func effectivePrefetch(t testing.TB, dsn string) int {
+	t.Helper()
+	rows, _ := oracleFetchParams(dsn)
+	if strings.HasSuffix(rows, "（驱动默认）") {
+		return goOraDefaultPrefetchRows
+	}
+	n, err := strconv.Atoi(rows)
+	if err != nil {
+		t.Fatalf("PREFETCH_ROWS 无效: %q", rows)
+	}
+	return n
+}
+
+// simulatedOracleRead 模拟按预取行数分批读取 total 行，每次往返耗时 rtt，返回往返次数
+func simulatedOracleRead(total, prefetch int, rtt time.Duration) int {
+	trips := 0
+	for read := 0; read < total; read += prefetch {
+		time.Sleep(rtt)
+		trips++
+	}
+	return trips
+}
+
+// oracleFetchDSN 按 oracle_fetch_rows 生成连接使用的 DSN（与 newSimpleDB 相同的路径），同时返回打印的日志
+func oracleFetchDSN(t testing.TB, driver, dsn string, fetchRows int) (string, *recordLogger) {
+	t.Helper()
+	logs := &recordLogger{}
+	out, err := withOracleFetch(dbConfig{Driver: driver, DSN: dsn, OracleFetchRows: fetchRows, log: newOutputLogger(logs)}, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, logs
+}
+
+func TestOracleFetchRowsPlumbed(t *testing.T) {
+	const total = 10000
+	cases := []struct {
+		name      string
+		driver    string
+		dsn       string
+		fetchRows int
+		want      int
+	}{
+		{"驱动默认", "oracle", oracleTestDSN, 0, goOraDefaultPrefetchRows},
+		{"oracle_fetch_rows", "oracle", oracleTestDSN, 1000, 1000},
+		{"DSN 中已指定时优先", "oracle", oracleTestDSN + "?prefetch_rows=500", 1000, 500},
+		{"非 Oracle 数据源忽略", "postgres", "postgres://u:p@db/app", 1000, goOraDefaultPrefetchRows},
+	}
+	for _, c := range cases {
+		dsn, logs := oracleFetchDSN(t, c.driver, c.dsn, c.fetchRows)
+		if c.driver != "oracle" {
+			if dsn != c.dsn || !logs.contains("仅对 oracle 生效") {
+				t.Errorf("%s: DSN 被改写为 %s 或缺少警告: %q", c.name, dsn, logs.lines)
+			}
+		} else if !logs.contains("PREFETCH_ROWS=" + strconv.Itoa(c.want)) {
+			t.Errorf("%s: 日志中没有实际生效的预取行数: %q", c.name, logs.lines)
+		}
+		got := effectivePrefetch(t, dsn)
+		if got != c.want {
+			t.Errorf("%s: 预取 %d 行，期望 %d（DSN %s）", c.name, got, c.want, dsn)
+		}
+		// 往返次数随预取行数下降：默认 400 次，1000 行时 10 次
+		if trips, want := simulatedOracleRead(total, got, 0), (total+c.want-1)/c.want; trips != want {
+			t.Errorf("%s: 往返 %d 次，期望 %d", c.name, trips, want)
+		}
+	}
+}
+
+// BenchmarkOracleFetchRows 模拟高延迟链路上按 oracle_fetch_rows 生效的预取行数读取，比较每秒读取的行数
+func BenchmarkOracleFetchRows(b *testing.B) {
+	const total = 5000
+	const rtt = 200 * time.Microsecond
+	for _, fetchRows := range []int{0, 100, 1000, 5000} {
+		name := "default"
+		if fetchRows > 0 {
+			name = strconv.Itoa(fetchRows)
+		}
+		b.Run(name, func(b *testing.B) {
+			dsn, _ := oracleFetchDSN(b, "oracle", oracleTestDSN, fetchRows)
+			prefetch := effectivePrefetch(b, dsn)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				simulatedOracleRead(total, prefetch, rtt)
+			}
+			b.ReportMetric(float64(total*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// listPartitions 查询源表的分区（schema 为空时使用当前库/默认 schema）
+// - postgres：声明式分区的叶子分区（子分区表名，与父表不在同一 schema 时带 schema 前缀）
+// - mysql：information_schema.partitions 中的分区名（含子分区时按分区合并）
+// - oracle：user_tab_partitions/all_tab_partitions 中的分区名
+// 非分区表返回空列表
+func listPartitions(ctx context.Context, src *simpleDB, schema, table string) ([]string, error) {
+	driver := normalizeDriver(src.cfg.Driver)
+
+	var query string
+	var args []interface{}
+	switch driver {
+	case "postgres", "postgresql":
+		if schema == "" {
+			schema = "public"
+		}
+		// 只展开声明式分区（relispartition）；传统继承的父表自身也可能有数据，保持整表复制
+		query = `WITH RECURSIVE tree AS (
+	SELECT i.inhrelid AS oid
+	FROM pg_inherits i
+	JOIN pg_class p ON p.oid = i.inhparent
+	JOIN pg_namespace pn ON pn.oid = p.relnamespace
+	WHERE pn.nspname = $1 AND p.relname = $2 AND p.relkind = 'p'
+	UNION ALL
+	SELECT i.inhrelid FROM pg_inherits i JOIN tree t ON i.inhparent = t.oid
+)
+SELECT CASE WHEN n.nspname = $1 THEN c.relname ELSE n.nspname || '.' || c.relname END
+FROM tree
+JOIN pg_class c ON c.oid = tree.oid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relispartition AND c.relkind <> 'p'
+ORDER BY c.relname`
+		args = append(args, schema, table)
+	case "mysql":
+		if schema == "" {
+			query = `SELECT partition_name FROM information_schema.partitions
+WHERE table_schema = DATABASE() AND table_name = ? AND partition_name IS NOT NULL
+GROUP BY partition_name ORDER BY MIN(partition_ordinal_position)`
+			args = append(args, table)
+		} else {
+			query = `SELECT partition_name FROM information_schema.partitions
+WHERE table_schema = ? AND table_name = ? AND partition_name IS NOT NULL
+GROUP BY partition_name ORDER BY MIN(partition_ordinal_position)`
+			args = append(args, schema, table)
+		}
+	case "oracle":
+		if schema == "" {
+			query = `SELECT partition_name FROM user_tab_partitions WHERE table_name = :1 ORDER BY partition_position`
+			args = append(args, strings.ToUpper(table))
+		} else {
+			query = `SELECT partition_name FROM all_tab_partitions WHERE table_owner = :1 AND table_name = :2 ORDER BY partition_position`
+			args = append(args, strings.ToUpper(schema), strings.ToUpper(table))
+		}
+	default:
+		return nil, fmt.Errorf("暂不支持从驱动 %s 读取分区信息", driver)
+	}
+
+	rows, err := src.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 的分区失败: %w", table, err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("读取表 %s 的分区失败: %w", table, err)
+		}
+		out = append(out, name)
+	}
+	return out, rows.Err()
+}
+
+// expandPartitions 把分区表拆成按分区复制的工作单元，所有单元写入同一张目标表
+// - postgres：单元的 source_table 为子分区表
+// - mysql/oracle：单元保留父表名并设置 partition，查询时使用 PARTITION (p)
+// 使用 select_sql 或已指定 partition 的表保持不变
+func expandPartitions(ctx context.Context, src *simpleDB, schema string, tables []configTable) ([]configTable, error) {
+	driver := normalizeDriver(src.cfg.Driver)
+	switch driver {
+	case "postgres", "postgresql", "mysql", "oracle":
+	default:
+		src.cfg.log.warnf("警告：expand_partitions 暂不支持源库驱动 %s，按整表复制\n", driver)
+		return tables, nil
+	}
+
+	out := make([]configTable, 0, len(tables))
+	for _, t := range tables {
+		if strings.TrimSpace(t.SourceTable) == "" || strings.TrimSpace(t.SelectSQL) != "" || strings.TrimSpace(t.Partition) != "" {
+			out = append(out, t)
+			continue
+		}
+		parts, err := listPartitions(ctx, src, schema, t.SourceTable)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 0 {
+			out = append(out, t)
+			continue
+		}
+		src.cfg.log.infof("表 %s 拆分为 %d 个分区单元\n", t.SourceTable, len(parts))
+		for _, p := range parts {
+			unit := t
+			unit.PartitionOf = t.SourceTable
+			unit.TargetTable = firstNonEmpty(t.TargetTable, t.SourceTable)
+			if driver == "mysql" || driver == "oracle" {
+				unit.Partition = p
+			} else {
+				unit.SourceTable = p
+			}
+			out = append(out, unit)
+		}
+	}
+	return out, nil
+}
+
+// sourceFrom 返回查询源表时 FROM 后的表达式（指定 partition 时追加 PARTITION (p)）
+func sourceFrom(opts copyTableOptions, driver string) (string, error) {
+	p := strings.TrimSpace(opts.Partition)
+	if p == "" {
+		return opts.Table, nil
+	}
+	switch normalizeDriver(driver) {
+	case "mysql", "oracle":
+		return fmt.Sprintf("%s PARTITION (%s)", opts.Table, p), nil
+	default:
+		return "", fmt.Errorf("partition 仅支持 MySQL/Oracle 源库，其他源库请直接把分区子表配置为 source_table")
+	}
+}
+
+// partitionTotal 同一父表的一组分区单元：先逐个准备，再一起复制，最后按父表汇总核对数据
+type partitionTotal struct {
+	parent       string
+	targetTable  string
+	opts         copyTableOptions   // 第一个分区单元的复制选项（用于按相同窗口统计目标表）
+	units        []copyTableOptions // 已准备好的分区单元
+	plan         *indexPlan         // 各单元共用的索引计划，全部单元复制完成后创建一次
+	watermark    *watermarkTracker  // 父表的水位（各单元的最大值合并），未记录水位时为 nil
+	stateKey     string             // 增量水位在状态文件中的键
+	oldWatermark *watermarkEntry    // 本次运行前的水位
+	sourceCount  int64              // 任一单元无法获取时为 -1
+	migrated     int64
+	copySeconds  float64  // 各分区单元的复制耗时之和
+	hints        []string // 各分区单元的核对提示
+}
+
+// defaultPartitionParallel 未配置 partition_parallel 时同一分区表同时复制的分区单元数
+const defaultPartitionParallel = 4
+
+// partitionUnitResult 一个分区单元的复制结果
+type partitionUnitResult struct {
+	migrated    int64
+	sourceCount int64
+	seconds     float64
+	err         error
+	skipped     bool // 其他单元失败或收到停止信号，未开始复制
+}
+
+// partitionParallel 同一分区表同时复制的分区单元数（table_list.partition_parallel）；
+// 以下情况逐个复制：consistent_snapshot（所有读取共用一个源库事务）、max_memory（多个持有者互相等待预算）、
+// SQLite 目标库（同时只有一个写入者）、文件目标（写入同一个文件）
+func (r *syncRunner) partitionParallel(dst *simpleDB, snapshot *sourceSnapshot) (int, string) {
+	n := defaultPartitionParallel
+	if r.cfg.TableList != nil && r.cfg.TableList.PartitionParallel > 0 {
+		n = r.cfg.TableList.PartitionParallel
+	}
+	switch {
+	case n <= 1:
+		return 1, ""
+	case snapshot != nil:
+		return 1, "consistent_snapshot 的读取共用一个源库事务"
+	case r.memory != nil:
+		return 1, "已配置 max_memory"
+	case dst.files != nil:
+		return 1, "文件目标写入同一个文件"
+	case normalizeDriver(dst.cfg.Driver) == "sqlite3":
+		return 1, "SQLite 目标库同时只能有一个写入者"
+	}
+	return n, ""
+}
+
+// copyPartitionUnits 复制同一分区表的全部分区单元，结果按单元顺序返回：
+// 第一个单元单独复制（自动建表、删除 rebuild_indexes 只在这里执行），其余单元最多 parallel 个同时复制；
+// 有单元失败或 stop 结束后不再开始新的单元
+func (r *syncRunner) copyPartitionUnits(ctx, stop context.Context, src, dst *simpleDB, p passOptions, units []copyTableOptions, parallel int) []partitionUnitResult {
+	results := make([]partitionUnitResult, len(units))
+	var failed atomic.Bool
+	copyUnit := func(i int) {
+		res := &results[i]
+		if failed.Load() || stop.Err() != nil {
+			res.skipped = true
+			return
+		}
+		opts := units[i]
+		r.notifyStarted(p, opts.Table, opts.Progress)
+		res.migrated, res.sourceCount, _, res.seconds, res.err = copyTableOrChunks(ctx, src, dst, opts)
+		if res.err != nil {
+			failed.Store(true)
+			return
+		}
+		r.notifyFinished(p, opts.Table, opts.Progress, nil, nil)
+	}
+
+	copyUnit(0)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel && w < len(units)-1; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				copyUnit(i)
+			}
+		}()
+	}
+	for i := 1; i < len(units); i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// countRows 统计目标表当前记录数，失败时返回 -1
+func countRows(ctx context.Context, db *simpleDB, table string) int64 {
+	var n int64
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteIdent(table, db.cfg.Driver))
+	if err := db.db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		db.cfg.log.warnf("警告：无法获取目标表 %s 记录数: %v\n", table, err)
+		return -1
+	}
+	return n
+}
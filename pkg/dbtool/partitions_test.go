@@ -0,0 +1,121 @@
+package dbtool
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPartitionUnitsFinishOncePerParent(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	var stmts []string
+	for i, part := range []string{"ev_p1", "ev_p2", "ev_p3"} {
+		stmts = append(stmts, "CREATE TABLE "+part+" (id INTEGER PRIMARY KEY, name TEXT)",
+			"CREATE INDEX idx_"+part+"_name ON "+part+" (name)")
+		for _, id := range []int{2*i + 1, 2*i + 2} {
+			stmts = append(stmts, "INSERT INTO "+part+" VALUES ("+strconv.Itoa(id)+", 'n')")
+		}
+	}
+	openTestSQLite(t, srcPath, stmts...)
+	dst := openTestSQLite(t, dstPath, "CREATE TABLE ev (id INTEGER PRIMARY KEY, name TEXT)", "CREATE INDEX idx_ev_name ON ev (name)")
+	unit := `"target_table": "ev", "incremental_key": "id", "since": "0", "sync_sequences": true, "rebuild_indexes": ["idx_ev_name"]`
+	cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "ev_p1", `+unit+`}, {"source_table": "ev_p2", `+unit+`}, {"source_table": "ev_p3", `+unit+`}]`)
+	logs := &recordLogger{}
+	r, err := openSyncRunner(context.Background(), cfg, runOptions{RunOnce: true, StatePath: filepath.Join(dir, "state.json"), Log: newOutputLogger(logs)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// 与 expand_partitions 拆分出的单元相同：连续的单元属于同一父表
+	for i := range r.tables {
+		r.tables[i].PartitionOf = "ev"
+	}
+	summary, err := r.runPass(passOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summary.results) != 1 {
+		t.Fatalf("汇总中有 %d 行，期望父表一行", len(summary.results))
+	}
+	res := summary.results[0]
+	if res.TableName != "ev" || res.SourceCount != 6 || res.TargetCount != 6 || res.HasDiff {
+		t.Fatalf("父表核对结果: %+v", res)
+	}
+	// 各单元的水位合并为父表水位
+	if res.WatermarkNew != "6" {
+		t.Errorf("父表水位 %q，期望 6", res.WatermarkNew)
+	}
+	// 序列同步与索引重建按父表各执行一次
+	count := func(s string) int {
+		n := 0
+		for _, line := range logs.lines {
+			if strings.Contains(line, s) {
+				n++
+			}
+		}
+		return n
+	}
+	if n := count("无需同步序列"); n != 1 {
+		t.Errorf("序列同步执行了 %d 次，期望 1 次", n)
+	}
+	if n := count("已创建索引"); n != 1 {
+		t.Errorf("索引重建执行了 %d 次，期望 1 次: %q", n, logs.lines)
+	}
+	var n int
+	if err := dst.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_ev_name'").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("全部分区单元复制后索引应已重建")
+	}
+}
+
+func TestWatermarkMerge(t *testing.T) {
+	parent := &watermarkTracker{}
+	for _, max := range [][]interface{}{{int64(5)}, {int64(12)}, nil, {int64(9)}} {
+		parent.merge(&watermarkTracker{numeric: []bool{true}, max: max})
+	}
+	if len(parent.max) != 1 || parent.max[0] != int64(12) {
+		t.Fatalf("合并后的水位 %v，期望 12", parent.max)
+	}
+}
+
+func TestCopyPartitionUnitsParallel(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	var stmts []string
+	parts := []string{"ev_p1", "ev_p2", "ev_p3", "ev_p4"}
+	for i, part := range parts {
+		stmts = append(stmts, "CREATE TABLE "+part+" (id INTEGER PRIMARY KEY)", "INSERT INTO "+part+" VALUES ("+strconv.Itoa(i+1)+")")
+	}
+	openTestSQLite(t, srcPath, stmts...)
+	openTestSQLite(t, dstPath, "CREATE TABLE ev (id INTEGER PRIMARY KEY)")
+	r, err := openSyncRunner(ctx, writeTestConfig(t, srcPath, dstPath, `[{"source_table": "ev_p1"}]`), runOptions{RunOnce: true, Log: newOutputLogger(&recordLogger{})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	src, dst := r.conns()
+
+	// Dry-Run 只读取源表，多个单元同时复制
+	done := &resultCollector{res: &RunResult{}}
+	listeners := serializeListeners([]copyListener{done})
+	var units []copyTableOptions
+	for _, part := range parts {
+		opts := tableOptions(r.cfg, configTable{SourceTable: part, TargetTable: "ev", PartitionOf: "ev"}, true, 0)
+		opts.Log = r.log.forTable(part)
+		opts.Progress = newTableProgress(opts, listeners)
+		units = append(units, opts)
+	}
+	results := r.copyPartitionUnits(ctx, ctx, src, dst, passOptions{}, units, 3)
+	for i, res := range results {
+		if res.err != nil || res.skipped || res.migrated != 1 {
+			t.Errorf("%s: %+v", parts[i], res)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package dbtool
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 连接池默认值（数据源未配置 max_open_conns 等时使用）
+const (
+	defaultMaxOpenConns    = 10
+	defaultMaxIdleConns    = 5
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultPingTimeout     = 5 * time.Second
+)
+
+// poolSettings 数据源的连接池参数
+type poolSettings struct {
+	maxOpen     int
+	maxIdle     int
+	lifetime    time.Duration
+	idleTime    time.Duration // 0 表示空闲连接不因空闲时间关闭
+	pingTimeout time.Duration
+	configured  bool // 配置了任一参数（连接时打印）
+}
+
+// resolvePool 解析数据源的连接池参数：未配置的取默认值，max_idle_conns 不能超过 max_open_conns
+func resolvePool(cfg dbConfig) (poolSettings, error) {
+	p := poolSettings{maxOpen: defaultMaxOpenConns, maxIdle: defaultMaxIdleConns, lifetime: defaultConnMaxLifetime, pingTimeout: defaultPingTimeout}
+	if cfg.MaxOpenConns < 0 || cfg.MaxIdleConns < 0 {
+		return p, fmt.Errorf("max_open_conns、max_idle_conns 不能为负数")
+	}
+	if cfg.MaxOpenConns > 0 {
+		p.maxOpen, p.configured = cfg.MaxOpenConns, true
+	}
+	if cfg.MaxIdleConns > 0 {
+		if cfg.MaxIdleConns > p.maxOpen {
+			return p, fmt.Errorf("max_idle_conns=%d 不能大于 max_open_conns=%d", cfg.MaxIdleConns, p.maxOpen)
+		}
+		p.maxIdle, p.configured = cfg.MaxIdleConns, true
+	} else if p.maxIdle > p.maxOpen {
+		// 只配置了较小的 max_open_conns 时，默认的空闲连接数随之收紧
+		p.maxIdle = p.maxOpen
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"conn_max_lifetime", cfg.ConnMaxLifetime, &p.lifetime},
+		{"conn_max_idle_time", cfg.ConnMaxIdleTime, &p.idleTime},
+		{"ping_timeout", cfg.PingTimeout, &p.pingTimeout},
+	} {
+		if s := strings.TrimSpace(d.value); s != "" {
+			v, err := time.ParseDuration(s)
+			if err != nil || v <= 0 {
+				return p, fmt.Errorf("%s 无效: %q（如 30m、1h）", d.name, d.value)
+			}
+			*d.dst, p.configured = v, true
+		}
+	}
+	if normalizeDriver(cfg.Driver) == "sqlite3" && cfg.SQLiteFastLoad {
+		// SQLite 同一时刻只允许一个写连接，多连接并发写入容易出现 SQLITE_BUSY
+		if cfg.MaxOpenConns > 1 {
+			cfg.log.warnf("警告：sqlite_fast_load 时连接池限制为 1 个连接，忽略 max_open_conns=%d\n", cfg.MaxOpenConns)
+		}
+		p.maxOpen = 1
+		p.maxIdle = min(p.maxIdle, 1)
+	}
+	return p, nil
+}
+
+// String 连接时打印的连接池参数
+func (p poolSettings) String() string {
+	idle := "不限"
+	if p.idleTime > 0 {
+		idle = p.idleTime.String()
+	}
+	return fmt.Sprintf("max_open_conns=%d, max_idle_conns=%d, conn_max_lifetime=%s, conn_max_idle_time=%s, ping_timeout=%s",
+		p.maxOpen, p.maxIdle, p.lifetime, idle, p.pingTimeout)
+}
@@ -0,0 +1,119 @@
+package dbtool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// post_batch_sql（表级）：每批提交前在同一事务中执行的语句，用于在目标库中维护自己的记账表（已加载到的键、批次号等），
+// 与数据一起提交或回滚。语句中可使用的命名占位符（按方言替换为绑定参数）：
+// - :rows_in_batch 本批写入的行数（atomic、COPY / LOAD DATA 整表一个事务时为全部行数）
+// - :total_rows    本次复制已写入的行数（含本批；chunk_by 时为当前区间）
+// - :max_inc_key   已扫描到的增量列最大值（复合增量列为 JSON 数组文本，未配置增量列时为 NULL）
+// - :run_id        本轮同步的标识，与 history_table 的 run_id 一致
+// 执行失败时本批失败（与写入失败相同）；没有写入任何行的提交不执行。write_mode_tx=none 不使用事务，
+// 无法与数据一起提交，打印警告后忽略
+
+// postBatchParams post_batch_sql 中可用的占位符
+var postBatchParams = []string{"rows_in_batch", "total_rows", "max_inc_key", "run_id"}
+
+// postBatchHook 解析后的 post_batch_sql：绑定参数形式的语句与各位置的占位符名称
+type postBatchHook struct {
+	query   string
+	names   []string
+	driver  string
+	runID   string
+	tracker *watermarkTracker
+}
+
+// newPostBatchHook 解析表的 post_batch_sql；未配置时返回 nil，引用了未知的占位符时返回错误
+func newPostBatchHook(opts copyTableOptions, driver string) (*postBatchHook, error) {
+	if strings.TrimSpace(opts.PostBatchSQL) == "" {
+		return nil, nil
+	}
+	h := &postBatchHook{driver: driver, runID: opts.RunID, tracker: opts.Watermark}
+	var unknown []string
+	h.query = replaceNamedParams(opts.PostBatchSQL, driver, func(name, raw string) string {
+		for _, p := range postBatchParams {
+			if p == name {
+				h.names = append(h.names, name)
+				return placeholder(len(h.names), driver)
+			}
+		}
+		unknown = append(unknown, ":"+name)
+		return raw
+	})
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("post_batch_sql 引用了未知的参数 %s（可用 :%s）", strings.Join(unknown, ", "), strings.Join(postBatchParams, "、:"))
+	}
+	return h, nil
+}
+
+// validatePostBatchSQL 检查 post_batch_sql；write_mode_tx=none 时打印警告（执行时忽略）
+func validatePostBatchSQL(opts copyTableOptions, driver string) error {
+	h, err := newPostBatchHook(opts, driver)
+	if err != nil || h == nil {
+		return err
+	}
+	if autocommitWrites(opts) {
+		opts.Log.warnf("警告：表 %s 配置了 write_mode_tx=none，写入不使用事务，post_batch_sql 无法与数据一起提交，将被忽略\n", opts.Table)
+	}
+	return nil
+}
+
+// args 按占位符顺序生成参数
+func (h *postBatchHook) args(rowsInBatch, totalRows int) []interface{} {
+	args := make([]interface{}, len(h.names))
+	for i, name := range h.names {
+		switch name {
+		case "rows_in_batch":
+			args[i] = int64(rowsInBatch)
+		case "total_rows":
+			args[i] = int64(totalRows)
+		case "max_inc_key":
+			args[i] = h.maxIncKey()
+		case "run_id":
+			args[i] = h.runID
+		}
+	}
+	return args
+}
+
+// maxIncKey 当前的增量列最大值：单列为值本身，复合增量列为 JSON 数组文本，没有时为 nil
+func (h *postBatchHook) maxIncKey() interface{} {
+	if h.tracker == nil || h.tracker.max == nil {
+		return nil
+	}
+	if len(h.tracker.max) == 1 {
+		return h.tracker.max[0]
+	}
+	data, err := json.Marshal(h.tracker.max)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// exec 在提交前的事务中执行 post_batch_sql（nil 或本批没有行时不做任何事）
+func (h *postBatchHook) exec(ctx context.Context, tx batchTx, rowsInBatch, totalRows int) error {
+	if h == nil || rowsInBatch == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, h.query, h.args(rowsInBatch, totalRows)...); err != nil {
+		return fmt.Errorf("执行 post_batch_sql 失败: %w", err)
+	}
+	return nil
+}
+
+// logDryRun Dry-Run 时打印一次绑定后的语句与示例参数（按一整批计）
+func (h *postBatchHook) logDryRun(opts copyTableOptions) {
+	if h == nil {
+		return
+	}
+	opts.Log.infof("Dry-Run 模式，每批提交前将在同一事务中执行 post_batch_sql（示例参数）：\n")
+	logBoundSQL(h.query, h.args(opts.BatchSize, opts.BatchSize), h.driver, opts.Log)
+}
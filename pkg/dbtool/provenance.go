@@ -0,0 +1,112 @@
+package dbtool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 来源列的种类，目标列名为种类前加下划线（_source_name 等）
+const (
+	provenanceSourceName  = "source_name"  // sources 中源库的名称（sync.source）
+	provenanceSourceTable = "source_table" // 源表名（分区单元为父表名）
+	provenanceRunID       = "run_id"       // 本轮同步的标识，与 history_table 的 run_id 一致
+)
+
+// provenanceConfig provenance：多个源库汇入同一目标表时，每行追加记录来源的列。
+// 列值都取自解析后的配置与本轮运行，不接受自由填写的字符串，避免汇入配置写错来源
+type provenanceConfig struct {
+	Columns []string `json:"columns"` // source_name / source_table / run_id
+	// VerifyBySource 目标表的记录数核对、sync_deletes 只针对 _source_name 为本数据源的行
+	VerifyBySource bool `json:"verify_by_source,omitempty"`
+}
+
+// provenance 返回表使用的来源列配置：表级 provenance 优先（columns 为空表示关闭），否则取 table_list 级默认值
+func provenance(cfg *toolConfig, t configTable) *provenanceConfig {
+	if t.Provenance != nil {
+		if len(t.Provenance.Columns) == 0 {
+			return nil
+		}
+		return t.Provenance
+	}
+	if cfg.TableList != nil && cfg.TableList.Provenance != nil && len(cfg.TableList.Provenance.Columns) > 0 {
+		return cfg.TableList.Provenance
+	}
+	return nil
+}
+
+// provenanceColumnName 来源列在目标表中的列名
+func provenanceColumnName(kind string) string {
+	return "_" + kind
+}
+
+// validateProvenance 检查来源列的种类，以及 source_name 所需的 sources + sync 配置
+func validateProvenance(opts copyTableOptions) error {
+	hasSourceName := false
+	for _, kind := range opts.Provenance {
+		switch kind {
+		case provenanceSourceName:
+			hasSourceName = true
+			if opts.SourceName == "" {
+				return fmt.Errorf("provenance 的 source_name 需要使用 sources + sync 配置（取值为 sync.source）")
+			}
+		case provenanceSourceTable, provenanceRunID:
+		default:
+			return fmt.Errorf("provenance.columns 无效: %q（可选 source_name、source_table、run_id）", kind)
+		}
+	}
+	if opts.VerifyBySource && !hasSourceName {
+		return fmt.Errorf("provenance.verify_by_source 需要在 columns 中包含 source_name")
+	}
+	return nil
+}
+
+// provenanceValue 返回来源列的值
+func provenanceValue(opts copyTableOptions, kind string) interface{} {
+	switch kind {
+	case provenanceSourceName:
+		return opts.SourceName
+	case provenanceSourceTable:
+		return firstNonEmpty(opts.PartitionOf, opts.Table)
+	case provenanceRunID:
+		if opts.RunID == "" {
+			return nil
+		}
+		return opts.RunID
+	}
+	return nil
+}
+
+// provenanceColumnType 自动建表时来源列的类型
+func provenanceColumnType(driver string) string {
+	switch normalizeDriver(driver) {
+	case "sqlserver":
+		return "NVARCHAR(255)"
+	case "oracle":
+		return "VARCHAR2(255)"
+	case "sqlite3":
+		return "TEXT"
+	default:
+		return "VARCHAR(255)"
+	}
+}
+
+// sourceScopePredicate verify_by_source 时目标表只统计本数据源的行：_source_name = ?
+func sourceScopePredicate(opts copyTableOptions, dstDriver string, args *[]interface{}) string {
+	if !opts.VerifyBySource || opts.SourceName == "" {
+		return ""
+	}
+	hasSourceName := false
+	for _, kind := range opts.Provenance {
+		hasSourceName = hasSourceName || kind == provenanceSourceName
+	}
+	if !hasSourceName {
+		return ""
+	}
+	*args = append(*args, opts.SourceName)
+	return fmt.Sprintf("%s = %s", quoteIdent(provenanceColumnName(provenanceSourceName), dstDriver), placeholder(len(*args), dstDriver))
+}
+
+// trimProvenanceKind 规范化配置中的种类（允许写成列名 _source_name）
+func trimProvenanceKind(s string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "_")
+}
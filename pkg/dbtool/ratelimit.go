@@ -0,0 +1,168 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateWindow rate_limit_schedule 中的一个时间段：[from, to) 内按 rate_limit 限速（0 表示不限速），
+// to 早于 from 时跨过午夜（如 22:00–06:00）
+type rateWindow struct {
+	From      string `json:"from"` // HH:MM
+	To        string `json:"to"`   // HH:MM
+	RateLimit int64  `json:"rate_limit"`
+
+	from, to int // 当天的分钟数
+}
+
+// contains 时间 t（本地时间）是否在时间段内
+func (w rateWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.from <= w.to {
+		return m >= w.from && m < w.to
+	}
+	return m >= w.from || m < w.to
+}
+
+// parseClock 解析 HH:MM 为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("时间 %q 无效（格式 HH:MM）", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// rateLimiter 按每秒行数限速的令牌桶，多个表、多个 goroutine 共用同一个实例时合计限速。
+// 桶容量为 0.1 秒的令牌（空闲后也不会突发超过限速太多），初始为空；配置了 schedule 时按当前时间所在的时间段确定速率，不在任何时间段内时按 base
+type rateLimiter struct {
+	base     int64
+	schedule []rateWindow
+
+	mu     sync.Mutex
+	rate   int64 // 当前生效的速率，0 表示不限速
+	tokens float64
+	last   time.Time
+	log    *tableLogger // 速率调整的日志输出，nil 时写控制台
+}
+
+// newRateLimiter 创建限速器；base 为 0 且没有 schedule 时返回 nil（不限速）
+func newRateLimiter(base int64, schedule []rateWindow) (*rateLimiter, error) {
+	if base < 0 {
+		return nil, fmt.Errorf("rate_limit 不能为负数")
+	}
+	if base == 0 && len(schedule) == 0 {
+		return nil, nil
+	}
+	windows := make([]rateWindow, len(schedule))
+	for i, w := range schedule {
+		var err error
+		if w.from, err = parseClock(w.From); err != nil {
+			return nil, fmt.Errorf("rate_limit_schedule 第 %d 项: %w", i+1, err)
+		}
+		if w.to, err = parseClock(w.To); err != nil {
+			return nil, fmt.Errorf("rate_limit_schedule 第 %d 项: %w", i+1, err)
+		}
+		if w.from == w.to {
+			return nil, fmt.Errorf("rate_limit_schedule 第 %d 项: from 与 to 相同", i+1)
+		}
+		if w.RateLimit < 0 {
+			return nil, fmt.Errorf("rate_limit_schedule 第 %d 项: rate_limit 不能为负数", i+1)
+		}
+		windows[i] = w
+	}
+	now := time.Now()
+	l := &rateLimiter{base: base, schedule: windows, last: now}
+	l.rate = l.rateAt(now)
+	return l, nil
+}
+
+// rateAt 时间 t 生效的速率：第一个包含 t 的时间段，否则为 base
+func (l *rateLimiter) rateAt(t time.Time) int64 {
+	for _, w := range l.schedule {
+		if w.contains(t) {
+			return w.RateLimit
+		}
+	}
+	return l.base
+}
+
+// current 当前生效的速率（nil 或不限速时为 0）
+func (l *rateLimiter) current() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// String 限速设置的说明（用于日志）
+func (l *rateLimiter) String() string {
+	if len(l.schedule) == 0 {
+		return rateText(l.base)
+	}
+	parts := []string{"默认" + rateText(l.base)}
+	for _, w := range l.schedule {
+		parts = append(parts, fmt.Sprintf("%s–%s %s", w.From, w.To, rateText(w.RateLimit)))
+	}
+	return strings.Join(parts, "，")
+}
+
+// rateText 速率的显示文本
+func rateText(rate int64) string {
+	if rate == 0 {
+		return "不限速"
+	}
+	return fmt.Sprintf("%d 行/秒", rate)
+}
+
+// wait 取 n 个令牌，不足时等待；ctx 取消时返回 ctx.Err()。nil 时立即返回
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if rate := l.rateAt(now); rate != l.rate {
+		l.log.infof("限速调整为 %s（rate_limit_schedule）\n", rateText(rate))
+		l.rate, l.tokens = rate, 0
+	}
+	if l.rate == 0 {
+		l.last = now
+		l.mu.Unlock()
+		return nil
+	}
+	// 补充令牌，最多 0.1 秒的量（至少 1 个）
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*float64(l.rate), max(float64(l.rate)/10, 1))
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		// 先预支，按欠下的令牌等待；其它 goroutine 随后排在后面
+		delay = time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// logEffectiveRate 配置了限速时在表的汇总中打印实际平均速度与当前限速
+func logEffectiveRate(opts copyTableOptions, rows int64, seconds float64) {
+	if opts.RateLimiter == nil || seconds <= 0 {
+		return
+	}
+	opts.Log.infof("平均速度: %.0f 行/秒（限速 %s）\n", float64(rows)/seconds, rateText(opts.RateLimiter.current()))
+}
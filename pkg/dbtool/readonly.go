@@ -0,0 +1,312 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// read_only 数据源的两层保护：
+// - 会话层：连接参数让数据库本身拒绝写入（Postgres default_transaction_read_only、MySQL transaction_read_only、
+//   SQLite query_only；SQL Server 指定了数据库时加 ApplicationIntent=ReadOnly，Oracle 没有连接级的只读设置）
+// - 代码层：连接在驱动之上包装一层检查，只允许 SELECT 及元数据语句，其它语句在发往数据库之前即被拒绝
+
+// errReadOnly 向 read_only 数据源执行写语句
+type errReadOnly struct {
+	label string
+	query string
+}
+
+func (e *errReadOnly) Error() string {
+	return fmt.Sprintf("数据源 %s 配置了 read_only，拒绝执行非查询语句: %s", e.label, truncateRunes(strings.Join(strings.Fields(e.query), " "), 200))
+}
+
+// withReadOnly 在 DSN 中加入只读会话参数（DSN 已指定时不覆盖）
+func withReadOnly(driver, dsn string) string {
+	switch {
+	case isPostgresDriver(driver):
+		return dsnWithParam(driver, dsn, "default_transaction_read_only", "on")
+	case driver == "mysql":
+		// MySQL 5.7.20+ / 8.0；连接时执行 SET transaction_read_only=1
+		return dsnWithParam(driver, dsn, "transaction_read_only", "1")
+	case driver == "sqlite3":
+		return dsnWithParam(driver, dsn, "_query_only", "1")
+	case driver == "sqlserver":
+		// ApplicationIntent=ReadOnly 要求 DSN 指定数据库，未指定时只靠代码层检查
+		if strings.Contains(strings.ToLower(dsn), "database=") {
+			return dsnWithParam(driver, dsn, "ApplicationIntent", "ReadOnly")
+		}
+	}
+	return dsn
+}
+
+// dsnWithParam 在 DSN 中加入一个连接参数，DSN 已指定该参数时不覆盖：
+// Postgres URL / key=value、SQL Server URL / ADO 连接串、MySQL 与 SQLite 的 ? 参数；其它驱动原样返回
+func dsnWithParam(driver, dsn, key, value string) string {
+	has := func(s string) bool { return strings.Contains(strings.ToLower(s), strings.ToLower(key)+"=") }
+	switch {
+	case isPostgresDriver(driver), driver == "sqlserver":
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") || strings.HasPrefix(dsn, "sqlserver://") {
+			u, err := url.Parse(dsn)
+			if err != nil {
+				return dsn
+			}
+			q := u.Query()
+			for k := range q {
+				if strings.EqualFold(k, key) {
+					return dsn
+				}
+			}
+			q.Set(key, value)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+		if has(dsn) || strings.HasPrefix(dsn, "odbc:") {
+			return dsn
+		}
+		if driver == "sqlserver" {
+			return strings.TrimSuffix(dsn, ";") + ";" + key + "=" + value
+		}
+		return strings.TrimSpace(dsn + " " + key + "='" + value + "'")
+	case driver == "mysql", driver == "sqlite3":
+		if i := strings.Index(dsn, "?"); i >= 0 && has(dsn[i:]) {
+			return dsn
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + key + "=" + url.QueryEscape(value)
+	}
+	return dsn
+}
+
+// openReadOnlyDB 打开带只读检查的连接；t 非 nil 时同时记录 SQL（被拒绝的语句也会记录）
+func openReadOnlyDB(cfg dbConfig, t *sqlTracer) (*sql.DB, error) {
+	inner, err := openConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var c driver.Connector = &readOnlyConnector{inner: inner, label: traceLabel(cfg)}
+	if t != nil {
+		c = &traceConnector{inner: c, label: traceLabel(cfg), tracer: t}
+	}
+	return sql.OpenDB(c), nil
+}
+
+// readOnlyConnector 为每个新连接包装 readOnlyConn
+type readOnlyConnector struct {
+	inner driver.Connector
+	label string
+}
+
+func (c *readOnlyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.inner.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyConn{Conn: conn, label: c.label}, nil
+}
+
+func (c *readOnlyConnector) Driver() driver.Driver { return c.inner.Driver() }
+
+// readOnlyConn 执行前检查语句，只放行查询与元数据语句；结果集原样返回驱动的实现
+type readOnlyConn struct {
+	driver.Conn
+	label string
+}
+
+func (c *readOnlyConn) check(query string) error {
+	if !isReadOnlyStatement(query) {
+		return &errReadOnly{label: c.label, query: query}
+	}
+	return nil
+}
+
+func (c *readOnlyConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.check(query); err != nil {
+		return nil, err
+	}
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *readOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *readOnlyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.check(query); err != nil {
+		return nil, err
+	}
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return e.ExecContext(ctx, query, args)
+}
+
+func (c *readOnlyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.check(query); err != nil {
+		return nil, err
+	}
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return q.QueryContext(ctx, query, args)
+}
+
+func (c *readOnlyConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() // 驱动未实现 ConnBeginTx 时的回退
+}
+
+func (c *readOnlyConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *readOnlyConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *readOnlyConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *readOnlyConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// readOnlyWriteWords 出现在允许的语句中（字符串、带引号的标识符与注释之外）即视为可能写入的关键字，
+// 如 WITH ... DELETE、SELECT ... INTO、SELECT ... FOR UPDATE、EXPLAIN ANALYZE、SELECT setval(...)
+var readOnlyWriteWords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true, "INTO": true,
+	"CREATE": true, "DROP": true, "ALTER": true, "TRUNCATE": true, "GRANT": true, "REVOKE": true,
+	"CALL": true, "EXEC": true, "EXECUTE": true, "ANALYZE": true, "NEXTVAL": true, "SETVAL": true,
+}
+
+// isReadOnlyStatement 判断语句（可含多条，以 ; 分隔）是否只读：
+// 以 SELECT / WITH / SHOW / DESCRIBE / EXPLAIN / VALUES / PRAGMA / SET / ALTER SESSION、游标或事务控制语句开头，
+// 且不含写入关键字；SET 不能改变只读设置，PRAGMA 不能赋值
+func isReadOnlyStatement(query string) bool {
+	stmts := splitStatementWords(query)
+	if len(stmts) == 0 {
+		return false
+	}
+	for _, words := range stmts {
+		first := words[0]
+		rest := words[1:]
+		switch first {
+		case "SELECT", "WITH", "SHOW", "DESCRIBE", "DESC", "EXPLAIN", "VALUES", "START", "BEGIN", "COMMIT", "ROLLBACK",
+			"DECLARE", "FETCH", "CLOSE": // Postgres 游标（DECLARE 的查询同样检查写入关键字）
+		case "PRAGMA":
+			for _, w := range rest {
+				if w == "=" {
+					return false
+				}
+			}
+		case "SET":
+			for _, w := range rest {
+				if strings.Contains(w, "READ_ONLY") || strings.Contains(w, "QUERY_ONLY") || w == "WRITE" {
+					return false
+				}
+			}
+		case "ALTER":
+			if len(rest) == 0 || rest[0] != "SESSION" {
+				return false
+			}
+			rest = rest[1:]
+		default:
+			return false
+		}
+		for _, w := range rest {
+			if readOnlyWriteWords[w] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitStatementWords 把语句按 ; 拆分，每条返回大写的单词序列（另外保留 = 号）；
+// 跳过字符串、带引号的标识符与注释，空语句忽略
+func splitStatementWords(query string) [][]string {
+	var out [][]string
+	var cur []string
+	var word strings.Builder
+	endWord := func() {
+		if word.Len() > 0 {
+			cur = append(cur, strings.ToUpper(word.String()))
+			word.Reset()
+		}
+	}
+	endStmt := func() {
+		endWord()
+		if len(cur) > 0 {
+			out = append(out, cur)
+		}
+		cur = nil
+	}
+	r := []rune(query)
+	for i := 0; i < len(r); i++ {
+		ch := r[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`' || ch == '[':
+			endWord()
+			closing := ch
+			if ch == '[' {
+				closing = ']'
+			}
+			for i++; i < len(r); i++ {
+				if r[i] == closing {
+					if i+1 < len(r) && r[i+1] == closing && closing != ']' {
+						i++ // 引号转义（'' 或 ""）
+						continue
+					}
+					break
+				}
+			}
+		case ch == '-' && i+1 < len(r) && r[i+1] == '-':
+			endWord()
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+		case ch == '/' && i+1 < len(r) && r[i+1] == '*':
+			endWord()
+			for i += 2; i+1 < len(r) && !(r[i] == '*' && r[i+1] == '/'); i++ {
+			}
+			i++
+		case ch == ';':
+			endStmt()
+		case ch == '=':
+			endWord()
+			cur = append(cur, "=")
+		case ch == '_' || ch == '$' || ch == '@' || ch == '#' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch > 127:
+			word.WriteRune(ch)
+		default:
+			endWord()
+		}
+	}
+	endStmt()
+	return out
+}
@@ -0,0 +1,467 @@
+package dbtool
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tableVerificationResult 记录单张表的数据核对结果
+type tableVerificationResult struct {
+	TableName     string `json:"table"`
+	SourceCount   int64  `json:"source_count"`
+	TargetCount   int64  `json:"target_count"`
+	MigratedCount int64  `json:"migrated"`
+	Diff          int64  `json:"diff"`
+	HasDiff       bool   `json:"has_diff"`
+	NotCompared   bool   `json:"not_compared,omitempty"` // 源表或目标表记录数未统计（skip_source_count 或查询失败），不参与差异计算
+	Mode          string `json:"mode,omitempty"`         // 核对方式：全表 / 窗口（目标表按复制窗口统计）
+
+	ColumnMismatches []string `json:"column_mismatches,omitempty"` // verify_columns 中统计不一致的项（如 "amount 合计"）
+	Since            string   `json:"since,omitempty"`             // 增量起点（未配置增量列时为空）
+	WatermarkOld     string   `json:"watermark_old,omitempty"`     // -state：本次运行前的水位
+	WatermarkNew     string   `json:"watermark_new,omitempty"`     // -state：本次运行后的水位
+	Deleted          int64    `json:"deleted"`                     // sync_deletes：删除（dry-run 时为将删除）的目标表行数，-1 表示未启用
+	Hints            []string `json:"hints,omitempty"`             // 需要人工核对的情况（如提交时连接断开、无法确认是否已提交的批次）
+	Atomic           bool     `json:"atomic,omitempty"`            // 整表在一个事务中写入（atomic）
+	Sequences        []string `json:"sequences,omitempty"`         // sync_sequences：调整过的序列（原值 → 新值）
+	CopySeconds      float64  `json:"copy_seconds,omitempty"`      // 数据复制耗时（不含延后创建/重建索引）
+	IndexSeconds     float64  `json:"index_seconds,omitempty"`     // indexes=deferred / rebuild_indexes：复制结束后创建索引的耗时
+}
+
+// newVerificationResult 根据记录数构建核对结果；任一记录数小于 0 时标记为未比较
+func newVerificationResult(table string, sourceCount, targetCount, migratedCount int64) tableVerificationResult {
+	result := tableVerificationResult{
+		TableName:     table,
+		SourceCount:   sourceCount,
+		TargetCount:   targetCount,
+		MigratedCount: migratedCount,
+		Deleted:       -1,
+	}
+	if sourceCount >= 0 && targetCount >= 0 {
+		result.Diff = targetCount - sourceCount
+		result.HasDiff = result.Diff != 0
+	} else {
+		result.NotCompared = true
+	}
+	return result
+}
+
+// verificationSummary 汇总所有表的核对结果
+type verificationSummary struct {
+	results          []tableVerificationResult
+	totalSource      int64 // 仅统计已比较的表
+	totalTarget      int64 // 仅统计已比较的表
+	totalMigrated    int64
+	totalDeleted     int64 // sync_deletes 删除的总行数
+	deleteTables     int   // 启用 sync_deletes 的表数
+	diffTables       int
+	notComparedTable int
+	columnDiffTables int            // 列统计存在不一致的表数
+	verifyOnly       bool           // -verify 模式：只核对不复制，报告中不显示迁移记录数
+	scripts          []scriptResult // before_sync / after_sync 脚本的执行结果
+}
+
+// summaryReport 汇总报告的 JSON 形式（HTTP API 等使用），字段含义与 print 输出的汇总报告一致
+type summaryReport struct {
+	Version           string                    `json:"dbtool_version"`
+	Tables            int                       `json:"tables"`
+	DiffTables        int                       `json:"diff_tables"`
+	NotComparedTables int                       `json:"not_compared_tables"`
+	ColumnDiffTables  int                       `json:"column_diff_tables"`
+	TotalSource       int64                     `json:"total_source"`
+	TotalTarget       int64                     `json:"total_target"`
+	TotalMigrated     int64                     `json:"total_migrated"`
+	TotalDeleted      int64                     `json:"total_deleted"`
+	Results           []tableVerificationResult `json:"results"`
+	Scripts           []scriptResult            `json:"scripts,omitempty"`
+}
+
+// report 返回汇总报告的 JSON 形式
+func (s *verificationSummary) report() summaryReport {
+	results := s.results
+	if results == nil {
+		results = []tableVerificationResult{}
+	}
+	return summaryReport{
+		Version:           version,
+		Tables:            len(s.results),
+		DiffTables:        s.diffTables,
+		NotComparedTables: s.notComparedTable,
+		ColumnDiffTables:  s.columnDiffTables,
+		TotalSource:       s.totalSource,
+		TotalTarget:       s.totalTarget,
+		TotalMigrated:     s.totalMigrated,
+		TotalDeleted:      s.totalDeleted,
+		Results:           results,
+		Scripts:           s.scripts,
+	}
+}
+
+// add 累加一张表的核对结果
+func (s *verificationSummary) add(r tableVerificationResult) {
+	s.results = append(s.results, r)
+	s.totalMigrated += r.MigratedCount
+	if r.Deleted >= 0 {
+		s.deleteTables++
+		s.totalDeleted += r.Deleted
+	}
+	if len(r.ColumnMismatches) > 0 {
+		s.columnDiffTables++
+	}
+	if r.NotCompared {
+		s.notComparedTable++
+		return
+	}
+	s.totalSource += r.SourceCount
+	s.totalTarget += r.TargetCount
+	if r.HasDiff {
+		s.diffTables++
+	}
+}
+
+// print 打印总体数据核对汇总报告
+func (s *verificationSummary) print(startTime time.Time) {
+	totalDiff := s.totalTarget - s.totalSource
+	totalDurationSeconds := time.Since(startTime).Seconds()
+	endTime := time.Now()
+
+	log.Printf("\n")
+	log.Printf("########################################\n")
+	if s.verifyOnly {
+		log.Printf("总体数据核对汇总报告（仅核对，未复制数据）\n")
+	} else {
+		log.Printf("总体数据核对汇总报告\n")
+	}
+	log.Printf("########################################\n")
+	log.Printf("总表数: %d\n", len(s.results))
+	log.Printf("存在差异的表数: %d\n", s.diffTables)
+	if windowed := s.countMode(verifyWindow); windowed > 0 {
+		log.Printf("按复制窗口核对的表数: %d（其余按全表核对）\n", windowed)
+	}
+	if exported := s.countMode(verifyFile); exported > 0 {
+		log.Printf("导出到文件的表数: %d（目标记录数为写入文件的行数）\n", exported)
+	}
+	if atomic := s.atomicTables(); len(atomic) > 0 {
+		log.Printf("整表一个事务写入（atomic）的表: %s\n", strings.Join(atomic, ", "))
+	}
+	if s.columnDiffTables > 0 {
+		log.Printf("列统计不一致的表数: %d\n", s.columnDiffTables)
+	}
+	if s.notComparedTable > 0 {
+		log.Printf("未比较的表数: %d\n", s.notComparedTable)
+	}
+	log.Printf("\n")
+	log.Printf("时间统计:\n")
+	log.Printf("  开始时间: %s\n", startTime.Format("2006-01-02 15:04:05"))
+	log.Printf("  结束时间: %s\n", endTime.Format("2006-01-02 15:04:05"))
+	if s.verifyOnly {
+		log.Printf("  总核对耗时: %.2f 秒 (%.2f 分钟)\n", totalDurationSeconds, totalDurationSeconds/60)
+	} else {
+		log.Printf("  总迁移耗时: %.2f 秒 (%.2f 分钟)\n", totalDurationSeconds, totalDurationSeconds/60)
+	}
+	log.Printf("  源库总记录数: %d\n", s.totalSource)
+	log.Printf("  目标库总记录数: %d\n", s.totalTarget)
+	if !s.verifyOnly {
+		log.Printf("  迁移总记录数: %d\n", s.totalMigrated)
+	}
+	if s.deleteTables > 0 {
+		log.Printf("  同步删除总记录数: %d（%d 张表启用 sync_deletes）\n", s.totalDeleted, s.deleteTables)
+	}
+	log.Printf("  总体差异: %d\n", totalDiff)
+	if totalDiff == 0 {
+		log.Printf("  数据核对结果: ✅ 无差异\n")
+	} else if totalDiff > 0 {
+		log.Printf("  数据核对结果: ⚠️ 目标库比源库多 %d 条\n", totalDiff)
+	} else {
+		log.Printf("  数据核对结果: ❌ 目标库比源库少 %d 条\n", -totalDiff)
+	}
+	if s.notComparedTable > 0 {
+		log.Printf("  （源库/目标库总记录数与总体差异不含未比较的表）\n")
+	}
+
+	// 打印存在差异的表详情
+	if s.diffTables > 0 {
+		log.Printf("\n")
+		log.Printf("存在差异的表详情:\n")
+		for _, result := range s.results {
+			if result.HasDiff {
+				if result.Diff > 0 {
+					log.Printf("  ❌ %s [%s]: 源库 %d 条, 目标库 %d 条, 多 %d 条\n",
+						result.TableName, result.Mode, result.SourceCount, result.TargetCount, result.Diff)
+				} else {
+					log.Printf("  ❌ %s [%s]: 源库 %d 条, 目标库 %d 条, 少 %d 条\n",
+						result.TableName, result.Mode, result.SourceCount, result.TargetCount, -result.Diff)
+				}
+			}
+		}
+	}
+	if s.columnDiffTables > 0 {
+		log.Printf("\n")
+		log.Printf("列统计不一致的表详情:\n")
+		for _, result := range s.results {
+			if len(result.ColumnMismatches) > 0 {
+				log.Printf("  ⚠️ %s: %s\n", result.TableName, strings.Join(result.ColumnMismatches, ", "))
+			}
+		}
+	}
+	if s.countHints() > 0 {
+		log.Printf("\n")
+		log.Printf("核对提示:\n")
+		for _, result := range s.results {
+			for _, hint := range result.Hints {
+				log.Printf("  ⚠️ %s: %s\n", result.TableName, hint)
+			}
+		}
+	}
+	if s.countIncremental() > 0 {
+		log.Printf("\n")
+		log.Printf("增量同步起点:\n")
+		for _, result := range s.results {
+			if result.Since != "" {
+				if result.WatermarkNew != "" || result.WatermarkOld != "" {
+					log.Printf("  %s: since %s，水位 %s → %s\n", result.TableName, result.Since,
+						firstNonEmpty(result.WatermarkOld, "（无）"), firstNonEmpty(result.WatermarkNew, "（无）"))
+				} else {
+					log.Printf("  %s: since %s\n", result.TableName, result.Since)
+				}
+			}
+		}
+	}
+	if s.countSequences() > 0 {
+		log.Printf("\n")
+		log.Printf("序列调整:\n")
+		for _, result := range s.results {
+			for _, seq := range result.Sequences {
+				log.Printf("  %s: %s\n", result.TableName, seq)
+			}
+		}
+	}
+	if s.countIndexBuilds() > 0 {
+		log.Printf("\n")
+		log.Printf("索引创建（不计入复制耗时）:\n")
+		for _, result := range s.results {
+			if result.IndexSeconds > 0 {
+				log.Printf("  %s: 复制 %.2f 秒，创建索引 %.2f 秒\n", result.TableName, result.CopySeconds, result.IndexSeconds)
+			}
+		}
+	}
+	if s.totalDeleted > 0 {
+		log.Printf("\n")
+		log.Printf("同步删除的表:\n")
+		for _, result := range s.results {
+			if result.Deleted > 0 {
+				log.Printf("  🗑 %s: 删除 %d 条源表已不存在的行\n", result.TableName, result.Deleted)
+			}
+		}
+	}
+	if len(s.scripts) > 0 {
+		log.Printf("\n")
+		log.Printf("同步前后脚本:\n")
+		for _, r := range s.scripts {
+			switch {
+			case r.Error != "":
+				log.Printf("  ❌ %s %s（%s）: 执行 %d/%d 条语句后失败: %s\n", r.Phase, r.Name, r.Source, r.Executed, r.Statements, r.Error)
+			case r.Skipped != "":
+				log.Printf("  ➖ %s %s（%s）: %s\n", r.Phase, r.Name, r.Source, r.Skipped)
+			case r.DryRun:
+				log.Printf("  ➖ %s %s（%s）: Dry-Run，%d 条语句未执行\n", r.Phase, r.Name, r.Source, r.Statements)
+			default:
+				log.Printf("  ✅ %s %s（%s）: %d 条语句，耗时 %.2f 秒\n", r.Phase, r.Name, r.Source, r.Executed, r.Seconds)
+			}
+		}
+	}
+	if s.notComparedTable > 0 {
+		log.Printf("\n")
+		log.Printf("未比较的表:\n")
+		for _, result := range s.results {
+			if result.NotCompared {
+				if s.verifyOnly {
+					log.Printf("  ➖ %s: 未比较\n", result.TableName)
+				} else {
+					log.Printf("  ➖ %s: 未比较（迁移 %d 条）\n", result.TableName, result.MigratedCount)
+				}
+			}
+		}
+	}
+	log.Printf("########################################\n")
+}
+
+// countMode 统计使用某种核对方式的表数
+func (s *verificationSummary) countMode(mode string) int {
+	n := 0
+	for _, r := range s.results {
+		if r.Mode == mode {
+			n++
+		}
+	}
+	return n
+}
+
+// countIncremental 统计增量同步的表数
+func (s *verificationSummary) countIncremental() int {
+	n := 0
+	for _, r := range s.results {
+		if r.Since != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// atomicTables 整表一个事务写入的表名
+func (s *verificationSummary) atomicTables() []string {
+	var out []string
+	for _, r := range s.results {
+		if r.Atomic {
+			out = append(out, r.TableName)
+		}
+	}
+	return out
+}
+
+// countHints 有核对提示的表数
+func (s *verificationSummary) countHints() int {
+	n := 0
+	for _, r := range s.results {
+		if len(r.Hints) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// countIndexBuilds 复制结束后创建过索引的表数
+func (s *verificationSummary) countIndexBuilds() int {
+	n := 0
+	for _, r := range s.results {
+		if r.IndexSeconds > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// countSequences 调整过序列的表数
+func (s *verificationSummary) countSequences() int {
+	n := 0
+	for _, r := range s.results {
+		if len(r.Sequences) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// reportOptions 汇总报告的文件输出（-report-json / -report-csv / -report-html），路径为空表示不输出
+type reportOptions struct {
+	JSON string // 汇总报告的 JSON 形式（与 HTTP API 的 report 相同）
+	CSV  string // 每张表一行的 CSV
+	HTML string // 便于邮件阅读的 HTML 表格
+}
+
+// reportCSVHeader CSV 报告的列
+var reportCSVHeader = []string{"table", "mode", "source_count", "target_count", "diff", "has_diff", "not_compared", "migrated", "deleted", "column_mismatches"}
+
+// writeReports 按 opts 把汇总报告写入 JSON / CSV / HTML 文件
+func (s *verificationSummary) writeReports(opts reportOptions) error {
+	if opts.JSON != "" {
+		data, err := s.jsonReport()
+		if err != nil {
+			return err
+		}
+		if err := writeFileSync(opts.JSON, data); err != nil {
+			return fmt.Errorf("写入 JSON 报告 %s 失败: %w", opts.JSON, err)
+		}
+	}
+	if opts.CSV != "" {
+		if err := writeFileSync(opts.CSV, s.csvReport()); err != nil {
+			return fmt.Errorf("写入 CSV 报告 %s 失败: %w", opts.CSV, err)
+		}
+	}
+	if opts.HTML != "" {
+		data, err := s.htmlReport()
+		if err != nil {
+			return err
+		}
+		if err := writeFileSync(opts.HTML, data); err != nil {
+			return fmt.Errorf("写入 HTML 报告 %s 失败: %w", opts.HTML, err)
+		}
+	}
+	return nil
+}
+
+// jsonReport 返回 -report-json 文件的内容
+func (s *verificationSummary) jsonReport() ([]byte, error) {
+	data, err := json.MarshalIndent(s.report(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("生成 JSON 报告失败: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// reportHTMLTemplate -report-html 的页面：汇总数字加每张表一行，存在差异的行标红
+var reportHTMLTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dbtool 同步报告</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+tr.diff td { background: #fde2e2; }
+</style>
+</head>
+<body>
+<h2>dbtool 同步报告</h2>
+<p>共 {{.Tables}} 张表，存在差异 {{.DiffTables}} 张，未比较 {{.NotComparedTables}} 张，列统计不一致 {{.ColumnDiffTables}} 张；
+源表 {{.TotalSource}} 条，目标表 {{.TotalTarget}} 条，迁移 {{.TotalMigrated}} 条。</p>
+<table>
+<tr><th>表</th><th>核对方式</th><th>源表</th><th>目标表</th><th>差异</th><th>迁移</th><th>删除</th><th>列统计不一致</th></tr>
+{{- range .Results}}
+<tr{{if or .HasDiff .ColumnMismatches}} class="diff"{{end}}><td>{{.TableName}}</td><td>{{.Mode}}</td><td>{{.SourceCount}}</td><td>{{.TargetCount}}</td><td>{{if .NotCompared}}未比较{{else}}{{.Diff}}{{end}}</td><td>{{.MigratedCount}}</td><td>{{if ge .Deleted 0}}{{.Deleted}}{{end}}</td><td>{{range $i, $c := .ColumnMismatches}}{{if $i}}; {{end}}{{$c}}{{end}}</td></tr>
+{{- end}}
+</table>
+<p>dbtool {{.Version}}</p>
+</body>
+</html>
+`))
+
+// htmlReport 返回 -report-html 文件的内容
+func (s *verificationSummary) htmlReport() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := reportHTMLTemplate.Execute(&buf, s.report()); err != nil {
+		return nil, fmt.Errorf("生成 HTML 报告失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// csvReport 返回每张表一行的 CSV 报告；未比较的表 diff 为空
+func (s *verificationSummary) csvReport() []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(reportCSVHeader)
+	for _, r := range s.results {
+		diff := ""
+		if !r.NotCompared {
+			diff = strconv.FormatInt(r.Diff, 10)
+		}
+		_ = w.Write([]string{
+			r.TableName, r.Mode,
+			strconv.FormatInt(r.SourceCount, 10), strconv.FormatInt(r.TargetCount, 10), diff,
+			strconv.FormatBool(r.HasDiff), strconv.FormatBool(r.NotCompared),
+			strconv.FormatInt(r.MigratedCount, 10), strconv.FormatInt(r.Deleted, 10),
+			strings.Join(r.ColumnMismatches, "; "),
+		})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
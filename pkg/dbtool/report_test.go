@@ -0,0 +1,126 @@
+package dbtool
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummaryReportFiles(t *testing.T) {
+	s := &verificationSummary{verifyOnly: true}
+	ok := newVerificationResult("orders", 10, 10, 0)
+	ok.Mode = verifyWindow
+	diff := newVerificationResult("users", 5, 3, 0)
+	diff.ColumnMismatches = []string{"amount 合计", "id 空值数"}
+	s.add(ok)
+	s.add(diff)
+	s.add(newVerificationResult("logs", -1, 7, 0))
+
+	dir := t.TempDir()
+	opts := reportOptions{JSON: filepath.Join(dir, "report.json"), CSV: filepath.Join(dir, "report.csv"), HTML: filepath.Join(dir, "report.html")}
+	if err := s.writeReports(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(opts.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report summaryReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("JSON 报告无效: %v", err)
+	}
+	if report.Tables != 3 || report.DiffTables != 1 || report.NotComparedTables != 1 || report.ColumnDiffTables != 1 {
+		t.Errorf("JSON 报告: %+v", report)
+	}
+
+	f, err := os.Open(opts.CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV 报告无效: %v", err)
+	}
+	if len(records) != 4 || len(records[0]) != len(reportCSVHeader) {
+		t.Fatalf("CSV 报告: %q", records)
+	}
+	want := [][]string{
+		{"orders", verifyWindow, "10", "10", "0", "false", "false"},
+		{"users", "", "5", "3", "-2", "true", "false"},
+		{"logs", "", "-1", "7", "", "false", "true"}, // 未比较的表没有差值
+	}
+	for i, w := range want {
+		for j, v := range w {
+			if records[i+1][j] != v {
+				t.Errorf("第 %d 行 %s 为 %q，期望 %q", i+1, reportCSVHeader[j], records[i+1][j], v)
+			}
+		}
+	}
+	if got := records[2][len(reportCSVHeader)-1]; got != "amount 合计; id 空值数" {
+		t.Errorf("列统计差异: %q", got)
+	}
+
+	page, err := os.ReadFile(opts.HTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `<tr class="diff"><td>users</td>`) || !strings.Contains(string(page), "<td>未比较</td>") {
+		t.Errorf("HTML 报告:\n%s", page)
+	}
+}
+
+func TestEmailAttachesReports(t *testing.T) {
+	n, err := newNotifier(&notificationConfig{Email: &emailConfig{Host: "smtp.example.com", From: "etl@example.com", To: []string{"dba@example.com"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.reports = reportOptions{JSON: "/tmp/out/verify.json", CSV: "/tmp/out/verify.csv", HTML: "/tmp/out/verify.html"}
+	n.passStarted(1)
+	n.tableFinished("orders", &tableVerificationResult{TableName: "orders", SourceCount: 2, TargetCount: 2, MigratedCount: 2}, nil)
+	msg, err := n.senders[0].sender.(*emailSender).message(n.build(notifyRunFinished, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 正文之外附带 JSON 与 HTML 报告，文件名与报告输出相同；CSV 不作为附件
+	var files []string
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name := p.FileName(); name != "" {
+			data, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+			if !bytes.Contains(data, []byte("orders")) {
+				t.Errorf("附件 %s 中没有表结果: %s", name, data)
+			}
+			files = append(files, name)
+		}
+	}
+	if got := strings.Join(files, ","); got != "verify.json,verify.html" {
+		t.Fatalf("附件 %s，期望 verify.json,verify.html", got)
+	}
+}
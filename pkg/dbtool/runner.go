@@ -0,0 +1,947 @@
+package dbtool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// errSyncStopped 收到中断信号后在表与表之间停止同步
+var errSyncStopped = errors.New("收到中断信号，同步已停止")
+
+// syncRunner 配置文件模式的同步执行器：持有解析后的表清单、源/目标连接与增量水位，
+// 一次性运行时执行一轮，-loop、schedule 模式下复用连接与水位重复执行多轮
+type syncRunner struct {
+	cfg       *toolConfig
+	run       runOptions
+	sourceCfg dbConfig
+	targetCfg dbConfig
+	tables    []configTable
+	schedule  *cronSchedule // 配置了 schedule 且未指定 -run-once 时按 cron 表达式常驻运行
+
+	src *simpleDB
+	dst *simpleDB
+
+	// 增量水位：-state 指定的状态文件，或 state_backend=target 时目标库中的状态表
+	state              *syncState
+	stateMu            sync.Mutex // HTTP API 允许并发运行时保护 state
+	stateStore         *targetStateStore
+	sourceID, targetID string
+	// savedKeys 本进程保存过水位的表：-reset-state 与 ignore_state 只忽略启动前记录的水位，
+	// 常驻运行（-loop、schedule、-serve）从第二轮起按本进程保存的水位继续
+	savedKeys map[string]bool
+
+	connMu    sync.Mutex       // 保护重连时替换 src/dst 与 inFlight
+	inFlight  int              // 进行中的运行数（-serve-concurrent 时可能多于 1），大于 0 时不重连
+	observers []passObserver   // 每一轮都通知的观察者（通知、指标等）
+	listeners []copyListener   // 每一轮都通知的复制事件监听者（状态文件、嵌入方的 Callbacks）
+	status    *statusReporter  // -status-file / GET /status 的进度汇总，未启用时为 nil
+	metrics   *metricsRegistry // -metrics-listen / -metrics-pushgateway 的指标，未启用时为 nil
+	history   *historyRecorder // history_table 的运行历史，未配置时为 nil
+	limiter   *rateLimiter     // rate_limit / -rate-limit 的全局限速，各表共用（合计限速），未配置时为 nil
+	memory    *memoryBudget    // max_memory / -max-memory 的全局内存预算，各表共用，未配置时为 nil
+
+	beforeSync, afterSync []preparedScript // before_sync / after_sync 脚本，每轮执行
+
+	log *tableLogger // 连接、表清单与各轮的日志输出（嵌入方的 Options.Logger），nil 时写控制台与 -log-file
+}
+
+// newSyncRunner 加载配置、解析表清单并连接源库与目标库
+func newSyncRunner(configPath string, run runOptions) (*syncRunner, error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载配置文件失败: %w", err)
+	}
+	return openSyncRunner(context.Background(), cfg, run)
+}
+
+// openSyncRunner 按已加载的配置解析表清单并连接源库与目标库；ctx 用于拉取表清单、依赖排序等准备查询
+func openSyncRunner(ctx context.Context, cfg *toolConfig, run runOptions) (*syncRunner, error) {
+	sourceCfg, targetCfg, tables, err := resolveConfig(cfg, run.Log)
+	if err != nil {
+		return nil, fmt.Errorf("解析配置失败: %w", err)
+	}
+
+	var schedule *cronSchedule
+	if expr := strings.TrimSpace(cfg.Schedule); expr != "" && !run.RunOnce {
+		if run.Loop > 0 || run.Serve.Addr != "" {
+			return nil, fmt.Errorf("配置了 schedule 时不能同时使用 -loop、-serve（可用 -run-once 忽略 schedule 立即执行一次）")
+		}
+		if schedule, err = parseCron(expr); err != nil {
+			return nil, fmt.Errorf("schedule 配置无效: %w", err)
+		}
+	}
+
+	if err := checkODBCConfig(cfg, sourceCfg, targetCfg); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(run.DumpDialect) != "" {
+		targetCfg.DumpDialect = run.DumpDialect
+	}
+	if err := checkFileTargetConfig(cfg, run, sourceCfg, targetCfg); err != nil {
+		return nil, err
+	}
+	if err := checkTargetTxOptions(targetCfg); err != nil {
+		return nil, fmt.Errorf("目标数据源配置错误: %w", err)
+	}
+	if err := checkSessionOptions(targetCfg); err != nil {
+		return nil, fmt.Errorf("目标数据源配置错误: %w", err)
+	}
+	rate := cfg.RateLimit
+	if run.RateLimit > 0 {
+		rate = run.RateLimit
+	}
+	limiter, err := newRateLimiter(rate, cfg.RateLimitSchedule)
+	if err != nil {
+		return nil, err
+	}
+	if limiter != nil {
+		limiter.log = run.Log
+	}
+	maxMemory := cfg.MaxMemory
+	if run.MaxMemory > 0 {
+		maxMemory = run.MaxMemory
+	}
+	memory, err := newMemoryBudget(maxMemory)
+	if err != nil {
+		return nil, err
+	}
+	beforeSync, err := prepareScripts(scriptBeforeSync, cfg.BeforeSync, cfg)
+	if err != nil {
+		return nil, err
+	}
+	afterSync, err := prepareScripts(scriptAfterSync, cfg.AfterSync, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if targetCfg.ReadOnly && !run.DryRun {
+		return nil, fmt.Errorf("目标数据源配置了 read_only，不能写入（请检查 sync 中的 source、target 是否写反）")
+	}
+
+	// 若为从源库拉取表清单，先连接源库查询表名列表
+	if tables, err = resolveTableList(ctx, cfg, sourceCfg, tables); err != nil {
+		return nil, err
+	}
+
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("表清单为空，请检查 table_list 或 tables 配置")
+	}
+
+	r := &syncRunner{cfg: cfg, run: run, sourceCfg: sourceCfg, targetCfg: targetCfg, schedule: schedule, limiter: limiter, memory: memory,
+		beforeSync: beforeSync, afterSync: afterSync, log: run.Log}
+	if limiter != nil {
+		run.Log.infof("限速: %s\n", limiter)
+	}
+	if memory != nil {
+		run.Log.infof("内存预算: %d 字节（未配置 lob_spill_threshold 的表超过 %d 字节的大字段溢写到临时文件）\n", memory.limit, memory.spillThreshold())
+	}
+	run.Log.infof("连接源数据库: %s\n", sourceCfg.Driver)
+	if r.src, err = newSimpleDB(sourceCfg); err != nil {
+		return nil, fmt.Errorf("源数据库连接失败: %w", err)
+	}
+
+	if cfg.OrderByDependencies {
+		schema := ""
+		if cfg.TableList != nil {
+			schema = strings.TrimSpace(cfg.TableList.Schema)
+		}
+		if tables, err = orderTablesByDependencies(ctx, r.src, schema, tables); err != nil {
+			r.Close()
+			return nil, fmt.Errorf("按外键依赖排序表清单失败: %w", err)
+		}
+	}
+
+	if cfg.TableList != nil && cfg.TableList.ExpandPartitions {
+		if tables, err = expandPartitions(ctx, r.src, strings.TrimSpace(cfg.TableList.Schema), tables); err != nil {
+			r.Close()
+			return nil, fmt.Errorf("拆分分区表失败: %w", err)
+		}
+	}
+	r.tables = tables
+
+	run.Log.infof("连接目标数据库: %s\n", targetCfg.Driver)
+	if r.dst, err = newSimpleDB(targetCfg); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("目标数据库连接失败: %w", err)
+	}
+
+	if err := r.openState(ctx); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// openState 按 state_backend 加载增量水位
+func (r *syncRunner) openState(ctx context.Context) error {
+	var err error
+	cfg, run := r.cfg, r.run
+	r.sourceID, r.targetID = dbIdentity(r.sourceCfg), dbIdentity(r.targetCfg)
+	switch strings.ToLower(strings.TrimSpace(cfg.StateBackend)) {
+	case "", stateBackendFile:
+		if strings.TrimSpace(run.StatePath) != "" {
+			if r.state, err = loadSyncState(run.StatePath); err != nil {
+				return fmt.Errorf("加载状态文件失败: %w", err)
+			}
+			if run.ResetState {
+				r.log.infof("-reset-state：忽略状态文件 %s 中已有的水位\n", run.StatePath)
+			}
+		} else if run.Loop > 0 || r.schedule != nil || run.Serve.Addr != "" {
+			// 常驻运行未指定状态文件时水位只保存在内存中：后续各轮只复制新行，进程退出后不保留
+			r.state = &syncState{Tables: make(map[string]watermarkEntry)}
+			r.log.infof("常驻运行未指定 -state，增量水位仅保存在内存中\n")
+		}
+	case stateBackendTarget:
+		r.stateStore = &targetStateStore{dst: r.dst, table: firstNonEmpty(strings.TrimSpace(cfg.StateTable), defaultStateTable)}
+		if err := r.stateStore.ensure(ctx, run.DryRun); err != nil {
+			return fmt.Errorf("准备状态表失败: %w", err)
+		}
+		if r.state, err = r.stateStore.load(ctx); err != nil {
+			return fmt.Errorf("加载状态表失败: %w", err)
+		}
+		// 状态表就在目标库中，按（源表, 目标表）区分即可
+		r.sourceID, r.targetID = "", ""
+		if strings.TrimSpace(run.StatePath) != "" {
+			r.log.warnf("警告：state_backend=target，忽略 -state %s\n", run.StatePath)
+		}
+		if run.ResetState {
+			r.log.infof("-reset-state：忽略状态表 %s 中已有的水位\n", r.stateStore.table)
+		}
+	default:
+		return fmt.Errorf("不支持的 state_backend: %s（可选 file、target）", cfg.StateBackend)
+	}
+	return nil
+}
+
+// Close 关闭源库与目标库连接
+func (r *syncRunner) Close() {
+	if r.src != nil {
+		_ = r.src.Close()
+	}
+	if r.dst != nil {
+		_ = r.dst.Close()
+	}
+}
+
+// ensureConnected 开始一次运行：检查源库与目标库连接，ping 失败时重新建立连接，返回运行结束时调用的 release。
+// 已有运行在进行时不 ping 也不重连：连接正被其它运行使用（pinned 的写入会话可能占满连接池，ping 会一直等待），
+// 替换连接会中断它们；连接确实断开时由进行中的运行报错，之后的运行再重连
+func (r *syncRunner) ensureConnected(ctx context.Context) (release func(), err error) {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+	release = func() {
+		r.connMu.Lock()
+		r.inFlight--
+		r.connMu.Unlock()
+	}
+	if r.inFlight > 0 {
+		r.inFlight++
+		return release, nil
+	}
+	reconnect := func(name string, db **simpleDB, cfg dbConfig) error {
+		if (*db).files != nil {
+			return nil
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, (*db).pingTimeout)
+		err := (*db).db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		r.log.infof("%s连接已断开（%v），重新连接\n", name, err)
+		fresh, err := newSimpleDB(cfg)
+		if err != nil {
+			return fmt.Errorf("%s重新连接失败: %w", name, err)
+		}
+		_ = (*db).Close()
+		*db = fresh
+		return nil
+	}
+	if err := reconnect("源数据库", &r.src, r.sourceCfg); err != nil {
+		return nil, err
+	}
+	if err := reconnect("目标数据库", &r.dst, r.targetCfg); err != nil {
+		return nil, err
+	}
+	if r.stateStore != nil {
+		r.stateStore.dst = r.dst
+	}
+	r.inFlight++
+	return release, nil
+}
+
+// tableLimiter 表的限速器：配置了表级 rate_limit 时单独限速（rate_limit_schedule 仍生效），否则共用全局限速器
+func (r *syncRunner) tableLimiter(t configTable) (*rateLimiter, error) {
+	if t.RateLimit == 0 {
+		return r.limiter, nil
+	}
+	l, err := newRateLimiter(t.RateLimit, r.cfg.RateLimitSchedule)
+	if l != nil {
+		l.log = r.log
+	}
+	return l, err
+}
+
+// newEntry 按本次复制实际读到的最大值生成水位记录
+func (r *syncRunner) newEntry(opts copyTableOptions, tracker *watermarkTracker) watermarkEntry {
+	e := watermarkEntry{
+		Source:         r.sourceID,
+		Target:         r.targetID,
+		Table:          firstNonEmpty(opts.PartitionOf, opts.Table),
+		TargetTable:    firstNonEmpty(opts.TargetTable, firstNonEmpty(opts.PartitionOf, opts.Table)),
+		IncrementalKey: strings.Join(incrementalKeys(opts), ","),
+		UpdatedAt:      time.Now().Format("2006-01-02 15:04:05"),
+	}
+	e.setValues(tracker)
+	return e
+}
+
+// saveWatermark 保存表的新水位，返回报告中显示的旧水位与新水位
+func (r *syncRunner) saveWatermark(key string, opts copyTableOptions, tracker *watermarkTracker, old *watermarkEntry) (oldValue, newValue string, err error) {
+	if old != nil {
+		oldValue, newValue = old.display(), old.display()
+	}
+	if r.state == nil || opts.DryRun || tracker == nil || tracker.max == nil {
+		return oldValue, newValue, nil
+	}
+	if partial := partialCopy(opts); partial != "" {
+		opts.Log.warnf("警告：本次按 %s 只复制了部分行，增量水位保持不变\n", partial)
+		return oldValue, newValue, nil
+	}
+	entry := r.newEntry(opts, tracker)
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	r.state.Tables[key] = entry
+	if r.savedKeys == nil {
+		r.savedKeys = make(map[string]bool)
+	}
+	r.savedKeys[key] = true
+	switch {
+	case opts.StateWriter != nil:
+		// 已随最终提交的事务写入状态表
+	case r.stateStore != nil:
+		_, dst := r.conns()
+		if err := r.stateStore.upsert(context.Background(), dst.db, entry); err != nil {
+			return "", "", fmt.Errorf("表 %s 保存增量水位失败: %w", opts.Table, err)
+		}
+	case strings.TrimSpace(r.run.StatePath) != "":
+		if err := saveSyncState(r.run.StatePath, r.state); err != nil {
+			return "", "", fmt.Errorf("表 %s 保存增量水位失败: %w", opts.Table, err)
+		}
+	}
+	opts.Log.infof("增量水位已更新: %s = %s\n", entry.IncrementalKey, entry.display())
+	return oldValue, entry.display(), nil
+}
+
+// conns 返回当前的源库与目标库连接
+func (r *syncRunner) conns() (src, dst *simpleDB) {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+	return r.src, r.dst
+}
+
+// passObserver 接收一轮同步中的表级事件：开始复制某张表、某张表完成或失败。
+// 同一轮内按表的顺序在执行同步的 goroutine 中依次调用
+type passObserver interface {
+	// tableStarted progress 为该表的复制进度，复制过程中持续累加
+	tableStarted(table string, progress *tableProgress)
+	// tableFinished result 为该表的核对结果（分区单元为 nil，核对结果在父表汇总时给出），err 非空表示失败
+	tableFinished(table string, result *tableVerificationResult, err error)
+}
+
+// passLifecycle 可选实现：需要感知一轮同步开始与结束的观察者（状态文件、指标等）
+type passLifecycle interface {
+	passStarted(tables int) // tables 为本轮要同步的表配置数
+	passFinished(err error)
+}
+
+// passOptions 一轮同步的运行参数
+type passOptions struct {
+	ctx       context.Context // 复制等数据库操作使用的 context，为空时不可取消
+	stop      context.Context // 结束后在表与表之间停止，为空时不停止
+	tables    map[string]bool // 只同步这些表（小写，按源表、目标表或分区父表匹配），为空表示全部
+	observers []passObserver  // 只在本轮通知的观察者
+	listeners []copyListener  // 只在本轮通知的复制事件监听者
+	runID     string          // 本轮的标识（运行历史表等使用），为空时自动生成
+}
+
+// tableFilter 校验表名并转为 passOptions.tables（HTTP API、库调用的表过滤）；不在表清单中的表名报错
+func (r *syncRunner) tableFilter(tables []string) (map[string]bool, error) {
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]bool)
+	for _, t := range r.tables {
+		for _, name := range []string{t.SourceTable, t.TargetTable, t.PartitionOf} {
+			if name != "" {
+				known[strings.ToLower(name)] = true
+			}
+		}
+	}
+	filter := make(map[string]bool)
+	var unknown []string
+	for _, name := range tables {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if !known[key] {
+			unknown = append(unknown, name)
+			continue
+		}
+		filter[key] = true
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("表清单中没有这些表: %s", strings.Join(unknown, ", "))
+	}
+	return filter, nil
+}
+
+// contexts 返回数据库操作与表间停止使用的 context（未设置时为 Background）
+func (p passOptions) contexts() (ctx, stop context.Context) {
+	ctx, stop = p.ctx, p.stop
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if stop == nil {
+		stop = context.Background()
+	}
+	return ctx, stop
+}
+
+// includes 判断表配置是否在本轮的表过滤范围内
+func (p passOptions) includes(t configTable) bool {
+	if len(p.tables) == 0 {
+		return true
+	}
+	for _, name := range []string{t.SourceTable, t.TargetTable, t.PartitionOf} {
+		if name != "" && p.tables[strings.ToLower(name)] {
+			return true
+		}
+	}
+	return false
+}
+
+// count 返回本轮要同步的表配置数
+func (p passOptions) count(tables []configTable) int {
+	n := 0
+	for _, t := range tables {
+		if strings.TrimSpace(t.SourceTable) != "" && p.includes(t) {
+			n++
+		}
+	}
+	return n
+}
+
+// notifyPass 对实现了 passLifecycle 的观察者与监听者调用 fn
+func (r *syncRunner) notifyPass(p passOptions, fn func(passLifecycle)) {
+	for _, o := range append(append([]passObserver{}, r.observers...), p.observers...) {
+		if l, ok := o.(passLifecycle); ok {
+			fn(l)
+		}
+	}
+	for _, o := range r.passListeners(p) {
+		if l, ok := o.(passLifecycle); ok {
+			fn(l)
+		}
+	}
+}
+
+// passListeners 返回全局与本轮的复制事件监听者
+func (r *syncRunner) passListeners(p passOptions) []copyListener {
+	return append(append([]copyListener{}, r.listeners...), p.listeners...)
+}
+
+// notifyStarted 通知全局与本轮的观察者某张表开始复制
+func (r *syncRunner) notifyStarted(p passOptions, table string, progress *tableProgress) {
+	for _, o := range r.observers {
+		o.tableStarted(table, progress)
+	}
+	for _, o := range p.observers {
+		o.tableStarted(table, progress)
+	}
+}
+
+// notifyFinished 通知全局与本轮的观察者、以及该表进度的监听者某张表完成或失败
+func (r *syncRunner) notifyFinished(p passOptions, table string, progress *tableProgress, result *tableVerificationResult, err error) {
+	for _, o := range r.observers {
+		o.tableFinished(table, result, err)
+	}
+	for _, o := range p.observers {
+		o.tableFinished(table, result, err)
+	}
+	progress.finish(result, err)
+}
+
+// checkIncremental 复制任何表之前检查本轮所有表的增量配置，避免前面的表已写入后才发现后面的表 since/until 无效
+func (r *syncRunner) checkIncremental(ctx context.Context, src *simpleDB, p passOptions) error {
+	for _, t := range r.tables {
+		if strings.TrimSpace(t.SourceTable) == "" || !p.includes(t) {
+			continue
+		}
+		opts := tableOptions(r.cfg, t, r.run.DryRun, r.run.Limit)
+		if err := validateIncremental(opts); err != nil {
+			return fmt.Errorf("表 %s 配置错误: %w", opts.Table, err)
+		}
+		if err := resolveIncrementalTypes(ctx, src, &opts); err != nil {
+			return fmt.Errorf("表 %s 增量条件无效: %w", opts.Table, err)
+		}
+	}
+	return nil
+}
+
+// runPass 按表清单执行一轮同步，返回本轮的核对汇总。
+// p.stop 结束后在表与表之间停止（当前表会完整结束），返回已完成表的汇总与 errSyncStopped
+func (r *syncRunner) runPass(p passOptions) (_ *verificationSummary, passErr error) {
+	if p.runID == "" {
+		p.runID = newRunID()
+	}
+	if r.history != nil {
+		p.observers = append(append([]passObserver{}, p.observers...), r.history.pass(p.runID))
+	}
+	r.notifyPass(p, func(l passLifecycle) { l.passStarted(p.count(r.tables)) })
+	defer func() { r.notifyPass(p, func(l passLifecycle) { l.passFinished(passErr) }) }()
+	ctx, stop := p.contexts()
+	cfg, run := r.cfg, r.run
+	passStart := time.Now()
+	src, dst := r.conns()
+
+	// 收集所有表的数据核对结果
+	summary := &verificationSummary{}
+
+	if err := r.checkIncremental(ctx, src, p); err != nil {
+		return summary, err
+	}
+
+	// after_sync 在本轮结束时执行（有表失败时只执行 always 的脚本），before_sync 在复制第一张表之前执行
+	if len(r.afterSync) > 0 {
+		defer func() {
+			results, err := r.runScripts(ctx, r.afterSync, passErr != nil)
+			summary.scripts = append(summary.scripts, results...)
+			if passErr == nil {
+				passErr = err
+			}
+		}()
+	}
+	if len(r.beforeSync) > 0 {
+		results, err := r.runScripts(ctx, r.beforeSync, false)
+		summary.scripts = append(summary.scripts, results...)
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	// consistent_snapshot：本轮所有表在源库的同一快照中读取
+	var snapshot *sourceSnapshot
+	if cfg.ConsistentSnapshot || run.Snapshot {
+		var err error
+		if snapshot, err = openSourceSnapshot(ctx, src); err != nil {
+			return summary, err
+		}
+		defer snapshot.Close()
+		r.log.infof("一致性快照: %s，本轮所有表在同一快照中读取\n", snapshot.describe())
+	}
+
+	// 表失败时通知观察者并结束本轮
+	current := ""
+	var currentProgress *tableProgress
+	fail := func(err error) (*verificationSummary, error) {
+		r.notifyFinished(p, current, currentProgress, nil, err)
+		return summary, err
+	}
+	listeners := r.passListeners(p)
+
+	// since=auto 按目标表解析一次（分区单元、同一目标表的多个配置共用，避免被先复制的部分抬高起点）
+	autoSince := make(map[string]copyTableOptions)
+
+	// 分区单元（expand_partitions 连续输出）按父表成组：逐个准备后一起复制，再按父表执行序列同步、索引创建与核对
+	// （所有单元写入同一目标表，这些步骤只执行一次）
+	var group *partitionTotal
+	unitListeners := serializeListeners(listeners)
+	copyPartition := func(pt *partitionTotal) error {
+		parallel, reason := r.partitionParallel(dst, snapshot)
+		if reason != "" && len(pt.units) > 2 {
+			pt.opts.Log.infof("分区表 %s 的分区单元逐个复制（%s）\n", pt.parent, reason)
+		} else if parallel > 1 && len(pt.units) > 2 {
+			pt.opts.Log.infof("分区表 %s 共 %d 个分区单元，第一个单元复制后最多 %d 个同时复制\n", pt.parent, len(pt.units), parallel)
+		}
+		results := r.copyPartitionUnits(ctx, stop, src, dst, p, pt.units, parallel)
+		var unitErr error
+		stopped := false
+		for k, res := range results {
+			unit := pt.units[k]
+			switch {
+			case res.skipped:
+				stopped = true
+			case res.err == nil:
+				if res.sourceCount < 0 || pt.sourceCount < 0 {
+					pt.sourceCount = -1
+				} else {
+					pt.sourceCount += res.sourceCount
+				}
+				pt.migrated += res.migrated
+				pt.copySeconds += res.seconds
+				pt.hints = append(pt.hints, unit.Hints.items()...)
+				pt.watermark.merge(unit.Watermark)
+			case unitErr == nil:
+				// 第一个失败的单元由 fail 通知，其余失败的单元在这里通知
+				unitErr = fmt.Errorf("表 %s 同步失败: %w", unit.Table, res.err)
+				current, currentProgress = unit.Table, unit.Progress
+			default:
+				r.notifyFinished(p, unit.Table, unit.Progress, nil, res.err)
+			}
+		}
+		if unitErr != nil || stopped {
+			// 预先删除的索引仍要重建；延后创建的索引与水位只在全部单元成功后处理
+			pt.plan.finish(false)
+			if unitErr != nil {
+				return unitErr
+			}
+			r.log.infof("分区表 %s 的分区单元未全部复制，停止同步\n", pt.parent)
+			return errSyncStopped
+		}
+
+		current = pt.parent
+		// 父表的汇总只报告结束，开始时间按本轮开始计算
+		totalOpts := pt.opts
+		totalOpts.Table, totalOpts.PartitionOf = pt.parent, ""
+		totalOpts.Log = r.log.forTable(pt.parent)
+		currentProgress = newTableProgress(totalOpts, listeners)
+		currentProgress.started = passStart
+		currentProgress.begun.Store(true)
+		currentProgress.add(pt.migrated)
+		pt.plan.finish(true)
+		var sequences []string
+		var err error
+		if totalOpts.SyncSequences || strings.TrimSpace(totalOpts.SequenceName) != "" {
+			if sequences, err = syncSequences(ctx, dst, pt.targetTable, totalOpts); err != nil {
+				return fmt.Errorf("表 %s 同步序列失败: %w", pt.parent, err)
+			}
+		}
+		if totalOpts.PostgresSetLogged {
+			if err := setTableLogged(ctx, dst, pt.targetTable, pt.plan.unlogged, totalOpts); err != nil {
+				return fmt.Errorf("表 %s 转为 LOGGED 失败: %w", pt.parent, err)
+			}
+		}
+		targetCount := countTargetRows(ctx, dst.db, pt.targetTable, pt.opts, dst.cfg.Driver)
+		totalOpts.Log.infof("分区表 %s 核对: 源表 %d 条，目标表 %d 条，迁移 %d 条\n", pt.parent, pt.sourceCount, targetCount, pt.migrated)
+		result := newVerificationResult(pt.parent, pt.sourceCount, targetCount, pt.migrated)
+		result.Mode = verificationMode(pt.opts, dst.cfg.Driver)
+		result.Since = incrementalStart(pt.opts)
+		result.Hints = pt.hints
+		result.Sequences = sequences
+		result.CopySeconds, result.IndexSeconds = pt.copySeconds, pt.plan.seconds
+		// 分区单元全部完成后才保存水位，避免中途失败时水位超前于未复制的分区
+		if result.WatermarkOld, result.WatermarkNew, err = r.saveWatermark(pt.stateKey, pt.opts, pt.watermark, pt.oldWatermark); err != nil {
+			return err
+		}
+		if len(pt.opts.VerifyColumns) > 0 && !pt.opts.DryRun {
+			parentOpts := pt.opts
+			parentOpts.Table, parentOpts.Partition = pt.parent, ""
+			result.ColumnMismatches = verifyColumnStats(ctx, src, dst, parentOpts)
+		}
+		summary.add(result)
+		r.notifyFinished(p, result.TableName, currentProgress, &result, nil)
+		return nil
+	}
+
+	for i, t := range r.tables {
+		if stop.Err() != nil {
+			r.log.infof("已完成 %d/%d 张表，停止同步\n", i, len(r.tables))
+			return summary, errSyncStopped
+		}
+		if strings.TrimSpace(t.SourceTable) == "" {
+			r.log.warnf("警告：第 %d 个表配置 source_table 为空，跳过\n", i)
+			continue
+		}
+		if !p.includes(t) {
+			continue
+		}
+		opts := tableOptions(cfg, t, run.DryRun, run.Limit)
+		opts.Log = r.log.forTable(t.SourceTable)
+		opts.DryRunSamples = run.Samples
+		opts.AuditRunStart = passStart
+		opts.RunID = p.runID
+		limiter, errLimit := r.tableLimiter(t)
+		if errLimit != nil {
+			return fail(fmt.Errorf("表 %s 配置错误: %w", opts.Table, errLimit))
+		}
+		opts.RateLimiter = limiter
+		opts.Hints = &copyHints{}
+		opts.Memory = r.memory
+		opts.Snapshot = snapshot
+		if r.memory != nil && opts.LobSpillThreshold == 0 {
+			opts.LobSpillThreshold = r.memory.spillThreshold()
+		}
+		opts.BatchSizing = &batchSizing{}
+		current = opts.Table
+		if opts.PartitionOf != "" {
+			// 分区单元可能并行复制，回调逐个调用；开始事件在单元实际开始复制时通知
+			opts.Progress = newTableProgress(opts, unitListeners)
+		} else {
+			opts.Progress = newTableProgress(opts, listeners)
+			r.notifyStarted(p, opts.Table, opts.Progress)
+		}
+		currentProgress = opts.Progress
+		if err := validateIncremental(opts); err != nil {
+			return fail(fmt.Errorf("表 %s 配置错误: %w", opts.Table, err))
+		}
+		if err := validateAtomic(opts); err != nil {
+			return fail(fmt.Errorf("表 %s 配置错误: %w", opts.Table, err))
+		}
+		if _, err := validateWriteModeTx(opts); err != nil {
+			return fail(fmt.Errorf("表 %s 配置错误: %w", opts.Table, err))
+		}
+		if err := validatePostBatchSQL(opts, r.targetCfg.Driver); err != nil {
+			return fail(fmt.Errorf("表 %s 配置错误: %w", opts.Table, err))
+		}
+		if err := checkODBCOptions(r.sourceCfg.Driver, r.targetCfg.Driver, opts); err != nil {
+			return fail(fmt.Errorf("表 %s 配置错误: %w", opts.Table, err))
+		}
+		if err := validateFileTarget(opts, r.targetCfg.Driver); err != nil {
+			return fail(fmt.Errorf("表 %s 配置错误: %w", opts.Table, err))
+		}
+		if err := checkSameTable(r.sourceCfg, r.targetCfg, firstNonEmpty(opts.PartitionOf, opts.Table), firstNonEmpty(opts.TargetTable, firstNonEmpty(opts.PartitionOf, opts.Table)), run.AllowSame); err != nil {
+			return fail(fmt.Errorf("表 %s: %w", opts.Table, err))
+		}
+
+		// 增量水位：状态文件中有记录时作为 since（优先于配置的 since/auto）
+		stateKey := watermarkKey(r.sourceID, r.targetID, firstNonEmpty(opts.PartitionOf, opts.Table), firstNonEmpty(opts.TargetTable, firstNonEmpty(opts.PartitionOf, opts.Table)))
+		var oldWatermark *watermarkEntry
+		if keys := strings.Join(incrementalKeys(opts), ","); r.state != nil && keys != "" {
+			r.stateMu.Lock()
+			e, ok := r.state.Tables[stateKey]
+			ignore := (run.ResetState || t.IgnoreState) && !r.savedKeys[stateKey]
+			r.stateMu.Unlock()
+			if ok && !ignore && strings.EqualFold(e.IncrementalKey, keys) {
+				oldWatermark = &e
+				applyWatermark(&opts, e)
+				opts.Log.infof("使用已记录的增量水位: %s > %s（%s 记录）\n", keys, e.display(), e.UpdatedAt)
+			}
+			if opts.PartitionOf != "" {
+				// 各单元分别记录，全部单元完成后合并为父表的水位
+				opts.Watermark = &watermarkTracker{}
+			} else {
+				opts.Watermark = &watermarkTracker{}
+				if r.stateStore != nil && partialCopy(opts) == "" {
+					// 水位随每次复制的最终提交一起写入状态表，不会超前于已提交的数据（只复制部分行时不写入）
+					tableOpts, tracker, store := opts, opts.Watermark, r.stateStore
+					opts.StateWriter = func(ctx context.Context, tx batchTx) error {
+						if tracker.max == nil {
+							return nil
+						}
+						return store.upsert(ctx, tx, r.newEntry(tableOpts, tracker))
+					}
+				}
+			}
+		}
+		if opts.Watermark == nil && opts.PostBatchSQL != "" && len(incrementalKeys(opts)) > 0 {
+			// post_batch_sql 的 :max_inc_key 取扫描到的增量列最大值，未记录增量水位时也需要跟踪
+			opts.Watermark = &watermarkTracker{}
+		}
+		if oldWatermark == nil && isAutoSince(opts.Since) {
+			targetKey := strings.ToLower(firstNonEmpty(opts.TargetTable, opts.Table))
+			if resolved, ok := autoSince[targetKey]; ok {
+				opts.Since, opts.SinceValue, opts.SinceAuto = resolved.Since, resolved.SinceValue, true
+			} else {
+				if err := resolveAutoSince(ctx, dst, &opts); err != nil {
+					return fail(fmt.Errorf("表 %s 解析 since=auto 失败: %w", opts.Table, err))
+				}
+				autoSince[targetKey] = opts
+			}
+		}
+		if err := resolveSoftDelete(ctx, src, &opts); err != nil {
+			return fail(fmt.Errorf("表 %s 软删除配置无效: %w", opts.Table, err))
+		}
+		if err := resolveIncrementalTypes(ctx, src, &opts); err != nil {
+			return fail(fmt.Errorf("表 %s 增量条件无效: %w", opts.Table, err))
+		}
+
+		opts.Log.infof("开始根据配置同步表: source=%s, target=%s\n",
+			opts.Table, firstNonEmpty(opts.TargetTable, opts.Table))
+
+		if opts.PartitionOf != "" {
+			// sync_deletes：分区单元只覆盖父表的一部分，无法判断，不支持
+			if opts.SyncDeletes {
+				opts.Log.warnf("警告：表 %s 为分区单元，忽略 sync_deletes\n", opts.Table)
+			}
+			if group == nil {
+				group = &partitionTotal{parent: opts.PartitionOf, targetTable: firstNonEmpty(opts.TargetTable, opts.PartitionOf), opts: opts,
+					plan: newIndexPlan(dst, opts), stateKey: stateKey, oldWatermark: oldWatermark}
+				if r.state != nil && len(incrementalKeys(opts)) > 0 {
+					group.watermark = &watermarkTracker{}
+				}
+			}
+			// 同一父表的单元共用索引计划与表级限速
+			opts.IndexPlan, opts.RateLimiter = group.plan, group.opts.RateLimiter
+			group.units = append(group.units, opts)
+			if i+1 < len(r.tables) && r.tables[i+1].PartitionOf == t.PartitionOf && r.tables[i+1].TargetTable == t.TargetTable {
+				continue
+			}
+			// 该分区表的最后一个单元：复制全部单元后按父表汇总
+			pt := group
+			group = nil
+			if err := copyPartition(pt); err != nil {
+				if errors.Is(err, errSyncStopped) {
+					return summary, err
+				}
+				return fail(err)
+			}
+			continue
+		}
+
+		// 延后创建/重建的索引在整张表的数据复制结束后创建，耗时单独统计
+		opts.IndexPlan = newIndexPlan(dst, opts)
+		migratedCount, sourceCount, targetCount, copySeconds, err := copyTableOrChunks(ctx, src, dst, opts)
+		opts.IndexPlan.finish(err == nil)
+		if err != nil {
+			return fail(fmt.Errorf("表 %s 同步失败: %w", opts.Table, err))
+		}
+		var sequences []string
+		if opts.SyncSequences || strings.TrimSpace(opts.SequenceName) != "" {
+			if sequences, err = syncSequences(ctx, dst, firstNonEmpty(opts.TargetTable, opts.Table), opts); err != nil {
+				return fail(fmt.Errorf("表 %s 同步序列失败: %w", opts.Table, err))
+			}
+		}
+		if opts.PostgresSetLogged {
+			if err := setTableLogged(ctx, dst, firstNonEmpty(opts.TargetTable, opts.Table), opts.IndexPlan.unlogged, opts); err != nil {
+				return fail(fmt.Errorf("表 %s 转为 LOGGED 失败: %w", opts.Table, err))
+			}
+		}
+
+		// sync_deletes：删除目标表中源表已不存在的行
+		deleted := int64(-1)
+		if opts.SyncDeletes {
+			if deleted, err = syncDeletes(ctx, src, dst, opts); err != nil {
+				return fail(fmt.Errorf("表 %s 同步删除失败: %w", opts.Table, err))
+			}
+			if deleted > 0 && !opts.DryRun {
+				targetCount = countTargetRows(ctx, dst.db, firstNonEmpty(opts.TargetTable, opts.Table), opts, dst.cfg.Driver)
+			}
+		}
+
+		result := newVerificationResult(opts.Table, sourceCount, targetCount, migratedCount)
+		result.Mode = verificationMode(opts, dst.cfg.Driver)
+		result.Since = incrementalStart(opts)
+		result.Deleted = deleted
+		result.Hints = opts.Hints.items()
+		result.Atomic = opts.Atomic && !opts.DryRun
+		result.Sequences = sequences
+		result.CopySeconds, result.IndexSeconds = copySeconds, opts.IndexPlan.seconds
+		if result.WatermarkOld, result.WatermarkNew, err = r.saveWatermark(stateKey, opts, opts.Watermark, oldWatermark); err != nil {
+			return fail(err)
+		}
+		if opts.ChunkBy != nil {
+			result.Mode = verifyWindow
+		}
+		if len(opts.VerifyColumns) > 0 && !opts.DryRun {
+			result.ColumnMismatches = verifyColumnStats(ctx, src, dst, opts)
+		}
+		if result.HasDiff && opts.DiagnoseDiff && !opts.DryRun {
+			if err := diagnoseDiff(ctx, src, dst, opts); err != nil {
+				opts.Log.warnf("警告：表 %s 差异定位失败: %v\n", opts.Table, err)
+			}
+		}
+		summary.add(result)
+		r.notifyFinished(p, result.TableName, opts.Progress, &result, nil)
+	}
+
+	return summary, nil
+}
+
+// copyTableOrChunks 配置了 chunk_by 时按区间复制，否则整表复制
+func copyTableOrChunks(ctx context.Context, src, dst *simpleDB, opts copyTableOptions) (int64, int64, int64, float64, error) {
+	if opts.ChunkBy != nil {
+		return copyTableChunked(ctx, src, dst, opts)
+	}
+	return copyTable(ctx, src, dst, opts)
+}
+
+// pushMetrics 配置了 -metrics-pushgateway 时推送当前指标
+func (r *syncRunner) pushMetrics() {
+	if r.metrics != nil && r.run.MetricsPushgateway != "" {
+		r.metrics.push(r.run.MetricsPushgateway)
+	}
+}
+
+// notifyStop 返回在收到 SIGINT/SIGTERM 时结束的 context；第一次中断后恢复默认的信号处理，再次中断时直接结束进程
+func notifyStop() (context.Context, context.CancelFunc) {
+	stop, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+			infof("收到中断信号，当前表完成后退出（再次 Ctrl+C 立即退出）\n")
+			cancel()
+		case <-stop.Done():
+		}
+		signal.Stop(sigs)
+	}()
+	return stop, cancel
+}
+
+// passCounter 常驻运行（-loop、schedule）时各轮的累计统计
+type passCounter struct {
+	passes   int
+	failed   int
+	migrated int64
+}
+
+// runCounted 常驻运行时执行一轮：先检查连接，再同步并打印本轮汇总与累计统计；失败只记录，不退出进程
+func (r *syncRunner) runCounted(stop context.Context, c *passCounter) {
+	c.passes++
+	passStart := time.Now()
+	r.log.infof("========== 第 %d 轮同步开始 ==========\n", c.passes)
+	var migrated int64
+	release, err := r.ensureConnected(context.Background())
+	if err == nil {
+		defer release()
+		var summary *verificationSummary
+		summary, err = r.runPass(passOptions{stop: stop})
+		summary.print(passStart)
+		r.pushMetrics()
+		migrated = summary.totalMigrated
+	}
+	c.migrated += migrated
+	switch {
+	case errors.Is(err, errSyncStopped):
+	case err != nil:
+		c.failed++
+		r.log.errorf("第 %d 轮同步失败: %v\n", c.passes, err)
+	default:
+		r.log.infof("第 %d 轮同步完成\n", c.passes)
+	}
+	log.Printf("本轮迁移 %d 条，耗时 %s；累计 %d 轮（失败 %d 轮），迁移 %d 条\n",
+		migrated, time.Since(passStart).Round(time.Millisecond), c.passes, c.failed, c.migrated)
+}
+
+// runLoop -loop 模式：每轮遍历完表清单后休眠 interval 再开始下一轮，复用已有连接（每轮开始前检查，断开时重连），
+// 按增量水位只复制新行。收到 SIGINT/SIGTERM 后完成当前表即退出；某一轮失败时记录错误，下一轮继续
+func (r *syncRunner) runLoop(interval time.Duration) {
+	stop, cancel := notifyStop()
+	defer cancel()
+
+	counter := &passCounter{}
+	for {
+		r.runCounted(stop, counter)
+		if stop.Err() != nil {
+			r.log.infof("-loop 已退出\n")
+			return
+		}
+
+		r.log.infof("%s 后开始第 %d 轮\n", interval, counter.passes+1)
+		timer := time.NewTimer(interval)
+		select {
+		case <-stop.Done():
+			timer.Stop()
+			r.log.infof("-loop 已退出\n")
+			return
+		case <-timer.C:
+		}
+	}
+}
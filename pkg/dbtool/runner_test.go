@@ -0,0 +1,206 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunPassSnapshotFailureReturnsSummary(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	openTestSQLite(t, srcPath, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	openTestSQLite(t, dstPath, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "t"}]`)
+	cfg.ConsistentSnapshot = true
+	r, err := openSyncRunner(context.Background(), cfg, runOptions{RunOnce: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// 源库连接已关闭，开启一致性快照失败
+	r.src.db.Close()
+	summary, err := r.runPass(passOptions{})
+	if err == nil {
+		t.Fatal("开启快照失败时本轮应返回错误")
+	}
+	if summary == nil {
+		t.Fatal("开启快照失败时应返回已初始化的汇总")
+	}
+	// 与 runCounted、HTTP API 一样打印汇总，不应 panic
+	summary.print(time.Now())
+}
+
+func TestPartialCopyKeepsWatermark(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	statePath := filepath.Join(dir, "state.json")
+	openTestSQLite(t, srcPath, "CREATE TABLE t (id INTEGER PRIMARY KEY)", "INSERT INTO t VALUES (1), (2), (3)")
+	openTestSQLite(t, dstPath, "CREATE TABLE t (id INTEGER)")
+	cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "t", "incremental_key": "id"}]`)
+
+	run := func(opts Options) *syncState {
+		t.Helper()
+		opts.StatePath = statePath
+		s, err := Open(ctx, cfg, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		if _, err := s.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		state, err := loadSyncState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state
+	}
+	// limit 只复制了部分行，不记录水位，下次仍从头复制
+	if state := run(Options{Limit: 1}); len(state.Tables) != 0 {
+		t.Fatalf("limit 复制后不应记录水位: %+v", state.Tables)
+	}
+	state := run(Options{})
+	if len(state.Tables) != 1 {
+		t.Fatalf("完整复制后应记录水位: %+v", state.Tables)
+	}
+	for _, e := range state.Tables {
+		if e.LastValue != "3" {
+			t.Fatalf("水位 %s，期望 3", e.LastValue)
+		}
+	}
+}
+
+func TestPartialCopyKeepsTargetStateTable(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	openTestSQLite(t, srcPath, "CREATE TABLE t (id INTEGER PRIMARY KEY)", "INSERT INTO t VALUES (1), (2), (3)")
+	dst := openTestSQLite(t, dstPath, "CREATE TABLE t (id INTEGER)")
+	cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "t", "incremental_key": "id"}]`)
+	cfg.StateBackend = "target"
+
+	for _, opts := range []Options{{Limit: 1}, {}} {
+		s, err := Open(ctx, cfg, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Run(ctx)
+		s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		var last sql.NullString
+		if err := dst.QueryRowContext(ctx, "SELECT COUNT(*), MAX(last_value) FROM dbtool_sync_state").Scan(&n, &last); err != nil {
+			t.Fatal(err)
+		}
+		if opts.Limit > 0 && n != 0 {
+			t.Fatalf("limit 复制后状态表中不应有水位（%d 行）", n)
+		}
+		if opts.Limit == 0 && (n != 1 || last.String != "3") {
+			t.Fatalf("完整复制后状态表: %d 行，水位 %s，期望 1 行、水位 3", n, last.String)
+		}
+	}
+}
+
+func TestResetStateOnlyFirstPass(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	statePath := filepath.Join(dir, "state.json")
+	openTestSQLite(t, srcPath, "CREATE TABLE t (id INTEGER PRIMARY KEY)", "INSERT INTO t VALUES (1), (2), (3)")
+	openTestSQLite(t, dstPath, "CREATE TABLE t (id INTEGER)")
+	cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "t", "incremental_key": "id"}]`)
+
+	copied := func(s *Syncer) int64 {
+		t.Helper()
+		res, err := s.Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.RowsCopied
+	}
+	s, err := Open(ctx, cfg, Options{StatePath: statePath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied(s)
+	s.Close()
+
+	// -reset-state 只在第一轮忽略已记录的水位，之后按本进程保存的水位继续
+	s, err = Open(ctx, cfg, Options{StatePath: statePath, ResetState: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if n := copied(s); n != 3 {
+		t.Fatalf("第一轮复制 %d 行，期望忽略水位复制全部 3 行", n)
+	}
+	if n := copied(s); n != 0 {
+		t.Fatalf("第二轮复制 %d 行，期望从水位继续、不复制", n)
+	}
+}
+
+func TestIncrementalBoundsCheckedBeforeCopy(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	openTestSQLite(t, srcPath,
+		"CREATE TABLE a (id INTEGER PRIMARY KEY)", "INSERT INTO a VALUES (9), (10), (11)",
+		"CREATE TABLE b (id INTEGER PRIMARY KEY)")
+	dst := openTestSQLite(t, dstPath, "CREATE TABLE a (id INTEGER PRIMARY KEY)", "CREATE TABLE b (id INTEGER PRIMARY KEY)")
+	copied := func() int {
+		t.Helper()
+		var n int
+		if err := dst.QueryRow("SELECT COUNT(*) FROM a").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	run := func(tables string) error {
+		t.Helper()
+		s, err := Open(ctx, writeTestConfig(t, srcPath, dstPath, tables), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		_, err = s.Run(ctx)
+		return err
+	}
+
+	// 第二张表的 until 不是整数，第一张表也不应复制
+	if err := run(`[{"source_table": "a", "incremental_key": "id", "since": "9"},
+		{"source_table": "b", "incremental_key": "id", "until": "abc"}]`); err == nil {
+		t.Fatal("until 无效时应在复制前失败")
+	}
+	if n := copied(); n != 0 {
+		t.Fatalf("配置校验失败前已复制了 %d 行", n)
+	}
+
+	// since 按整数绑定，10、11 大于 9（按字符串比较时 '10' < '9'）
+	if err := run(`[{"source_table": "a", "incremental_key": "id", "since": "9"}]`); err != nil {
+		t.Fatal(err)
+	}
+	if n := copied(); n != 2 {
+		t.Fatalf("复制了 %d 行，期望 2", n)
+	}
+}
+
+func TestSetLoggedOnlyForTablesCreatedUnlogged(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "dst.db"), "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	// 驱动按 Postgres 处理，底层是 sqlite：执行 ALTER TABLE ... SET LOGGED 会报错
+	dst := &simpleDB{db: db, cfg: dbConfig{Driver: "postgres"}}
+	opts := copyTableOptions{Table: "t", PostgresSetLogged: true, Log: newTableLogger("t")}
+	if err := setTableLogged(ctx, dst, "t", false, opts); err != nil {
+		t.Fatalf("已存在的目标表不应执行 SET LOGGED: %v", err)
+	}
+	if err := setTableLogged(ctx, dst, "t", true, opts); err == nil {
+		t.Fatal("本次以 UNLOGGED 创建的表应执行 SET LOGGED")
+	}
+}
@@ -0,0 +1,122 @@
+package dbtool
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// dbEndpoint 返回用于判断源库与目标库是否为同一个库的规范化标识：驱动/主机:端口/库名。
+// 忽略用户、密码与连接参数，补全默认端口，localhost 与 127.0.0.1 视为同一主机；无法解析的 DSN 按原文比较
+func dbEndpoint(cfg dbConfig) string {
+	driver := normalizeDriver(cfg.Driver)
+	dsn := strings.TrimSpace(cfg.DSN)
+	var host, port, db string
+	switch {
+	case driver == "mysql":
+		mc, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return driver + "|" + dsn
+		}
+		if mc.Net == "unix" {
+			return driver + "|unix:" + filepath.Clean(mc.Addr) + "/" + mc.DBName
+		}
+		host, port = splitHostPort(mc.Addr, "3306")
+		db = mc.DBName
+	case isPostgresDriver(driver):
+		params := postgresParams(dsn)
+		if params == nil {
+			return driver + "|" + dsn
+		}
+		host, port = firstNonEmpty(params["host"], "localhost"), firstNonEmpty(params["port"], "5432")
+		db = firstNonEmpty(params["dbname"], params["user"])
+	case driver == "sqlserver":
+		u, err := url.Parse(dsn)
+		if err != nil || u.Scheme == "" {
+			return driver + "|" + strings.ToLower(dsn)
+		}
+		host, port = u.Hostname(), firstNonEmpty(u.Port(), "1433")
+		db = firstNonEmpty(u.Query().Get("database"), u.Query().Get("initial catalog"))
+	case driver == "sqlite3":
+		path := strings.TrimPrefix(dsn, "file:")
+		path, _, _ = strings.Cut(path, "?")
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		return driver + "|" + filepath.Clean(path)
+	default:
+		return driver + "|" + dsn
+	}
+	return driver + "|" + normalizeHost(host) + ":" + port + "/" + db
+}
+
+// splitHostPort 拆分 host:port，缺少端口时补默认端口
+func splitHostPort(addr, defaultPort string) (host, port string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
+	}
+	return host, firstNonEmpty(port, defaultPort)
+}
+
+// normalizeHost 主机名小写，本机的几种写法视为同一主机
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1":
+		return "localhost"
+	}
+	return host
+}
+
+// postgresParams 解析 Postgres 的 URL 或 key=value 形式的 DSN，只取 host、port、dbname、user
+func postgresParams(dsn string) map[string]string {
+	params := make(map[string]string)
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return nil
+		}
+		params["host"], params["port"] = u.Hostname(), u.Port()
+		params["dbname"] = strings.TrimPrefix(u.Path, "/")
+		params["user"] = u.User.Username()
+		for _, k := range []string{"host", "port", "dbname", "user"} {
+			if v := u.Query().Get(k); v != "" {
+				params[k] = v
+			}
+		}
+		return params
+	}
+	for _, field := range strings.Fields(dsn) {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		params[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(v, "'")
+	}
+	return params
+}
+
+// sameTable 判断源表与目标表名是否指向同一张表（不区分大小写；一侧不带 schema 时按表名比较）
+func sameTable(a, b string) bool {
+	a, b = strings.ToLower(strings.TrimSpace(a)), strings.ToLower(strings.TrimSpace(b))
+	if a == b {
+		return true
+	}
+	if strings.Contains(a, ".") != strings.Contains(b, ".") {
+		return a[strings.LastIndex(a, ".")+1:] == b[strings.LastIndex(b, ".")+1:]
+	}
+	return false
+}
+
+// checkSameTable 源库与目标库为同一个库且源表即目标表时拒绝复制（-allow-same-database 时跳过检查）
+func checkSameTable(src, dst dbConfig, table, targetTable string, allow bool) error {
+	if allow || dbEndpoint(src) != dbEndpoint(dst) || !sameTable(table, firstNonEmpty(targetTable, table)) {
+		return nil
+	}
+	return fmt.Errorf("源库与目标库是同一个库（%s），且目标表就是源表 %s，复制会破坏源数据；确认无误时使用 -allow-same-database", redactDSN(dbEndpoint(src)), table)
+}
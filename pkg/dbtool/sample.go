@@ -0,0 +1,79 @@
+package dbtool
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// 抽样方式（sample_mode）
+const (
+	sampleRandom = "random" // 随机抽样（默认），每次运行选中的行不同
+	sampleHash   = "hash"   // 按 sample_key 的哈希值取模抽样，重复试跑选中相同的行
+)
+
+// sampleClause 按 sample_percent 注入到生成查询中的抽样子句
+type sampleClause struct {
+	tableSuffix string // 追加在 FROM 表名后的子句（Postgres/SQL Server 的 TABLESAMPLE）
+	predicate   string // 追加到 WHERE 的抽样条件
+	random      bool   // 随机抽样：COUNT 与 SELECT 选中的行不同，源表记录数以实际读取的行数为准
+}
+
+// buildSampleClause 根据抽样比例与方式生成方言对应的抽样子句；percent <= 0 时返回 nil
+func buildSampleClause(opts copyTableOptions, driver string) (*sampleClause, error) {
+	p := opts.SamplePercent
+	if p <= 0 {
+		return nil, nil
+	}
+	if p > 100 {
+		return nil, fmt.Errorf("sample_percent 必须在 (0, 100] 之间，当前为 %v", p)
+	}
+	driver = normalizeDriver(driver)
+	// 以万分之一为单位取模，支持 0.01% 粒度的比例
+	basis := int64(math.Round(p * 100))
+
+	switch mode := strings.ToLower(strings.TrimSpace(opts.SampleMode)); mode {
+	case "", sampleRandom:
+		c := &sampleClause{random: true}
+		switch driver {
+		case "postgres", "postgresql":
+			c.tableSuffix = fmt.Sprintf(" TABLESAMPLE BERNOULLI (%v)", p)
+		case "sqlserver":
+			c.tableSuffix = fmt.Sprintf(" TABLESAMPLE (%v PERCENT)", p)
+		case "mysql":
+			c.predicate = fmt.Sprintf("RAND() < %v", p/100)
+		case "oracle":
+			c.predicate = fmt.Sprintf("DBMS_RANDOM.VALUE < %v", p/100)
+		case "sqlite3":
+			c.predicate = fmt.Sprintf("ABS(RANDOM()) %% 10000 < %d", basis)
+		default:
+			return nil, fmt.Errorf("sample_percent 暂不支持源库驱动 %s", driver)
+		}
+		return c, nil
+	case sampleHash:
+		key := strings.TrimSpace(firstNonEmpty(opts.SampleKey, opts.IncrementalKey))
+		if key == "" {
+			return nil, fmt.Errorf("sample_mode=hash 需要配置 sample_key 或 incremental_key")
+		}
+		col := quoteIdent(key, driver)
+		var expr string
+		switch driver {
+		case "postgres", "postgresql":
+			expr = fmt.Sprintf("ABS(HASHTEXT(%s::text)::bigint) %% 10000", col)
+		case "sqlserver":
+			expr = fmt.Sprintf("ABS(CAST(CHECKSUM(%s) AS BIGINT)) %% 10000", col)
+		case "mysql":
+			expr = fmt.Sprintf("CRC32(%s) %% 10000", col)
+		case "oracle":
+			expr = fmt.Sprintf("ORA_HASH(%s, 9999)", col)
+		case "sqlite3":
+			// SQLite 没有内置哈希函数，按整数键取模
+			expr = fmt.Sprintf("ABS(CAST(%s AS INTEGER)) %% 10000", col)
+		default:
+			return nil, fmt.Errorf("sample_percent 暂不支持源库驱动 %s", driver)
+		}
+		return &sampleClause{predicate: fmt.Sprintf("%s < %d", expr, basis)}, nil
+	default:
+		return nil, fmt.Errorf("sample_mode 仅支持 random/hash，当前为 %q", opts.SampleMode)
+	}
+}
@@ -0,0 +1,140 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+)
+
+// atomic + skip_bad_rows：整表一个事务中，每批写入前设置保存点，批内某一行写入失败时回滚到保存点
+// （Postgres 中失败的语句会使整个事务中止，必须回滚到保存点才能继续），把本批中之前的行逐行重新写入，
+// 跳过失败的这一行，事务继续。各方言的语法：
+// - Postgres、MySQL（InnoDB）、SQLite、CockroachDB：SAVEPOINT / RELEASE SAVEPOINT / ROLLBACK TO SAVEPOINT
+// - SQL Server：SAVE TRANSACTION / ROLLBACK TRANSACTION（没有释放保存点的语句）
+// - Oracle：SAVEPOINT / ROLLBACK TO SAVEPOINT（没有释放保存点的语句）
+// 目标库不支持保存点（ODBC 等）或设置保存点失败时打印警告，退回原来的行为：任何一行失败整表回滚
+
+const (
+	// savepointName 每批的保存点名
+	savepointName = "dbtool_batch"
+	// maxSkippedRowLogs 逐行打印的跳过行数上限，之后只计数
+	maxSkippedRowLogs = 10
+)
+
+// savepointSQL 一种方言的保存点语句；release 为空表示不需要释放
+type savepointSQL struct {
+	set, release, rollback string
+}
+
+// savepointDialect 目标库的保存点语句，不支持时返回 false
+func savepointDialect(dst *simpleDB) (savepointSQL, bool) {
+	switch driver := normalizeDriver(dst.cfg.Driver); {
+	case driver == "sqlserver":
+		return savepointSQL{
+			set:      "SAVE TRANSACTION " + savepointName,
+			rollback: "ROLLBACK TRANSACTION " + savepointName,
+		}, true
+	case driver == "oracle":
+		return savepointSQL{
+			set:      "SAVEPOINT " + savepointName,
+			rollback: "ROLLBACK TO SAVEPOINT " + savepointName,
+		}, true
+	case driver == "mysql", driver == "sqlite3", isPostgresDriver(driver):
+		return savepointSQL{
+			set:      "SAVEPOINT " + savepointName,
+			release:  "RELEASE SAVEPOINT " + savepointName,
+			rollback: "ROLLBACK TO SAVEPOINT " + savepointName,
+		}, true
+	}
+	return savepointSQL{}, false
+}
+
+// batchSavepoints atomic 事务中当前批次的保存点与本批已写入的行；nil 表示不使用保存点
+type batchSavepoints struct {
+	sql     savepointSQL
+	tx      batchTx
+	log     *tableLogger
+	columns []string
+	redact  map[string]bool
+	lobs    *lobWriter
+
+	rows    [][]interface{} // 本批（保存点之后）已写入的行
+	skipped int64           // 跳过的行数
+}
+
+// newBatchSavepoints atomic 且配置了 skip_bad_rows 时返回批次保存点；目标库不支持时打印警告并返回 nil
+func newBatchSavepoints(dst *simpleDB, opts copyTableOptions, columns []string) *batchSavepoints {
+	if !opts.Atomic || !opts.SkipBadRows || opts.DryRun {
+		return nil
+	}
+	sp, ok := savepointDialect(dst)
+	if !ok {
+		opts.Log.warnf("警告：目标库 %s 不支持保存点，skip_bad_rows 不生效，任何一行写入失败时整表回滚\n", dst.cfg.Driver)
+		return nil
+	}
+	opts.Log.infof("skip_bad_rows：每批写入前设置保存点，写入失败的行回滚到保存点后跳过，整表事务继续\n")
+	return &batchSavepoints{sql: sp, log: opts.Log, columns: columns, redact: redactedColumns(opts), lobs: opts.Lobs}
+}
+
+// begin 在 tx 中为下一批设置保存点；失败时打印警告并停用保存点（之后任何一行失败整表回滚）
+func (s *batchSavepoints) begin(ctx context.Context, tx batchTx) *batchSavepoints {
+	if s == nil {
+		return nil
+	}
+	s.tx, s.rows = tx, s.rows[:0]
+	if _, err := tx.ExecContext(ctx, s.sql.set); err != nil {
+		s.log.warnf("警告：设置保存点失败（%v），skip_bad_rows 不再生效，任何一行写入失败时整表回滚\n", err)
+		return nil
+	}
+	return s
+}
+
+// add 记录本批写入的一行（写入前调用）
+func (s *batchSavepoints) add(args []interface{}) {
+	if s != nil {
+		s.rows = append(s.rows, append([]interface{}(nil), args...))
+	}
+}
+
+// next 本批结束：释放保存点（方言需要时）并为下一批设置新的保存点
+func (s *batchSavepoints) next(ctx context.Context) (*batchSavepoints, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if s.sql.release != "" {
+		if _, err := s.tx.ExecContext(ctx, s.sql.release); err != nil {
+			return nil, fmt.Errorf("释放保存点失败: %w", err)
+		}
+	}
+	return s.begin(ctx, s.tx), nil
+}
+
+// skip 本批最后一行（刚写入失败的行，row 为其行号）写入失败：回滚到保存点（保存点保留），逐行重新写入本批之前的行，
+// 跳过失败的这一行。之前的行重新写入失败时返回错误（由调用方回滚整表）
+func (s *batchSavepoints) skip(ctx context.Context, insertSQL string, row int, cause error) error {
+	if _, err := s.tx.ExecContext(ctx, s.sql.rollback); err != nil {
+		return fmt.Errorf("%w（回滚到保存点失败: %v）", cause, err)
+	}
+	bad := s.rows[len(s.rows)-1]
+	s.rows = s.rows[:len(s.rows)-1]
+	for _, args := range s.rows {
+		if err := s.lobs.exec(ctx, s.tx, insertSQL, args); err != nil {
+			return fmt.Errorf("%w（回滚到保存点后重新写入本批之前的行失败: %v）", cause, err)
+		}
+	}
+	s.skipped++
+	if s.skipped <= maxSkippedRowLogs {
+		s.log.warnf("警告：跳过第 %d 行（skip_bad_rows）: %v；%s\n", row, cause, formatRowValues(s.columns, bad, s.redact))
+		if s.skipped == maxSkippedRowLogs {
+			s.log.warnf("警告：已跳过 %d 行，之后跳过的行不再逐行打印\n", maxSkippedRowLogs)
+		}
+	}
+	return nil
+}
+
+// count 跳过的行数（nil 时为 0）
+func (s *batchSavepoints) count() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.skipped
+}
@@ -0,0 +1,217 @@
+package dbtool
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule 解析后的 5 段 cron 表达式（分 时 日 月 周），每段为允许取值的位图
+type cronSchedule struct {
+	expr                     string
+	minute, hour, dom, month uint64
+	dow                      uint64
+	domAny, dowAny           bool // 日、周为 * 时为 true（两者都有限制时按标准 cron 取“或”）
+}
+
+// cronMacros 常用的 cron 简写
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+
+var cronDayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// parseCron 解析标准 5 段 cron 表达式：支持 *、列表（1,3）、范围（1-5）、步长（*/15、0-30/10）、
+// 月份与星期的英文缩写（JAN、MON），星期中 0 与 7 均表示周日；另支持 @daily、@hourly 等简写
+func parseCron(expr string) (*cronSchedule, error) {
+	text := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(text)]; ok {
+		text = macro
+	}
+	fields := strings.Fields(text)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式 %q 应为 5 段（分 时 日 月 周）", expr)
+	}
+	c := &cronSchedule{expr: strings.TrimSpace(expr)}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的分钟段无效: %w", expr, err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的小时段无效: %w", expr, err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的日期段无效: %w", expr, err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的月份段无效: %w", expr, err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的星期段无效: %w", expr, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		// 7 与 0 同为周日
+		c.dow |= 1
+	}
+	c.domAny, c.dowAny = fields[2] == "*", fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField 解析 cron 的一段，返回 [min, max] 内允许取值的位图
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	value := func(s string) (int, error) {
+		if n, ok := names[strings.ToLower(s)]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("无法识别 %q", s)
+		}
+		if n < min || n > max {
+			return 0, fmt.Errorf("%d 超出范围 %d-%d", n, min, max)
+		}
+		return n, nil
+	}
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("步长 %q 无效", part[i+1:])
+			}
+			rangePart, step = part[:i], n
+		}
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("范围 %q 起点大于终点", rangePart)
+			}
+		default:
+			n, err := value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			lo = n
+			if step == 1 {
+				hi = n
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// matchDay 判断某天是否满足日期与星期段：两段都有限制时满足任一即可（标准 cron 语义）
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowOK
+	case c.dowAny:
+		return domOK
+	default:
+		return domOK || dowOK
+	}
+}
+
+// next 返回 t 之后（不含 t 所在的分钟）第一个满足表达式的时间；5 年内没有匹配时返回零值
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// runSchedule schedule 模式：常驻运行，在满足 cron 表达式的时间执行一轮同步。
+// 触发时上一轮仍在运行则跳过本次触发并打印警告；收到 SIGINT/SIGTERM 后等待进行中的一轮完成当前表再退出
+func (r *syncRunner) runSchedule(sched *cronSchedule) {
+	stop, cancel := notifyStop()
+	defer cancel()
+
+	counter := &passCounter{}
+	done := make(chan struct{}, 1)
+	running := false
+	for {
+		next := sched.next(time.Now())
+		if next.IsZero() {
+			r.log.infof("schedule %q 在未来 5 年内没有匹配的时间，退出\n", sched.expr)
+			return
+		}
+		r.log.infof("schedule %q：下次运行时间 %s\n", sched.expr, next.Format("2006-01-02 15:04:05"))
+		if r.status != nil {
+			r.status.setNextRun(next)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+	wait:
+		for {
+			select {
+			case <-done:
+				running = false
+				r.log.infof("schedule %q：下次运行时间 %s\n", sched.expr, next.Format("2006-01-02 15:04:05"))
+			case <-stop.Done():
+				timer.Stop()
+				if running {
+					<-done
+				}
+				r.log.infof("schedule 已退出\n")
+				return
+			case <-timer.C:
+				break wait
+			}
+		}
+		if running {
+			r.log.warnf("警告：上一轮同步仍在进行，跳过 %s 的触发\n", next.Format("2006-01-02 15:04:05"))
+			continue
+		}
+		running = true
+		go func() {
+			r.runCounted(stop, counter)
+			done <- struct{}{}
+		}()
+	}
+}
@@ -0,0 +1,268 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// before_sync / after_sync（配置级）：每轮同步开始复制第一张表之前、所有表结束之后依次执行的脚本，
+// 如建 schema、授权、ANALYZE。每个脚本指定执行的数据源（source、target 或 sources 中的名称），
+// 语句来自内联的 sql 列表（每一项原样作为一条语句执行，PL/SQL 块等写成一项）或 .sql 文件
+// （按分号拆分语句，字符串、带引号的标识符、注释与 Postgres 的 $tag$ 引用中的分号不拆分）。
+// 语句逐条自动提交，某条失败时该脚本及之后的脚本不再执行：before_sync 失败时本轮不复制任何表；
+// after_sync 默认只在所有表成功时执行，配置 always 后有表失败（或中断）时也执行。Dry-Run 只打印语句
+
+// 脚本阶段
+const (
+	scriptBeforeSync = "before_sync"
+	scriptAfterSync  = "after_sync"
+)
+
+// syncScript before_sync / after_sync 中的一个脚本
+type syncScript struct {
+	Name   string   `json:"name,omitempty"`   // 日志与报告中的名称，默认为文件名或“阶段[序号]”
+	Source string   `json:"source"`           // 执行的数据源：source / target，或 sources 中的名称
+	SQL    []string `json:"sql,omitempty"`    // 内联语句，每一项为一条语句
+	File   string   `json:"file,omitempty"`   // .sql 文件路径（与 sql 二选一）
+	Always bool     `json:"always,omitempty"` // after_sync：有表失败或同步中断时也执行
+}
+
+// preparedScript 启动时解析好的脚本
+type preparedScript struct {
+	phase      string
+	name       string
+	source     string
+	statements []string
+	always     bool
+}
+
+// scriptResult 一个脚本的执行结果（汇总报告）
+type scriptResult struct {
+	Phase      string  `json:"phase"`
+	Name       string  `json:"name"`
+	Source     string  `json:"source"`
+	Statements int     `json:"statements"`
+	Executed   int     `json:"executed"`          // 成功执行的语句数
+	Seconds    float64 `json:"seconds"`           // 执行耗时
+	Skipped    string  `json:"skipped,omitempty"` // 未执行的原因
+	DryRun     bool    `json:"dry_run,omitempty"` // Dry-Run：只打印语句
+	Error      string  `json:"error,omitempty"`
+}
+
+// prepareScripts 检查脚本配置、读取并拆分 .sql 文件；数据源名称在执行前才解析为连接
+func prepareScripts(phase string, scripts []syncScript, cfg *toolConfig) ([]preparedScript, error) {
+	var out []preparedScript
+	for i, s := range scripts {
+		label := fmt.Sprintf("%s[%d]", phase, i+1)
+		p := preparedScript{phase: phase, name: strings.TrimSpace(s.Name), source: strings.TrimSpace(s.Source), always: s.Always}
+		if p.source == "" {
+			return nil, fmt.Errorf("%s 未指定 source（source、target 或 sources 中的名称）", label)
+		}
+		if !knownScriptSource(p.source, cfg) {
+			return nil, fmt.Errorf("%s 的 source %q 不是 source、target，也不在 sources 中", label, p.source)
+		}
+		if s.Always && phase == scriptBeforeSync {
+			return nil, fmt.Errorf("%s：always 只用于 after_sync", label)
+		}
+		file := strings.TrimSpace(s.File)
+		switch {
+		case file != "" && len(s.SQL) > 0:
+			return nil, fmt.Errorf("%s 不能同时配置 sql 与 file", label)
+		case file != "":
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s 读取 SQL 文件失败: %w", label, err)
+			}
+			p.statements = splitSQLStatements(string(data))
+			if p.name == "" {
+				p.name = filepath.Base(file)
+			}
+		default:
+			for _, stmt := range s.SQL {
+				if stmt = strings.TrimSpace(stmt); stmt != "" {
+					p.statements = append(p.statements, stmt)
+				}
+			}
+		}
+		if len(p.statements) == 0 {
+			return nil, fmt.Errorf("%s 没有可执行的语句（请配置 sql 或 file）", label)
+		}
+		if p.name == "" {
+			p.name = label
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// knownScriptSource 脚本的数据源名称是否有效
+func knownScriptSource(name string, cfg *toolConfig) bool {
+	if name == "source" || name == "target" {
+		return true
+	}
+	_, ok := cfg.Sources[name]
+	return ok
+}
+
+// scriptDB 脚本数据源的连接：source / target（或 sync 中对应的名称）使用同步的连接，
+// 其它 sources 中的数据源临时连接，返回的 release 关闭临时连接
+func (r *syncRunner) scriptDB(name string) (db *simpleDB, release func(), err error) {
+	src, dst := r.conns()
+	switch {
+	case name == "source", r.cfg.Sync != nil && name == strings.TrimSpace(r.cfg.Sync.Source):
+		return src, func() {}, nil
+	case name == "target", r.cfg.Sync != nil && name == strings.TrimSpace(r.cfg.Sync.Target):
+		return dst, func() {}, nil
+	}
+	cfg := r.cfg.Sources[name]
+	cfg.role, cfg.log = name, r.log
+	r.log.infof("连接数据源 %s: %s\n", name, cfg.Driver)
+	if db, err = newSimpleDB(cfg); err != nil {
+		return nil, nil, fmt.Errorf("数据源 %s 连接失败: %w", name, err)
+	}
+	return db, func() { _ = db.Close() }, nil
+}
+
+// runScripts 依次执行一个阶段的脚本，返回各脚本的结果；passFailed 为 true 时 after_sync 只执行 always 的脚本。
+// 某个脚本失败时之后的脚本不再执行，返回该错误
+func (r *syncRunner) runScripts(ctx context.Context, scripts []preparedScript, passFailed bool) ([]scriptResult, error) {
+	var results []scriptResult
+	var firstErr error
+	for _, s := range scripts {
+		res := scriptResult{Phase: s.phase, Name: s.name, Source: s.source, Statements: len(s.statements), DryRun: r.run.DryRun}
+		switch {
+		case firstErr != nil:
+			res.Skipped = "前面的脚本失败，未执行"
+		case passFailed && !s.always:
+			res.Skipped = "有表失败或同步中断，未执行（未配置 always）"
+		default:
+			if err := r.runScript(ctx, s, &res); err != nil {
+				res.Error = err.Error()
+				firstErr = fmt.Errorf("%s 脚本 %s 执行失败: %w", s.phase, s.name, err)
+			}
+		}
+		if res.Skipped != "" {
+			r.log.infof("%s 脚本 %s: %s\n", s.phase, s.name, res.Skipped)
+		}
+		results = append(results, res)
+	}
+	return results, firstErr
+}
+
+// runScript 在脚本的数据源上逐条执行语句（Dry-Run 时只打印）
+func (r *syncRunner) runScript(ctx context.Context, s preparedScript, res *scriptResult) error {
+	start := time.Now()
+	defer func() { res.Seconds = time.Since(start).Seconds() }()
+	if r.run.DryRun {
+		r.log.infof("[DRY-RUN] %s 脚本 %s（数据源 %s，%d 条语句），实际运行时将执行：\n", s.phase, s.name, s.source, len(s.statements))
+		for i, stmt := range s.statements {
+			r.log.infof("[DRY-RUN]   %d: %s\n", i+1, stmt)
+		}
+		return nil
+	}
+	db, release, err := r.scriptDB(s.source)
+	if err != nil {
+		return err
+	}
+	defer release()
+	r.log.infof("执行 %s 脚本 %s（数据源 %s，%d 条语句）\n", s.phase, s.name, s.source, len(s.statements))
+	for i, stmt := range s.statements {
+		r.log.debugf("%s 脚本 %s 第 %d 条: %s\n", s.phase, s.name, i+1, stmt)
+		if _, err := db.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("第 %d 条语句失败: %w（%s）", i+1, err, truncateRunes(strings.Join(strings.Fields(stmt), " "), 200))
+		}
+		res.Executed++
+	}
+	r.log.infof("%s 脚本 %s 执行完成，耗时 %s\n", s.phase, s.name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// splitSQLStatements 按分号把 SQL 文本拆分为语句：单引号字符串（连续两个单引号为转义）、双引号与反引号标识符、
+// -- 与 /* */ 注释、Postgres 的 $tag$...$tag$ 引用中的分号不拆分；只有注释或空白的部分丢弃
+func splitSQLStatements(text string) []string {
+	var out []string
+	start, hasCode := 0, false
+	flush := func(end int) {
+		if hasCode {
+			out = append(out, strings.TrimSpace(text[start:end]))
+		}
+		start, hasCode = end+1, false
+	}
+	n := len(text)
+	for i := 0; i < n; {
+		c := text[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < n {
+				if text[j] == c {
+					if j+1 < n && text[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			hasCode = true
+			i = j + 1
+		case c == '-' && i+1 < n && text[i+1] == '-':
+			j := strings.IndexByte(text[i:], '\n')
+			if j < 0 {
+				j = n - i
+			}
+			i += j
+		case c == '/' && i+1 < n && text[i+1] == '*':
+			j := strings.Index(text[i+2:], "*/")
+			if j < 0 {
+				i = n
+			} else {
+				i += 2 + j + 2
+			}
+		case c == '$' && (i == 0 || !isIdentPart(text[i-1])):
+			hasCode = true
+			tag, ok := dollarQuoteTag(text[i:])
+			if !ok {
+				i++
+				continue
+			}
+			j := strings.Index(text[i+len(tag):], tag)
+			if j < 0 {
+				i = n
+			} else {
+				i += len(tag) + j + len(tag)
+			}
+		case c == ';':
+			flush(i)
+			i++
+		default:
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				hasCode = true
+			}
+			i++
+		}
+	}
+	if start < n {
+		flush(n)
+	}
+	return out
+}
+
+// dollarQuoteTag s 以 Postgres 的美元引用开头（$$ 或 $tag$）时返回该标记
+func dollarQuoteTag(s string) (string, bool) {
+	j := 1
+	for j < len(s) && s[j] != '$' {
+		if !isIdentPart(s[j]) || (j == 1 && !isIdentStart(s[j])) {
+			return "", false
+		}
+		j++
+	}
+	if j >= len(s) {
+		return "", false
+	}
+	return s[:j+1], true
+}
@@ -0,0 +1,184 @@
+package dbtool
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// selectArg select_sql 中命名占位符（:name）对应的参数
+type selectArg struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// placeholder 返回方言的第 n 个（从 1 开始）绑定参数占位符
+func placeholder(n int, driver string) string {
+	switch normalizeDriver(driver) {
+	case "postgres", "postgresql":
+		return fmt.Sprintf("$%d", n)
+	case "sqlserver":
+		return fmt.Sprintf("@p%d", n)
+	case "oracle":
+		return fmt.Sprintf(":%d", n)
+	default:
+		return "?"
+	}
+}
+
+// bindSelectSQL 把 select_sql 中的命名占位符替换为方言的绑定参数，返回最终 SQL 与参数：
+// - :since / :until 取表配置的 since/until，其余名称取 select_args
+// - :where 按原文替换为 (where 条件)，未配置 where 时为 1=1（它是 SQL 片段，无法绑定）
+// 字符串字面量、带引号的标识符、注释以及 Postgres 的 :: 类型转换中的冒号不视为占位符。
+// 引用了未提供的参数，或 select_args 中有未被引用的参数，均视为配置错误
+func bindSelectSQL(query string, opts copyTableOptions, driver string) (string, []interface{}, error) {
+	values := make(map[string]interface{})
+	for _, a := range opts.SelectArgs {
+		name := strings.ToLower(strings.TrimSpace(a.Name))
+		if name == "" {
+			return "", nil, fmt.Errorf("select_args 中存在未命名的参数")
+		}
+		if name == "since" || name == "until" || name == "where" {
+			return "", nil, fmt.Errorf("select_args 不能使用保留名称 %q（请使用表配置的 %s）", a.Name, name)
+		}
+		if _, dup := values[name]; dup {
+			return "", nil, fmt.Errorf("select_args 中参数 %q 重复", a.Name)
+		}
+		values[name] = normalizeArgValue(a.Value)
+	}
+	// 与表的复制窗口按同样的方式绑定，同一份配置在 select_sql 与表上得到相同的窗口
+	if strings.TrimSpace(opts.Since) != "" {
+		values["since"] = sourceBound(sinceArg(opts), driver)
+	}
+	if strings.TrimSpace(opts.Until) != "" {
+		values["until"] = sourceBound(untilArg(opts), driver)
+	}
+
+	var args []interface{}
+	used := make(map[string]bool)
+	var missing []string
+	query = replaceNamedParams(query, driver, func(name, raw string) string {
+		used[name] = true
+		if name == "where" {
+			if strings.TrimSpace(opts.Where) != "" {
+				return "(" + opts.Where + ")"
+			}
+			return "1=1"
+		}
+		if v, ok := values[name]; ok {
+			args = append(args, v)
+			return placeholder(len(args), driver)
+		}
+		missing = append(missing, ":"+name)
+		return raw
+	})
+
+	if len(missing) > 0 {
+		return "", nil, fmt.Errorf("select_sql 引用了未提供的参数 %s（:since/:until 需配置 since/until，其他参数需配置在 select_args 中）", strings.Join(missing, ", "))
+	}
+	var unused []string
+	for _, a := range opts.SelectArgs {
+		if !used[strings.ToLower(strings.TrimSpace(a.Name))] {
+			unused = append(unused, a.Name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return "", nil, fmt.Errorf("select_args 中的参数 %s 未在 select_sql 中引用", strings.Join(unused, ", "))
+	}
+	for _, name := range []string{"since", "until"} {
+		if _, ok := values[name]; ok && !used[name] {
+			opts.Log.warnf("警告：配置了 %s 但 select_sql 中没有引用 :%s，该条件不会生效\n", name, name)
+		}
+	}
+	return query, args, nil
+}
+
+// replaceNamedParams 把 query 中的命名占位符（:name）替换为 repl 的返回值（name 为小写名称，raw 为原文）；
+// 字符串字面量、带引号的标识符、注释以及 Postgres 的 :: 类型转换中的冒号原样保留
+func replaceNamedParams(query, driver string, repl func(name, raw string) string) string {
+	var b strings.Builder
+	bracketQuote := normalizeDriver(driver) == "sqlserver"
+	n := len(query)
+	for i := 0; i < n; {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`' || (c == '[' && bracketQuote):
+			// 字符串字面量 / 带引号的标识符，原样复制到闭合引号
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			j := i + 1
+			for j < n {
+				if query[j] == closing {
+					if closing == '\'' && j+1 < n && query[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j < n {
+				j++
+			}
+			b.WriteString(query[i:j])
+			i = j
+		case c == '-' && i+1 < n && query[i+1] == '-':
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				j = n - i
+			}
+			b.WriteString(query[i : i+j])
+			i += j
+		case c == '/' && i+1 < n && query[i+1] == '*':
+			j := strings.Index(query[i+2:], "*/")
+			end := n
+			if j >= 0 {
+				end = i + 2 + j + 2
+			}
+			b.WriteString(query[i:end])
+			i = end
+		case c == ':' && i+1 < n && query[i+1] == ':':
+			b.WriteString("::")
+			i += 2
+		case c == ':' && i+1 < n && isIdentStart(query[i+1]):
+			j := i + 1
+			for j < n && isIdentPart(query[j]) {
+				j++
+			}
+			b.WriteString(repl(strings.ToLower(query[i+1:j]), query[i:j]))
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// normalizeArgValue JSON 数字解析为 float64，整数值转为 int64 再绑定
+func normalizeArgValue(v interface{}) interface{} {
+	if f, ok := v.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return v
+}
+
+// logBoundSQL 打印绑定后的 SQL 与各位置的参数值（Dry-Run 使用）
+func logBoundSQL(query string, args []interface{}, driver string, logger *tableLogger) {
+	logger.infof("最终 SQL: %s\n", query)
+	for i, a := range args {
+		logger.infof("  参数 %d (%s) = %v\n", i+1, placeholder(i+1, driver), a)
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,310 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// syncSequences 复制完成后把目标表的序列/自增值推进到 MAX(列)+1，避免应用后续插入主键冲突
+// - postgres:  表列拥有的序列（serial/identity），SELECT setval(seq, COALESCE(MAX(col),0)+1, false)
+// - mysql:     AUTO_INCREMENT 列，ALTER TABLE ... AUTO_INCREMENT = MAX+1
+// - sqlserver: IDENTITY 列，DBCC CHECKIDENT (..., RESEED, MAX)
+// - oracle:    sequence_name 指定的序列或标识列序列，见 syncSequencesOracle
+// Dry-Run 模式只打印语句，不执行。返回汇总报告中列出的序列调整（目前只有 oracle 给出原值与新值）
+func syncSequences(ctx context.Context, dst *simpleDB, table string, opts copyTableOptions) ([]string, error) {
+	switch normalizeDriver(dst.cfg.Driver) {
+	case "postgres", "postgresql":
+		return nil, syncSequencesPostgres(ctx, dst, table, opts)
+	case "mysql":
+		return nil, syncAutoIncrementMySQL(ctx, dst, table, opts)
+	case "sqlserver":
+		return nil, syncIdentityMSSQL(ctx, dst, table, opts)
+	case "oracle":
+		return syncSequencesOracle(ctx, dst, table, opts)
+	case "sqlite3":
+		opts.Log.infof("SQLite 自增值自动跟随 MAX(rowid)，无需同步序列\n")
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("暂不支持为驱动 %s 同步序列", dst.cfg.Driver)
+	}
+}
+
+func syncSequencesPostgres(ctx context.Context, dst *simpleDB, table string, opts copyTableOptions) error {
+	rows, err := dst.db.QueryContext(ctx, `SELECT column_name, pg_get_serial_sequence(quote_ident(table_schema) || '.' || quote_ident(table_name), column_name)
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = $1
+ORDER BY ordinal_position`, table)
+	if err != nil {
+		return fmt.Errorf("查询表 %s 的序列失败: %w", table, err)
+	}
+	type owned struct{ column, sequence string }
+	var seqs []owned
+	for rows.Next() {
+		var col string
+		var seq sql.NullString
+		if err := rows.Scan(&col, &seq); err != nil {
+			rows.Close()
+			return fmt.Errorf("读取表 %s 的序列失败: %w", table, err)
+		}
+		if seq.Valid && seq.String != "" {
+			seqs = append(seqs, owned{col, seq.String})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(seqs) == 0 {
+		opts.Log.infof("表 %s 没有序列需要同步\n", table)
+		return nil
+	}
+
+	for _, s := range seqs {
+		stmt := fmt.Sprintf("SELECT setval('%s', COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+			strings.ReplaceAll(s.sequence, "'", "''"), quoteIdent(s.column, dst.cfg.Driver), quoteIdent(table, dst.cfg.Driver))
+		if opts.DryRun {
+			opts.Log.infof("Dry-Run 模式，将执行: %s\n", stmt)
+			continue
+		}
+		var next int64
+		if err := dst.db.QueryRowContext(ctx, stmt).Scan(&next); err != nil {
+			return fmt.Errorf("同步序列 %s 失败: %w", s.sequence, err)
+		}
+		opts.Log.infof("序列 %s（列 %s）已同步，下一个值: %d\n", s.sequence, s.column, next)
+	}
+	return nil
+}
+
+func syncAutoIncrementMySQL(ctx context.Context, dst *simpleDB, table string, opts copyTableOptions) error {
+	var col string
+	err := dst.db.QueryRowContext(ctx, `SELECT column_name FROM information_schema.columns
+WHERE table_schema = DATABASE() AND table_name = ? AND extra LIKE '%auto_increment%'`, table).Scan(&col)
+	if err == sql.ErrNoRows {
+		opts.Log.infof("表 %s 没有 AUTO_INCREMENT 列需要同步\n", table)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询表 %s 的 AUTO_INCREMENT 列失败: %w", table, err)
+	}
+
+	maxVal, err := maxColumnValue(ctx, dst, table, col)
+	if err != nil {
+		return err
+	}
+	stmt := fmt.Sprintf("ALTER TABLE %s AUTO_INCREMENT = %d", quoteIdent(table, dst.cfg.Driver), maxVal+1)
+	if opts.DryRun {
+		opts.Log.infof("Dry-Run 模式，将执行: %s\n", stmt)
+		return nil
+	}
+	if _, err := dst.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("同步 AUTO_INCREMENT 失败: %w", err)
+	}
+	opts.Log.infof("表 %s 的 AUTO_INCREMENT（列 %s）已同步，下一个值: %d\n", table, col, maxVal+1)
+	return nil
+}
+
+func syncIdentityMSSQL(ctx context.Context, dst *simpleDB, table string, opts copyTableOptions) error {
+	var col string
+	err := dst.db.QueryRowContext(ctx, `SELECT name FROM sys.identity_columns WHERE object_id = OBJECT_ID(@p1)`, table).Scan(&col)
+	if err == sql.ErrNoRows {
+		opts.Log.infof("表 %s 没有 IDENTITY 列需要同步\n", table)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询表 %s 的 IDENTITY 列失败: %w", table, err)
+	}
+
+	maxVal, err := maxColumnValue(ctx, dst, table, col)
+	if err != nil {
+		return err
+	}
+	if maxVal == 0 {
+		opts.Log.infof("表 %s 为空，跳过 IDENTITY 同步\n", table)
+		return nil
+	}
+	stmt := fmt.Sprintf("DBCC CHECKIDENT ('%s', RESEED, %d)", strings.ReplaceAll(table, "'", "''"), maxVal)
+	if opts.DryRun {
+		opts.Log.infof("Dry-Run 模式，将执行: %s\n", stmt)
+		return nil
+	}
+	if _, err := dst.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("同步 IDENTITY 失败: %w", err)
+	}
+	opts.Log.infof("表 %s 的 IDENTITY（列 %s）已同步，下一个值: %d\n", table, col, maxVal+1)
+	return nil
+}
+
+// maxColumnValue 查询整数列的最大值（表为空时返回 0）
+func maxColumnValue(ctx context.Context, db *simpleDB, table, col string) (int64, error) {
+	var maxVal sql.NullInt64
+	query := fmt.Sprintf("SELECT MAX(%s) FROM %s", quoteIdent(col, db.cfg.Driver), quoteIdent(table, db.cfg.Driver))
+	if err := db.db.QueryRowContext(ctx, query).Scan(&maxVal); err != nil {
+		return 0, fmt.Errorf("查询 %s.%s 最大值失败: %w", table, col, err)
+	}
+	return maxVal.Int64, nil
+}
+
+// oracleSequence 待同步的 Oracle 序列
+type oracleSequence struct {
+	owner      string // 序列所属用户，为空表示当前 schema
+	name       string
+	column     string
+	identity   bool   // 标识列（12c+）的系统序列，只能通过 ALTER TABLE ... MODIFY 调整
+	generation string // 标识列的 GENERATION_TYPE：ALWAYS / BY DEFAULT
+}
+
+// oracleCurrentSchema 当前 schema（ALTER SESSION SET CURRENT_SCHEMA 之后不等于登录用户，user_* 视图查不到）
+const oracleCurrentSchema = "SYS_CONTEXT('USERENV', 'CURRENT_SCHEMA')"
+
+// syncSequencesOracle 把 Oracle 表的序列推进到 MAX(列)+1，返回汇总报告中列出的调整（原值 → 新值）。
+// 序列来源：配置的 sequence_name（可写作 owner.name，列取 sequence_column 或单列主键），否则自动识别标识列的系统序列。
+// 表与序列按当前 schema 在 all_* 视图中查找
+func syncSequencesOracle(ctx context.Context, dst *simpleDB, table string, opts copyTableOptions) ([]string, error) {
+	var seqs []oracleSequence
+	if name := strings.TrimSpace(opts.SequenceName); name != "" {
+		col := strings.TrimSpace(opts.SequenceColumn)
+		if col == "" {
+			keys, err := fetchPrimaryKey(ctx, dst, table)
+			if err != nil {
+				return nil, err
+			}
+			if len(keys) != 1 {
+				return nil, fmt.Errorf("表 %s 没有单列主键，请通过 sequence_column 指定序列对应的列", table)
+			}
+			col = keys[0]
+		}
+		seq := oracleSequence{name: strings.ToUpper(name), column: col}
+		if i := strings.LastIndex(seq.name, "."); i > 0 {
+			seq.owner, seq.name = seq.name[:i], seq.name[i+1:]
+		}
+		seqs = append(seqs, seq)
+	} else {
+		rows, err := dst.db.QueryContext(ctx, `SELECT owner, column_name, sequence_name, generation_type FROM all_tab_identity_cols
+WHERE owner = `+oracleCurrentSchema+` AND table_name = :1`, strings.ToUpper(table))
+		if err != nil {
+			return nil, fmt.Errorf("查询表 %s 的标识列失败: %w", table, err)
+		}
+		for rows.Next() {
+			var s oracleSequence
+			if err := rows.Scan(&s.owner, &s.column, &s.name, &s.generation); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("读取表 %s 的标识列失败: %w", table, err)
+			}
+			s.identity = true
+			seqs = append(seqs, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(seqs) == 0 {
+		opts.Log.infof("表 %s 没有序列需要同步（可通过 sequence_name 指定）\n", table)
+		return nil, nil
+	}
+
+	var changes []string
+	for _, s := range seqs {
+		maxVal, err := maxColumnValue(ctx, dst, table, s.column)
+		if err != nil {
+			return nil, err
+		}
+		target := maxVal + 1
+		qualified := s.name
+		if s.owner != "" {
+			qualified = s.owner + "." + s.name
+		}
+
+		var lastNumber, increment, cacheSize int64
+		if err := dst.db.QueryRowContext(ctx, `SELECT last_number, increment_by, cache_size FROM all_sequences
+WHERE sequence_owner = NVL(:1, `+oracleCurrentSchema+`) AND sequence_name = :2`, s.owner, s.name).Scan(&lastNumber, &increment, &cacheSize); err != nil {
+			return nil, fmt.Errorf("查询序列 %s 失败: %w", qualified, err)
+		}
+		// last_number 是写入磁盘的值：NOCACHE 时即下一个值；CACHE n 时是缓存的上限，
+		// 实际下一个值可能小到 last_number - n*increment_by
+		old := fmt.Sprint(lastNumber)
+		if cacheSize > 0 {
+			if low := lastNumber - cacheSize*increment; low >= target {
+				opts.Log.infof("序列 %s 缓存中最小的值 %d 已不小于 MAX(%s)+1=%d，无需调整\n", qualified, low, s.column, target)
+				continue
+			}
+			old = fmt.Sprintf("不超过 %d（CACHE %d）", lastNumber, cacheSize)
+			if !s.identity && !opts.DryRun {
+				// 缓存时只能取一次 NEXTVAL 得到实际的当前值（消耗一个序列值）
+				var current int64
+				if err := dst.db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s.NEXTVAL FROM dual", qualified)).Scan(&current); err != nil {
+					return nil, fmt.Errorf("读取序列 %s 失败: %w", qualified, err)
+				}
+				lastNumber, old = current+increment, fmt.Sprint(current+increment)
+				if lastNumber >= target {
+					opts.Log.infof("序列 %s 下一个值 %d 已不小于 MAX(%s)+1=%d，无需调整\n", qualified, lastNumber, s.column, target)
+					continue
+				}
+			}
+		} else if lastNumber >= target {
+			opts.Log.infof("序列 %s 当前值 %d 已不小于 MAX(%s)+1=%d，无需调整\n", qualified, lastNumber, s.column, target)
+			continue
+		}
+
+		if s.identity {
+			// 标识列的系统序列不能直接 ALTER SEQUENCE，START WITH LIMIT VALUE 会从列的当前最大值继续
+			stmt := fmt.Sprintf("ALTER TABLE %s MODIFY (%s GENERATED %s AS IDENTITY (START WITH LIMIT VALUE))",
+				quoteIdent(table, dst.cfg.Driver), quoteIdent(s.column, dst.cfg.Driver), s.generation)
+			if opts.DryRun {
+				opts.Log.infof("Dry-Run 模式，将执行: %s\n", stmt)
+				continue
+			}
+			if _, err := dst.db.ExecContext(ctx, stmt); err != nil {
+				return nil, fmt.Errorf("调整标识列 %s 的序列失败: %w", s.column, err)
+			}
+		} else if err := advanceOracleSequence(ctx, dst, qualified, target, increment, opts); err != nil {
+			return nil, err
+		}
+		if !opts.DryRun {
+			opts.Log.infof("序列 %s（列 %s）已调整: 原值 %s -> 新值 %d\n", qualified, s.column, old, target)
+			changes = append(changes, fmt.Sprintf("%s（列 %s）: %s → %d", qualified, s.column, old, target))
+		}
+	}
+	return changes, nil
+}
+
+// advanceOracleSequence 使序列的下一个值为 target：
+// 优先使用 ALTER SEQUENCE ... RESTART START WITH（新版本），不支持时退回到临时修改 INCREMENT BY 的方式
+func advanceOracleSequence(ctx context.Context, dst *simpleDB, seq string, target, increment int64, opts copyTableOptions) error {
+	restart := fmt.Sprintf("ALTER SEQUENCE %s RESTART START WITH %d", seq, target)
+	if opts.DryRun {
+		opts.Log.infof("Dry-Run 模式，将执行: %s（不支持时改用 INCREMENT BY 方式）\n", restart)
+		return nil
+	}
+	_, err := dst.db.ExecContext(ctx, restart)
+	if err == nil {
+		return nil
+	}
+	opts.Log.infof("ALTER SEQUENCE ... RESTART 不可用（%v），改用 INCREMENT BY 方式\n", err)
+
+	if increment <= 0 {
+		return fmt.Errorf("序列 %s 的步长为 %d，无法自动推进", seq, increment)
+	}
+	var current int64
+	if err := dst.db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s.NEXTVAL FROM dual", seq)).Scan(&current); err != nil {
+		return fmt.Errorf("读取序列 %s 失败: %w", seq, err)
+	}
+	// 调整后下一次 NEXTVAL = current + gap + increment = target
+	gap := target - current - increment
+	if gap <= 0 {
+		return nil
+	}
+	if _, err := dst.db.ExecContext(ctx, fmt.Sprintf("ALTER SEQUENCE %s INCREMENT BY %d", seq, gap)); err != nil {
+		return fmt.Errorf("调整序列 %s 步长失败: %w", seq, err)
+	}
+	_, errNext := dst.db.ExecContext(ctx, fmt.Sprintf("SELECT %s.NEXTVAL FROM dual", seq))
+	if _, err := dst.db.ExecContext(ctx, fmt.Sprintf("ALTER SEQUENCE %s INCREMENT BY %d", seq, increment)); err != nil {
+		return fmt.Errorf("恢复序列 %s 步长失败: %w", seq, err)
+	}
+	if errNext != nil {
+		return fmt.Errorf("推进序列 %s 失败: %w", seq, errNext)
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package dbtool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -serve 关闭时对进行中运行的处理方式
+const (
+	shutdownFinish = "finish" // 等待进行中的运行全部完成
+	shutdownCancel = "cancel" // 取消进行中的运行（当前表中止，已提交的批次保留）
+)
+
+// healthBusy /healthz 中连接池已被进行中的运行占满、未 ping 的数据源
+const healthBusy = "busy"
+
+// maxKeptRuns HTTP API 在内存中保留的运行记录数，超出时丢弃最早结束的运行
+const maxKeptRuns = 100
+
+// 运行与表的状态
+const (
+	runRunning   = "running"
+	runSucceeded = "succeeded"
+	runFailed    = "failed"
+	runCancelled = "cancelled"
+
+	tableRunning = "running"
+	tableDone    = "done"
+	tableFailed  = "failed"
+)
+
+// serveOptions -serve 模式的参数
+type serveOptions struct {
+	Addr            string
+	AllowConcurrent bool   // 允许同时进行多个运行
+	Shutdown        string // 收到 SIGINT/SIGTERM 时：finish（默认）/ cancel
+}
+
+// apiServer HTTP API：复用已加载的配置与连接，按请求触发同步并记录每次运行的进度
+type apiServer struct {
+	runner *syncRunner
+	opts   serveOptions
+
+	// ctx 取消时中止进行中的运行（shutdown=cancel）
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	seq    int
+	active int
+	runs   map[string]*apiRun
+}
+
+// apiRun 一次通过 API 触发的运行，同时作为该轮的 passObserver 记录表级进度
+type apiRun struct {
+	mu         sync.Mutex
+	ID         string              `json:"id"`
+	Status     string              `json:"status"`
+	Tables     []string            `json:"tables,omitempty"` // 请求中的表过滤
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+	Progress   []*apiTableProgress `json:"progress"`
+	Report     *summaryReport      `json:"report,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// apiTableProgress 单张表（或分区单元）的进度
+type apiTableProgress struct {
+	Table      string                   `json:"table"`
+	Status     string                   `json:"status"`
+	RowsCopied int64                    `json:"rows_copied"`
+	StartedAt  time.Time                `json:"started_at"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty"`
+	Result     *tableVerificationResult `json:"result,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+
+	progress *tableProgress
+}
+
+// tableStarted 实现 passObserver
+func (a *apiRun) tableStarted(table string, progress *tableProgress) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Progress = append(a.Progress, &apiTableProgress{Table: table, Status: tableRunning, StartedAt: time.Now(), progress: progress})
+}
+
+// tableFinished 实现 passObserver；没有开始记录的表（分区父表汇总）直接追加
+func (a *apiRun) tableFinished(table string, result *tableVerificationResult, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	var p *apiTableProgress
+	for i := len(a.Progress) - 1; i >= 0; i-- {
+		if a.Progress[i].Table == table && a.Progress[i].FinishedAt == nil {
+			p = a.Progress[i]
+			break
+		}
+	}
+	if p == nil {
+		p = &apiTableProgress{Table: table, StartedAt: now}
+		a.Progress = append(a.Progress, p)
+	}
+	p.FinishedAt, p.Status = &now, tableDone
+	if result != nil {
+		res := *result
+		p.Result = &res
+	}
+	if err != nil {
+		p.Status, p.Error = tableFailed, errText(err)
+	}
+}
+
+// finish 记录运行结束的状态与汇总报告
+func (a *apiRun) finish(summary *verificationSummary, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	a.FinishedAt = &now
+	if summary != nil {
+		report := summary.report()
+		a.Report = &report
+	}
+	switch {
+	case err == nil:
+		a.Status = runSucceeded
+	case errors.Is(err, errSyncStopped) || errors.Is(err, context.Canceled):
+		a.Status, a.Error = runCancelled, errText(err)
+	default:
+		a.Status, a.Error = runFailed, errText(err)
+	}
+}
+
+// snapshot 返回运行记录的 JSON
+func (a *apiRun) snapshot() ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, p := range a.Progress {
+		if p.progress != nil {
+			p.RowsCopied = p.progress.rows.Load()
+		}
+	}
+	return json.MarshalIndent(a, "", "  ")
+}
+
+// runServe -serve 模式：启动 HTTP API，直到收到 SIGINT/SIGTERM
+func (r *syncRunner) runServe(opts serveOptions) error {
+	switch opts.Shutdown {
+	case "":
+		opts.Shutdown = shutdownFinish
+	case shutdownFinish, shutdownCancel:
+	default:
+		return fmt.Errorf("不支持的 -serve-shutdown: %s（可选 finish、cancel）", opts.Shutdown)
+	}
+	s := &apiServer{runner: r, opts: opts, runs: make(map[string]*apiRun)}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	defer s.cancel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sync", s.handleSync)
+	mux.HandleFunc("/runs/", s.handleRun)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/status", s.handleStatus)
+	if r.metrics != nil {
+		mux.Handle("/metrics", r.metrics)
+	}
+	srv := &http.Server{Addr: opts.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	stop, cancelStop := notifyStop()
+	defer cancelStop()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	infof("HTTP API 已启动: %s（POST /sync，GET /runs/{id}，GET /status，GET /healthz）\n", opts.Addr)
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("HTTP API 启动失败: %w", err)
+	case <-stop.Done():
+	}
+
+	infof("HTTP API 正在关闭（%s）\n", opts.Shutdown)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		warnf("警告：关闭 HTTP 服务失败: %v\n", err)
+	}
+	if opts.Shutdown == shutdownCancel {
+		s.cancel()
+	}
+	s.wg.Wait()
+	infof("HTTP API 已退出\n")
+	return nil
+}
+
+// writeJSON 以 JSON 写出响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// writeError 以 {"error": "..."} 写出错误响应
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// syncRequest POST /sync 的请求体（可为空）
+type syncRequest struct {
+	Tables []string `json:"tables"` // 只同步这些表（源表名、目标表名或分区父表名），为空表示全部
+}
+
+// handleSync POST /sync：按可选的表过滤开始一次运行，返回运行 ID（202）
+func (s *apiServer) handleSync(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+		return
+	}
+	var body syncRequest
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "请求体不是有效的 JSON: %v", err)
+			return
+		}
+	}
+	filter, err := s.runner.tableFilter(body.Tables)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, "服务正在关闭")
+		return
+	}
+	if s.active > 0 && !s.opts.AllowConcurrent {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, "已有同步正在进行（可用 -serve-concurrent 允许并发运行）")
+		return
+	}
+	s.seq++
+	run := &apiRun{ID: strconv.Itoa(s.seq), Status: runRunning, Tables: body.Tables, StartedAt: time.Now(), Progress: []*apiTableProgress{}}
+	s.runs[run.ID] = run
+	s.active++
+	s.pruneLocked()
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	infof("HTTP API：开始运行 %s（表过滤: %s）\n", run.ID, firstNonEmpty(strings.Join(body.Tables, ", "), "全部"))
+	go s.execute(run, filter)
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": run.ID, "status": runRunning, "url": "/runs/" + run.ID})
+}
+
+// execute 执行一次运行：先检查连接，再按表过滤同步一轮，CLI 同样打印汇总报告
+func (s *apiServer) execute(run *apiRun, filter map[string]bool) {
+	defer s.wg.Done()
+	start := time.Now()
+	release, err := s.runner.ensureConnected(s.ctx)
+	var summary *verificationSummary
+	if err == nil {
+		summary, err = s.runner.runPass(passOptions{ctx: s.ctx, stop: s.ctx, tables: filter, observers: []passObserver{run}})
+		release()
+		summary.print(start)
+		s.runner.pushMetrics()
+	}
+	run.finish(summary, err)
+	if err != nil {
+		errorf("HTTP API：运行 %s 失败: %v\n", run.ID, err)
+	} else {
+		infof("HTTP API：运行 %s 完成，耗时 %s\n", run.ID, time.Since(start).Round(time.Millisecond))
+	}
+
+	s.mu.Lock()
+	s.active--
+	s.mu.Unlock()
+}
+
+// pruneLocked 超出 maxKeptRuns 时丢弃最早结束的运行（调用方持有 s.mu）
+func (s *apiServer) pruneLocked() {
+	if len(s.runs) <= maxKeptRuns {
+		return
+	}
+	var finished []*apiRun
+	for _, run := range s.runs {
+		run.mu.Lock()
+		if run.FinishedAt != nil {
+			finished = append(finished, run)
+		}
+		run.mu.Unlock()
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].StartedAt.Before(finished[j].StartedAt) })
+	for _, run := range finished {
+		if len(s.runs) <= maxKeptRuns {
+			break
+		}
+		delete(s.runs, run.ID)
+	}
+}
+
+// handleRun GET /runs/{id}：返回运行状态、各表进度与结束后的核对汇总
+func (s *apiServer) handleRun(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "仅支持 GET")
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(req.URL.Path, "/runs/"), "/")
+	s.mu.Lock()
+	run, ok := s.runs[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "运行 %s 不存在", id)
+		return
+	}
+	data, err := run.snapshot()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "序列化运行记录失败: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(append(data, '\n'))
+}
+
+// handleHealth GET /healthz：ping 源库与目标库，任一失败时返回 503。
+// 连接池的连接全部被进行中的运行占用时（如 sqlite 只有一个连接、被写入会话固定）ping 会一直等到超时，
+// 此时不 ping，报告 busy（视为正常）
+func (s *apiServer) handleHealth(w http.ResponseWriter, req *http.Request) {
+	src, dst := s.runner.conns()
+	check := func(db *simpleDB) string {
+		if db.files != nil {
+			return "ok"
+		}
+		if st := db.db.Stats(); st.MaxOpenConnections > 0 && st.InUse >= st.MaxOpenConnections {
+			return healthBusy
+		}
+		ctx, cancel := context.WithTimeout(req.Context(), db.pingTimeout)
+		defer cancel()
+		if err := db.db.PingContext(ctx); err != nil {
+			return errText(err)
+		}
+		return "ok"
+	}
+	resp := map[string]string{"source": check(src), "target": check(dst)}
+	healthy := func(v string) bool { return v == "ok" || v == healthBusy }
+	status := http.StatusOK
+	resp["status"] = "ok"
+	if !healthy(resp["source"]) || !healthy(resp["target"]) {
+		status, resp["status"] = http.StatusServiceUnavailable, "error"
+	}
+	writeJSON(w, status, resp)
+}
+
+// handleStatus GET /status：与 -status-file 相同的进度内容
+func (s *apiServer) handleStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "仅支持 GET")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.runner.status.snapshot())
+}
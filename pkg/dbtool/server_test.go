@@ -0,0 +1,79 @@
+package dbtool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// openTestRunner 连接 sqlite 源库与目标库的 syncRunner
+func openTestRunner(t *testing.T) *syncRunner {
+	t.Helper()
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	openTestSQLite(t, srcPath, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	openTestSQLite(t, dstPath, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	r, err := openSyncRunner(context.Background(), writeTestConfig(t, srcPath, dstPath, `[{"source_table": "t"}]`), runOptions{RunOnce: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestEnsureConnectedKeepsConnectionsInUse(t *testing.T) {
+	ctx := context.Background()
+	r := openTestRunner(t)
+	release, err := r.ensureConnected(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 连接断开，但另一个运行仍在进行，不能替换它正在使用的连接
+	src := r.src
+	src.db.Close()
+	release2, err := r.ensureConnected(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.src != src {
+		t.Fatal("有运行进行中时不应重连")
+	}
+	release()
+	release2()
+
+	// 没有进行中的运行时重连
+	release, err = r.ensureConnected(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if r.src == src {
+		t.Fatal("连接断开且没有运行进行中时应重新连接")
+	}
+}
+
+func TestHealthzSkipsPingWhenPoolBusy(t *testing.T) {
+	ctx := context.Background()
+	r := openTestRunner(t)
+	// 写入会话固定了连接池中唯一的连接
+	r.dst.db.SetMaxOpenConns(1)
+	conn, err := r.dst.db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s := &apiServer{runner: r}
+	w := httptest.NewRecorder()
+	s.handleHealth(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var resp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || resp["target"] != healthBusy || resp["source"] != "ok" {
+		t.Fatalf("%d %v，期望 200 且目标库为 %s", w.Code, resp, healthBusy)
+	}
+}
@@ -0,0 +1,291 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dbExecutor 是 *sql.DB 与 *sql.Conn 的公共方法集，写入路径通过它执行，以便固定到同一会话
+type dbExecutor interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// writeSession 单张表的写入会话
+// 会话级参数（如 MySQL FOREIGN_KEY_CHECKS）只对当前连接生效，而连接池每次可能给出不同连接，
+// 因此需要这类参数时把写入固定到一个专用的 sql.Conn 上，结束时恢复参数后再归还连接池
+type writeSession struct {
+	db        *sql.DB
+	conn      *sql.Conn
+	settings  []sessionSetting
+	restore   []string
+	txOptions *sql.TxOptions // tx_isolation：开启写入事务时的隔离级别，nil 表示驱动默认
+	log       *tableLogger
+}
+
+// sessionSetting 一条会话级参数：设置语句、恢复语句、生效后的提示及失败时的排查提示
+type sessionSetting struct {
+	set     string
+	restore string
+	current string // 非空时设置前用该查询读取原值（整数），restore 为以原值格式化的语句
+	note    string
+	hint    string
+}
+
+// apply 在 conn 上设置该参数，返回恢复语句
+func (st sessionSetting) apply(ctx context.Context, conn *sql.Conn) (string, error) {
+	restore := st.restore
+	if st.current != "" {
+		var v int64
+		if err := conn.QueryRowContext(ctx, st.current).Scan(&v); err != nil {
+			return "", err
+		}
+		restore = fmt.Sprintf(st.restore, v)
+	}
+	if _, err := conn.ExecContext(ctx, st.set); err != nil {
+		return "", err
+	}
+	return restore, nil
+}
+
+// checkSessionOptions 启动时检查目标库的写入会话参数：session_replication_role 只支持 replica，
+// 且已跳过触发器与外键检查，不能再与其他跳过外键/约束检查的选项同时配置（避免按目标库驱动静默忽略其中一个）
+func checkSessionOptions(cfg dbConfig) error {
+	role := strings.ToLower(strings.TrimSpace(cfg.SessionReplicationRole))
+	if role == "" {
+		return nil
+	}
+	if role != "replica" {
+		return fmt.Errorf("session_replication_role 仅支持 replica，当前为 %q", cfg.SessionReplicationRole)
+	}
+	var others []string
+	if cfg.DisableFKChecks {
+		others = append(others, "disable_fk_checks")
+	}
+	if cfg.DisableUniqueChecks {
+		others = append(others, "disable_unique_checks")
+	}
+	if cfg.SQLiteFastLoad {
+		others = append(others, "sqlite_fast_load")
+	}
+	if len(others) > 0 {
+		return fmt.Errorf("session_replication_role 已跳过触发器与外键检查，不能与 %s 同时配置", strings.Join(others, "、"))
+	}
+	return nil
+}
+
+// sessionSettings 根据目标库配置返回写入会话需要设置的参数
+func sessionSettings(cfg dbConfig, logger *tableLogger) ([]sessionSetting, error) {
+	var out []sessionSetting
+	driver := normalizeDriver(cfg.Driver)
+	switch driver {
+	case "mysql":
+		if cfg.DisableFKChecks {
+			out = append(out, sessionSetting{
+				set:     "SET FOREIGN_KEY_CHECKS=0",
+				restore: "SET FOREIGN_KEY_CHECKS=%d",
+				current: "SELECT @@SESSION.foreign_key_checks",
+				note:    "外键检查已关闭（FOREIGN_KEY_CHECKS=0），写入的数据不会校验引用完整性",
+			})
+		}
+		if cfg.DisableUniqueChecks {
+			out = append(out, sessionSetting{
+				set:     "SET UNIQUE_CHECKS=0",
+				restore: "SET UNIQUE_CHECKS=%d",
+				current: "SELECT @@SESSION.unique_checks",
+				note:    "唯一性检查已关闭（UNIQUE_CHECKS=0），二级唯一索引上的重复值可能不会报错",
+			})
+		}
+	case "sqlite3":
+		if cfg.SQLiteFastLoad {
+			mode := strings.ToUpper(strings.TrimSpace(cfg.SQLiteJournalMode))
+			switch mode {
+			case "":
+				mode = "WAL"
+			case "WAL", "MEMORY":
+			default:
+				return nil, fmt.Errorf("sqlite_journal_mode 仅支持 wal/memory，当前为 %q", cfg.SQLiteJournalMode)
+			}
+			out = append(out,
+				sessionSetting{
+					set:     "PRAGMA journal_mode=" + mode,
+					restore: "PRAGMA journal_mode=DELETE",
+					note:    fmt.Sprintf("SQLite 快速导入：journal_mode=%s, synchronous=OFF，进程崩溃或断电时数据库文件可能损坏；每张表导入结束后恢复为 journal_mode=DELETE, synchronous=FULL", mode),
+				},
+				sessionSetting{set: "PRAGMA synchronous=OFF", restore: "PRAGMA synchronous=FULL"},
+				sessionSetting{set: "PRAGMA temp_store=MEMORY", restore: "PRAGMA temp_store=DEFAULT"},
+				sessionSetting{
+					set:     "PRAGMA foreign_keys=OFF",
+					restore: "PRAGMA foreign_keys=ON",
+					note:    "SQLite 快速导入：foreign_keys=OFF，导入期间不校验外键",
+				},
+			)
+		}
+	}
+	if driver != "mysql" && (cfg.DisableFKChecks || cfg.DisableUniqueChecks) {
+		logger.warnf("警告：disable_fk_checks/disable_unique_checks 仅对 MySQL 目标库生效，已忽略\n")
+	}
+	if driver != "sqlite3" && cfg.SQLiteFastLoad {
+		logger.warnf("警告：sqlite_fast_load 仅对 SQLite 目标库生效，已忽略\n")
+	}
+
+	if role := strings.ToLower(strings.TrimSpace(cfg.SessionReplicationRole)); role != "" {
+		if driver != "postgres" && driver != "postgresql" {
+			logger.warnf("警告：session_replication_role 仅对 Postgres 目标库生效，已忽略\n")
+		} else if role != "replica" {
+			return nil, fmt.Errorf("session_replication_role 仅支持 replica，当前为 %q", cfg.SessionReplicationRole)
+		} else {
+			out = append(out, sessionSetting{
+				set:     "SET session_replication_role = replica",
+				restore: "RESET session_replication_role",
+				note:    "session_replication_role=replica：目标表的触发器与外键约束不会执行，依赖触发器填充的派生列不会被赋值",
+				hint:    "需要超级用户，或在 PG15+ 上执行 GRANT SET ON PARAMETER session_replication_role TO 用户",
+			})
+		}
+	}
+	sync, err := synchronousCommitSetting(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if sync != nil {
+		out = append(out, *sync)
+	}
+	return out, nil
+}
+
+// openWriteSession 打开写入会话；不需要会话参数时直接使用连接池。batchTimeout 为 batch_timeout（0 表示不限时）
+// Dry-Run 模式只打印将执行的会话语句
+func openWriteSession(ctx context.Context, dst *simpleDB, opts copyTableOptions, batchTimeout time.Duration) (*writeSession, error) {
+	s := &writeSession{db: dst.db, log: opts.Log}
+	level, name, err := parseTxIsolation(dst.cfg)
+	if err != nil {
+		return nil, err
+	}
+	if level != sql.LevelDefault {
+		s.txOptions = &sql.TxOptions{Isolation: level}
+		opts.Log.infof("写入事务隔离级别: %s（tx_isolation）\n", name)
+	}
+	settings, err := sessionSettings(dst.cfg, opts.Log)
+	if err != nil {
+		return nil, err
+	}
+	// 与 batchReplay 一致：逐批事务写入时才按批限时
+	if batchTimeout > 0 && !opts.DryRun && !opts.Atomic && !autocommitWrites(opts) {
+		if st := lockTimeoutSetting(dst.cfg.Driver, batchTimeout); st != nil {
+			settings = append(settings, *st)
+		}
+	}
+	if len(settings) == 0 {
+		return s, nil
+	}
+	if opts.DryRun {
+		for _, st := range settings {
+			opts.Log.infof("Dry-Run 模式，写入会话将执行: %s\n", st.set)
+		}
+		return s, nil
+	}
+
+	conn, err := dst.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取目标库专用连接失败: %w", err)
+	}
+	s.conn, s.settings = conn, settings
+	for _, st := range settings {
+		restore, err := st.apply(ctx, conn)
+		if err != nil {
+			s.Close()
+			if st.hint != "" {
+				return nil, fmt.Errorf("设置会话参数失败（%s，%s）: %w", st.set, st.hint, err)
+			}
+			return nil, fmt.Errorf("设置会话参数失败（%s）: %w", st.set, err)
+		}
+		s.restore = append(s.restore, restore)
+	}
+	for _, st := range settings {
+		if st.note != "" {
+			opts.Log.warnf("⚠️ %s\n", st.note)
+		}
+	}
+	return s, nil
+}
+
+// executor 返回写入使用的执行器（专用连接或连接池）；配置了 tx_isolation 时开启的事务使用该隔离级别
+func (s *writeSession) executor() dbExecutor {
+	var e dbExecutor = s.db
+	if s.conn != nil {
+		e = s.conn
+	}
+	if s.txOptions != nil {
+		return isolatedExecutor{dbExecutor: e, opts: s.txOptions}
+	}
+	return e
+}
+
+// Close 恢复会话参数并归还连接；出错路径上也会调用，因此使用独立的 context。
+// 任一参数未能恢复时丢弃该连接，不归还连接池
+func (s *writeSession) Close() {
+	if s == nil || s.conn == nil {
+		return
+	}
+	restored := true
+	for _, stmt := range s.restore {
+		if _, err := s.conn.ExecContext(context.Background(), stmt); err != nil {
+			s.log.warnf("警告：恢复会话参数失败（%s）: %v\n", stmt, err)
+			restored = false
+		}
+	}
+	if !restored {
+		s.log.warnf("警告：目标库专用连接的会话参数未能恢复，已丢弃该连接\n")
+		discardConn(s.conn)
+	}
+	_ = s.conn.Close()
+	s.conn = nil
+}
+
+// discardConn 使连接在 Close 时被关闭而不是归还连接池（会话参数未恢复，其它查询借到它会沿用这些参数）
+func discardConn(conn *sql.Conn) {
+	_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+}
+
+// pinned 写入是否固定在专用连接上
+func (s *writeSession) pinned() bool {
+	return s != nil && s.conn != nil
+}
+
+// reconnect 专用连接断开后换一个连接：在新连接上 ping 确认目标库已恢复，再重新设置会话参数（使用连接池时不需要）。
+// 旧连接先丢弃再取新连接，连接池只允许一个连接（如 sqlite_fast_load）时也能取到
+func (s *writeSession) reconnect(ctx context.Context, pingTimeout time.Duration) error {
+	if !s.pinned() {
+		return nil
+	}
+	// 旧连接上的会话参数没有恢复，不能归还连接池
+	discardConn(s.conn)
+	_ = s.conn.Close()
+	s.restore = nil
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("获取目标库专用连接失败: %w", err)
+	}
+	// 新连接的原值可能不同，恢复语句按新连接重新记录
+	s.conn = conn
+	pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+	err = conn.PingContext(pingCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("目标库专用连接不可用: %w", err)
+	}
+	for _, st := range s.settings {
+		restore, err := st.apply(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("重新设置会话参数失败（%s）: %w", st.set, err)
+		}
+		s.restore = append(s.restore, restore)
+	}
+	return nil
+}
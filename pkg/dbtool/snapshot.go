@@ -0,0 +1,158 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// consistent_snapshot：一轮同步在源库上只开启一个只读事务，所有表的 COUNT 与 SELECT 都在其中执行，
+// 各表读到的是同一时刻的数据（外键关联的表之间不会出现复制过程中新增的子行找不到父行）。按源库选用最强的机制：
+// - MySQL：REPEATABLE READ + START TRANSACTION WITH CONSISTENT SNAPSHOT（仅 InnoDB 等事务引擎的表一致）
+// - Postgres：REPEATABLE READ READ ONLY，并导出快照（pg_export_snapshot），其它会话可用 SET TRANSACTION SNAPSHOT 读到同一快照
+// - SQL Server：SNAPSHOT 隔离级别（需要数据库开启 ALLOW_SNAPSHOT_ISOLATION）
+// - Oracle：SET TRANSACTION READ ONLY（事务级读一致，等同于开始时 SCN 的 AS OF SCN 查询，受 undo 保留时长限制）
+// - SQLite：读事务（WAL 模式下不阻塞写入）
+// 所有读取共用一个会话，因此表只能依次复制（本工具按表串行复制，满足要求）；将来并行读取多张表时，
+// 只有 Postgres 能通过导出的快照让多个会话读到同一快照，MySQL 的一致性快照无法在会话之间共享。
+// 同一事务中不能再切换隔离级别，与 mssql_read_hint 互斥；连接断开后快照失效，不能续读（mysql_read=resume / page 不重试）
+
+// snapshotCloseTimeout 结束快照事务的超时（复制已取消时也要执行）
+const snapshotCloseTimeout = 5 * time.Second
+
+// sourceQuerier 源库上执行查询的对象：*sql.DB，或一致性快照的事务 / 连接
+type sourceQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// sourceSnapshot 一轮同步共用的源库只读事务
+type sourceSnapshot struct {
+	q      sourceQuerier
+	tx     *sql.Tx   // 通过 database/sql 开启的事务（MySQL 为 nil，用 conn 手动开启）
+	conn   *sql.Conn // MySQL：START TRANSACTION WITH CONSISTENT SNAPSHOT 所在的连接
+	driver string
+	id     string // Postgres 导出的快照 ID 或 Oracle 的 SCN，取不到时为空
+	log    *tableLogger
+}
+
+// sourceDB 源表查询使用的对象：配置了 consistent_snapshot 时为快照事务，否则为连接池
+func sourceDB(src *simpleDB, opts copyTableOptions) sourceQuerier {
+	if opts.Snapshot != nil {
+		return opts.Snapshot.q
+	}
+	return src.db
+}
+
+// openSourceSnapshot 在源库上开启一致性快照事务；源库不支持时返回错误
+func openSourceSnapshot(ctx context.Context, src *simpleDB) (*sourceSnapshot, error) {
+	s := &sourceSnapshot{driver: normalizeDriver(src.cfg.Driver), log: src.cfg.log}
+	var err error
+	switch {
+	case s.driver == "mysql":
+		err = s.beginMySQL(ctx, src.db)
+	case isPostgresDriver(s.driver):
+		if err = s.begin(ctx, src.db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}); err == nil {
+			if err = s.tx.QueryRowContext(ctx, "SELECT pg_export_snapshot()").Scan(&s.id); err != nil {
+				// 无法导出快照（如权限或版本不支持）时重新开启事务，事务本身仍然一致
+				s.log.debugf("导出快照失败（不影响本轮读取的一致性）: %v\n", err)
+				s.id, err = "", s.restart(ctx, src.db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+			}
+		}
+	case s.driver == "sqlserver":
+		err = s.begin(ctx, src.db, &sql.TxOptions{Isolation: sql.LevelSnapshot})
+	case s.driver == "oracle":
+		if err = s.begin(ctx, src.db, nil); err == nil {
+			if _, err = s.tx.ExecContext(ctx, "SET TRANSACTION READ ONLY"); err == nil {
+				if errSCN := s.tx.QueryRowContext(ctx, "SELECT TO_CHAR(DBMS_FLASHBACK.GET_SYSTEM_CHANGE_NUMBER) FROM DUAL").Scan(&s.id); errSCN != nil {
+					s.log.debugf("查询当前 SCN 失败（不影响本轮读取的一致性）: %v\n", errSCN)
+				}
+			}
+		}
+	case s.driver == "sqlite3":
+		if err = s.begin(ctx, src.db, nil); err == nil {
+			// 延迟事务在第一次读取时才取得快照
+			var n int64
+			err = s.tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&n)
+		}
+	default:
+		return nil, fmt.Errorf("consistent_snapshot 不支持源库驱动 %s（支持 mysql、postgres、sqlserver、oracle、sqlite3）", src.cfg.Driver)
+	}
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("开启一致性快照失败: %w", err)
+	}
+	return s, nil
+}
+
+// begin 通过 database/sql 开启事务
+func (s *sourceSnapshot) begin(ctx context.Context, db *sql.DB, opts *sql.TxOptions) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	s.tx, s.q = tx, tx
+	return nil
+}
+
+// restart 事务中的语句失败后（Postgres 事务已中止）重新开启事务
+func (s *sourceSnapshot) restart(ctx context.Context, db *sql.DB, opts *sql.TxOptions) error {
+	_ = s.tx.Rollback()
+	return s.begin(ctx, db, opts)
+}
+
+// beginMySQL 在专用连接上开启一致性快照（database/sql 的事务选项无法表达 WITH CONSISTENT SNAPSHOT）
+func (s *sourceSnapshot) beginMySQL(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	s.conn, s.q = conn, conn
+	if _, err := conn.ExecContext(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY")
+	return err
+}
+
+// describe 快照的说明（用于日志）
+func (s *sourceSnapshot) describe() string {
+	switch {
+	case s.driver == "mysql":
+		return "REPEATABLE READ，START TRANSACTION WITH CONSISTENT SNAPSHOT（MyISAM 等非事务引擎的表不在快照内）"
+	case isPostgresDriver(s.driver):
+		if s.id != "" {
+			return fmt.Sprintf("REPEATABLE READ READ ONLY，导出快照 %s（其它会话可用 SET TRANSACTION SNAPSHOT '%s' 读到同一快照）", s.id, s.id)
+		}
+		return "REPEATABLE READ READ ONLY"
+	case s.driver == "sqlserver":
+		return "SNAPSHOT 隔离级别（需要数据库开启 ALLOW_SNAPSHOT_ISOLATION）"
+	case s.driver == "oracle":
+		if s.id != "" {
+			return fmt.Sprintf("只读事务，SCN %s（读取时间超过 undo 保留时长可能报 ORA-01555）", s.id)
+		}
+		return "只读事务（读取时间超过 undo 保留时长可能报 ORA-01555）"
+	}
+	return "读事务"
+}
+
+// Close 结束快照事务（只读，回滚即可）并归还连接
+func (s *sourceSnapshot) Close() {
+	if s == nil {
+		return
+	}
+	if s.tx != nil {
+		if err := s.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			s.log.debugf("结束一致性快照事务失败: %v\n", err)
+		}
+	}
+	if s.conn != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), snapshotCloseTimeout)
+		_, _ = s.conn.ExecContext(ctx, "ROLLBACK")
+		cancel()
+		_ = s.conn.Close()
+	}
+}
@@ -0,0 +1,70 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// softDeleteColumn 返回表使用的软删除列：表级 soft_delete_column 优先（配置为空字符串表示关闭），否则取 table_list 级默认值
+func softDeleteColumn(cfg *toolConfig, t configTable) string {
+	if t.SoftDeleteColumn != nil {
+		return strings.TrimSpace(*t.SoftDeleteColumn)
+	}
+	if cfg.TableList != nil {
+		return strings.TrimSpace(cfg.TableList.SoftDeleteColumn)
+	}
+	return ""
+}
+
+// softDeletePredicate 返回软删除过滤条件：默认只复制未删除的行（col IS NULL），include_only_deleted 时只复制已删除的行
+func softDeletePredicate(column string, onlyDeleted bool, driver string) string {
+	if onlyDeleted {
+		return quoteIdent(column, driver) + " IS NOT NULL"
+	}
+	return quoteIdent(column, driver) + " IS NULL"
+}
+
+// resolveSoftDelete 确认源表存在软删除列，不存在时（或使用 select_sql 时）记录日志并关闭该表的软删除过滤
+func resolveSoftDelete(ctx context.Context, src *simpleDB, opts *copyTableOptions) error {
+	col := strings.TrimSpace(opts.SoftDeleteColumn)
+	if col == "" {
+		if opts.IncludeOnlyDeleted {
+			return fmt.Errorf("include_only_deleted 需要配置 soft_delete_column")
+		}
+		return nil
+	}
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		opts.Log.infof("表 %s 使用自定义 SELECT 查询，不追加软删除条件（%s）\n", opts.Table, col)
+		opts.SoftDeleteColumn = ""
+		return nil
+	}
+	from, err := sourceFrom(*opts, src.cfg.Driver)
+	if err != nil {
+		return err
+	}
+	rows, err := src.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", from))
+	if err != nil {
+		return fmt.Errorf("读取源表 %s 的列失败: %w", opts.Table, err)
+	}
+	cols, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("读取源表 %s 的列失败: %w", opts.Table, err)
+	}
+	for _, c := range cols {
+		if strings.EqualFold(c, col) {
+			opts.SoftDeleteColumn = c
+			if opts.IncludeOnlyDeleted {
+				opts.Log.infof("表 %s 只复制已软删除的行（%s IS NOT NULL）\n", opts.Table, c)
+			}
+			return nil
+		}
+	}
+	if opts.IncludeOnlyDeleted {
+		return fmt.Errorf("表 %s 没有软删除列 %s，无法使用 include_only_deleted", opts.Table, col)
+	}
+	opts.Log.infof("表 %s 没有软删除列 %s，不追加软删除条件\n", opts.Table, col)
+	opts.SoftDeleteColumn = ""
+	return nil
+}
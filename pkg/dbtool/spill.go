@@ -0,0 +1,415 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// isLargeObjectType 判断数据库类型名是否属于大字段（LOB/TEXT）家族
+func isLargeObjectType(dbType string) bool {
+	dbType = strings.ToUpper(strings.TrimSpace(dbType))
+	switch {
+	case strings.Contains(dbType, "CLOB"), strings.Contains(dbType, "BLOB"):
+		// CLOB/NCLOB/BLOB 以及 go-ora 的 OCIClobLocator/OCIBlobLocator
+		return true
+	case strings.Contains(dbType, "TEXT"), strings.Contains(dbType, "BYTEA"):
+		// TEXT/MEDIUMTEXT/LONGTEXT/NTEXT、Postgres BYTEA
+		return true
+	case dbType == "LONG", strings.Contains(dbType, "LONGVARCHAR"), strings.Contains(dbType, "LONGRAW"), dbType == "LONG RAW":
+		return true
+	case dbType == "JSON", dbType == "JSONB", dbType == "XML", dbType == "IMAGE":
+		return true
+	}
+	return false
+}
+
+// 大字段写入端溢写（lob_spill_threshold，旧名 large_value_threshold）：LOB/TEXT 列的值超过阈值时写入临时文件，
+// 行中只保留 *spilledValue，扫描得到的值随即释放。写入目标库时从文件按块读取，绑定的值不超过一块（见 lobWriter）；
+// 文件目标逐块编码写入；COPY / LOAD DATA 中含溢写大字段的行在整表导入之后、提交之前用分块的 INSERT 写入。
+//
+// 这不是流式读取：源库驱动（go-ora、lib/pq、mysql、go-mssqldb）在 Scan 时已把整个值读入内存，且都没有通过
+// database/sql 公开逐块读取 LOB 的接口（go-ora 的 LOB FETCH=POST/STREAM 也是取回整个值后才返回）。因此单个值的
+// 上限仍是可用内存，读取每个值时的峰值约为值大小的一到两倍；溢写保证的是同一时刻只有一份完整的值、不随批次累积，
+// 写入时也不再复制一份
+
+const (
+	// lobChunkSize 分块写入大字段时每块的字节数
+	lobChunkSize = 1 << 20
+	// oracleLobChunkSize Oracle 通过 PL/SQL 的 VARCHAR2 / RAW 变量追加，每块不超过 32767 字节
+	oracleLobChunkSize = 32000
+)
+
+// lobSpillThreshold 表的溢写阈值：lob_spill_threshold，未配置时取旧名称 large_value_threshold
+func lobSpillThreshold(t configTable) int64 {
+	if t.LobSpillThreshold > 0 {
+		return t.LobSpillThreshold
+	}
+	return t.LargeValueThreshold
+}
+
+// spilledValue 表示一个已溢写到临时文件的大字段值
+type spilledValue struct {
+	path   string
+	size   int64
+	binary bool
+}
+
+func (s *spilledValue) String() string {
+	return fmt.Sprintf("<大字段 %d 字节，已溢写到临时文件>", s.size)
+}
+
+// spillValue 将大字段值写入临时文件，释放行内存
+func spillValue(v interface{}, binary bool) (*spilledValue, error) {
+	f, err := os.CreateTemp("", "dbtool_lob_*.bin")
+	if err != nil {
+		return nil, fmt.Errorf("创建大字段临时文件失败: %w", err)
+	}
+	var n int
+	switch x := v.(type) {
+	case []byte:
+		n, err = f.Write(x)
+	case string:
+		n, err = f.WriteString(x)
+	default:
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, fmt.Errorf("不支持溢写的值类型 %T", v)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, fmt.Errorf("写入大字段临时文件失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return nil, fmt.Errorf("关闭大字段临时文件失败: %w", err)
+	}
+	return &spilledValue{path: f.Name(), size: int64(n), binary: binary}, nil
+}
+
+// load 把溢写的值整个读回内存（只在无法分块写入时使用）
+func (s *spilledValue) load() (interface{}, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("读取大字段临时文件失败: %w", err)
+	}
+	if s.binary {
+		return data, nil
+	}
+	return string(data), nil
+}
+
+// chunks 打开溢写文件按块读取；text 为 true 时在 UTF-8 字符边界处切分，不会把一个字符拆到两块中
+func (s *spilledValue) chunks(size int, text bool) (*chunkReader, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("读取大字段临时文件失败: %w", err)
+	}
+	return &chunkReader{f: f, buf: make([]byte, size), text: text}, nil
+}
+
+// chunkReader 溢写文件的分块读取
+type chunkReader struct {
+	f     *os.File
+	buf   []byte
+	text  bool
+	carry int // 上一块末尾不完整的字符，留到这一块
+	eof   bool
+}
+
+// next 返回下一块（在下一次调用前有效），读完时返回 nil
+func (r *chunkReader) next() ([]byte, error) {
+	if r.eof {
+		return nil, nil
+	}
+	copy(r.buf, r.buf[len(r.buf)-r.carry:])
+	n, err := io.ReadFull(r.f, r.buf[r.carry:])
+	n += r.carry
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		r.eof = true
+	case err != nil:
+		return nil, fmt.Errorf("读取大字段临时文件失败: %w", err)
+	}
+	end := n
+	if r.text && !r.eof {
+		end = runeBoundary(r.buf[:n])
+	}
+	// 未读完时缓冲区是满的，不完整的字符在缓冲区末尾，下一次移到开头
+	r.carry = n - end
+	if end == 0 {
+		return nil, nil
+	}
+	return r.buf[:end], nil
+}
+
+// Close 关闭溢写文件
+func (r *chunkReader) Close() error {
+	return r.f.Close()
+}
+
+// runeBoundary b 中最后一个完整 UTF-8 字符之后的位置；末尾不完整的字符留到下一块
+func runeBoundary(b []byte) int {
+	for i := len(b) - 1; i > 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
+			}
+			return i
+		}
+	}
+	return len(b)
+}
+
+// valueSize 返回字符串/二进制值的字节长度，其他类型返回 0
+func valueSize(v interface{}) int64 {
+	switch x := v.(type) {
+	case []byte:
+		return int64(len(x))
+	case string:
+		return int64(len(x))
+	case *spilledValue:
+		return x.size
+	}
+	return 0
+}
+
+// memorySize 一个值写入时在内存中的字节数：溢写的大字段按块读取，最多占一块
+func memorySize(v interface{}) int64 {
+	if s, ok := v.(*spilledValue); ok {
+		return min(s.size, lobChunkSize)
+	}
+	return valueSize(v)
+}
+
+// fixedValueSize 估算行大小时数值、时间等定长值按此计算的字节数
+const fixedValueSize = 8
+
+// rowSize 估算一行占用的内存字节数：字符串/二进制按长度，溢写的大字段按一块，其它值按定长计算。
+// batch_bytes 与 max_memory 共用此估算，两者的口径一致
+func rowSize(args []interface{}) int64 {
+	var n int64
+	for _, v := range args {
+		if size := memorySize(v); size > 0 {
+			n += size
+		} else {
+			n += fixedValueSize
+		}
+	}
+	return n
+}
+
+// hasSpilled 判断一行参数中是否包含溢写的大字段
+func hasSpilled(args []interface{}) bool {
+	for _, v := range args {
+		if _, ok := v.(*spilledValue); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// materializeRow 把一行中溢写的大字段读回内存（目标库无法分块写入时使用）
+func materializeRow(args []interface{}) error {
+	for i, v := range args {
+		s, ok := v.(*spilledValue)
+		if !ok {
+			continue
+		}
+		val, err := s.load()
+		if err != nil {
+			return err
+		}
+		args[i] = val
+	}
+	return nil
+}
+
+// discardSpilled 删除一行中的溢写文件（行已写入、出错或 dry-run 时使用）
+func discardSpilled(args []interface{}) {
+	for _, v := range args {
+		if s, ok := v.(*spilledValue); ok {
+			_ = os.Remove(s.path)
+		}
+	}
+}
+
+// lobWriter 把含溢写大字段的行写入目标库，绑定的值不超过一块：INSERT 时大字段只绑定第一块，之后在同一事务中按主键逐块追加
+// （Postgres、CockroachDB、SQLite: c = c || ?，MySQL: CONCAT，SQL Server: c.WRITE，Oracle: DBMS_LOB.WRITEAPPEND），
+// 内存占用与大字段的大小无关。目标表没有主键或目标库不支持追加时退回整值绑定，并打印一次警告
+type lobWriter struct {
+	driver    string
+	table     string
+	columns   []string
+	binary    []bool
+	keys      []int  // 目标表主键列在插入列中的下标
+	insertSQL string // COPY / LOAD DATA 导入之后写入含溢写大字段的行使用
+	chunk     int
+	log       *tableLogger
+	warned    bool
+}
+
+// newLobWriter 未启用大字段溢写（lob_spill_threshold 为 0）时返回 nil
+func newLobWriter(dst *simpleDB, table string, columns []string, conv *valueConverter, overriding bool, log *tableLogger) (*lobWriter, error) {
+	if conv.lobSpillThreshold <= 0 {
+		return nil, nil
+	}
+	insertSQL, err := buildInsertSQL(table, columns, dst.cfg.Driver, overriding)
+	if err != nil {
+		return nil, err
+	}
+	w := &lobWriter{driver: normalizeDriver(dst.cfg.Driver), table: table, columns: columns, keys: conv.keyIndexes, insertSQL: insertSQL, chunk: lobChunkSize, log: log}
+	if w.driver == "oracle" {
+		w.chunk = oracleLobChunkSize
+	}
+	w.binary = make([]bool, len(columns))
+	for i := range columns {
+		w.binary[i] = conv.isBinary(i)
+	}
+	return w, nil
+}
+
+// execRows COPY / LOAD DATA 导入之后，在同一事务中写入推迟的含溢写大字段的行
+func (w *lobWriter) execRows(ctx context.Context, tx batchTx, rows [][]interface{}) error {
+	for _, args := range rows {
+		if err := w.exec(ctx, tx, w.insertSQL, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exec 在 tx 中写入一行：没有溢写的大字段时直接执行 insertSQL，否则先写入各大字段的第一块，再逐块追加其余部分
+func (w *lobWriter) exec(ctx context.Context, tx batchTx, insertSQL string, args []interface{}) error {
+	if w == nil || !hasSpilled(args) {
+		_, err := tx.ExecContext(ctx, insertSQL, args...)
+		return err
+	}
+	row := append([]interface{}(nil), args...)
+	if !w.chunked(args) {
+		if err := materializeRow(row); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, insertSQL, row...)
+		return err
+	}
+
+	readers := make(map[int]*chunkReader)
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+	for i, v := range args {
+		s, ok := v.(*spilledValue)
+		if !ok {
+			continue
+		}
+		r, err := s.chunks(w.chunk, !s.binary)
+		if err != nil {
+			return err
+		}
+		readers[i] = r
+		first, err := r.next()
+		if err != nil {
+			return err
+		}
+		row[i] = chunkArg(first, s.binary)
+	}
+	if _, err := tx.ExecContext(ctx, insertSQL, row...); err != nil {
+		return err
+	}
+
+	keyArgs := make([]interface{}, 0, len(w.keys)+1)
+	keyArgs = append(keyArgs, nil)
+	for _, k := range w.keys {
+		keyArgs = append(keyArgs, args[k])
+	}
+	for i, r := range readers {
+		query := w.appendSQL(i)
+		for {
+			chunk, err := r.next()
+			if err != nil {
+				return err
+			}
+			if chunk == nil {
+				break
+			}
+			keyArgs[0] = chunkArg(chunk, w.binary[i])
+			if _, err := tx.ExecContext(ctx, query, keyArgs...); err != nil {
+				return fmt.Errorf("追加大字段 %s 失败: %w", w.columns[i], err)
+			}
+		}
+	}
+	return nil
+}
+
+// chunked 该行能否分块写入：目标库支持追加，目标表有主键且主键值不为空、没有溢写；不能时打印一次警告
+func (w *lobWriter) chunked(args []interface{}) bool {
+	reason := ""
+	switch {
+	case w.appendSQL(0) == "":
+		reason = fmt.Sprintf("目标库 %s 不支持分块追加", w.driver)
+	case len(w.keys) == 0:
+		reason = "目标表没有主键"
+	default:
+		for _, k := range w.keys {
+			if _, spilled := args[k].(*spilledValue); args[k] == nil || spilled {
+				reason = fmt.Sprintf("主键列 %s 的值为空或是溢写的大字段", w.columns[k])
+			}
+		}
+	}
+	if reason == "" {
+		return true
+	}
+	if !w.warned {
+		w.warned = true
+		w.log.warnf("警告：表 %s 溢写的大字段按整值绑定（%s），写入时占用与值大小相同的内存\n", w.table, reason)
+	}
+	return false
+}
+
+// appendSQL 向第 i 个插入列追加一块的语句（第 1 个参数为这一块，之后为主键值）；方言不支持时返回空串
+func (w *lobWriter) appendSQL(i int) string {
+	col := quoteIdent(w.columns[i], w.driver)
+	table := quoteIdent(w.table, w.driver)
+	where := make([]string, len(w.keys))
+	for j, k := range w.keys {
+		where[j] = quoteIdent(w.columns[k], w.driver) + " = " + placeholder(j+2, w.driver)
+	}
+	cond := strings.Join(where, " AND ")
+	p := placeholder(1, w.driver)
+	switch {
+	case isPostgresDriver(w.driver):
+		return fmt.Sprintf("UPDATE %s SET %s = %s || %s WHERE %s", table, col, col, p, cond)
+	case w.driver == "sqlite3" && w.binary[i]:
+		// SQLite 的 || 结果为文本，转回 BLOB
+		return fmt.Sprintf("UPDATE %s SET %s = CAST(%s || %s AS BLOB) WHERE %s", table, col, col, p, cond)
+	case w.driver == "sqlite3":
+		return fmt.Sprintf("UPDATE %s SET %s = %s || %s WHERE %s", table, col, col, p, cond)
+	case w.driver == "mysql":
+		return fmt.Sprintf("UPDATE %s SET %s = CONCAT(%s, %s) WHERE %s", table, col, col, p, cond)
+	case w.driver == "sqlserver":
+		return fmt.Sprintf("UPDATE %s SET %s.WRITE(%s, NULL, 0) WHERE %s", table, col, p, cond)
+	case w.driver == "oracle":
+		lob, buf, length := "CLOB", "VARCHAR2(32767)", "LENGTH"
+		if w.binary[i] {
+			lob, buf, length = "BLOB", "RAW(32767)", "UTL_RAW.LENGTH"
+		}
+		return fmt.Sprintf("DECLARE v_lob %s; v_buf %s := %s; BEGIN SELECT %s INTO v_lob FROM %s WHERE %s FOR UPDATE; DBMS_LOB.WRITEAPPEND(v_lob, %s(v_buf), v_buf); END;",
+			lob, buf, p, col, table, cond, length)
+	}
+	return ""
+}
+
+// chunkArg 一块的绑定值：二进制为 []byte，文本为 string
+func chunkArg(chunk []byte, binary bool) interface{} {
+	if binary {
+		return chunk
+	}
+	return string(chunk)
+}
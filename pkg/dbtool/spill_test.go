@@ -0,0 +1,213 @@
+package dbtool
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// largeText 生成含多字节字符的长文本，字符跨越分块边界
+func largeText(n int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < n; i++ {
+		b.WriteString("行")
+		b.WriteString(strings.Repeat("a", i%5))
+		b.WriteString("😀'\"\\,\n")
+	}
+	return b.String()
+}
+
+func TestChunkReaderKeepsRunes(t *testing.T) {
+	text := largeText(10000)
+	s, err := spillValue(text, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer discardSpilled([]interface{}{s})
+
+	for _, size := range []int{4, 5, 7, 64, 4096} {
+		r, err := s.chunks(size, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		for {
+			chunk, err := r.next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if chunk == nil {
+				break
+			}
+			if len(chunk) > size {
+				t.Fatalf("块大小 %d 超过 %d", len(chunk), size)
+			}
+			if !utf8.Valid(chunk) {
+				t.Fatalf("块大小 %d: 字符被拆到两块中", size)
+			}
+			got.Write(chunk)
+		}
+		r.Close()
+		if got.String() != text {
+			t.Fatalf("块大小 %d: 拼接后的文本不一致", size)
+		}
+	}
+}
+
+// spillTestSource 创建含大字段的源表，返回各行的值
+func spillTestSource(t *testing.T, path, ddl string) map[int64][2]interface{} {
+	t.Helper()
+	r := rand.New(rand.NewSource(835))
+	values := map[int64][2]interface{}{
+		1: {randomBytes(r, 2*lobChunkSize+777), largeText(2*lobChunkSize + 333)},
+		2: {[]byte{1, 2, 3}, "短文本"},
+		3: {randomBytes(r, lobChunkSize), nil},
+		4: {nil, largeText(5000)},
+	}
+	src := openTestSQLite(t, path, ddl)
+	for id, v := range values {
+		if _, err := src.Exec("INSERT INTO docs (id, data, body) VALUES (?, ?, ?)", id, v[0], v[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return values
+}
+
+// noSpillFiles 检查临时目录中没有遗留的溢写文件
+func noSpillFiles(t *testing.T, dir string) {
+	t.Helper()
+	left, _ := filepath.Glob(filepath.Join(dir, "dbtool_lob_*"))
+	if len(left) > 0 {
+		t.Fatalf("遗留了 %d 个溢写文件", len(left))
+	}
+}
+
+func TestLargeValueChunkedInsert(t *testing.T) {
+	for name, ddl := range map[string]string{
+		"主键":  "CREATE TABLE docs (id INTEGER PRIMARY KEY, data BLOB, body TEXT)",
+		"无主键": "CREATE TABLE docs (id INTEGER, data BLOB, body TEXT)",
+	} {
+		t.Run(name, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			dir := t.TempDir()
+			srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+			values := spillTestSource(t, srcPath, ddl)
+			dst := openTestSQLite(t, dstPath, ddl)
+
+			cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "docs", "lob_spill_threshold": 1000}]`)
+			ctx := context.Background()
+			s, err := Open(ctx, cfg, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if _, err := s.Run(ctx); err != nil {
+				t.Fatalf("同步失败: %v", err)
+			}
+			noSpillFiles(t, tmp)
+
+			rows, err := dst.Query("SELECT id, data, typeof(data), body FROM docs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			seen := 0
+			for rows.Next() {
+				var id int64
+				var data []byte
+				var typ string
+				var body *string
+				if err := rows.Scan(&id, &data, &typ, &body); err != nil {
+					t.Fatal(err)
+				}
+				seen++
+				want := values[id]
+				if want[0] == nil && typ != "null" || want[0] != nil && typ != "blob" {
+					t.Errorf("id=%d 的二进制列类型为 %s", id, typ)
+				}
+				if want[0] != nil && !bytes.Equal(data, want[0].([]byte)) {
+					t.Errorf("id=%d 的二进制值不一致: 长度 %d，期望 %d", id, len(data), len(want[0].([]byte)))
+				}
+				if (body == nil) != (want[1] == nil) || body != nil && *body != want[1].(string) {
+					t.Errorf("id=%d 的文本值不一致", id)
+				}
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if seen != len(values) {
+				t.Fatalf("目标表 %d 行，期望 %d", seen, len(values))
+			}
+		})
+	}
+}
+
+func TestLargeValueFileTargetStreams(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "src.db")
+	spillTestSource(t, srcPath, "CREATE TABLE docs (id INTEGER PRIMARY KEY, data BLOB, body TEXT)")
+
+	// 同一张表分别以溢写和不溢写导出，文件内容应完全一致
+	export := func(target, threshold string) []byte {
+		out := filepath.Join(t.TempDir(), "out")
+		path := filepath.Join(t.TempDir(), "config.json")
+		text := `{"source": {"driver": "sqlite3", "dsn": "` + srcPath + `"},
+ "target": {"driver": ` + target + `, "dsn": "` + out + `"},
+ "tables": [{"source_table": "docs", "select_sql": "SELECT id, data, body FROM docs ORDER BY id", "large_value_threshold": ` + threshold + `}]}`
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("加载配置失败: %v", err)
+		}
+		ctx := context.Background()
+		s, err := Open(ctx, cfg, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		if _, err := s.Run(ctx); err != nil {
+			t.Fatalf("导出失败: %v", err)
+		}
+		files, _ := filepath.Glob(filepath.Join(out, "docs.*"))
+		if len(files) != 1 {
+			t.Fatalf("导出文件: %v", files)
+		}
+		data, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// sqldump 第一行含生成时间
+		if bytes.HasPrefix(data, []byte("-- dbtool")) {
+			data = data[bytes.IndexByte(data, '\n'):]
+		}
+		return data
+	}
+
+	for _, target := range []string{
+		`"csv"`,
+		`"csv", "csv_line_ending": "crlf"`,
+		`"jsonl"`,
+		`"jsonl", "jsonl_binary": "string"`,
+		`"sqldump", "dump_dialect": "postgres"`,
+		`"sqldump", "dump_dialect": "mysql"`,
+		`"sqldump", "dump_dialect": "sqlserver"`,
+		`"sqldump", "dump_dialect": "oracle"`,
+	} {
+		tmp := t.TempDir()
+		t.Setenv("TMPDIR", tmp)
+		want := export(target, "0")
+		got := export(target, "1000")
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: 溢写后导出的文件不一致（%d 字节，期望 %d 字节）", target, len(got), len(want))
+		}
+		noSpillFiles(t, tmp)
+	}
+}
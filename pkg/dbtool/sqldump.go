@@ -0,0 +1,242 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqldump 目标（driver: sqldump，见 filetarget.go）：把表导出为可执行的 .sql 文件，用于交给离线环境的 DBA 执行。
+// dump_dialect（-dump-dialect 覆盖）指定建表语句与字面量的方言，与源库无关：mysql、postgres、sqlserver、oracle、sqlite3。
+// 每个文件依次为：
+// - 会话设置（字符集、standard_conforming_strings、SET DEFINE OFF 等）
+// - 配置了 auto_create 时的 CREATE TABLE（按该方言的类型映射，字段映射的 target_type、nullable、default 生效，含主键；只在第一个文件中）
+// - 开启事务并锁表，按 batch_size 行一条的多行 INSERT（SQL Server 每条最多 1000 行，Oracle 每行一条），提交并解锁
+// 字面量：字符串按方言转义（MySQL 还转义反斜杠，SQL Server 使用 N'...'），二进制为十六进制（X'..'、'\x..'::bytea、0x..、HEXTORAW），
+// 时间精确到微秒（Oracle 使用 TO_TIMESTAMP），布尔值为 TRUE/FALSE（Postgres）或 1/0，NaN/Inf 为 NULL
+
+// dumpDialects dump_dialect 的取值
+var dumpDialects = []string{"mysql", "postgres", "sqlserver", "oracle", "sqlite3"}
+
+// parseDumpDialect 检查 dump_dialect（postgresql、mssql 等别名按驱动名规范化）
+func parseDumpDialect(v string) (string, error) {
+	d := normalizeDriver(v)
+	if d == "postgresql" {
+		d = "postgres"
+	}
+	if d == "" {
+		return "", fmt.Errorf("sqldump 目标需要配置 dump_dialect 或使用 -dump-dialect（可选 %s）", strings.Join(dumpDialects, "、"))
+	}
+	for _, s := range dumpDialects {
+		if s == d {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("dump_dialect 无效: %q（可选 %s）", v, strings.Join(dumpDialects, "、"))
+}
+
+// sqlDump 一张表的 dump 语句
+type sqlDump struct {
+	dialect string
+	table   string // 原始表名（文件头注释）
+	quoted  string // 按方言引用的表名
+	ddl     string
+
+	insert        string // 一条 INSERT 语句的开头
+	rowStart      string // 每组值的开头
+	rowSep        string // 同一条语句中两组值之间
+	insertEnd     string // 语句结尾
+	rowsPerInsert int
+}
+
+func newSQLDump(dialect, table string, columns []string, ddl string, batchSize int) *sqlDump {
+	cols := make([]string, len(columns))
+	for i, c := range columns {
+		cols[i] = quoteIdent(c, dialect)
+	}
+	d := &sqlDump{dialect: dialect, table: table, quoted: quoteIdent(table, dialect), ddl: ddl, rowsPerInsert: batchSize}
+	d.insert = fmt.Sprintf("INSERT INTO %s (%s) VALUES", d.quoted, strings.Join(cols, ", "))
+	switch {
+	case dialect == "oracle":
+		// Oracle 不支持多行 VALUES
+		d.insert += " "
+		d.rowStart, d.rowsPerInsert = "(", 1
+	case dialect == "sqlserver" && d.rowsPerInsert > 1000:
+		// SQL Server 的 VALUES 最多 1000 行
+		d.rowsPerInsert = 1000
+	}
+	if d.rowStart == "" {
+		d.insert += "\n"
+		d.rowStart = "  ("
+	}
+	d.rowSep, d.insertEnd = ",\n", ";\n"
+	return d
+}
+
+// header 第 part 个文件的开头：会话设置、建表语句（第一个文件）、开启事务并锁表
+func (d *sqlDump) header(part int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- dbtool 导出: 表 %s，方言 %s，第 %d 个文件，生成于 %s\n\n", d.table, d.dialect, part, time.Now().Format("2006-01-02 15:04:05"))
+	switch d.dialect {
+	case "mysql":
+		b.WriteString("SET NAMES utf8mb4;\n")
+	case "postgres":
+		b.WriteString("SET client_encoding = 'UTF8';\nSET standard_conforming_strings = on;\n")
+	case "sqlserver":
+		b.WriteString("SET NOCOUNT ON;\nSET XACT_ABORT ON;\n")
+	case "oracle":
+		// SQL*Plus 中 & 会被当作替换变量
+		b.WriteString("SET DEFINE OFF\n")
+	}
+	if part == 1 && d.ddl != "" {
+		b.WriteString("\n" + d.ddl + ";\n")
+	}
+	b.WriteString("\n")
+	switch d.dialect {
+	case "mysql":
+		fmt.Fprintf(&b, "SET autocommit = 0;\nLOCK TABLES %s WRITE;\n", d.quoted)
+	case "postgres":
+		fmt.Fprintf(&b, "BEGIN;\nLOCK TABLE %s IN ACCESS EXCLUSIVE MODE;\n", d.quoted)
+	case "sqlserver":
+		fmt.Fprintf(&b, "BEGIN TRANSACTION;\nSELECT TOP 0 * FROM %s WITH (TABLOCKX, HOLDLOCK);\n", d.quoted)
+	case "oracle":
+		fmt.Fprintf(&b, "LOCK TABLE %s IN EXCLUSIVE MODE;\n", d.quoted)
+	case "sqlite3":
+		b.WriteString("BEGIN IMMEDIATE;\n")
+	}
+	return b.String()
+}
+
+// footer 每个文件的结尾：提交事务并解锁
+func (d *sqlDump) footer() string {
+	switch d.dialect {
+	case "mysql":
+		return "COMMIT;\nUNLOCK TABLES;\n"
+	case "sqlserver":
+		return "COMMIT TRANSACTION;\n"
+	}
+	return "COMMIT;\n"
+}
+
+// literal 一个值在方言中的字面量；decimal 为定点数列（驱动以文本返回，是合法数字时不加引号）
+func (d *sqlDump) literal(v interface{}, binary, decimal bool) string {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		switch {
+		case d.dialect == "postgres" && x:
+			return "TRUE"
+		case d.dialect == "postgres":
+			return "FALSE"
+		case x:
+			return "1"
+		}
+		return "0"
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case int, int8, int16, int32, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", x)
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return "NULL"
+		}
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case float32:
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return "NULL"
+		}
+		return strconv.FormatFloat(float64(x), 'g', -1, 32)
+	case time.Time:
+		return d.timeLiteral(x)
+	case []byte:
+		if binary {
+			return d.binaryLiteral(x)
+		}
+		return d.textLiteral(string(x), decimal)
+	case string:
+		return d.textLiteral(x, decimal)
+	}
+	return d.textLiteral(fmt.Sprintf("%v", v), false)
+}
+
+// textLiteral 字符串字面量（SQL Server 使用 N'...' 保留 Unicode）
+func (d *sqlDump) textLiteral(s string, decimal bool) string {
+	if decimal && isNumberText(s) {
+		return s
+	}
+	if d.dialect == "sqlserver" {
+		return "N" + sqlLiteral(s, d.dialect)
+	}
+	return sqlLiteral(s, d.dialect)
+}
+
+// binaryLiteral 二进制字面量
+func (d *sqlDump) binaryLiteral(b []byte) string {
+	prefix, suffix := d.binaryAffixes()
+	return prefix + hex.EncodeToString(b) + suffix
+}
+
+// binaryAffixes 二进制字面量中十六进制串前后的部分
+func (d *sqlDump) binaryAffixes() (string, string) {
+	switch d.dialect {
+	case "postgres":
+		return `'\x`, `'::bytea`
+	case "sqlserver":
+		return "0x", ""
+	case "oracle":
+		return "HEXTORAW('", "')"
+	}
+	return "X'", "'"
+}
+
+// textAffixes 字符串字面量中转义后的内容前后的部分（溢写的大字段逐块写入时使用）
+func (d *sqlDump) textAffixes() (string, string) {
+	if d.dialect == "sqlserver" {
+		return "N'", "'"
+	}
+	return "'", "'"
+}
+
+// timeLiteral 时间字面量（微秒）；Postgres 的非 UTC 时间带上偏移量，timestamptz 列按确定的时刻写入。
+// SQL Server 的 DATETIME 列不接受超过 3 位小数的字符串，此时写为 DATETIME2，写入 DATETIME 列时按其精度舍入
+func (d *sqlDump) timeLiteral(t time.Time) string {
+	switch {
+	case d.dialect == "oracle":
+		return fmt.Sprintf("TO_TIMESTAMP('%s', 'YYYY-MM-DD HH24:MI:SS.FF6')", t.Format("2006-01-02 15:04:05.000000"))
+	case d.dialect == "postgres" && t.Location() != time.UTC:
+		return "'" + t.Format("2006-01-02 15:04:05.999999-07:00") + "'"
+	case d.dialect == "sqlserver" && t.Nanosecond()/int(time.Microsecond)%1000 != 0:
+		return "CAST('" + t.Format("2006-01-02 15:04:05.999999") + "' AS DATETIME2)"
+	}
+	return "'" + t.Format("2006-01-02 15:04:05.999999") + "'"
+}
+
+// dumpTableDDL 按 dump 方言生成建表语句：源表目录中的可空性与默认值、主键（未使用 select_sql 时），字段映射的类型覆盖
+func dumpTableDDL(ctx context.Context, src *simpleDB, colTypes []*sql.ColumnType, table, dialect string, opts copyTableOptions) (string, error) {
+	opts.MySQLTableOptions = resolveMySQLTableOptions(ctx, src, dialect, opts.MySQLTableOptions, opts.Log)
+	meta := &sourceTableMeta{}
+	if strings.TrimSpace(opts.SelectSQL) == "" {
+		if srcCols, err := fetchTargetColumns(ctx, src, opts.Table); err != nil {
+			opts.Log.warnf("警告：%v，建表语句中不设置列默认值\n", err)
+		} else {
+			meta.columns = srcCols
+			meta.defaults = translateDefaults(srcCols, src.cfg.Driver, dialect, meta, opts)
+		}
+		if pk, _, err := sourceIndexesForDDL(ctx, src, table, dialect, opts); err != nil {
+			opts.Log.warnf("警告：%v，建表语句中不设置主键\n", err)
+		} else {
+			meta.primaryKey = pk
+		}
+	}
+	ddl, err := buildCreateTableDDL(table, colTypes, dialect, meta, opts)
+	if err != nil {
+		return "", fmt.Errorf("生成建表语句失败: %w", err)
+	}
+	return ddl, nil
+}
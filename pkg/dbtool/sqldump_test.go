@@ -0,0 +1,109 @@
+package dbtool
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// exportSQLDump 把 sqlite 源表按 dump_dialect 导出为 sqldump，返回文件内容与同步错误
+func exportSQLDump(t *testing.T, srcPath, dialect, tables string) ([]byte, error) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out")
+	path := filepath.Join(t.TempDir(), "config.json")
+	text := `{"source": {"driver": "sqlite3", "dsn": "` + srcPath + `"},
+ "target": {"driver": "sqldump", "dump_dialect": "` + dialect + `", "dsn": "` + out + `"},
+ "tables": ` + tables + `}`
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	ctx := context.Background()
+	s, err := Open(ctx, cfg, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Run(ctx); err != nil {
+		return nil, err
+	}
+	files, _ := filepath.Glob(filepath.Join(out, "*.sql"))
+	if len(files) != 1 {
+		t.Fatalf("导出文件: %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, nil
+}
+
+func TestSQLDumpConvertsForDialect(t *testing.T) {
+	srcPath := filepath.Join(t.TempDir(), "src.db")
+	openTestSQLite(t, srcPath,
+		"CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, flag TEXT, note TEXT, at TEXT)",
+		"INSERT INTO t VALUES (1, 'a'||char(0)||'b', 'true', '', '1700-01-01 00:00:00')")
+	// 配置了 columns 时只导出列出的列
+	columns := `"columns": [{"source": "id"}, {"source": "name"}, {"source": "flag", "target_type": "BOOLEAN"}, {"source": "note"}]`
+
+	cases := []struct {
+		dialect string
+		extra   string
+		want    []string
+		absent  []string
+	}{
+		// Postgres 不接受文本中的 NUL，按 nul_byte_policy（默认 strip）去除；布尔值写为 TRUE
+		{"postgres", "", []string{"'ab'", "TRUE"}, []string{"\x00"}},
+		// MySQL 保留 NUL，布尔值写为 1
+		{"mysql", "", []string{"\x00", ", 1, "}, nil},
+		// Oracle 空字符串按 empty_string_policy 处理
+		{"oracle", `, "empty_string_policy": "space"`, []string{"' '"}, nil},
+	}
+	for _, c := range cases {
+		data, err := exportSQLDump(t, srcPath, c.dialect, `[{"source_table": "t", `+columns+c.extra+`}]`)
+		if err != nil {
+			t.Fatalf("%s: 导出失败: %v", c.dialect, err)
+		}
+		for _, s := range c.want {
+			if !bytes.Contains(data, []byte(s)) {
+				t.Errorf("%s: 导出内容中没有 %q:\n%s", c.dialect, s, data)
+			}
+		}
+		for _, s := range c.absent {
+			if bytes.Contains(data, []byte(s)) {
+				t.Errorf("%s: 导出内容中不应有 %q", c.dialect, s)
+			}
+		}
+	}
+
+	// SQL Server DATETIME 列不能保存 1753 年之前的日期，按 date_range_policy（默认 error）报错
+	_, err := exportSQLDump(t, srcPath, "sqlserver", `[{"source_table": "t", "columns": [{"source": "id"}, {"source": "at", "target_type": "DATETIME"}]}]`)
+	if err == nil {
+		t.Error("sqlserver: 超出 DATETIME 范围的日期应导出失败")
+	}
+}
+
+func TestSQLServerTimeLiteral(t *testing.T) {
+	d := &sqlDump{dialect: "sqlserver"}
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC), "'2024-03-01 08:30:00'"},
+		{time.Date(2024, 3, 1, 8, 30, 0, 123000000, time.UTC), "'2024-03-01 08:30:00.123'"},
+		// 超过 3 位小数时 DATETIME 不接受字符串，写为 DATETIME2
+		{time.Date(2024, 3, 1, 8, 30, 0, 123456000, time.UTC), "CAST('2024-03-01 08:30:00.123456' AS DATETIME2)"},
+		{time.Date(2024, 3, 1, 8, 30, 0, 100, time.UTC), "'2024-03-01 08:30:00'"},
+	}
+	for _, c := range cases {
+		if got := d.timeLiteral(c.at); got != c.want {
+			t.Errorf("%s: %s，期望 %s", c.at, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package dbtool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// 数据源可配置 ssh 经跳板机连接数据库：连接数据库前先建立 SSH 连接，
+// - MySQL 驱动支持自定义拨号，直接经 SSH 连接拨号
+// - 其它驱动（Postgres、SQL Server、Oracle）在本机 127.0.0.1 上开一个随机端口转发到数据库，DSN 中的主机改为该端口
+// 隧道随连接关闭；常驻运行（-loop 等）重新连接数据库时隧道一并重建
+const (
+	sshDialTimeout       = 15 * time.Second
+	sshKeepAliveInterval = 30 * time.Second
+)
+
+// sshConfig 数据源的 SSH 隧道配置
+type sshConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"` // 默认 22
+	User string `json:"user"`
+
+	// 认证方式（可同时配置，按 agent、key_file、password 的顺序尝试）；都未配置时使用 SSH_AUTH_SOCK 指向的 ssh-agent
+	Agent         bool   `json:"agent,omitempty"`
+	KeyFile       string `json:"key_file,omitempty"`
+	KeyPassphrase string `json:"key_passphrase,omitempty"`
+	Password      string `json:"password,omitempty"`
+
+	// KnownHosts 校验跳板机主机密钥的 known_hosts 文件，默认 ~/.ssh/known_hosts
+	KnownHosts string `json:"known_hosts,omitempty"`
+	// InsecureIgnoreHostKey 不校验主机密钥（仅用于测试环境）
+	InsecureIgnoreHostKey bool `json:"insecure_ignore_host_key,omitempty"`
+}
+
+// addr 跳板机地址
+func (c *sshConfig) addr() string {
+	port := c.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// sshTunnel 一条 SSH 连接及其上的本地端口转发
+type sshTunnel struct {
+	client   *ssh.Client
+	listener net.Listener // 本地端口转发（MySQL 使用拨号时为 nil）
+	done     chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+	log      *tableLogger // 隧道的日志输出（随连接配置）
+}
+
+// openSSHTunnel 连接跳板机
+func openSSHTunnel(c *sshConfig, logger *tableLogger) (*sshTunnel, error) {
+	if strings.TrimSpace(c.Host) == "" || strings.TrimSpace(c.User) == "" {
+		return nil, fmt.Errorf("ssh 需要配置 host 与 user")
+	}
+	auth, closeAgent, err := sshAuthMethods(c)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+	hostKey, err := sshHostKeyCallback(c, logger)
+	if err != nil {
+		return nil, err
+	}
+	client, err := ssh.Dial("tcp", c.addr(), &ssh.ClientConfig{
+		User:            c.User,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+		Timeout:         sshDialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("连接 SSH 跳板机 %s 失败: %w", c.addr(), err)
+	}
+	t := &sshTunnel{client: client, done: make(chan struct{}), log: logger}
+	go t.keepAlive()
+	logger.infof("已建立 SSH 隧道: %s@%s\n", c.User, c.addr())
+	return t, nil
+}
+
+// sshAuthMethods 按配置生成认证方式；返回的函数关闭 ssh-agent 连接（握手完成后调用）
+func sshAuthMethods(c *sshConfig) ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+	closeAgent := func() {}
+	useAgent := c.Agent || (c.KeyFile == "" && c.Password == "")
+	if useAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			if c.Agent {
+				return nil, closeAgent, fmt.Errorf("ssh 配置了 agent，但环境变量 SSH_AUTH_SOCK 未设置")
+			}
+			return nil, closeAgent, fmt.Errorf("ssh 未配置 key_file 或 password，且环境变量 SSH_AUTH_SOCK 未设置（无法使用 ssh-agent）")
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("连接 ssh-agent 失败: %w", err)
+		}
+		closeAgent = func() { conn.Close() }
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if c.KeyFile != "" {
+		key, err := os.ReadFile(expandHome(c.KeyFile))
+		if err != nil {
+			closeAgent()
+			return nil, func() {}, fmt.Errorf("读取 SSH 私钥失败: %w", err)
+		}
+		var signer ssh.Signer
+		if c.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(c.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			closeAgent()
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				return nil, func() {}, fmt.Errorf("SSH 私钥 %s 有密码保护，请配置 key_passphrase", c.KeyFile)
+			}
+			return nil, func() {}, fmt.Errorf("解析 SSH 私钥 %s 失败: %w", c.KeyFile, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if c.Password != "" {
+		methods = append(methods, ssh.Password(c.Password))
+	}
+	return methods, closeAgent, nil
+}
+
+// sshHostKeyCallback 按 known_hosts 校验跳板机的主机密钥
+func sshHostKeyCallback(c *sshConfig, logger *tableLogger) (ssh.HostKeyCallback, error) {
+	if c.InsecureIgnoreHostKey {
+		logger.warnf("警告：ssh 配置了 insecure_ignore_host_key，不校验跳板机 %s 的主机密钥\n", c.addr())
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	path := c.KnownHosts
+	if path == "" {
+		path = "~/.ssh/known_hosts"
+	}
+	cb, err := knownhosts.New(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("读取 known_hosts 失败: %w（可用 ssh-keyscan 把跳板机加入 known_hosts，或配置 known_hosts 指定文件）", err)
+	}
+	return cb, nil
+}
+
+// expandHome 展开路径开头的 ~
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// keepAlive 定期发送 keepalive，连接断开时关闭隧道（随后数据库 ping 失败，触发重新连接）
+func (t *sshTunnel) keepAlive() {
+	ticker := time.NewTicker(sshKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			if _, _, err := t.client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				t.log.warnf("警告：SSH 隧道已断开: %v\n", err)
+				t.Close()
+				return
+			}
+		}
+	}
+}
+
+// dial 经 SSH 连接拨号到数据库
+func (t *sshTunnel) dial(ctx context.Context, addr string) (net.Conn, error) {
+	return t.client.DialContext(ctx, "tcp", addr)
+}
+
+// forward 在本机随机端口上监听，把连接经 SSH 转发到 remote，返回本地地址
+func (t *sshTunnel) forward(remote string) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("SSH 隧道监听本地端口失败: %w", err)
+	}
+	t.listener = ln
+	go func() {
+		for {
+			local, err := ln.Accept()
+			if err != nil {
+				return // 隧道已关闭
+			}
+			t.wg.Add(1)
+			go func() {
+				defer t.wg.Done()
+				t.pipe(local, remote)
+			}()
+		}
+	}()
+	t.log.debugf("SSH 隧道转发: %s -> %s\n", ln.Addr(), remote)
+	return ln.Addr().String(), nil
+}
+
+// pipe 把一个本地连接经 SSH 转发到 remote，任一方向结束时关闭两端
+func (t *sshTunnel) pipe(local net.Conn, remote string) {
+	defer local.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), sshDialTimeout)
+	conn, err := t.dial(ctx, remote)
+	cancel()
+	if err != nil {
+		t.log.warnf("警告：经 SSH 隧道连接 %s 失败: %v\n", remote, err)
+		return
+	}
+	defer conn.Close()
+	copied := make(chan struct{}, 2)
+	go func() { _, _ = io.Copy(conn, local); copied <- struct{}{} }()
+	go func() { _, _ = io.Copy(local, conn); copied <- struct{}{} }()
+	select {
+	case <-copied:
+	case <-t.done:
+	}
+}
+
+// Close 关闭本地监听与 SSH 连接
+func (t *sshTunnel) Close() {
+	t.once.Do(func() {
+		close(t.done)
+		if t.listener != nil {
+			_ = t.listener.Close()
+		}
+		_ = t.client.Close()
+		t.wg.Wait()
+	})
+}
+
+// sshDialerSeq 为每条隧道注册的 MySQL 拨号网络名编号（驱动不支持注销，重连时注册新名字）
+var sshDialerSeq atomic.Int64
+
+// tunnelDSN 建立隧道后改写 DSN：MySQL 改用经隧道拨号的网络名，其它驱动把主机改为本地转发端口
+func tunnelDSN(driver, dsn string, t *sshTunnel) (string, error) {
+	switch {
+	case driver == "mysql":
+		mc, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "", fmt.Errorf("解析 MySQL DSN 失败: %w", err)
+		}
+		if mc.Net == "unix" {
+			return "", fmt.Errorf("ssh 隧道不支持 unix socket 连接")
+		}
+		name := fmt.Sprintf("dbtool-ssh-%d", sshDialerSeq.Add(1))
+		mysql.RegisterDialContext(name, func(ctx context.Context, addr string) (net.Conn, error) {
+			return t.dial(ctx, addr)
+		})
+		mc.Net = name
+		return mc.FormatDSN(), nil
+	case isPostgresDriver(driver), driver == "sqlserver", driver == "oracle":
+		remote, rewrite, err := dsnHostPort(driver, dsn)
+		if err != nil {
+			return "", err
+		}
+		local, err := t.forward(remote)
+		if err != nil {
+			return "", err
+		}
+		host, port, _ := net.SplitHostPort(local)
+		return rewrite(host, port), nil
+	}
+	return "", fmt.Errorf("驱动 %s 不支持 ssh 隧道", driver)
+}
+
+// pgKVHostPattern Postgres key=value DSN 中的 host、port
+var (
+	pgKVHostPattern = regexp.MustCompile(`(^|\s)host\s*=\s*('[^']*'|\S+)`)
+	pgKVPortPattern = regexp.MustCompile(`(^|\s)port\s*=\s*('[^']*'|\S+)`)
+	adoServerKey    = regexp.MustCompile(`(?i)(^|;)\s*(server|data source|address|addr)\s*=\s*([^;]*)`)
+)
+
+// dsnHostPort 取出 DSN 中数据库的地址，返回把地址替换为 host:port 的函数
+func dsnHostPort(driver, dsn string) (string, func(host, port string) string, error) {
+	defaultPort := strconv.Itoa(defaultPorts[envDriver(driver)])
+	withPort := func(hostport string) string {
+		if _, _, err := net.SplitHostPort(hostport); err == nil {
+			return hostport
+		}
+		return net.JoinHostPort(strings.Trim(hostport, "[]"), defaultPort)
+	}
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil || u.Host == "" {
+			return "", nil, fmt.Errorf("ssh 隧道无法从 DSN 中取出数据库地址")
+		}
+		if driver == "sqlserver" && strings.Contains(u.Path, "/") && strings.Trim(u.Path, "/") != "" {
+			return "", nil, fmt.Errorf("ssh 隧道不支持 SQL Server 命名实例（%s），请在 DSN 中写端口", u.Path)
+		}
+		remote := withPort(u.Host)
+		return remote, func(host, port string) string {
+			u.Host = net.JoinHostPort(host, port)
+			return u.String()
+		}, nil
+	}
+	if isPostgresDriver(driver) {
+		m := pgKVHostPattern.FindStringSubmatch(dsn)
+		if m == nil {
+			return "", nil, fmt.Errorf("ssh 隧道需要 DSN 中配置 host")
+		}
+		host := strings.Trim(m[2], "'")
+		port := defaultPort
+		if p := pgKVPortPattern.FindStringSubmatch(dsn); p != nil {
+			port = strings.Trim(p[2], "'")
+		}
+		return net.JoinHostPort(host, port), func(h, p string) string {
+			s := pgKVHostPattern.ReplaceAllString(dsn, "${1}host="+h)
+			if pgKVPortPattern.MatchString(s) {
+				return pgKVPortPattern.ReplaceAllString(s, "${1}port="+p)
+			}
+			return s + " port=" + p
+		}, nil
+	}
+	if driver == "sqlserver" {
+		// ADO 连接串：server=host,port 或 server=host\instance
+		m := adoServerKey.FindStringSubmatch(dsn)
+		if m == nil {
+			return "", nil, fmt.Errorf("ssh 隧道需要 DSN 中配置 server")
+		}
+		server := strings.TrimSpace(m[3])
+		if strings.Contains(server, `\`) {
+			return "", nil, fmt.Errorf("ssh 隧道不支持 SQL Server 命名实例（%s），请在 DSN 中写端口", server)
+		}
+		remote := withPort(server)
+		if host, port, found := strings.Cut(server, ","); found {
+			remote = net.JoinHostPort(strings.TrimSpace(host), strings.TrimSpace(port))
+		}
+		return remote, func(h, p string) string {
+			return adoServerKey.ReplaceAllString(dsn, "${1}${2}="+h+","+p)
+		}, nil
+	}
+	return "", nil, fmt.Errorf("ssh 隧道无法从 DSN 中取出数据库地址")
+}
@@ -0,0 +1,293 @@
+package dbtool
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// watermarkTracker 在扫描源表行时记录增量列的最大值（即实际复制到的位置）；
+// 复合增量列记录整个元组，按列顺序逐个比较
+type watermarkTracker struct {
+	index   []int // 各增量列在结果列中的位置，为空表示结果中缺少增量列
+	numeric []bool
+	max     []interface{}
+}
+
+// bind 按结果列定位增量列；同一跟踪器可跨多次 copyTable（chunk_by 的各区间）累计最大值
+func (w *watermarkTracker) bind(cols []string, colTypes []*sql.ColumnType, keys []string, logger *tableLogger) {
+	if w == nil {
+		return
+	}
+	w.index, w.numeric = nil, nil
+	for _, key := range keys {
+		found := -1
+		for i, c := range cols {
+			if strings.EqualFold(c, strings.TrimSpace(key)) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			logger.warnf("警告：查询结果中没有增量列 %s，无法记录增量水位\n", key)
+			w.index, w.numeric = nil, nil
+			return
+		}
+		w.index = append(w.index, found)
+		w.numeric = append(w.numeric, isNumericTypeName(colTypes[found].DatabaseTypeName()))
+	}
+}
+
+// observe 用一行扫描结果更新最大值（增量列含 NULL 的行无法比较，跳过）
+func (w *watermarkTracker) observe(row []interface{}) {
+	if w == nil || len(w.index) == 0 {
+		return
+	}
+	tuple := make([]interface{}, len(w.index))
+	for i, idx := range w.index {
+		if idx >= len(row) || row[idx] == nil {
+			return
+		}
+		v := row[idx]
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		tuple[i] = v
+	}
+	if w.max == nil || w.compare(tuple, w.max) > 0 {
+		w.max = tuple
+	}
+}
+
+// merge 合并另一个跟踪器记录的最大值（并行复制的分区单元各自记录，完成后合并到父表）
+func (w *watermarkTracker) merge(o *watermarkTracker) {
+	if w == nil || o == nil || o.max == nil {
+		return
+	}
+	if w.max == nil || o.compare(o.max, w.max) > 0 {
+		w.index, w.numeric, w.max = o.index, o.numeric, o.max
+	}
+}
+
+// compare 按列顺序比较两个增量元组
+func (w *watermarkTracker) compare(a, b []interface{}) int {
+	for i := range a {
+		if c := compareWatermark(a[i], b[i], w.numeric[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareWatermark 比较两个增量列值：时间按时间先后，数值按数值大小，其余按文本
+func compareWatermark(a, b interface{}, numeric bool) int {
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb)
+		}
+	}
+	x, y := diffValueString(a), diffValueString(b)
+	if numeric {
+		rx, okx := new(big.Rat).SetString(x)
+		ry, oky := new(big.Rat).SetString(y)
+		if okx && oky {
+			return rx.Cmp(ry)
+		}
+	}
+	return strings.Compare(x, y)
+}
+
+// 水位值的类型，用于下次运行时还原为类型化的 since
+const (
+	watermarkInt    = "int"
+	watermarkNumber = "number"
+	watermarkTime   = "time"
+	watermarkString = "string"
+)
+
+// watermarkEntry 一张表的增量水位
+type watermarkEntry struct {
+	Source         string `json:"source"` // 源库标识（驱动 + DSN 摘要，不含明文密码）
+	Target         string `json:"target"` // 目标库标识
+	Table          string `json:"table"`
+	TargetTable    string `json:"target_table"`
+	IncrementalKey string `json:"incremental_key"`
+	LastValue      string `json:"last_value,omitempty"`
+	ValueType      string `json:"value_type,omitempty"`
+	// 复合增量列（incremental_keys）的水位按列顺序保存在 LastValues/ValueTypes 中，IncrementalKey 为逗号连接的列名
+	LastValues []string `json:"last_values,omitempty"`
+	ValueTypes []string `json:"value_types,omitempty"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// syncState 状态文件内容（-state）
+type syncState struct {
+	Tables map[string]watermarkEntry `json:"tables"`
+}
+
+// dbIdentity 返回数据库连接的标识：驱动加 DSN 的摘要，避免把密码写进状态文件
+func dbIdentity(cfg dbConfig) string {
+	h := fnv.New64a()
+	h.Write([]byte(cfg.DSN))
+	return fmt.Sprintf("%s:%016x", normalizeDriver(cfg.Driver), h.Sum64())
+}
+
+// watermarkKey 状态中一张表的键：源库/目标库/源表/目标表
+func watermarkKey(source, target, table, targetTable string) string {
+	return strings.Join([]string{source, target, table, targetTable}, "|")
+}
+
+// loadSyncState 读取状态文件，不存在时返回空状态
+func loadSyncState(path string) (*syncState, error) {
+	state := &syncState{Tables: make(map[string]watermarkEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取状态文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("解析状态文件 %s 失败: %w", path, err)
+	}
+	if state.Tables == nil {
+		state.Tables = make(map[string]watermarkEntry)
+	}
+	return state, nil
+}
+
+// saveSyncState 写入状态文件（先写临时文件再改名，保证原子替换）
+func saveSyncState(path string, state *syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化状态失败: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	return nil
+}
+
+// writeFileSync 写入文件并落盘后再关闭，改名之后断电也不会留下空的状态文件
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// partialCopy 本次只复制了源表的一部分（limit、sample_percent）时返回对应的参数名，此时不能推进增量水位，
+// 否则下次从抽样或截断处继续，漏掉的行不会再复制。自定义 SELECT 忽略这两项，不算部分复制
+func partialCopy(opts copyTableOptions) string {
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		return ""
+	}
+	switch {
+	case opts.Limit > 0:
+		return fmt.Sprintf("limit=%d", opts.Limit)
+	case opts.SamplePercent > 0:
+		return fmt.Sprintf("sample_percent=%v", opts.SamplePercent)
+	}
+	return ""
+}
+
+// newWatermarkEntry 把跟踪到的最大值转换为可持久化的水位
+func newWatermarkEntry(v interface{}, numeric bool) (value, valueType string) {
+	switch x := v.(type) {
+	case time.Time:
+		// 保存墙上时间，与 since 字面量的比较方式一致
+		return x.Format("2006-01-02T15:04:05.999999999"), watermarkTime
+	case int64, int32, int, uint64, uint32:
+		return fmt.Sprint(x), watermarkInt
+	case float64, float32:
+		return diffValueString(x), watermarkNumber
+	default:
+		s := diffValueString(x)
+		if numeric {
+			if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return s, watermarkInt
+			}
+			return s, watermarkNumber
+		}
+		return s, watermarkString
+	}
+}
+
+// setValues 把跟踪到的最大值写入水位：单列写 LastValue/ValueType，复合增量列写 LastValues/ValueTypes
+func (e *watermarkEntry) setValues(w *watermarkTracker) {
+	e.LastValue, e.ValueType, e.LastValues, e.ValueTypes = "", "", nil, nil
+	if len(w.max) == 1 {
+		e.LastValue, e.ValueType = newWatermarkEntry(w.max[0], w.numeric[0])
+		return
+	}
+	for i, v := range w.max {
+		value, valueType := newWatermarkEntry(v, w.numeric[i])
+		e.LastValues = append(e.LastValues, value)
+		e.ValueTypes = append(e.ValueTypes, valueType)
+	}
+}
+
+// display 返回水位的展示形式（复合增量列为元组）
+func (e watermarkEntry) display() string {
+	if len(e.LastValues) == 0 {
+		return e.LastValue
+	}
+	return "(" + strings.Join(e.LastValues, ", ") + ")"
+}
+
+// applyWatermark 把水位还原为表的增量起点（复合增量列还原为 since 元组）
+func applyWatermark(opts *copyTableOptions, e watermarkEntry) {
+	if len(opts.IncrementalKeys) == 0 {
+		opts.Since, opts.SinceValue = sinceFromWatermark(e.LastValue, e.ValueType)
+		return
+	}
+	opts.SinceTuple = make([]interface{}, len(e.LastValues))
+	for i, value := range e.LastValues {
+		valueType := ""
+		if i < len(e.ValueTypes) {
+			valueType = e.ValueTypes[i]
+		}
+		text, typed := sinceFromWatermark(value, valueType)
+		if typed == nil {
+			typed = text
+		}
+		opts.SinceTuple[i] = typed
+	}
+}
+
+// sinceFromWatermark 把水位值还原为 since（文本形式与类型化的值）
+func sinceFromWatermark(value, valueType string) (string, interface{}) {
+	switch valueType {
+	case watermarkInt:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return value, n
+		}
+	case watermarkNumber:
+		return value, json.Number(value)
+	case watermarkTime:
+		if t, err := time.Parse("2006-01-02T15:04:05.999999999", value); err == nil {
+			return diffValueString(t), t
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,191 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 增量水位的存放位置（state_backend）
+const (
+	stateBackendFile   = "file"   // -state 指定的本地状态文件（默认）
+	stateBackendTarget = "target" // 目标库中的状态表，与每张表的最终提交在同一事务中写入
+)
+
+// defaultStateTable state_backend=target 时默认的状态表名
+const defaultStateTable = "dbtool_sync_state"
+
+// stateTupleType 状态表 value_type 中复合水位的前缀
+const stateTupleType = "tuple:"
+
+// targetStateStore 保存在目标库状态表中的增量水位：
+// 状态表与数据在同一个库，水位与最后一批数据一起提交，水位不会超前于已提交的数据
+type targetStateStore struct {
+	dst   *simpleDB
+	table string
+}
+
+// stateTableDDL 返回状态表的建表语句，主键为（源表, 目标表）
+func stateTableDDL(table, driver string) string {
+	text, ts := "VARCHAR(255)", "TIMESTAMP"
+	value := "VARCHAR(1000)"
+	switch normalizeDriver(driver) {
+	case "mysql":
+		ts = "DATETIME(6)"
+	case "sqlserver":
+		text, value, ts = "NVARCHAR(255)", "NVARCHAR(1000)", "DATETIME2"
+	case "oracle":
+		text, value = "VARCHAR2(255)", "VARCHAR2(1000)"
+	case "sqlite3":
+		text, value, ts = "TEXT", "TEXT", "TEXT"
+	}
+	return fmt.Sprintf(`CREATE TABLE %s (
+  source_table %s NOT NULL,
+  target_table %s NOT NULL,
+  incremental_key %s NOT NULL,
+  last_value %s,
+  value_type %s,
+  updated_at %s,
+  PRIMARY KEY (source_table, target_table)
+)`, quoteIdent(table, driver), text, text, text, value, text, ts)
+}
+
+// ensure 状态表不存在时创建；dry-run 时只提示不创建
+func (s *targetStateStore) ensure(ctx context.Context, dryRun bool) error {
+	exists, err := checkTableExists(ctx, s.dst, s.table)
+	if err != nil {
+		return fmt.Errorf("检查状态表 %s 是否存在失败: %w", s.table, err)
+	}
+	if exists {
+		return nil
+	}
+	if dryRun {
+		s.dst.cfg.log.infof("[DRY-RUN] 状态表 %s 不存在，实际运行时将自动创建\n", s.table)
+		return nil
+	}
+	ddl := stateTableDDL(s.table, s.dst.cfg.Driver)
+	s.dst.cfg.log.infof("创建状态表 %s\n", s.table)
+	if _, err := s.dst.db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("创建状态表 %s 失败: %w", s.table, err)
+	}
+	return nil
+}
+
+// load 读取状态表中的全部水位；状态表不存在时返回空状态
+func (s *targetStateStore) load(ctx context.Context) (*syncState, error) {
+	state := &syncState{Tables: make(map[string]watermarkEntry)}
+	exists, err := checkTableExists(ctx, s.dst, s.table)
+	if err != nil {
+		return nil, fmt.Errorf("检查状态表 %s 是否存在失败: %w", s.table, err)
+	}
+	if !exists {
+		return state, nil
+	}
+	query := fmt.Sprintf("SELECT source_table, target_table, incremental_key, last_value, value_type, updated_at FROM %s",
+		quoteIdent(s.table, s.dst.cfg.Driver))
+	rows, err := s.dst.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("读取状态表 %s 失败: %w", s.table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e watermarkEntry
+		var lastValue, valueType sql.NullString
+		var updatedAt interface{}
+		if err := rows.Scan(&e.Table, &e.TargetTable, &e.IncrementalKey, &lastValue, &valueType, &updatedAt); err != nil {
+			return nil, fmt.Errorf("读取状态表 %s 失败: %w", s.table, err)
+		}
+		e.LastValue, e.ValueType = lastValue.String, valueType.String
+		if strings.HasPrefix(e.ValueType, stateTupleType) {
+			// 复合增量列：last_value 为 JSON 数组，value_type 为 tuple:类型1,类型2
+			if err := json.Unmarshal([]byte(e.LastValue), &e.LastValues); err != nil {
+				return nil, fmt.Errorf("状态表 %s 中 %s 的复合水位无法解析: %w", s.table, e.Table, err)
+			}
+			e.ValueTypes = strings.Split(strings.TrimPrefix(e.ValueType, stateTupleType), ",")
+			e.LastValue, e.ValueType = "", ""
+		}
+		if updatedAt != nil {
+			e.UpdatedAt = diffValueString(updatedAt)
+		}
+		state.Tables[watermarkKey("", "", e.Table, e.TargetTable)] = e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取状态表 %s 失败: %w", s.table, err)
+	}
+	return state, nil
+}
+
+// upsertSQL 返回按（源表, 目标表）写入或更新一条水位的方言语句，参数依次为
+// source_table, target_table, incremental_key, last_value, value_type, updated_at
+func (s *targetStateStore) upsertSQL() string {
+	driver := s.dst.cfg.Driver
+	table := quoteIdent(s.table, driver)
+	p := make([]string, 6)
+	for i := range p {
+		p[i] = placeholder(i+1, driver)
+	}
+	values := strings.Join(p, ", ")
+	const cols = "source_table, target_table, incremental_key, last_value, value_type, updated_at"
+	switch normalizeDriver(driver) {
+	case "mysql":
+		return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)
+ON DUPLICATE KEY UPDATE incremental_key = VALUES(incremental_key), last_value = VALUES(last_value),
+  value_type = VALUES(value_type), updated_at = VALUES(updated_at)`, table, cols, values)
+	case "sqlserver":
+		return fmt.Sprintf(`MERGE INTO %s WITH (HOLDLOCK) AS d
+USING (SELECT %s AS source_table, %s AS target_table, %s AS incremental_key, %s AS last_value, %s AS value_type, %s AS updated_at) AS s
+ON d.source_table = s.source_table AND d.target_table = s.target_table
+WHEN MATCHED THEN UPDATE SET incremental_key = s.incremental_key, last_value = s.last_value, value_type = s.value_type, updated_at = s.updated_at
+WHEN NOT MATCHED THEN INSERT (%s) VALUES (s.source_table, s.target_table, s.incremental_key, s.last_value, s.value_type, s.updated_at);`,
+			table, p[0], p[1], p[2], p[3], p[4], p[5], cols)
+	case "oracle":
+		return fmt.Sprintf(`MERGE INTO %s d
+USING (SELECT %s AS source_table, %s AS target_table, %s AS incremental_key, %s AS last_value, %s AS value_type, %s AS updated_at FROM dual) s
+ON (d.source_table = s.source_table AND d.target_table = s.target_table)
+WHEN MATCHED THEN UPDATE SET d.incremental_key = s.incremental_key, d.last_value = s.last_value, d.value_type = s.value_type, d.updated_at = s.updated_at
+WHEN NOT MATCHED THEN INSERT (%s) VALUES (s.source_table, s.target_table, s.incremental_key, s.last_value, s.value_type, s.updated_at)`,
+			table, p[0], p[1], p[2], p[3], p[4], p[5], cols)
+	default:
+		if s.dst.cockroach {
+			// CockroachDB：冲突列为主键且更新全部其它列时，UPSERT 省去 ON CONFLICT 的额外读取
+			return fmt.Sprintf("UPSERT INTO %s (%s) VALUES (%s)", table, cols, values)
+		}
+		// postgres / sqlite3
+		return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)
+ON CONFLICT (source_table, target_table) DO UPDATE SET incremental_key = EXCLUDED.incremental_key,
+  last_value = EXCLUDED.last_value, value_type = EXCLUDED.value_type, updated_at = EXCLUDED.updated_at`, table, cols, values)
+	}
+}
+
+// upsert 写入一条水位；exec 为数据的最终提交事务时，水位与数据一起生效
+func (s *targetStateStore) upsert(ctx context.Context, exec interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, e watermarkEntry) error {
+	var updatedAt interface{} = time.Now()
+	if normalizeDriver(s.dst.cfg.Driver) == "sqlite3" {
+		updatedAt = time.Now().Format("2006-01-02 15:04:05")
+	}
+	lastValue, valueType := e.LastValue, e.ValueType
+	if len(e.LastValues) > 0 {
+		data, err := json.Marshal(e.LastValues)
+		if err != nil {
+			return fmt.Errorf("序列化复合水位失败: %w", err)
+		}
+		lastValue, valueType = string(data), stateTupleType+strings.Join(e.ValueTypes, ",")
+	}
+	if _, err := exec.ExecContext(ctx, s.upsertSQL(), e.Table, e.TargetTable, e.IncrementalKey, lastValue, valueType, updatedAt); err != nil {
+		return fmt.Errorf("写入状态表 %s 失败: %w", s.table, err)
+	}
+	return nil
+}
+
+// writeStateInTx 在最终提交前把当前水位写入同一事务（未配置 state_backend=target、没有水位或只复制了部分行时不做任何事）
+func writeStateInTx(ctx context.Context, tx batchTx, opts copyTableOptions) error {
+	if opts.StateWriter == nil || opts.DryRun || partialCopy(opts) != "" {
+		return nil
+	}
+	return opts.StateWriter(ctx, tx)
+}
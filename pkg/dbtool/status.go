@@ -0,0 +1,374 @@
+package dbtool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultStatusInterval -status-file 默认的刷新间隔
+const defaultStatusInterval = 5 * time.Second
+
+// tableProgress 单张表的复制进度：复制循环中每行原子加一，指标与 HTTP API 按需读取，不影响吞吐；
+// 表开始、批次提交、行级错误与表结束时同步通知 listeners（Callbacks、-status-file）
+type tableProgress struct {
+	rows      atomic.Int64 // 已写入（dry-run 时为已读取）的行数
+	expected  atomic.Int64 // 计划复制的行数（源表窗口记录数），-1 表示未知
+	batches   atomic.Int64 // 已提交的批次（事务）数
+	rowErrors atomic.Int64 // 行级错误（值转换、写入失败）数
+	started   time.Time
+
+	table, target, partitionOf string
+	listeners                  []copyListener
+	begun                      atomic.Bool
+	reported                   atomic.Int64 // 最近一次通知的行数，行数未变时不重复通知
+}
+
+// newTableProgress 创建一张表的进度计数；listeners 为空时只计数
+func newTableProgress(opts copyTableOptions, listeners []copyListener) *tableProgress {
+	p := &tableProgress{started: time.Now(), listeners: listeners}
+	p.table, p.partitionOf = opts.Table, opts.PartitionOf
+	p.target = firstNonEmpty(opts.TargetTable, firstNonEmpty(opts.PartitionOf, opts.Table))
+	p.expected.Store(-1)
+	return p
+}
+
+// begin 开始复制：第一次调用时通知 tableStart（chunk_by 的后续区间不再通知），estimatedRows < 0 表示未知
+func (p *tableProgress) begin(estimatedRows int64) {
+	if p == nil || !p.begun.CompareAndSwap(false, true) {
+		return
+	}
+	for _, l := range p.listeners {
+		l.tableStart(p.table, estimatedRows)
+	}
+}
+
+// reportRows 通知已写入的行数（批次提交后，或 Dry-Run、COPY 等未逐批提交时按行数间隔）
+func (p *tableProgress) reportRows() {
+	if p == nil {
+		return
+	}
+	rows := p.rows.Load()
+	if p.reported.Swap(rows) == rows && rows > 0 {
+		return
+	}
+	for _, l := range p.listeners {
+		l.batchCommitted(p.table, rows)
+	}
+}
+
+// finish 表完成或失败：未开始时先补发 tableStart，再通知 tableDone
+func (p *tableProgress) finish(detail *tableVerificationResult, err error) {
+	if p == nil {
+		return
+	}
+	p.begin(-1)
+	// 失败时按最近一次通知（已提交）的行数报告
+	finishTable(p.listeners, newTableResult(p.table, p.target, p.partitionOf, p.started, p.reported.Load(), detail, err), detail)
+}
+
+// add 累加已复制的行数（nil 时不做任何事）
+func (p *tableProgress) add(n int64) {
+	if p != nil {
+		p.rows.Add(n)
+	}
+}
+
+// batchCommitted 记录一次批次提交并通知已写入的行数（nil 时不做任何事）
+func (p *tableProgress) batchCommitted() {
+	if p != nil {
+		p.batches.Add(1)
+		p.reportRows()
+	}
+}
+
+// rowError 记录一次行级错误，row 为出错行的标识（nil 时不做任何事）
+func (p *tableProgress) rowError(row RowRef, err error) {
+	if p == nil {
+		return
+	}
+	p.rowErrors.Add(1)
+	for _, l := range p.listeners {
+		l.rowError(p.table, row, err)
+	}
+}
+
+// addExpected 累加计划复制的行数（chunk_by 每个区间各统计一次）；n < 0 表示无法统计
+func (p *tableProgress) addExpected(n int64) {
+	if p == nil || n < 0 {
+		return
+	}
+	if !p.expected.CompareAndSwap(-1, n) {
+		p.expected.Add(n)
+	}
+}
+
+// statusCurrent 状态输出中正在复制的表
+type statusCurrent struct {
+	Table      string    `json:"table"`
+	StartedAt  time.Time `json:"started_at"`
+	RowsCopied int64     `json:"rows_copied"`
+	SourceRows *int64    `json:"source_rows,omitempty"` // 计划复制的行数，未知时省略
+	Rate       float64   `json:"rows_per_second"`
+	ETASeconds *float64  `json:"eta_seconds,omitempty"` // 按当前速度估算的剩余秒数，无法估算时省略
+}
+
+// statusCompleted 状态输出中已结束的表
+type statusCompleted struct {
+	Table           string                   `json:"table"`
+	Status          string                   `json:"status"` // done / failed
+	DurationSeconds float64                  `json:"duration_seconds"`
+	RowsCopied      int64                    `json:"rows_copied"`
+	Result          *tableVerificationResult `json:"result,omitempty"`
+	Error           string                   `json:"error,omitempty"`
+}
+
+// statusPayload 状态文件与 GET /status 的内容
+type statusPayload struct {
+	State       string            `json:"state"` // idle / running / succeeded / failed / stopped
+	UpdatedAt   time.Time         `json:"updated_at"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"` // 当前（或最近一轮）同步的开始时间
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+	TablesTotal int               `json:"tables_total"`
+	TablesDone  int               `json:"tables_done"`
+	RowsCopied  int64             `json:"rows_copied"`
+	Rate        float64           `json:"rows_per_second"`
+	RateLimit   int64             `json:"rate_limit,omitempty"` // 当前生效的全局限速（行/秒），不限速时省略
+	Buffered    int64             `json:"buffered_bytes"`       // 缓存中尚未提交的行的估算字节数（配置了 max_memory 时统计）
+	MaxMemory   int64             `json:"max_memory,omitempty"` // max_memory，未配置时省略
+	Current     []statusCurrent   `json:"current"`
+	Completed   []statusCompleted `json:"completed"`
+	NextRun     *time.Time        `json:"next_run,omitempty"` // schedule 模式下次运行时间
+	Error       string            `json:"error,omitempty"`
+}
+
+// statusReporter 汇总同步进度供 -status-file 与 GET /status 输出：
+// 基于与嵌入方 Callbacks 相同的复制事件（copyListener）即时记录（耗时短于刷新间隔的表也不会遗漏），文件按间隔整体重写
+type statusReporter struct {
+	path     string
+	interval time.Duration
+
+	mu          sync.Mutex
+	state       string
+	startedAt   *time.Time
+	finishedAt  *time.Time
+	tablesTotal int
+	tablesDone  int
+	running     []*statusRunning
+	completed   []statusCompleted
+	rowsDone    int64 // 已结束的表复制的行数
+	nextRun     *time.Time
+	lastErr     string
+	limiter     *rateLimiter  // 全局限速器，状态中显示当前生效的限速
+	memory      *memoryBudget // 全局内存预算，状态中显示缓存中的字节数
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// statusRunning 正在复制的表及其进度（最近一次批次事件报告的行数）
+type statusRunning struct {
+	table    string
+	started  time.Time
+	rows     int64
+	expected int64 // -1 表示未知
+}
+
+// newStatusReporter 创建状态汇总；path 非空时按 interval 重写状态文件
+func newStatusReporter(path string, interval time.Duration) *statusReporter {
+	if interval <= 0 {
+		interval = defaultStatusInterval
+	}
+	return &statusReporter{path: path, interval: interval, state: "idle", completed: []statusCompleted{}, done: make(chan struct{})}
+}
+
+// start 启动后台刷新（未配置状态文件时不启动）
+func (s *statusReporter) start() {
+	if s.path == "" {
+		return
+	}
+	s.flush()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.flush()
+			}
+		}
+	}()
+}
+
+// close 停止后台刷新并写入最终状态
+func (s *statusReporter) close() {
+	close(s.done)
+	s.wg.Wait()
+	s.flush()
+}
+
+// passStarted 实现 passLifecycle：开始一轮同步，清空上一轮的表记录
+func (s *statusReporter) passStarted(tables int) {
+	s.mu.Lock()
+	now := time.Now()
+	s.state, s.startedAt, s.finishedAt = "running", &now, nil
+	s.tablesTotal, s.tablesDone, s.running, s.completed, s.rowsDone, s.lastErr = tables, 0, nil, []statusCompleted{}, 0, ""
+	s.mu.Unlock()
+	s.flush()
+}
+
+// passFinished 实现 passLifecycle：一轮同步结束
+func (s *statusReporter) passFinished(err error) {
+	s.mu.Lock()
+	now := time.Now()
+	s.finishedAt = &now
+	switch {
+	case err == nil:
+		s.state = "succeeded"
+	case errors.Is(err, errSyncStopped):
+		s.state = "stopped"
+	default:
+		s.state, s.lastErr = "failed", errText(err)
+	}
+	s.mu.Unlock()
+	s.flush()
+}
+
+// setNextRun 记录 schedule 的下次运行时间
+func (s *statusReporter) setNextRun(t time.Time) {
+	s.mu.Lock()
+	s.nextRun = &t
+	s.mu.Unlock()
+	s.flush()
+}
+
+// tableStart 实现 copyListener
+func (s *statusReporter) tableStart(table string, estimatedRows int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = append(s.running, &statusRunning{table: table, started: time.Now(), expected: estimatedRows})
+}
+
+// batchCommitted 实现 copyListener
+func (s *statusReporter) batchCommitted(table string, rowsSoFar int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.running {
+		if r.table == table {
+			r.rows = rowsSoFar
+		}
+	}
+}
+
+// rowError 实现 copyListener：行级错误随表的失败一起记录
+func (s *statusReporter) rowError(table string, row RowRef, err error) {}
+
+// tableDone 实现 copyListener
+func (s *statusReporter) tableDone(result TableResult, detail *tableVerificationResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := statusCompleted{Table: result.Table, Status: tableDone, RowsCopied: result.RowsCopied, DurationSeconds: result.Duration.Seconds()}
+	for i, r := range s.running {
+		if r.table == result.Table {
+			// 分区父表的汇总没有对应的开始事件，不计入已完成的表数
+			s.rowsDone += c.RowsCopied
+			s.tablesDone++
+			s.running = append(s.running[:i], s.running[i+1:]...)
+			break
+		}
+	}
+	if detail != nil {
+		res := *detail
+		c.Result = &res
+	}
+	if result.Err != nil {
+		c.Status, c.Error = tableFailed, errText(result.Err)
+	}
+	s.completed = append(s.completed, c)
+}
+
+// snapshot 返回当前状态
+func (s *statusReporter) snapshot() statusPayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	p := statusPayload{
+		State:       s.state,
+		UpdatedAt:   now,
+		StartedAt:   s.startedAt,
+		FinishedAt:  s.finishedAt,
+		TablesTotal: s.tablesTotal,
+		TablesDone:  s.tablesDone,
+		RowsCopied:  s.rowsDone,
+		Current:     []statusCurrent{},
+		Completed:   s.completed,
+		NextRun:     s.nextRun,
+		Error:       s.lastErr,
+		RateLimit:   s.limiter.current(),
+		Buffered:    s.memory.buffered(),
+	}
+	if s.memory != nil {
+		p.MaxMemory = s.memory.limit
+	}
+	for _, r := range s.running {
+		rows := r.rows
+		elapsed := now.Sub(r.started).Seconds()
+		cur := statusCurrent{Table: r.table, StartedAt: r.started, RowsCopied: rows}
+		if elapsed > 0 {
+			cur.Rate = float64(rows) / elapsed
+		}
+		if expected := r.expected; expected >= 0 {
+			cur.SourceRows = &expected
+			if cur.Rate > 0 && expected >= rows {
+				eta := float64(expected-rows) / cur.Rate
+				cur.ETASeconds = &eta
+			}
+		}
+		p.RowsCopied += rows
+		p.Current = append(p.Current, cur)
+	}
+	if s.startedAt != nil {
+		end := now
+		if s.finishedAt != nil {
+			end = *s.finishedAt
+		}
+		if elapsed := end.Sub(*s.startedAt).Seconds(); elapsed > 0 {
+			p.Rate = float64(p.RowsCopied) / elapsed
+		}
+	}
+	return p
+}
+
+// flush 原子重写状态文件（先写临时文件再改名）；失败只打印警告
+func (s *statusReporter) flush() {
+	if s.path == "" {
+		return
+	}
+	if err := writeStatusFile(s.path, s.snapshot()); err != nil {
+		warnf("警告：%v\n", err)
+	}
+}
+
+// writeStatusFile 写入状态文件（先写临时文件再改名，读取方不会读到写了一半的内容）
+func writeStatusFile(path string, p statusPayload) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化状态失败: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mysqlTableOptions MySQL 自动建表时追加的表选项
+type mysqlTableOptions struct {
+	Engine    string `json:"engine,omitempty"`     // 存储引擎，如 InnoDB
+	Charset   string `json:"charset,omitempty"`    // 默认字符集，如 utf8mb4
+	Collation string `json:"collation,omitempty"`  // 默认排序规则，如 utf8mb4_unicode_ci
+	RowFormat string `json:"row_format,omitempty"` // 行格式，如 DYNAMIC
+}
+
+// defaultMySQLTableOptions 未配置且源库为 UTF-8 时使用的默认表选项
+var defaultMySQLTableOptions = mysqlTableOptions{
+	Engine:    "InnoDB",
+	Charset:   "utf8mb4",
+	Collation: "utf8mb4_unicode_ci",
+}
+
+// mergeMySQLTableOptions 逐项合并表选项，override 中非空的项覆盖 base
+func mergeMySQLTableOptions(base, override *mysqlTableOptions) *mysqlTableOptions {
+	if base == nil && override == nil {
+		return nil
+	}
+	out := mysqlTableOptions{}
+	if base != nil {
+		out = *base
+	}
+	if override != nil {
+		if override.Engine != "" {
+			out.Engine = override.Engine
+		}
+		if override.Charset != "" {
+			out.Charset = override.Charset
+		}
+		if override.Collation != "" {
+			out.Collation = override.Collation
+		}
+		if override.RowFormat != "" {
+			out.RowFormat = override.RowFormat
+		}
+	}
+	return &out
+}
+
+// clause 生成追加在 CREATE TABLE (...) 之后的表选项子句
+func (o *mysqlTableOptions) clause() string {
+	if o == nil {
+		return ""
+	}
+	var parts []string
+	if o.Engine != "" {
+		parts = append(parts, "ENGINE="+o.Engine)
+	}
+	if o.Charset != "" {
+		parts = append(parts, "DEFAULT CHARSET="+o.Charset)
+	}
+	if o.Collation != "" {
+		parts = append(parts, "COLLATE="+o.Collation)
+	}
+	if o.RowFormat != "" {
+		parts = append(parts, "ROW_FORMAT="+o.RowFormat)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, " ")
+}
+
+// resolveMySQLTableOptions 确定自动建表使用的 MySQL 表选项：
+// 目标非 MySQL 时忽略（配置了则警告）；未配置且源库为 UTF-8 时使用 InnoDB + utf8mb4 默认值
+func resolveMySQLTableOptions(ctx context.Context, src *simpleDB, dstDriver string, configured *mysqlTableOptions, logger *tableLogger) *mysqlTableOptions {
+	if normalizeDriver(dstDriver) != "mysql" {
+		if configured != nil {
+			logger.warnf("警告：mysql_table_options 仅对 MySQL 目标库生效，已忽略\n")
+		}
+		return nil
+	}
+	if configured != nil {
+		return configured
+	}
+	if sourceIsUTF8(ctx, src) {
+		opts := defaultMySQLTableOptions
+		return &opts
+	}
+	return nil
+}
+
+// sourceIsUTF8 判断源库文本是否为 UTF-8：配置了 charset 时以配置为准，否则查询源库的库级编码
+func sourceIsUTF8(ctx context.Context, src *simpleDB) bool {
+	if strings.TrimSpace(src.cfg.Charset) != "" {
+		enc, err := lookupCharset(src.cfg.Charset)
+		return err == nil && enc == nil
+	}
+
+	var query string
+	switch normalizeDriver(src.cfg.Driver) {
+	case "mysql":
+		query = "SELECT @@character_set_database"
+	case "postgres", "postgresql":
+		query = "SHOW server_encoding"
+	case "oracle":
+		query = "SELECT value FROM nls_database_parameters WHERE parameter = 'NLS_CHARACTERSET'"
+	default:
+		// SQLite 文本总是 UTF-8；SQL Server 的 NVARCHAR 读出后即为 UTF-8
+		return true
+	}
+	var charset string
+	if err := src.db.QueryRowContext(ctx, query).Scan(&charset); err != nil {
+		return false
+	}
+	charset = strings.ToUpper(charset)
+	return strings.Contains(charset, "UTF8") || strings.Contains(charset, "UTF-8")
+}
+
+// isPostgresDriver 判断驱动是否为 Postgres
+func isPostgresDriver(driver string) bool {
+	d := normalizeDriver(driver)
+	return d == "postgres" || d == "postgresql"
+}
+
+// setTableLogged 复制完成后把 UNLOGGED 表转为普通表（会重写整张表并写入 WAL）；
+// 只转换本次运行以 UNLOGGED 创建的表（unlogged），已存在的目标表保持原样
+func setTableLogged(ctx context.Context, dst *simpleDB, table string, unlogged bool, opts copyTableOptions) error {
+	if !isPostgresDriver(dst.cfg.Driver) {
+		opts.Log.warnf("警告：postgres_set_logged 仅对 Postgres 目标库生效，已忽略\n")
+		return nil
+	}
+	if dst.cockroach {
+		opts.Log.warnf("警告：CockroachDB 不支持 ALTER TABLE ... SET LOGGED，postgres_set_logged 已忽略\n")
+		return nil
+	}
+	if !unlogged {
+		opts.Log.infof("表 %s 不是本次以 UNLOGGED 创建的，跳过 SET LOGGED\n", table)
+		return nil
+	}
+	stmt := fmt.Sprintf("ALTER TABLE %s SET LOGGED", quoteIdent(table, dst.cfg.Driver))
+	if opts.DryRun {
+		opts.Log.infof("Dry-Run 模式，将执行: %s\n", stmt)
+		return nil
+	}
+	start := time.Now()
+	if _, err := dst.db.ExecContext(ctx, stmt); err != nil {
+		return err
+	}
+	opts.Log.infof("表 %s 已转为 LOGGED，耗时 %.2f 秒\n", table, time.Since(start).Seconds())
+	return nil
+}
@@ -0,0 +1,244 @@
+package dbtool
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// zonedTimeLayouts 源库返回带时区偏移的文本时尝试的格式（RFC3339 之外），按文本自身的偏移解析：
+// Postgres timestamptz（+08 / +05:30）、SQL Server datetimeoffset（+08:00 前有空格）、Go time.Time 的默认格式
+var zonedTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02T15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999 Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+}
+
+// naiveTimeLayouts 源库返回文本形式时间值时尝试的格式（不含时区）
+var naiveTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// isTimeType 判断数据库类型名是否为日期/时间戳类型（不含纯时间 TIME 与 INTERVAL）
+func isTimeType(dbType string) bool {
+	dbType = strings.ToUpper(strings.TrimSpace(dbType))
+	if dbType == "TIME" || dbType == "TIMETZ" || strings.Contains(dbType, "INTERVAL") {
+		return false
+	}
+	return strings.Contains(dbType, "DATE") || strings.Contains(dbType, "TIME")
+}
+
+// isZonedTimeType 判断时间类型是否自带时区（其值表示确定的时刻，不需要按源时区重新解释）
+func isZonedTimeType(dbType string) bool {
+	dbType = strings.ToUpper(dbType)
+	return strings.Contains(dbType, "TZ") || strings.Contains(dbType, "TIME ZONE") ||
+		strings.Contains(dbType, "DATETIMEOFFSET")
+}
+
+// timeNormalizer 将源库时间值统一解析为 time.Time，并按配置输出本地时间或 UTC
+type timeNormalizer struct {
+	loc *time.Location // 源库无时区时间值所在的时区
+	utc bool           // true=写入 UTC，false=写入源时区的本地时间
+}
+
+// newTimeNormalizer 根据时区名与输出方式构建时间规范化器；tz 为空时返回 nil（不做处理）
+func newTimeNormalizer(tz, output string) (*timeNormalizer, error) {
+	tz = strings.TrimSpace(tz)
+	output = strings.ToLower(strings.TrimSpace(output))
+	if tz == "" {
+		if output != "" {
+			return nil, fmt.Errorf("timestamp_output 需要配合 timezone/source_timezone 使用")
+		}
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("加载时区 %q 失败: %w", tz, err)
+	}
+	n := &timeNormalizer{loc: loc}
+	switch output {
+	case "", "local":
+	case "utc":
+		n.utc = true
+	default:
+		return nil, fmt.Errorf("timestamp_output 仅支持 local 或 utc，当前为 %q", output)
+	}
+	return n, nil
+}
+
+// normalize 转换单个时间值；ok=false 表示无法识别，调用方应保持原值
+// - string/[]byte：按源时区解析（带偏移量的文本按其自身偏移解析，见 zonedTimeLayouts）
+// - time.Time：无时区类型按其墙上时间在源时区重新解释；带时区类型保持时刻不变
+func (n *timeNormalizer) normalize(v interface{}, zoned bool) (time.Time, bool) {
+	var t time.Time
+	switch x := v.(type) {
+	case time.Time:
+		if zoned {
+			t = x
+		} else {
+			t = time.Date(x.Year(), x.Month(), x.Day(), x.Hour(), x.Minute(), x.Second(), x.Nanosecond(), n.loc)
+		}
+	case []byte:
+		parsed, ok := n.parse(string(x))
+		if !ok {
+			return time.Time{}, false
+		}
+		t = parsed
+	case string:
+		parsed, ok := n.parse(x)
+		if !ok {
+			return time.Time{}, false
+		}
+		t = parsed
+	default:
+		return time.Time{}, false
+	}
+	if n.utc {
+		return t.UTC(), true
+	}
+	return t.In(n.loc), true
+}
+
+func (n *timeNormalizer) parse(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	for _, layout := range zonedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	for _, layout := range naiveTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, n.loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// 零值日期处理策略（zero_date_policy）
+const (
+	zeroDateNull    = "null"     // 替换为 NULL
+	zeroDateMinDate = "min_date" // 替换为目标库可接受的最小日期
+	zeroDateCustom  = "custom"   // 替换为 zero_date_value 指定的字面量
+	zeroDateError   = "error"    // 报错并中止该表
+)
+
+// validateZeroDatePolicy 校验零值日期策略配置
+func validateZeroDatePolicy(policy, value string) error {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "", zeroDateNull, zeroDateMinDate, zeroDateError:
+		return nil
+	case zeroDateCustom:
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("zero_date_policy 为 custom 时必须配置 zero_date_value")
+		}
+		return nil
+	default:
+		return fmt.Errorf("zero_date_policy 仅支持 null/min_date/custom/error，当前为 %q", policy)
+	}
+}
+
+// isZeroDate 判断值是否为 MySQL 零值日期：'0000-00-00'（文本）或 parseTime 开启时驱动返回的零值 time.Time
+func isZeroDate(v interface{}) bool {
+	switch x := v.(type) {
+	case string:
+		return strings.HasPrefix(strings.TrimSpace(x), "0000-00-00")
+	case []byte:
+		return strings.HasPrefix(strings.TrimSpace(string(x)), "0000-00-00")
+	case time.Time:
+		return x.IsZero()
+	}
+	return false
+}
+
+// minDateFor 返回目标库 DATE/DATETIME 可接受的最小日期
+func minDateFor(driver string) time.Time {
+	switch normalizeDriver(driver) {
+	case "mysql":
+		return time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)
+	case "sqlserver":
+		// datetime 下限为 1753-01-01（datetime2 可更早，但此值对两者都合法）
+		return time.Date(1753, 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// 超出目标列日期范围时的处理策略（date_range_policy，仅目标库为 sqlserver 时生效）
+const (
+	dateRangeClamp    = "clamp"    // 截断到边界值
+	dateRangeNull     = "null"     // 替换为 NULL
+	dateRangeSentinel = "sentinel" // 替换为 date_range_sentinel 指定的值
+	dateRangeError    = "error"    // 报错（默认），包含行与列信息
+)
+
+// validateDateRangePolicy 校验日期范围策略配置
+func validateDateRangePolicy(policy, sentinel string) error {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "", dateRangeClamp, dateRangeNull, dateRangeError:
+		return nil
+	case dateRangeSentinel:
+		if strings.TrimSpace(sentinel) == "" {
+			return fmt.Errorf("date_range_policy 为 sentinel 时必须配置 date_range_sentinel")
+		}
+		return nil
+	default:
+		return fmt.Errorf("date_range_policy 仅支持 clamp/null/sentinel/error，当前为 %q", policy)
+	}
+}
+
+// sqlServerDateRange 返回 SQL Server 目标列类型可表示的日期范围；ok=false 表示无需检查
+// DATE/DATETIME2/DATETIMEOFFSET 覆盖 0001-9999，与 Go 可表示的范围基本一致，不做检查
+func sqlServerDateRange(dataType string) (min, max time.Time, ok bool) {
+	switch strings.ToUpper(strings.TrimSpace(dataType)) {
+	case "DATETIME":
+		return time.Date(1753, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(9999, 12, 31, 23, 59, 59, 997000000, time.UTC), true
+	case "SMALLDATETIME":
+		return time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2079, 6, 6, 23, 59, 0, 0, time.UTC), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// asTime 将 time.Time 或文本形式的时间值解析为 time.Time（文本按 UTC 墙上时间解析，仅用于范围比较）
+func asTime(v interface{}) (time.Time, bool) {
+	var s string
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	default:
+		return time.Time{}, false
+	}
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	for _, layout := range zonedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	for _, layout := range naiveTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// wallClock 返回 t 的墙上时间（按 UTC 表示），用于与目标列范围比较
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
@@ -0,0 +1,403 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// sqlTracer -trace-sql：记录发往源库、目标库的每条语句及耗时。
+// 只在开启时包装驱动，关闭时连接直接使用原驱动，没有额外开销
+type sqlTracer struct {
+	// maxValueLen 参数值最多显示的字符数；0 表示不显示参数值，只显示个数
+	maxValueLen int
+}
+
+// activeSQLTracer 非 nil 时 newSimpleDB 打开的连接都会记录 SQL
+var activeSQLTracer *sqlTracer
+
+// openTracedDB 以记录 SQL 的方式打开数据库：取得驱动的 Connector 后包装
+func openTracedDB(cfg dbConfig, t *sqlTracer) (*sql.DB, error) {
+	inner, err := openConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(&traceConnector{inner: inner, label: traceLabel(cfg), tracer: t}), nil
+}
+
+// openConnector 取得驱动按 DSN 打开连接的 Connector，供包装使用
+func openConnector(cfg dbConfig) (driver.Connector, error) {
+	probe, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	drv := probe.Driver()
+	_ = probe.Close()
+
+	if dc, ok := drv.(driver.DriverContext); ok {
+		return dc.OpenConnector(cfg.DSN)
+	}
+	return dsnConnector{dsn: cfg.DSN, drv: drv}, nil
+}
+
+// traceLabel 日志中连接的标识：角色/驱动
+func traceLabel(cfg dbConfig) string {
+	if cfg.role != "" {
+		return cfg.role + "/" + cfg.Driver
+	}
+	return cfg.Driver
+}
+
+// dsnConnector 驱动未实现 DriverContext 时按 DSN 打开连接
+type dsnConnector struct {
+	dsn string
+	drv driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.drv.Open(c.dsn) }
+func (c dsnConnector) Driver() driver.Driver                        { return c.drv }
+
+// traceConnector 为每个新连接包装 traceConn
+type traceConnector struct {
+	inner  driver.Connector
+	label  string
+	tracer *sqlTracer
+}
+
+func (c *traceConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.inner.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &traceConn{Conn: conn, label: c.label, tracer: c.tracer}, nil
+}
+
+func (c *traceConnector) Driver() driver.Driver { return c.inner.Driver() }
+
+// traceConn 记录经过该连接的语句。同一连接上连续重复执行的同一语句（逐行 INSERT、COPY 行）
+// 只记录第一次，之后在下一条不同的语句（通常是 COMMIT）之前汇总次数与耗时
+type traceConn struct {
+	driver.Conn
+	label  string
+	tracer *sqlTracer
+
+	mu        sync.Mutex
+	last      string
+	repeats   int
+	repeatDur time.Duration
+}
+
+// trace 记录一条语句
+func (c *traceConn) trace(query string, args []driver.NamedValue, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil && query == c.last {
+		c.repeats++
+		c.repeatDur += d
+		return
+	}
+	c.flushLocked()
+	c.last = query
+	if err != nil {
+		// 出错的语句总是单独记录
+		c.last = ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[SQL %s] (%s) %s", c.label, d.Round(time.Microsecond), strings.TrimSpace(query))
+	if len(args) > 0 {
+		b.WriteString(" ")
+		b.WriteString(c.tracer.formatArgs(args))
+	}
+	if err != nil {
+		fmt.Fprintf(&b, " 出错: %v", err)
+	}
+	infof("%s\n", b.String())
+}
+
+// flushLocked 输出上一条语句的重复次数（调用方持有 c.mu）
+func (c *traceConn) flushLocked() {
+	if c.repeats > 0 {
+		infof("[SQL %s] 上一语句又执行了 %d 次，共耗时 %s\n", c.label, c.repeats, c.repeatDur.Round(time.Microsecond))
+	}
+	c.repeats, c.repeatDur = 0, 0
+}
+
+// formatArgs 格式化参数：默认只显示个数；-trace-sql-values 大于 0 时显示截断后的值
+func (t *sqlTracer) formatArgs(args []driver.NamedValue) string {
+	if t.maxValueLen <= 0 {
+		return fmt.Sprintf("[%d 个参数，值已省略]", len(args))
+	}
+	parts := make([]string, len(args))
+	for i, a := range args {
+		switch v := a.Value.(type) {
+		case nil:
+			parts[i] = "NULL"
+		case []byte:
+			parts[i] = fmt.Sprintf("<%d 字节>", len(v))
+		case string:
+			parts[i] = "'" + truncateRunes(v, t.maxValueLen) + "'"
+		case time.Time:
+			parts[i] = v.Format(time.RFC3339Nano)
+		default:
+			parts[i] = truncateRunes(fmt.Sprint(v), t.maxValueLen)
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// truncateRunes 按字符截断，超出时追加 …
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n]) + "…"
+}
+
+func (c *traceConn) Close() error {
+	c.mu.Lock()
+	c.flushLocked()
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+func (c *traceConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		c.trace(query, nil, 0, err)
+		return nil, err
+	}
+	return &traceStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *traceConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *traceConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		// 驱动改走 Prepare，由 traceStmt 记录
+		return nil, err
+	}
+	c.trace(query, args, time.Since(start), err)
+	return res, err
+}
+
+func (c *traceConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	c.trace(query, args, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	return &traceRows{Rows: rows, conn: c, start: start}, nil
+}
+
+func (c *traceConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var tx driver.Tx
+	var err error
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin() // 驱动未实现 ConnBeginTx 时的回退
+	}
+	c.trace("BEGIN", nil, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	return &traceTx{Tx: tx, conn: c}, nil
+}
+
+func (c *traceConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *traceConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *traceConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue 参数类型检查交给原驱动；驱动未实现时使用 database/sql 的默认转换
+func (c *traceConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// traceTx 记录 COMMIT / ROLLBACK
+type traceTx struct {
+	driver.Tx
+	conn *traceConn
+}
+
+func (t *traceTx) Commit() error {
+	start := time.Now()
+	err := t.Tx.Commit()
+	t.conn.trace("COMMIT", nil, time.Since(start), err)
+	return err
+}
+
+func (t *traceTx) Rollback() error {
+	start := time.Now()
+	err := t.Tx.Rollback()
+	t.conn.trace("ROLLBACK", nil, time.Since(start), err)
+	return err
+}
+
+// traceStmt 预处理语句（驱动不支持直接执行带参数语句时 database/sql 会先 Prepare；COPY 也走这里）
+type traceStmt struct {
+	driver.Stmt
+	conn  *traceConn
+	query string
+}
+
+func (s *traceStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(namedValues(args)) // 驱动未实现 StmtExecContext 时的回退
+	}
+	s.conn.trace(s.query, args, time.Since(start), err)
+	return res, err
+}
+
+func (s *traceStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args)) // 驱动未实现 StmtQueryContext 时的回退
+	}
+	s.conn.trace(s.query, args, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	return &traceRows{Rows: rows, conn: s.conn, start: start}, nil
+}
+
+// namedValues 转为旧接口使用的参数列表
+func namedValues(args []driver.NamedValue) []driver.Value {
+	out := make([]driver.Value, len(args))
+	for i, a := range args {
+		out[i] = a.Value
+	}
+	return out
+}
+
+// traceRows 统计读取的行数，关闭时记录总耗时；列类型信息原样交给驱动
+type traceRows struct {
+	driver.Rows
+	conn  *traceConn
+	start time.Time
+	n     int64
+}
+
+func (r *traceRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.n++
+	}
+	return err
+}
+
+func (r *traceRows) Close() error {
+	err := r.Rows.Close()
+	if r.n > 1 {
+		infof("[SQL %s] 查询结束：读取 %d 行，共耗时 %s\n", r.conn.label, r.n, time.Since(r.start).Round(time.Microsecond))
+	}
+	return err
+}
+
+func (r *traceRows) ColumnTypeScanType(index int) reflect.Type {
+	if c, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return c.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *traceRows) ColumnTypeDatabaseTypeName(index int) string {
+	if c, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return c.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *traceRows) ColumnTypeLength(index int) (int64, bool) {
+	if c, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return c.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *traceRows) ColumnTypeNullable(index int) (bool, bool) {
+	if c, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return c.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *traceRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if c, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return c.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func (r *traceRows) HasNextResultSet() bool {
+	if c, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return c.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *traceRows) NextResultSet() error {
+	if c, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return c.NextResultSet()
+	}
+	return io.EOF
+}
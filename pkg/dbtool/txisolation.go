@@ -0,0 +1,115 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// 目标库写入事务的隔离级别（数据源级 tx_isolation）：复制写入的每个事务（包括每批提交后重新开启的事务、
+// COPY / LOAD DATA 的事务）都以该级别开启，如 SQL Server 用 READ COMMITTED 减少阻塞。
+// 各驱动支持的级别不同，配置了不支持的级别时启动即报错。
+// synchronous_commit（Postgres 目标库）：写入会话的 synchronous_commit，设为 off 时提交不等待 WAL 落盘，
+// 崩溃时可能丢失最近提交的事务（不会损坏数据），表结束后 RESET
+
+// txIsolationLevels tx_isolation 的取值
+var txIsolationLevels = map[string]sql.IsolationLevel{
+	"READ UNCOMMITTED": sql.LevelReadUncommitted,
+	"READ COMMITTED":   sql.LevelReadCommitted,
+	"REPEATABLE READ":  sql.LevelRepeatableRead,
+	"SNAPSHOT":         sql.LevelSnapshot,
+	"SERIALIZABLE":     sql.LevelSerializable,
+}
+
+// txIsolationSupport 各驱动支持的 tx_isolation
+var txIsolationSupport = map[string][]string{
+	"mysql":      {"READ UNCOMMITTED", "READ COMMITTED", "REPEATABLE READ", "SERIALIZABLE"},
+	"postgres":   {"READ UNCOMMITTED", "READ COMMITTED", "REPEATABLE READ", "SERIALIZABLE"},
+	"postgresql": {"READ UNCOMMITTED", "READ COMMITTED", "REPEATABLE READ", "SERIALIZABLE"},
+	"sqlserver":  {"READ UNCOMMITTED", "READ COMMITTED", "REPEATABLE READ", "SNAPSHOT", "SERIALIZABLE"},
+	"sqlite3":    {"SERIALIZABLE"},
+}
+
+// synchronousCommitValues synchronous_commit 的取值
+var synchronousCommitValues = []string{"off", "local", "remote_write", "on", "remote_apply"}
+
+// parseTxIsolation 解析数据源的 tx_isolation（不区分大小写，空格、下划线、连字符等价）并检查驱动是否支持，
+// 未配置时返回 LevelDefault
+func parseTxIsolation(cfg dbConfig) (sql.IsolationLevel, string, error) {
+	name := strings.ToUpper(strings.Join(strings.FieldsFunc(cfg.TxIsolation, func(r rune) bool { return r == ' ' || r == '_' || r == '-' }), " "))
+	if name == "" {
+		return sql.LevelDefault, "", nil
+	}
+	level, ok := txIsolationLevels[name]
+	if !ok {
+		return 0, "", fmt.Errorf("tx_isolation 无效: %q（可选 READ UNCOMMITTED、READ COMMITTED、REPEATABLE READ、SNAPSHOT、SERIALIZABLE）", cfg.TxIsolation)
+	}
+	driver := normalizeDriver(cfg.Driver)
+	supported, known := txIsolationSupport[driver]
+	if !known {
+		if driver == "oracle" {
+			return 0, "", fmt.Errorf("Oracle 驱动（go-ora）只能使用默认隔离级别（READ COMMITTED），不支持 tx_isolation")
+		}
+		return 0, "", fmt.Errorf("驱动 %s 不支持 tx_isolation", cfg.Driver)
+	}
+	for _, s := range supported {
+		if s == name {
+			return level, name, nil
+		}
+	}
+	return 0, "", fmt.Errorf("%s 目标库不支持 tx_isolation=%s（可选 %s）", cfg.Driver, name, strings.Join(supported, "、"))
+}
+
+// checkTargetTxOptions 启动时检查目标库的 tx_isolation 与 synchronous_commit
+func checkTargetTxOptions(cfg dbConfig) error {
+	if _, _, err := parseTxIsolation(cfg); err != nil {
+		return err
+	}
+	_, err := parseSynchronousCommit(cfg)
+	return err
+}
+
+// parseSynchronousCommit 检查 synchronous_commit 的取值（不区分大小写），未配置时返回空串
+func parseSynchronousCommit(cfg dbConfig) (string, error) {
+	v := strings.ToLower(strings.TrimSpace(cfg.SynchronousCommit))
+	if v == "" {
+		return "", nil
+	}
+	for _, s := range synchronousCommitValues {
+		if s == v {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("synchronous_commit 无效: %q（可选 %s）", cfg.SynchronousCommit, strings.Join(synchronousCommitValues, "、"))
+}
+
+// synchronousCommitSetting 按 synchronous_commit 生成写入会话的参数；未配置或非 Postgres 目标库（打印警告）时返回 nil
+func synchronousCommitSetting(cfg dbConfig, logger *tableLogger) (*sessionSetting, error) {
+	v, err := parseSynchronousCommit(cfg)
+	if err != nil || v == "" {
+		return nil, err
+	}
+	if !isPostgresDriver(normalizeDriver(cfg.Driver)) {
+		logger.warnf("警告：synchronous_commit 仅对 Postgres 目标库生效，已忽略\n")
+		return nil, nil
+	}
+	st := &sessionSetting{set: "SET synchronous_commit = " + v, restore: "RESET synchronous_commit"}
+	if v == "off" {
+		st.note = "synchronous_commit=off：提交不等待 WAL 写入磁盘，数据库崩溃时可能丢失最近提交的批次（不会损坏数据）"
+	}
+	return st, nil
+}
+
+// isolatedExecutor 按 tx_isolation 开启写入事务：调用方未指定事务选项时使用目标库配置的隔离级别
+type isolatedExecutor struct {
+	dbExecutor
+	opts *sql.TxOptions
+}
+
+func (e isolatedExecutor) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if opts == nil {
+		opts = e.opts
+	}
+	return e.dbExecutor.BeginTx(ctx, opts)
+}
@@ -0,0 +1,205 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exitVerifyDiff -verify 发现差异时的退出码（便于定时任务判断一致性）
+const exitVerifyDiff = 2
+
+// verifyOptions -verify 的命令行选项
+type verifyOptions struct {
+	Diagnose bool          // 对不一致的表定位差异区间（-diagnose）
+	Parallel int           // 同时核对的表数（-verify-parallel），小于 1 时按 1
+	Reports  reportOptions // 汇总报告输出（-report-json / -report-csv / -report-html）
+}
+
+// runVerify 只核对不复制：连接源库与目标库，按解析后的表清单统计源表/目标表记录数并打印汇总报告。
+// 只执行 SELECT，不开启任何写事务；vopts.Diagnose 为 true（或表配置 diagnose_diff）时对不一致的表定位差异区间；
+// 先逐表检查配置，再按 vopts.Parallel 并发核对，汇总按表清单顺序输出；存在差异时以 exitVerifyDiff 退出
+func runVerify(configPath string, vopts verifyOptions) {
+	cfg, tables, src, dst := openVerifyTargets(configPath)
+	defer src.Close()
+	defer dst.Close()
+
+	summary := &verificationSummary{verifyOnly: true}
+	startTime := time.Now()
+	var pending []copyTableOptions
+	for i, t := range tables {
+		if strings.TrimSpace(t.SourceTable) == "" {
+			warnf("警告：第 %d 个表配置 source_table 为空，跳过\n", i)
+			continue
+		}
+		opts := tableOptions(cfg, t, true, 0)
+		if err := validateIncremental(opts); err != nil {
+			fatalf("表 %s 配置错误: %v", opts.Table, err)
+		}
+		if isAutoSince(opts.Since) {
+			opts.Log.warnf("警告：表 %s 的 since=auto 在复制后无法还原，按不带 since 的窗口处理\n", opts.Table)
+			opts.Since = ""
+		}
+		if err := resolveSoftDelete(context.Background(), src, &opts); err != nil {
+			fatalf("表 %s 软删除配置无效: %v", opts.Table, err)
+		}
+		if err := resolveIncrementalTypes(context.Background(), src, &opts); err != nil {
+			fatalf("表 %s 增量条件无效: %v", opts.Table, err)
+		}
+		pending = append(pending, opts)
+	}
+
+	results := make([]tableVerificationResult, len(pending))
+	parallel := vopts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	if parallel > 1 {
+		infof("同时核对 %d 张表\n", parallel)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel && w < len(pending); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = verifyOne(src, dst, pending[i], vopts.Diagnose)
+			}
+		}()
+	}
+	for i := range pending {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, result := range results {
+		summary.add(result)
+	}
+	summary.print(startTime)
+	if err := summary.writeReports(vopts.Reports); err != nil {
+		warnf("警告：%v\n", err)
+	}
+
+	if summary.diffTables > 0 || summary.columnDiffTables > 0 {
+		_ = src.Close()
+		_ = dst.Close()
+		os.Exit(exitVerifyDiff)
+	}
+}
+
+// verifyOne 核对一张表，失败时记为未比较；按需定位差异区间
+func verifyOne(src, dst *simpleDB, opts copyTableOptions, diagnose bool) tableVerificationResult {
+	opts.Log.infof("开始核对表: source=%s, target=%s\n", opts.Table, firstNonEmpty(opts.TargetTable, opts.Table))
+	result, err := verifyTable(context.Background(), src, dst, opts)
+	if err != nil {
+		opts.Log.warnf("警告：表 %s 核对失败: %v\n", opts.Table, err)
+		result = newVerificationResult(opts.Table, -1, -1, 0)
+		result.Mode = verificationMode(opts, dst.cfg.Driver)
+	}
+	if result.HasDiff && (diagnose || opts.DiagnoseDiff) {
+		if err := diagnoseDiff(context.Background(), src, dst, opts); err != nil {
+			opts.Log.warnf("警告：表 %s 差异定位失败: %v\n", opts.Table, err)
+		}
+	}
+	return result
+}
+
+// openVerifyTargets 加载配置、解析表清单并连接源库与目标库（-verify / -diff-keys 共用，失败时直接退出）
+func openVerifyTargets(configPath string) (*toolConfig, []configTable, *simpleDB, *simpleDB) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fatalf("加载配置文件失败: %v", err)
+	}
+
+	sourceCfg, targetCfg, tables, err := resolveConfig(cfg, nil)
+	if err != nil {
+		fatalf("解析配置失败: %v", err)
+	}
+	if isFileDriver(targetCfg.Driver) {
+		fatalf("%s 目标不支持 -verify / -diff-keys（文件没有可查询的目标表，导出的行数见同步的汇总报告）", targetCfg.Driver)
+	}
+	if tables, err = resolveTableList(context.Background(), cfg, sourceCfg, tables); err != nil {
+		fatalf("%v", err)
+	}
+	if len(tables) == 0 {
+		fatalf("表清单为空，请检查 table_list 或 tables 配置")
+	}
+
+	infof("连接源数据库: %s\n", sourceCfg.Driver)
+	src, err := newSimpleDB(sourceCfg)
+	if err != nil {
+		fatalf("源数据库连接失败: %v", err)
+	}
+
+	infof("连接目标数据库: %s\n", targetCfg.Driver)
+	dst, err := newSimpleDB(targetCfg)
+	if err != nil {
+		_ = src.Close()
+		fatalf("目标数据库连接失败: %v", err)
+	}
+	return cfg, tables, src, dst
+}
+
+// splitWindowOpts 返回分别用于源库与目标库统计的选项：配置了 chunk_by 时把整个 [start, end) 窗口并入 where
+func splitWindowOpts(opts copyTableOptions, srcDriver, dstDriver string) (srcOpts, dstOpts copyTableOptions, err error) {
+	srcOpts, dstOpts = opts, opts
+	if c := opts.ChunkBy; c != nil {
+		start, end, _, err := parseChunkWindow(c)
+		if err != nil {
+			return opts, opts, err
+		}
+		srcOpts.Where = andWhere(opts.Where, chunkPredicate(c.Column, start, end, srcDriver))
+		dstOpts.Where = andWhere(opts.Where, chunkPredicate(c.Column, start, end, dstDriver))
+		srcOpts.ChunkBy, dstOpts.ChunkBy = nil, nil
+	}
+	return srcOpts, dstOpts, nil
+}
+
+// verifyTable 按与复制相同的窗口（where、增量条件、抽样、chunk_by 窗口、select_sql）统计单张表的源表与目标表记录数
+func verifyTable(ctx context.Context, src, dst *simpleDB, opts copyTableOptions) (tableVerificationResult, error) {
+	targetTable := firstNonEmpty(opts.TargetTable, opts.Table)
+	srcOpts, dstOpts, err := splitWindowOpts(opts, src.cfg.Driver, dst.cfg.Driver)
+	if err != nil {
+		return tableVerificationResult{}, err
+	}
+	mode := verificationMode(opts, dst.cfg.Driver)
+	if opts.ChunkBy != nil {
+		mode = verifyWindow
+	}
+
+	from, err := sourceFrom(srcOpts, src.cfg.Driver)
+	if err != nil {
+		return tableVerificationResult{}, err
+	}
+	var selectSQL string
+	var selectArgs []interface{}
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		if selectSQL, selectArgs, err = bindSelectSQL(opts.SelectSQL, opts, src.cfg.Driver); err != nil {
+			return tableVerificationResult{}, fmt.Errorf("绑定 select_sql 参数失败: %w", err)
+		}
+	}
+	sample, err := buildSampleClause(opts, src.cfg.Driver)
+	if err != nil {
+		return tableVerificationResult{}, err
+	}
+
+	window, windowArgs := sourceWindow(srcOpts, src.cfg.Driver, sample)
+	if selectSQL != "" {
+		windowArgs = selectArgs
+	}
+	sourceCount := countSourceRows(ctx, src, srcOpts, from, selectSQL, windowArgs, window, sample)
+	targetCount := countTargetRows(ctx, dst.db, targetTable, dstOpts, dst.cfg.Driver)
+	opts.Log.infof("表 %s 核对: 源表 %d 条，目标表 %d 条（核对方式: %s）\n", opts.Table, sourceCount, targetCount, mode)
+
+	result := newVerificationResult(opts.Table, sourceCount, targetCount, 0)
+	result.Mode = mode
+	if len(opts.VerifyColumns) > 0 {
+		result.ColumnMismatches = verifyColumnStats(ctx, src, dst, opts)
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package dbtool
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// 构建信息，发布构建时通过 -ldflags 设置，例如：
+//
+//	go build -ldflags "-X dbtool/pkg/dbtool.version=v1.2.3 -X dbtool/pkg/dbtool.commit=$(git rev-parse --short HEAD) -X dbtool/pkg/dbtool.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// 未设置时 commit、buildDate 取 Go 工具链记录的 VCS 信息（go build 于 git 工作区中时），仍无法得到时为 unknown
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildInfo 返回提交与构建时间，未通过 -ldflags 设置时取 VCS 信息
+func buildInfo() (rev, date string) {
+	rev, date = commit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok && (rev == "" || date == "") {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && rev == "":
+				rev = s.Value
+				if len(rev) > 12 {
+					rev = rev[:12]
+				}
+			case s.Key == "vcs.time" && date == "":
+				date = s.Value
+			}
+		}
+	}
+	return firstNonEmpty(rev, "unknown"), firstNonEmpty(date, "unknown")
+}
+
+// Version 返回版本号（未通过 -ldflags 设置时为 dev）
+func Version() string {
+	return version
+}
+
+// appName 连接的应用名（Postgres application_name、SQL Server app name 等），便于 DBA 识别 dbtool 的会话
+func appName() string {
+	return "dbtool/" + version
+}
+
+// printVersion -version：打印版本、提交、构建时间、Go 版本与编译进来的数据库驱动
+func printVersion() {
+	rev, date := buildInfo()
+	fmt.Printf("dbtool %s\n", version)
+	fmt.Printf("  commit:     %s\n", rev)
+	fmt.Printf("  build date: %s\n", date)
+	fmt.Printf("  go:         %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Printf("  drivers:    %s\n", strings.Join(sql.Drivers(), ", "))
+}
+
+// withAppName 在 DSN 中加入应用名（DSN 已指定时不覆盖）：Postgres application_name、SQL Server app name、
+// MySQL 连接属性 program_name；其它驱动原样返回
+func withAppName(driver, dsn string) string {
+	name := appName()
+	switch {
+	case isPostgresDriver(driver):
+		return dsnWithParam(driver, dsn, "application_name", name)
+	case driver == "sqlserver":
+		return dsnWithParam(driver, dsn, "app name", name)
+	case driver == "mysql":
+		if strings.Contains(dsn, "connectionAttributes=") {
+			return dsn
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		// 属性值中的 , 与 : 是分隔符
+		value := strings.NewReplacer(",", "_", ":", "_").Replace(name)
+		return dsn + sep + "connectionAttributes=" + url.QueryEscape("program_name:"+value)
+	}
+	return dsn
+}
@@ -0,0 +1,96 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// listViewsFromSource 从源库查询视图名列表（table_list.include_views 时与表清单合并）
+func listViewsFromSource(ctx context.Context, src *simpleDB, schema string) ([]string, error) {
+	driver := normalizeDriver(src.cfg.Driver)
+	switch driver {
+	case "mysql":
+		if schema == "" {
+			return queryNames(ctx, src.db, `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'VIEW' ORDER BY table_name`)
+		}
+		return queryNames(ctx, src.db, `SELECT table_name FROM information_schema.tables WHERE table_schema = ? AND table_type = 'VIEW' ORDER BY table_name`, schema)
+	case "postgres", "postgresql":
+		if schema == "" {
+			schema = "public"
+		}
+		return queryNames(ctx, src.db, `SELECT table_name FROM information_schema.tables WHERE table_schema = $1 AND table_type = 'VIEW' ORDER BY table_name`, schema)
+	case "sqlite3":
+		return queryNames(ctx, src.db, `SELECT name FROM sqlite_master WHERE type='view' ORDER BY name`)
+	case "sqlserver":
+		if schema == "" {
+			schema = "dbo"
+		}
+		return queryNames(ctx, src.db, `
+SELECT v.name
+FROM sys.views AS v
+INNER JOIN sys.schemas AS s ON v.schema_id = s.schema_id
+WHERE s.name = @p1
+ORDER BY v.name`, schema)
+	case "oracle":
+		if schema == "" {
+			return queryNames(ctx, src.db, `SELECT view_name FROM user_views ORDER BY view_name`)
+		}
+		return queryNames(ctx, src.db, `SELECT view_name FROM all_views WHERE owner = :1 ORDER BY view_name`, strings.ToUpper(schema))
+	default:
+		return nil, fmt.Errorf("暂不支持从驱动 %s 拉取视图清单", driver)
+	}
+}
+
+// queryNames 执行返回单列名称的查询
+func queryNames(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		out = append(out, name)
+	}
+	return out, rows.Err()
+}
+
+// checkViewIncrementalKey 视图配置了增量选项时，确认视图结果中存在增量关键列
+// 否则源库只会报出含糊的“列不存在”错误
+func checkViewIncrementalKey(ctx context.Context, src *simpleDB, from string, opts copyTableOptions) error {
+	keys := incrementalKeys(opts)
+	bounded := strings.TrimSpace(opts.Since) != "" || strings.TrimSpace(opts.Until) != "" ||
+		len(opts.SinceTuple) > 0 || len(opts.UntilTuple) > 0
+	if len(keys) == 0 || !bounded {
+		return nil
+	}
+	rows, err := src.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", from))
+	if err != nil {
+		return fmt.Errorf("读取视图 %s 的列失败: %w", opts.Table, err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("读取视图 %s 的列失败: %w", opts.Table, err)
+	}
+	for _, key := range keys {
+		found := false
+		for _, c := range cols {
+			if strings.EqualFold(c, key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("视图 %s 的结果列中没有增量关键列 %s，无法使用 incremental_key/since/until（视图列: %s）",
+				opts.Table, key, strings.Join(cols, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package dbtool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sinceAuto since 配置为该值时，复制前取目标表增量列的当前最大值作为起点
+const sinceAuto = "auto"
+
+// isAutoSince 判断 since 是否配置为 auto
+func isAutoSince(since string) bool {
+	return strings.EqualFold(strings.TrimSpace(since), sinceAuto)
+}
+
+// resolveAutoSince 查询目标表 MAX(增量列)（按字段映射取目标列名）作为本次的 since：
+// 目标表不存在或为空（MAX 为 NULL）时清空 since，按全量复制。
+// 查询到的值保留原始类型放在 SinceValue 中，源库条件按类型生成字面量、目标库按类型绑定
+func resolveAutoSince(ctx context.Context, dst *simpleDB, opts *copyTableOptions) error {
+	key := strings.TrimSpace(opts.IncrementalKey)
+	if key == "" {
+		return fmt.Errorf("since=auto 需要配置 incremental_key")
+	}
+	targetTable := firstNonEmpty(opts.TargetTable, opts.Table)
+	opts.Since, opts.SinceValue, opts.SinceAuto = "", nil, true
+
+	exists, err := checkTableExists(ctx, dst, targetTable)
+	if err != nil {
+		return fmt.Errorf("检查目标表 %s 是否存在失败: %w", targetTable, err)
+	}
+	if !exists {
+		opts.Log.infof("since=auto：目标表 %s 不存在，按全量复制\n", targetTable)
+		return nil
+	}
+
+	col := firstNonEmpty(columnRenames(*opts)[strings.ToLower(key)], key)
+	var v interface{}
+	query := fmt.Sprintf("SELECT MAX(%s) FROM %s", quoteIdent(col, dst.cfg.Driver), quoteIdent(targetTable, dst.cfg.Driver))
+	if err := dst.db.QueryRowContext(ctx, query).Scan(&v); err != nil {
+		return fmt.Errorf("查询目标表 %s 的 MAX(%s) 失败: %w", targetTable, col, err)
+	}
+	if v == nil {
+		opts.Log.infof("since=auto：目标表 %s 为空，按全量复制\n", targetTable)
+		return nil
+	}
+	if b, ok := v.([]byte); ok {
+		// []byte 在部分驱动上会按二进制绑定，统一转为字符串
+		v = string(b)
+	}
+	opts.SinceValue = v
+	opts.Since = diffValueString(v)
+	opts.Log.infof("since=auto：目标表 %s 的 MAX(%s) = %s，作为本次增量起点\n", targetTable, col, opts.Since)
+	return nil
+}
+
+// sourceBound 返回源库条件中绑定的增量边界值：整数、时间按类型绑定，十进制数按原文绑定（由数据库按列类型转换）。
+// 时间值已换算到源库时区：Postgres 驱动带偏移量发送（timestamp 列按墙上时间比较，timestamptz 列按确定的时刻比较），
+// Oracle 驱动按 DATE/TIMESTAMP 绑定；MySQL 驱动会按 DSN 的 loc 换算、SQL Server 驱动按 datetimeoffset 发送，
+// SQLite 没有时间类型，这些驱动改为绑定源库时区的墙上时间文本
+func sourceBound(v interface{}, driver string) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		return x.String()
+	case time.Time:
+		switch normalizeDriver(driver) {
+		case "postgres", "postgresql", "oracle":
+			return x
+		}
+		return x.Format("2006-01-02 15:04:05.999999999")
+	}
+	return v
+}
+
+// sinceArg 返回绑定 since 时使用的参数值（有类型化的 SinceValue 时优先），源库上再经 sourceBound 转换
+func sinceArg(opts copyTableOptions) interface{} {
+	if opts.SinceValue != nil {
+		return opts.SinceValue
+	}
+	return strings.TrimSpace(opts.Since)
+}
+
+// untilArg 返回绑定 until 时使用的参数值
+func untilArg(opts copyTableOptions) interface{} {
+	if opts.UntilValue != nil {
+		return opts.UntilValue
+	}
+	return strings.TrimSpace(opts.Until)
+}
+
+// incrementalStart 返回汇报用的增量起点描述；未配置增量列时为空
+func incrementalStart(opts copyTableOptions) string {
+	if len(opts.IncrementalKeys) > 0 {
+		if len(opts.SinceTuple) == 0 {
+			return "（全量）"
+		}
+		return tupleString(opts.SinceTuple)
+	}
+	if strings.TrimSpace(opts.IncrementalKey) == "" {
+		return ""
+	}
+	since := strings.TrimSpace(opts.Since)
+	switch {
+	case opts.SinceAuto && since == "":
+		return "auto（目标表为空，全量）"
+	case opts.SinceAuto:
+		return "auto → " + since
+	case since == "":
+		return "（全量）"
+	default:
+		return since
+	}
+}
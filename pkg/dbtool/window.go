@@ -0,0 +1,283 @@
+package dbtool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 数据核对方式
+const (
+	verifyWhole  = "全表" // 无过滤条件的全量复制：比较源表与目标表的全部记录数
+	verifyWindow = "窗口" // 带 where/增量条件：目标表按等价条件统计，只比较复制窗口内的记录数
+	verifyFile   = "文件" // 文件目标：比较源表（复制窗口内）记录数与写入文件的行数
+)
+
+// sourceWindow 返回源表上的复制窗口条件：用户自定义 where + 软删除条件 + 增量条件（含复合增量列的元组条件）+ 抽样条件。
+// 增量边界按类型绑定为参数（args 按占位符顺序，从第 1 个开始编号），其余条件为 SQL 片段
+func sourceWindow(opts copyTableOptions, driver string, sample *sampleClause) (clauses []string, args []interface{}) {
+	bind := func(v interface{}) string {
+		args = append(args, sourceBound(v, driver))
+		return placeholder(len(args), driver)
+	}
+	if strings.TrimSpace(opts.Where) != "" {
+		clauses = append(clauses, "("+opts.Where+")")
+	}
+	if col := strings.TrimSpace(opts.SoftDeleteColumn); col != "" {
+		clauses = append(clauses, softDeletePredicate(col, opts.IncludeOnlyDeleted, driver))
+	}
+	if len(opts.IncrementalKeys) > 0 {
+		cols := make([]string, len(opts.IncrementalKeys))
+		for i, k := range opts.IncrementalKeys {
+			cols[i] = quoteIdent(k, driver)
+		}
+		if len(opts.SinceTuple) > 0 {
+			clauses = append(clauses, tuplePredicate(cols, ">", supportsRowValues(driver),
+				func(i int) string { return bind(opts.SinceTuple[i]) }))
+		}
+		if len(opts.UntilTuple) > 0 {
+			clauses = append(clauses, tuplePredicate(cols, "<=", supportsRowValues(driver),
+				func(i int) string { return bind(opts.UntilTuple[i]) }))
+		}
+	}
+	if strings.TrimSpace(opts.IncrementalKey) != "" && strings.TrimSpace(opts.Since) != "" {
+		clauses = append(clauses,
+			fmt.Sprintf("%s > %s", quoteIdent(opts.IncrementalKey, driver), bind(sinceArg(opts))))
+	}
+	if strings.TrimSpace(opts.IncrementalKey) != "" && strings.TrimSpace(opts.Until) != "" {
+		clauses = append(clauses,
+			fmt.Sprintf("%s <= %s", quoteIdent(opts.IncrementalKey, driver), bind(untilArg(opts))))
+	}
+	if sample != nil && sample.predicate != "" {
+		clauses = append(clauses, sample.predicate)
+	}
+	return clauses, args
+}
+
+// countSourceRows 统计源表记录数（用于数据核对），无法统计时返回 -1：
+// skip_source_count > count_sql > 随机抽样（以实际读取行数为准）> 去重后的记录数 > select_sql 子查询 > 表 + 复制窗口。
+// args 为 select_sql 的参数，未使用 select_sql 时为复制窗口条件的参数
+func countSourceRows(ctx context.Context, src *simpleDB, opts copyTableOptions, from, selectSQL string, args []interface{}, window []string, sample *sampleClause) int64 {
+	var sourceCount int64
+	if opts.SkipSourceCount {
+		opts.Log.infof("跳过源表记录数统计（skip_source_count），数据核对不比较\n")
+		return -1
+	}
+	if strings.TrimSpace(opts.CountSQL) != "" {
+		if err := sourceDB(src, opts).QueryRowContext(ctx, opts.CountSQL).Scan(&sourceCount); err != nil {
+			opts.Log.warnf("警告：执行 count_sql 失败: %v\n", err)
+			return -1
+		}
+		opts.Log.infof("源表记录数（count_sql）: %d\n", sourceCount)
+		return sourceCount
+	}
+	if sample != nil && sample.random {
+		opts.Log.infof("随机抽样，源表记录数以实际读取的行数为准\n")
+		return -1
+	}
+
+	if len(opts.DedupKeys) > 0 {
+		if strings.TrimSpace(opts.SelectSQL) != "" {
+			return countDedupRows(ctx, src, opts, "("+selectSQL+") tmp", args, nil)
+		}
+		return countDedupRows(ctx, src, opts, from, args, window)
+	}
+
+	var countQuery string
+	if strings.TrimSpace(opts.SelectSQL) != "" {
+		// 使用自定义 SELECT 查询时，通过子查询获取记录数（派生表别名不带 AS，Oracle 不支持）
+		countQuery = "SELECT COUNT(*) FROM (" + selectSQL + ") tmp"
+	} else {
+		countQuery = fmt.Sprintf("SELECT COUNT(*) FROM %s", from)
+		if len(window) > 0 {
+			countQuery += " WHERE " + strings.Join(window, " AND ")
+		}
+	}
+	if err := sourceDB(src, opts).QueryRowContext(ctx, countQuery, args...).Scan(&sourceCount); err != nil {
+		opts.Log.warnf("警告：无法获取源表记录数: %v\n", err)
+		return -1
+	}
+	opts.Log.infof("源表记录数: %d\n", sourceCount)
+	return sourceCount
+}
+
+// targetWindow 把复制窗口（where + 软删除条件 + 增量 since/until）转换为目标表上的等价条件：
+// 源列名按字段映射替换为目标列名，并使用目标库的标识符引用与绑定参数。
+// 使用 select_sql 或抽样时无法在目标表上还原窗口，返回 ok=false（按全表核对）
+func targetWindow(opts copyTableOptions, dstDriver string) (cond string, args []interface{}, ok bool) {
+	if strings.TrimSpace(opts.SelectSQL) != "" || opts.SamplePercent > 0 {
+		// 无法按同样条件统计目标表，只保留 verify_by_source 的来源条件
+		if scope := sourceScopePredicate(opts, dstDriver, &args); scope != "" {
+			return scope, args, true
+		}
+		return "", nil, false
+	}
+
+	rename := columnRenames(opts)
+
+	var conds []string
+	if strings.TrimSpace(opts.Where) != "" {
+		conds = append(conds, "("+translateWhere(opts.Where, rename, dstDriver)+")")
+	}
+	if col := strings.TrimSpace(opts.SoftDeleteColumn); col != "" {
+		// 配置了字段映射但未映射软删除列时，目标表没有该列，无法按同样条件统计
+		if target, ok := rename[strings.ToLower(col)]; ok || len(opts.Columns) == 0 {
+			conds = append(conds, softDeletePredicate(firstNonEmpty(target, col), opts.IncludeOnlyDeleted, dstDriver))
+		}
+	}
+	if len(opts.IncrementalKeys) > 0 {
+		cols := make([]string, len(opts.IncrementalKeys))
+		for i, k := range opts.IncrementalKeys {
+			cols[i] = quoteIdent(firstNonEmpty(rename[strings.ToLower(k)], k), dstDriver)
+		}
+		for _, b := range []struct {
+			op    string
+			tuple []interface{}
+		}{{">", opts.SinceTuple}, {"<=", opts.UntilTuple}} {
+			if len(b.tuple) == 0 {
+				continue
+			}
+			tuple := b.tuple
+			conds = append(conds, tuplePredicate(cols, b.op, supportsRowValues(dstDriver), func(i int) string {
+				args = append(args, targetBound(tuple[i], opts))
+				return placeholder(len(args), dstDriver)
+			}))
+		}
+	}
+	if key := strings.TrimSpace(opts.IncrementalKey); key != "" {
+		col := quoteIdent(firstNonEmpty(rename[strings.ToLower(key)], key), dstDriver)
+		if strings.TrimSpace(opts.Since) != "" {
+			args = append(args, targetBound(sinceArg(opts), opts))
+			conds = append(conds, fmt.Sprintf("%s > %s", col, placeholder(len(args), dstDriver)))
+		}
+		if strings.TrimSpace(opts.Until) != "" {
+			args = append(args, targetBound(untilArg(opts), opts))
+			conds = append(conds, fmt.Sprintf("%s <= %s", col, placeholder(len(args), dstDriver)))
+		}
+	}
+	if scope := sourceScopePredicate(opts, dstDriver, &args); scope != "" {
+		conds = append(conds, scope)
+	}
+	if len(conds) == 0 {
+		return "", nil, false
+	}
+	return strings.Join(conds, " AND "), args, true
+}
+
+// targetBound 返回目标库上绑定的边界值：时间值与写入时的时间规范化方式一致（timestamp_output=utc 时按 UTC 绑定）
+func targetBound(v interface{}, opts copyTableOptions) interface{} {
+	if t, ok := v.(time.Time); ok && strings.EqualFold(strings.TrimSpace(opts.TimestampOutput), "utc") {
+		return t.UTC()
+	}
+	return v
+}
+
+// columnRenames 返回字段映射：小写源列名 -> 目标列名（不含 skip 的列）
+func columnRenames(opts copyTableOptions) map[string]string {
+	rename := make(map[string]string)
+	for _, c := range opts.Columns {
+		if srcCol := strings.TrimSpace(c.Source); srcCol != "" && !c.Skip {
+			rename[strings.ToLower(srcCol)] = firstNonEmpty(strings.TrimSpace(c.Target), srcCol)
+		}
+	}
+	return rename
+}
+
+// verificationMode 返回表的数据核对方式
+func verificationMode(opts copyTableOptions, dstDriver string) string {
+	if isFileDriver(dstDriver) {
+		return verifyFile
+	}
+	if _, _, ok := targetWindow(opts, dstDriver); ok {
+		return verifyWindow
+	}
+	return verifyWhole
+}
+
+// countTargetRows 统计目标表记录数（有复制窗口时只统计窗口内的记录），失败时返回 -1
+func countTargetRows(ctx context.Context, w dbExecutor, table string, opts copyTableOptions, dstDriver string) int64 {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteIdent(table, dstDriver))
+	cond, args, windowed := targetWindow(opts, dstDriver)
+	if windowed {
+		query += " WHERE " + cond
+	}
+	var n int64
+	if err := w.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		opts.Log.warnf("警告：无法获取目标表记录数: %v\n", err)
+		return -1
+	}
+	return n
+}
+
+// quotedMapped 判断以双引号开头的标识符是否在字段映射中（MySQL 中双引号也可能是字符串，未映射的原样保留）
+func quotedMapped(rest string, rename map[string]string) bool {
+	j := strings.IndexByte(rest, '"')
+	if j < 0 {
+		return false
+	}
+	_, ok := rename[strings.ToLower(rest[:j])]
+	return ok
+}
+
+// translateWhere 把源库 where 条件改写为目标库可执行的条件：
+// 字段映射中的源列名替换为目标列名，反引号/方括号标识符按目标库方式重新引用，字符串字面量保持不变
+func translateWhere(where string, rename map[string]string, dstDriver string) string {
+	var b strings.Builder
+	n := len(where)
+	for i := 0; i < n; {
+		c := where[i]
+		switch {
+		case c == '\'':
+			j := i + 1
+			for j < n {
+				if where[j] == '\'' {
+					if j+1 < n && where[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j < n {
+				j++
+			}
+			b.WriteString(where[i:j])
+			i = j
+		case c == '`' || c == '[' || (c == '"' && quotedMapped(where[i+1:], rename)):
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			j := strings.IndexByte(where[i+1:], closing)
+			if j < 0 {
+				b.WriteString(where[i:])
+				i = n
+				continue
+			}
+			name := where[i+1 : i+1+j]
+			b.WriteString(quoteIdent(firstNonEmpty(rename[strings.ToLower(name)], name), dstDriver))
+			i += j + 2
+		case isIdentStart(c):
+			j := i + 1
+			for j < n && (isIdentPart(where[j]) || where[j] == '$') {
+				j++
+			}
+			word := where[i:j]
+			// 限定名（t.col）与函数名原样保留
+			qualified := i > 0 && where[i-1] == '.'
+			isCall := j < n && where[j] == '('
+			if target, ok := rename[strings.ToLower(word)]; ok && !qualified && !isCall {
+				b.WriteString(quoteIdent(target, dstDriver))
+			} else {
+				b.WriteString(word)
+			}
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
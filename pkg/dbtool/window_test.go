@@ -0,0 +1,148 @@
+package dbtool
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// windowCond 源库条件中的列名与比较符（MySQL 反引号、Postgres/Oracle 双引号且 Oracle 为大写），边界值为绑定参数
+var windowCond = regexp.MustCompile("^[`\"]?(\\w+)[`\"]? (>|<=) (\\?|\\$\\d+|:\\d+)$")
+
+// windowBound 把源库条件与绑定的边界值还原为时刻：time.Time 按其时刻，文本为源库时区的墙上时间
+func windowBound(t *testing.T, clause string, arg interface{}, srcLoc *time.Location) (string, time.Time) {
+	t.Helper()
+	m := windowCond.FindStringSubmatch(clause)
+	if m == nil {
+		t.Fatalf("无法解析条件 %s", clause)
+	}
+	switch v := arg.(type) {
+	case time.Time:
+		return m[1] + " " + m[2], v
+	case string:
+		if at, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", v, srcLoc); err == nil {
+			return m[1] + " " + m[2], at
+		}
+	}
+	t.Fatalf("无法解析绑定的边界值 %#v", arg)
+	return "", time.Time{}
+}
+
+func TestIncrementalWindowSameAcrossDialects(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("缺少时区数据")
+	}
+	cases := []struct {
+		name     string
+		sourceTZ string
+		opts     copyTableOptions
+		since    time.Time
+		until    time.Time
+	}{
+		{
+			name:     "since_timezone 与源库时区不同",
+			sourceTZ: "UTC",
+			opts: copyTableOptions{IncrementalKey: "updated_at", Since: "2024-03-01 08:00:00", Until: "2024-03-02 08:00:00",
+				SinceTimezone: "Asia/Shanghai"},
+			since: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			until: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "since_format",
+			sourceTZ: "Asia/Shanghai",
+			opts: copyTableOptions{IncrementalKey: "updated_at", Since: "01/03/2024 00:00", Until: "01/03/2024 12:30",
+				SinceFormat: "02/01/2006 15:04"},
+			since: time.Date(2024, 3, 1, 0, 0, 0, 0, shanghai),
+			until: time.Date(2024, 3, 1, 12, 30, 0, 0, shanghai),
+		},
+		{
+			name:  "未配置时区按 UTC",
+			opts:  copyTableOptions{IncrementalKey: "updated_at", Since: "2024-03-01T00:00:00.123456Z", Until: "2024-03-01 10:00:00"},
+			since: time.Date(2024, 3, 1, 0, 0, 0, 123456000, time.UTC),
+			until: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, c := range cases {
+		opts := c.opts
+		if err := applyKeyTypes(&opts, []string{keyTypeTimestamp}, c.sourceTZ); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		srcLoc := time.UTC
+		if c.sourceTZ != "" {
+			srcLoc, _ = time.LoadLocation(c.sourceTZ)
+		}
+		for _, driver := range []string{"mysql", "postgres", "oracle"} {
+			clauses, args := sourceWindow(opts, driver, nil)
+			if len(clauses) != 2 || len(args) != 2 {
+				t.Fatalf("%s/%s: 条件 %v，参数 %v", c.name, driver, clauses, args)
+			}
+			for i, want := range []struct {
+				cond string
+				at   time.Time
+			}{{"updated_at >", c.since}, {"updated_at <=", c.until}} {
+				cond, at := windowBound(t, clauses[i], args[i], srcLoc)
+				if !strings.EqualFold(cond, want.cond) || !at.Equal(want.at) {
+					t.Errorf("%s/%s: 条件 %s 对应 %s %s，期望 %s %s", c.name, driver, clauses[i], cond, at, want.cond, want.at)
+				}
+			}
+		}
+	}
+}
+
+func TestSelectSQLBoundsMatchWindow(t *testing.T) {
+	opts := copyTableOptions{IncrementalKey: "updated_at", Since: "2024-03-01 08:00:00", Until: "2024-03-02 08:00:00",
+		SinceTimezone: "UTC", SelectSQL: "SELECT * FROM orders WHERE updated_at > :since AND updated_at <= :until"}
+	if err := applyKeyTypes(&opts, []string{keyTypeTimestamp}, "Asia/Shanghai"); err != nil {
+		t.Skipf("缺少时区数据: %v", err)
+	}
+	for _, driver := range []string{"mysql", "postgres", "oracle", "sqlserver", "sqlite3"} {
+		_, windowArgs := sourceWindow(opts, driver, nil)
+		_, selectArgs, err := bindSelectSQL(opts.SelectSQL, opts, driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(selectArgs) != len(windowArgs) {
+			t.Fatalf("%s: select_sql 参数 %v，窗口参数 %v", driver, selectArgs, windowArgs)
+		}
+		for i := range windowArgs {
+			if selectArgs[i] != windowArgs[i] {
+				t.Errorf("%s: select_sql 绑定 %#v，表的窗口绑定 %#v", driver, selectArgs[i], windowArgs[i])
+			}
+		}
+	}
+}
+
+func TestIncrementalWindowOnSource(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.db"), filepath.Join(dir, "dst.db")
+	// 源表保存 Asia/Shanghai 的墙上时间
+	openTestSQLite(t, srcPath, "CREATE TABLE ev (id INTEGER PRIMARY KEY, updated_at TIMESTAMP)",
+		"INSERT INTO ev VALUES (1, '2024-03-01 07:59:59'), (2, '2024-03-01 08:00:01'), (3, '2024-03-02 08:00:00'), (4, '2024-03-02 08:00:01')")
+	for _, selectSQL := range []string{"", "SELECT id, updated_at FROM ev WHERE updated_at > :since AND updated_at <= :until"} {
+		dst := openTestSQLite(t, dstPath, "DROP TABLE IF EXISTS ev", "CREATE TABLE ev (id INTEGER PRIMARY KEY, updated_at TIMESTAMP)")
+		cfg := writeTestConfig(t, srcPath, dstPath, `[{"source_table": "ev", "incremental_key": "updated_at",
+			"since": "2024-03-01T00:00:00Z", "until": "2024-03-02 00:00:00", "since_timezone": "UTC",
+			"source_timezone": "Asia/Shanghai", "select_sql": "`+selectSQL+`"}]`)
+		s, err := Open(ctx, cfg, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Run(ctx); err != nil {
+			s.Close()
+			t.Fatalf("select_sql=%q: %v", selectSQL, err)
+		}
+		s.Close()
+		var ids string
+		if err := dst.QueryRow("SELECT group_concat(id) FROM (SELECT id FROM ev ORDER BY id)").Scan(&ids); err != nil {
+			t.Fatal(err)
+		}
+		if ids != "2,3" {
+			t.Errorf("select_sql=%q: 复制了 %s，期望 2,3", selectSQL, ids)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package dbtool
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// write_mode_tx（表级）：写入是否使用显式事务。
+// - tx（默认）：每批一个事务，出错时回滚本批
+// - none：不调用 BeginTx，每条 INSERT 直接在连接上执行、自动提交（MyISAM 表、部分代理、ClickHouse 等对显式事务支持不好的目标库）。
+//   仍按 batch_size 划分批次，用于进度日志与重试：连接断开或超时后只重试失败的那一行（之前的行已经写入，不能重放）。
+//   出错时已写入的行不会回滚，数据核对是判断是否完整的主要依据。COPY / LOAD DATA 依赖事务，改用 INSERT
+
+const (
+	writeModeTx   = "tx"
+	writeModeNone = "none"
+)
+
+// batchTx 一批写入所在的事务：*sql.Tx，或 write_mode_tx=none 时自动提交的 autocommitTx
+type batchTx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Commit() error
+	Rollback() error
+}
+
+// autocommitTx 不开启事务，语句直接在写入会话上执行；Commit、Rollback 不做任何事（已执行的语句已提交）
+type autocommitTx struct {
+	w dbExecutor
+}
+
+func (t autocommitTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.w.ExecContext(ctx, query, args...)
+}
+
+func (autocommitTx) Commit() error { return nil }
+
+func (autocommitTx) Rollback() error { return nil }
+
+// validateWriteModeTx 检查 write_mode_tx 的取值及与 atomic 的组合，为空时返回 tx
+func validateWriteModeTx(opts copyTableOptions) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(opts.WriteModeTx)); v {
+	case "", writeModeTx:
+		return writeModeTx, nil
+	case writeModeNone:
+		if opts.Atomic {
+			return "", fmt.Errorf("write_mode_tx=none 不使用事务，不能与 atomic 同时配置")
+		}
+		return v, nil
+	}
+	return "", fmt.Errorf("write_mode_tx 无效: %q（可选 tx、none）", opts.WriteModeTx)
+}
+
+// autocommitWrites 表是否按 write_mode_tx=none 写入
+func autocommitWrites(opts copyTableOptions) bool {
+	return strings.EqualFold(strings.TrimSpace(opts.WriteModeTx), writeModeNone)
+}
+
+// logAutocommit 说明 write_mode_tx=none 的后果；跳过源表记录数统计时提醒将无法核对
+func logAutocommit(opts copyTableOptions) {
+	opts.Log.infof("write_mode_tx=none：不使用事务，每条 INSERT 自动提交；出错时已写入的行不会回滚，以数据核对结果为准\n")
+	if opts.SkipSourceCount {
+		opts.Log.warnf("警告：表 %s 配置了 skip_source_count，write_mode_tx=none 时将无法通过数据核对确认写入是否完整\n", opts.Table)
+	}
+}